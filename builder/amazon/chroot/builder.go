@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	awscommon "github.com/hashicorp/packer/builder/amazon/common"
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/chroot"
 	"github.com/hashicorp/packer/helper/config"
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
@@ -73,6 +74,19 @@ type Config struct {
 	// pre_mount_commands and root_volume_size. The below options are also
 	// required in this mode only:
 	FromScratch bool `mapstructure:"from_scratch" required:"false"`
+	// Path to a local raw or qcow2 disk image, for example the output of the
+	// QEMU builder, which is written onto a new volume instead of starting
+	// from an existing AMI root volume snapshot. This is much faster than
+	// importing the image with the `amazon-import` post-processor. As with
+	// from_scratch, source_ami is no longer used and root_device_name and
+	// ami_block_device_mappings become required. root_volume_size defaults
+	// to the virtual size of the image rounded up to the next GB and
+	// ami_virtualization_type defaults to hvm. Writing a qcow2 image requires
+	// qemu-img on the instance.
+	FromImage string `mapstructure:"from_image" required:"false"`
+	// The format of from_image, raw or qcow2. By default, it is detected
+	// from the content of the image.
+	FromImageFormat string `mapstructure:"from_image_format" required:"false"`
 	// Options to supply the mount command when mounting devices. Each option
 	// will be prefixed with -o and supplied to the mount command ran by
 	// Packer. Because this command is ran in a shell, user discretion is
@@ -168,6 +182,12 @@ type Config struct {
 	ctx interpolate.Context
 }
 
+// newVolume tells whether the root volume is a new volume rather than a
+// volume created from the snapshot of the source AMI.
+func (c *Config) newVolume() bool {
+	return c.FromScratch || c.FromImage != ""
+}
+
 type wrappedCommandTemplate struct {
 	Command string
 }
@@ -257,7 +277,16 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
-	if b.config.FromScratch {
+	if b.config.FromImage != "" {
+		if b.config.FromScratch {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("from_image cannot be used with from_scratch."))
+		}
+		if b.config.SourceAmi != "" || !b.config.SourceAmiFilter.Empty() {
+			warns = append(warns, "source_ami and source_ami_filter are unused when from_image is set")
+		}
+		errs = packer.MultiErrorAppend(errs, b.config.prepareFromImage()...)
+	} else if b.config.FromScratch {
 		if b.config.SourceAmi != "" || !b.config.SourceAmiFilter.Empty() {
 			warns = append(warns, "source_ami and source_ami_filter are unused when from_scratch is true")
 		}
@@ -347,7 +376,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	state.Put("awsSession", session)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
-	state.Put("wrappedCommand", CommandWrapper(wrappedCommand))
+	state.Put("wrappedCommand", chroot.CommandWrapper(wrappedCommand))
 
 	// Build the steps
 	steps := []multistep.Step{
@@ -358,7 +387,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		&StepInstanceInfo{},
 	}
 
-	if !b.config.FromScratch {
+	if !b.config.newVolume() {
 		steps = append(steps,
 			&awscommon.StepSourceAMIInfo{
 				SourceAmi:                b.config.SourceAmi,
@@ -382,20 +411,27 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		},
 		&StepAttachVolume{},
 		&StepEarlyUnflock{},
-		&StepPreMountCommands{
+		&StepWriteImage{},
+		&chroot.StepPreMountCommands{
 			Commands: b.config.PreMountCommands,
+			Ctx:      b.config.ctx,
 		},
 		&StepMountDevice{
 			MountOptions:   b.config.MountOptions,
 			MountPartition: b.config.MountPartition,
 		},
-		&StepPostMountCommands{
+		&chroot.StepPostMountCommands{
 			Commands: b.config.PostMountCommands,
+			Ctx:      b.config.ctx,
+		},
+		&chroot.StepMountExtra{
+			ChrootMounts: b.config.ChrootMounts,
 		},
-		&StepMountExtra{},
-		&StepCopyFiles{},
-		&StepChrootProvision{},
-		&StepEarlyCleanup{},
+		&chroot.StepCopyFiles{
+			Files: b.config.CopyFiles,
+		},
+		&chroot.StepChrootProvision{},
+		&chroot.StepEarlyCleanup{},
 		&StepSnapshot{},
 		&awscommon.StepDeregisterAMI{
 			AccessConfig:        &b.config.AccessConfig,
@@ -418,6 +454,10 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Name:              b.config.AMIName,
 			OriginalRegion:    *ec2conn.Config.Region,
 		},
+		&awscommon.StepShareAMI{
+			AccessConfig: &b.config.AccessConfig,
+			Share:        b.config.AMIShare,
+		},
 		&awscommon.StepModifyAMIAttributes{
 			Description:    b.config.AMIDescription,
 			Users:          b.config.AMIUsers,
@@ -1,6 +1,8 @@
 package chroot
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -209,3 +211,71 @@ func TestBuilderPrepare_RootDeviceNameNoAMIMappings(t *testing.T) {
 		t.Fatalf("should have error")
 	}
 }
+
+func testFromImageConfig(image string) map[string]interface{} {
+	config := testConfig()
+	delete(config, "source_ami")
+	config["from_image"] = image
+	config["root_device_name"] = "/dev/sda1"
+	config["ami_block_device_mappings"] = []interface{}{
+		map[string]string{"device_name": "/dev/sda1"},
+	}
+	return config
+}
+
+func TestBuilderPrepare_FromImage(t *testing.T) {
+	dir, raw, qcow2 := testImages(t)
+	defer os.RemoveAll(dir)
+
+	var b Builder
+	warnings, err := b.Prepare(testFromImageConfig(qcow2))
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.FromImageFormat != "qcow2" || b.config.RootVolumeSize != 5 || b.config.AMIVirtType != "hvm" {
+		t.Fatalf("bad: %s, %d, %s", b.config.FromImageFormat, b.config.RootVolumeSize, b.config.AMIVirtType)
+	}
+	if !b.config.newVolume() {
+		t.Fatal("should build a new volume")
+	}
+
+	// The size of a raw image is rounded up
+	b = Builder{}
+	if _, err := b.Prepare(testFromImageConfig(raw)); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.FromImageFormat != "raw" || b.config.RootVolumeSize != 1 {
+		t.Fatalf("bad: %s, %d", b.config.FromImageFormat, b.config.RootVolumeSize)
+	}
+}
+
+func TestBuilderPrepare_FromImageErrors(t *testing.T) {
+	dir, raw, qcow2 := testImages(t)
+	defer os.RemoveAll(dir)
+
+	cases := map[string]map[string]interface{}{
+		"missing image":  testFromImageConfig(filepath.Join(dir, "missing.raw")),
+		"small volume":   testFromImageConfig(qcow2),
+		"wrong format":   testFromImageConfig(raw),
+		"unknown format": testFromImageConfig(raw),
+		"from scratch":   testFromImageConfig(raw),
+		"no mappings":    testFromImageConfig(raw),
+		"no root device": testFromImageConfig(raw),
+	}
+	cases["small volume"]["root_volume_size"] = 4
+	cases["wrong format"]["from_image_format"] = "qcow2"
+	cases["unknown format"]["from_image_format"] = "vmdk"
+	cases["from scratch"]["from_scratch"] = true
+	delete(cases["no mappings"], "ami_block_device_mappings")
+	delete(cases["no root device"], "root_device_name")
+
+	for name, config := range cases {
+		var b Builder
+		if _, err := b.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
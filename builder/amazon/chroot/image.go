@@ -0,0 +1,54 @@
+package chroot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer/common/chroot"
+)
+
+const gigabyte = 1024 * 1024 * 1024
+
+// prepareFromImage validates from_image and sets the defaults which depend
+// on the image.
+func (c *Config) prepareFromImage() []error {
+	var errs []error
+
+	format, size, err := chroot.ImageInfo(c.FromImage)
+	if err != nil {
+		return append(errs, fmt.Errorf("Error reading from_image: %s", err))
+	}
+
+	switch c.FromImageFormat {
+	case "":
+		c.FromImageFormat = format
+	case "raw":
+	case "qcow2":
+		if format != "qcow2" {
+			errs = append(errs, fmt.Errorf("%s is not a qcow2 image", c.FromImage))
+		}
+	default:
+		errs = append(errs, errors.New("from_image_format must be raw or qcow2"))
+	}
+
+	// The volume must be large enough for the virtual disk
+	minSize := (size + gigabyte - 1) / gigabyte
+	if c.RootVolumeSize == 0 {
+		c.RootVolumeSize = minSize
+	} else if c.RootVolumeSize < minSize {
+		errs = append(errs, fmt.Errorf(
+			"root_volume_size must be at least %d to hold from_image", minSize))
+	}
+
+	if c.AMIVirtType == "" {
+		c.AMIVirtType = "hvm"
+	}
+	if c.RootDeviceName == "" {
+		errs = append(errs, errors.New("root_device_name is required with from_image."))
+	}
+	if len(c.AMIMappings) == 0 {
+		errs = append(errs, errors.New("ami_block_device_mappings is required with from_image."))
+	}
+
+	return errs
+}
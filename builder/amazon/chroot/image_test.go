@@ -0,0 +1,120 @@
+package chroot
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// testImages writes a raw image of 3 MB and a qcow2 header of a 5 GB image.
+func testImages(t *testing.T) (string, string, string) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw := filepath.Join(dir, "disk.raw")
+	if err := ioutil.WriteFile(raw, make([]byte, 3*1024*1024), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	header := make([]byte, 104)
+	copy(header, "QFI\xfb")
+	binary.BigEndian.PutUint32(header[4:8], 3)
+	binary.BigEndian.PutUint64(header[24:32], 5*gigabyte)
+	qcow2 := filepath.Join(dir, "disk.qcow2")
+	if err := ioutil.WriteFile(qcow2, header, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return dir, raw, qcow2
+}
+
+func TestWriteImageCommand(t *testing.T) {
+	command := writeImageCommand("qcow2", "/tmp/disk.qcow2", "/dev/xvdf")
+	if command != "qemu-img convert -n -f qcow2 -O raw '/tmp/disk.qcow2' '/dev/xvdf'" {
+		t.Fatalf("bad: %s", command)
+	}
+
+	command = writeImageCommand("raw", "/tmp/disk.raw", "/dev/xvdf")
+	if command != "dd if='/tmp/disk.raw' of='/dev/xvdf' bs=4M conv=sparse,fsync" {
+		t.Fatalf("bad: %s", command)
+	}
+}
+
+func TestWaitForDevice(t *testing.T) {
+	dir, raw, _ := testImages(t)
+	defer os.RemoveAll(dir)
+
+	if err := waitForDevice(context.Background(), raw, time.Second); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := waitForDevice(context.Background(), raw+"1", time.Second); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestNVMeDiskDevice(t *testing.T) {
+	cases := map[string]string{
+		"/dev/nvme1n1p": "/dev/nvme1n1",
+		"/dev/nvme1n1":  "/dev/nvme1n1",
+		"/dev/xvdf":     "/dev/xvdf",
+	}
+	for path, expected := range cases {
+		if device := nvmeDiskDevice(path); device != expected {
+			t.Fatalf("%s: bad: %s", path, device)
+		}
+	}
+}
+
+func TestStepWriteImage_nvme(t *testing.T) {
+	dir, raw, _ := testImages(t)
+	defer os.RemoveAll(dir)
+
+	// the device of the partition, created by udev once the partition
+	// table is read again
+	disk := filepath.Join(dir, "nvme1n1")
+	if err := ioutil.WriteFile(disk+"p1", nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var commands []string
+	state := new(multistep.BasicStateBag)
+	config := &Config{
+		FromImage:       raw,
+		FromImageFormat: "raw",
+		NVMEDevicePath:  disk + "p",
+		MountPartition:  "1",
+	}
+	config.AMIVirtType = "hvm"
+	state.Put("config", config)
+	state.Put("device", "/dev/sdf")
+	state.Put("ui", &packer.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)})
+	state.Put("wrappedCommand", chroot.CommandWrapper(func(command string) (string, error) {
+		commands = append(commands, command)
+		return "true", nil
+	}))
+
+	step := new(StepWriteImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+
+	expected := []string{
+		writeImageCommand("raw", raw, disk),
+		"blockdev --rereadpt '" + disk + "'",
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Fatalf("bad: %#v", commands)
+	}
+}
@@ -54,10 +54,10 @@ func (s *StepCreateVolume) Run(ctx context.Context, state multistep.StateBag) mu
 	}
 
 	var createVolume *ec2.CreateVolumeInput
-	if config.FromScratch {
+	if config.newVolume() {
 		rootVolumeType := ec2.VolumeTypeGp2
 		if s.RootVolumeType == "io1" {
-			err := errors.New("Cannot use io1 volume when building from scratch")
+			err := errors.New("Cannot use io1 volume when building from scratch or from an image")
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
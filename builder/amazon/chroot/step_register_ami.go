@@ -30,7 +30,7 @@ func (s *StepRegisterAMI) Run(ctx context.Context, state multistep.StateBag) mul
 	var registerOpts *ec2.RegisterImageInput
 
 	// Source Image is only required to be passed if the image is not from scratch
-	if config.FromScratch {
+	if config.newVolume() {
 		registerOpts = buildBaseRegisterOpts(config, nil, s.RootVolumeSize, snapshotID)
 	} else {
 		image := state.Get("source_image").(*ec2.Image)
@@ -81,7 +81,7 @@ func buildBaseRegisterOpts(config *Config, sourceImage *ec2.Image, rootVolumeSiz
 		rootDeviceName string
 	)
 
-	generatingNewBlockDeviceMappings := config.FromScratch || len(config.AMIMappings) > 0
+	generatingNewBlockDeviceMappings := config.newVolume() || len(config.AMIMappings) > 0
 	if generatingNewBlockDeviceMappings {
 		mappings = config.AMIMappings.BuildEC2BlockDeviceMappings()
 		rootDeviceName = config.RootDeviceName
@@ -115,7 +115,7 @@ func buildBaseRegisterOpts(config *Config, sourceImage *ec2.Image, rootVolumeSiz
 		newMappings[i] = newDevice
 	}
 
-	if config.FromScratch {
+	if config.newVolume() {
 		return &ec2.RegisterImageInput{
 			Name:                &config.AMIName,
 			Architecture:        aws.String(config.Architecture),
@@ -0,0 +1,122 @@
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepWriteImage writes the local disk image of from_image onto the
+// attached volume. For HVM images, the partition table of the volume is then
+// read again, and the step waits for the partition to mount to appear.
+type StepWriteImage struct{}
+
+// partitionTimeout is how long StepWriteImage waits for the device of the
+// partition to mount.
+const partitionTimeout = 30 * time.Second
+
+func (s *StepWriteImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	if config.FromImage == "" {
+		return multistep.ActionContinue
+	}
+
+	device := state.Get("device").(string)
+	if config.NVMEDevicePath != "" {
+		// nvme_device_path is the prefix of the partitions, the image is
+		// written to the whole disk
+		device = nvmeDiskDevice(config.NVMEDevicePath)
+	}
+
+	path, err := filepath.Abs(config.FromImage)
+	if err != nil {
+		err := fmt.Errorf("Error writing image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Writing %s image %s to %s...", config.FromImageFormat, path, device))
+	ictx := config.ctx
+	ictx.Data = nil
+	if err := chroot.RunLocalCommands([]string{writeImageCommand(config.FromImageFormat, path, device)},
+		wrappedCommand, ictx, ui); err != nil {
+		err := fmt.Errorf("Error writing image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Paravirtual images have no partition table
+	if config.AMIVirtType != "hvm" {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Reading the partition table of the image...")
+	if err := chroot.RunLocalCommands([]string{fmt.Sprintf("blockdev --rereadpt '%s'", device)},
+		wrappedCommand, ictx, ui); err != nil {
+		err := fmt.Errorf("Error reading the partition table: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// udev creates the devices of the partitions asynchronously
+	partition := chroot.PartitionDevice(device, config.MountPartition)
+	if err := waitForDevice(ctx, partition, partitionTimeout); err != nil {
+		err := fmt.Errorf("Error waiting for partition: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+// waitForDevice waits for the device node at path to exist.
+func waitForDevice(ctx context.Context, path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("device %s did not appear after %s", path, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second / 2):
+		}
+	}
+}
+
+func (s *StepWriteImage) Cleanup(state multistep.StateBag) {}
+
+// nvmeDiskDevice returns the device of the disk of nvme_device_path, which
+// is documented as the prefix of the devices of its partitions, such as
+// /dev/nvme1n1p for /dev/nvme1n1.
+func nvmeDiskDevice(path string) string {
+	if n := len(path); n > 1 && path[n-1] == 'p' && path[n-2] >= '0' && path[n-2] <= '9' {
+		return path[:n-1]
+	}
+	return path
+}
+
+// writeImageCommand returns the command writing an image onto a device.
+// The new volume is zeroed, so the zero blocks of the image are skipped.
+func writeImageCommand(format, path, device string) string {
+	if format == "qcow2" {
+		return fmt.Sprintf("qemu-img convert -n -f qcow2 -O raw '%s' '%s'", path, device)
+	}
+	return fmt.Sprintf("dd if='%s' of='%s' bs=4M conv=sparse,fsync", path, device)
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/hashicorp/packer/template/interpolate"
 )
 
+var reAccountId = regexp.MustCompile(`^\d{12}$`)
+
 // AMIConfig is for common configuration related to creating AMIs.
 type AMIConfig struct {
 	// The name of the resulting AMI that will appear when managing AMIs in the
@@ -127,6 +129,42 @@ type AMIConfig struct {
 	// to create volumes from the snapshot(s). all will make the snapshot
 	// publicly accessible.
 	SnapshotGroups []string `mapstructure:"snapshot_groups" required:"false"`
+	// Share the resulting AMI(s) and their snapshots with other accounts,
+	// including when they are encrypted. See [sharing encrypted
+	// AMIs](/docs/builders/amazon.html#sharing-encrypted-amis).
+	AMIShare AMIShareConfig `mapstructure:"ami_share" required:"false"`
+}
+
+// AMIShareConfig shares AMIs encrypted with customer managed KMS keys with
+// other accounts. Usage example:
+//
+// ```json
+// {
+//   "ami_share": {
+//     "accounts": ["123456789012"],
+//     "kms_key_ids": {
+//       "us-east-1": "arn:aws:kms:us-east-1:111111111111:key/11111111-1111-1111-1111-111111111111",
+//       "eu-west-1": "arn:aws:kms:eu-west-1:111111111111:key/22222222-2222-2222-2222-222222222222"
+//     }
+//   }
+// }
+// ```
+type AMIShareConfig struct {
+	// The account IDs to share the AMI(s) with. They are given the
+	// permission to launch the AMI(s), to create volumes from their
+	// snapshots and to use the KMS keys encrypting these snapshots.
+	Accounts []string `mapstructure:"accounts" required:"true"`
+	// The customer managed KMS keys to encrypt the AMI(s) with, by region:
+	// the AMI of each region is copied with the key of its region, which
+	// implies `encrypt_boot`. The build region and each of `ami_regions`
+	// must then have a key, either in this map, in `kms_key_id` for the
+	// build region or in `region_kms_key_ids`. When this is not set, the
+	// AMI(s) keep their keys, which must not be the default EBS key.
+	KMSKeyIds map[string]string `mapstructure:"kms_key_ids" required:"false"`
+}
+
+func (c *AMIShareConfig) Empty() bool {
+	return len(c.Accounts) == 0 && len(c.KMSKeyIds) == 0
 }
 
 func stringInSlice(s []string, searchstr string) bool {
@@ -145,6 +183,8 @@ func (c *AMIConfig) Prepare(accessConfig *AccessConfig, ctx *interpolate.Context
 		errs = append(errs, fmt.Errorf("ami_name must be specified"))
 	}
 
+	errs = append(errs, c.prepareShare(accessConfig)...)
+
 	// Make sure that if we have region_kms_key_ids defined,
 	//  the regions in region_kms_key_ids are also in ami_regions
 	if len(c.AMIRegionKMSKeyIDs) > 0 {
@@ -255,6 +295,75 @@ func (c *AMIConfig) prepareRegions(accessConfig *AccessConfig) (errs []error) {
 	return errs
 }
 
+// prepareShare sets the keys of the region copies from ami_share, before the
+// keys are validated along with the regions.
+func (c *AMIConfig) prepareShare(accessConfig *AccessConfig) (errs []error) {
+	share := &c.AMIShare
+	if share.Empty() {
+		return nil
+	}
+
+	if len(share.Accounts) == 0 {
+		errs = append(errs, fmt.Errorf("ami_share.accounts must be specified"))
+	}
+	for _, account := range share.Accounts {
+		if !reAccountId.MatchString(account) {
+			errs = append(errs, fmt.Errorf("%s is not a valid account ID in ami_share.accounts", account))
+		}
+	}
+
+	if len(share.KMSKeyIds) == 0 {
+		return errs
+	}
+
+	if c.AMIEncryptBootVolume.False() {
+		errs = append(errs, fmt.Errorf("ami_share.kms_key_ids cannot be used when encrypt_boot is false"))
+		return errs
+	}
+	c.AMIEncryptBootVolume = config.TriTrue
+
+	buildRegion := ""
+	if accessConfig != nil {
+		buildRegion = accessConfig.RawRegion
+	}
+
+	for region, kmsKey := range share.KMSKeyIds {
+		if !validateKmsKey(kmsKey) {
+			errs = append(errs, fmt.Errorf("%s is not a valid KMS Key Id.", kmsKey))
+			continue
+		}
+		switch {
+		case region == buildRegion:
+			if c.AMIKmsKeyId != "" && c.AMIKmsKeyId != kmsKey {
+				errs = append(errs, fmt.Errorf("ami_share.kms_key_ids and kms_key_id have different keys for region %s", region))
+			}
+			c.AMIKmsKeyId = kmsKey
+		case stringInSlice(c.AMIRegions, region):
+			if key := c.AMIRegionKMSKeyIDs[region]; key != "" && key != kmsKey {
+				errs = append(errs, fmt.Errorf("ami_share.kms_key_ids and region_kms_key_ids have different keys for region %s", region))
+			}
+			if c.AMIRegionKMSKeyIDs == nil {
+				c.AMIRegionKMSKeyIDs = make(map[string]string)
+			}
+			c.AMIRegionKMSKeyIDs[region] = kmsKey
+		default:
+			errs = append(errs, fmt.Errorf("Region %s is in ami_share.kms_key_ids but not in ami_regions", region))
+		}
+	}
+
+	// The default EBS key of a region can't be shared
+	if buildRegion != "" && !c.AMISkipBuildRegion && c.AMIKmsKeyId == "" {
+		errs = append(errs, fmt.Errorf("ami_share.kms_key_ids must have a key for region %s", buildRegion))
+	}
+	for _, region := range c.AMIRegions {
+		if region != buildRegion && c.AMIRegionKMSKeyIDs[region] == "" {
+			errs = append(errs, fmt.Errorf("ami_share.kms_key_ids must have a key for region %s", region))
+		}
+	}
+
+	return errs
+}
+
 // See https://docs.aws.amazon.com/AWSEC2/latest/APIReference/API_CopyImage.html
 func validateKmsKey(kmsKey string) (valid bool) {
 	kmsKeyIdPattern := `[a-f0-9-]+$`
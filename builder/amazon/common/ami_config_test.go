@@ -176,6 +176,85 @@ func TestAMIConfigPrepare_Share_EncryptedBoot(t *testing.T) {
 	}
 }
 
+func TestAMIConfigPrepare_AMIShare(t *testing.T) {
+	c := testAMIConfig()
+	c.AMIRegions = []string{"us-west-1", "us-west-2"}
+	c.AMIShare = AMIShareConfig{
+		Accounts: []string{"123456789012"},
+	}
+	accessConf := getFakeAccessConfig("us-east-1")
+
+	// The AMIs keep their keys
+	if err := c.Prepare(accessConf, nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if c.AMIEncryptBootVolume != config.TriUnset {
+		t.Fatalf("bad: %#v", c.AMIEncryptBootVolume)
+	}
+
+	c.AMIShare.KMSKeyIds = map[string]string{
+		"us-east-1": "arn:aws:kms:us-east-1:111111111111:key/11111111-1111-1111-1111-111111111111",
+		"us-west-1": "arn:aws:kms:us-west-1:111111111111:key/22222222-2222-2222-2222-222222222222",
+		"us-west-2": "alias/shared",
+	}
+	if err := c.Prepare(accessConf, nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if !c.AMIEncryptBootVolume.True() {
+		t.Fatal("should encrypt the AMIs")
+	}
+	if c.AMIKmsKeyId != c.AMIShare.KMSKeyIds["us-east-1"] {
+		t.Fatalf("bad: %s", c.AMIKmsKeyId)
+	}
+	expected := map[string]string{
+		"us-west-1": "arn:aws:kms:us-west-1:111111111111:key/22222222-2222-2222-2222-222222222222",
+		"us-west-2": "alias/shared",
+	}
+	if !reflect.DeepEqual(c.AMIRegionKMSKeyIDs, expected) {
+		t.Fatalf("bad: %#v", c.AMIRegionKMSKeyIDs)
+	}
+}
+
+func TestAMIConfigPrepare_AMIShare_errors(t *testing.T) {
+	accessConf := getFakeAccessConfig("us-east-1")
+	cases := map[string]func(c *AMIConfig){
+		"no accounts": func(c *AMIConfig) {
+			c.AMIShare.Accounts = nil
+		},
+		"bad account": func(c *AMIConfig) {
+			c.AMIShare.Accounts = []string{"alice"}
+		},
+		"missing region key": func(c *AMIConfig) {
+			delete(c.AMIShare.KMSKeyIds, "us-west-1")
+		},
+		"unknown region": func(c *AMIConfig) {
+			c.AMIShare.KMSKeyIds["eu-west-1"] = "alias/shared"
+		},
+		"conflicting key": func(c *AMIConfig) {
+			c.AMIKmsKeyId = "alias/other"
+		},
+		"no encryption": func(c *AMIConfig) {
+			c.AMIEncryptBootVolume = config.TriFalse
+		},
+	}
+
+	for name, f := range cases {
+		c := testAMIConfig()
+		c.AMIRegions = []string{"us-west-1"}
+		c.AMIShare = AMIShareConfig{
+			Accounts: []string{"123456789012"},
+			KMSKeyIds: map[string]string{
+				"us-east-1": "alias/shared",
+				"us-west-1": "alias/shared",
+			},
+		}
+		f(c)
+		if err := c.Prepare(accessConf, nil); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
 func TestAMIConfigPrepare_ValidateKmsKey(t *testing.T) {
 	c := testAMIConfig()
 	c.AMIEncryptBootVolume = config.TriTrue
@@ -335,8 +335,25 @@ type RunConfig struct {
 	WindowsPasswordTimeout time.Duration `mapstructure:"windows_password_timeout" required:"false"`
 
 	// Communicator settings
-	Comm         communicator.Config `mapstructure:",squash"`
-	SSHInterface string              `mapstructure:"ssh_interface"`
+	Comm communicator.Config `mapstructure:",squash"`
+	// One of `public_ip`, `private_ip`, `public_dns`, `private_dns` or
+	// `session_manager`. If set, either the public IP address, private IP
+	// address, public DNS name or private DNS name will used as the host for
+	// SSH. The default behaviour if inside a VPC is to use the public IP
+	// address if available, otherwise the private IP address will be used.
+	// If not in a VPC the public DNS name will be used. Also works for WinRM.
+	//
+	// With `session_manager`, Packer starts an [AWS Systems Manager Session
+	// Manager](https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager.html)
+	// session which forwards a local port to the SSH or WinRM port of the
+	// instance, so the temporary security group has no inbound rule. This
+	// requires the `session-manager-plugin` of the AWS CLI, an instance with
+	// the SSM agent running and an `iam_instance_profile` allowing it to
+	// register with Systems Manager.
+	SSHInterface string `mapstructure:"ssh_interface"`
+	// The local port of the Session Manager session when `ssh_interface` is
+	// `session_manager`. Defaults to a free port between 8000 and 9000.
+	SessionManagerPort int `mapstructure:"session_manager_port"`
 }
 
 func (c *RunConfig) Prepare(ctx *interpolate.Context) []error {
@@ -366,10 +383,25 @@ func (c *RunConfig) Prepare(ctx *interpolate.Context) []error {
 		c.SSHInterface != "private_ip" &&
 		c.SSHInterface != "public_dns" &&
 		c.SSHInterface != "private_dns" &&
+		c.SSHInterface != "session_manager" &&
 		c.SSHInterface != "" {
 		errs = append(errs, fmt.Errorf("Unknown interface type: %s", c.SSHInterface))
 	}
 
+	if c.SSHInterface == "session_manager" {
+		if c.IamInstanceProfile == "" {
+			errs = append(errs, fmt.Errorf("iam_instance_profile must be provided when ssh_interface is session_manager."))
+		}
+		if c.Comm.Type == "none" {
+			errs = append(errs, fmt.Errorf("ssh_interface session_manager requires a communicator."))
+		}
+	} else if c.SessionManagerPort != 0 {
+		errs = append(errs, fmt.Errorf("session_manager_port can only be set when ssh_interface is session_manager."))
+	}
+	if c.SessionManagerPort < 0 || c.SessionManagerPort > 65535 {
+		errs = append(errs, fmt.Errorf("session_manager_port must be a valid port number."))
+	}
+
 	if c.Comm.SSHKeyPairName != "" {
 		if c.Comm.Type == "winrm" && c.Comm.WinRMPassword == "" && c.Comm.SSHPrivateKeyFile == "" {
 			errs = append(errs, fmt.Errorf("ssh_private_key_file must be provided to retrieve the winrm password when using ssh_keypair_name."))
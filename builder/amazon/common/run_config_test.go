@@ -160,6 +160,25 @@ func TestRunConfigPrepare_SSHPort(t *testing.T) {
 	}
 }
 
+func TestRunConfigPrepare_SessionManager(t *testing.T) {
+	c := testConfig()
+	c.SSHInterface = "session_manager"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("Should error without iam_instance_profile")
+	}
+
+	c.IamInstanceProfile = "packer-ssm"
+	c.SessionManagerPort = 8022
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SSHInterface = "private_ip"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("Should error with session_manager_port")
+	}
+}
+
 func TestRunConfigPrepare_UserData(t *testing.T) {
 	c := testConfig()
 	tf, err := ioutil.TempFile("", "packer")
@@ -50,6 +50,9 @@ func SSHHost(e ec2Describer, sshInterface string, host string) func(multistep.St
 					if i.PrivateDnsName != nil {
 						host = *i.PrivateDnsName
 					}
+				case "session_manager":
+					// StepCreateSSMTunnel listens on the loopback interface
+					return "localhost", nil
 				default:
 					panic(fmt.Sprintf("Unknown interface type: %s", sshInterface))
 				}
@@ -85,3 +88,20 @@ func SSHHost(e ec2Describer, sshInterface string, host string) func(multistep.St
 		return "", errors.New("couldn't determine address for instance")
 	}
 }
+
+// Port returns a function that can be given to the communicator for
+// determining the port to connect to. With the session_manager interface,
+// this is the local port of the session started by StepCreateSSMTunnel.
+func Port(sshInterface string, port int) func(multistep.StateBag) (int, error) {
+	return func(state multistep.StateBag) (int, error) {
+		if sshInterface != "session_manager" {
+			return port, nil
+		}
+
+		sessionPort, ok := state.GetOk("sessionPort")
+		if !ok {
+			return 0, errors.New("no Session Manager session was started")
+		}
+		return sessionPort.(int), nil
+	}
+}
@@ -51,6 +51,8 @@ func TestSSHHost(t *testing.T) {
 		{3, "vpc-id", "private_dns", false, "", ""},
 		{3, "vpc-id", "public_dns", false, "", ""},
 		{3, "vpc-id", "public_ip", false, "", ""},
+		{1, "", "session_manager", true, "localhost", ""},
+		{1, "vpc-id", "session_manager", true, "localhost", ""},
 		{1, "", "", true, sshHostTemplate, sshHostTemplate},
 		{1, "vpc-id", "", true, sshHostTemplate, sshHostTemplate},
 		{2, "vpc-id", "private_dns", true, sshHostTemplate, sshHostTemplate},
@@ -62,6 +64,31 @@ func TestSSHHost(t *testing.T) {
 	}
 }
 
+func TestPort(t *testing.T) {
+	st := &multistep.BasicStateBag{}
+
+	port, err := Port("public_ip", 22)(st)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if port != 22 {
+		t.Fatalf("bad: %d", port)
+	}
+
+	if _, err := Port("session_manager", 22)(st); err == nil {
+		t.Fatal("should have error without session")
+	}
+
+	st.Put("sessionPort", 8123)
+	port, err = Port("session_manager", 22)(st)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if port != 8123 {
+		t.Fatalf("bad: %d", port)
+	}
+}
+
 func testSSHHost(t *testing.T, allowTries int, vpcId string, sshInterface string,
 	ok bool, wantHost string, sshHostOverride string) {
 	t.Logf("allowTries=%d vpcId=%s sshInterface=%s ok=%t wantHost=%q sshHostOverride=%s",
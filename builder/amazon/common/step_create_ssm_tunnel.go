@@ -0,0 +1,236 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	packernet "github.com/hashicorp/packer/common/net"
+	"github.com/hashicorp/packer/common/retry"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// SessionManagerPlugin starts the local end of a Session Manager port
+// forwarding session. It must return once the forwarding is started and keep
+// forwarding until ctx is cancelled.
+type SessionManagerPlugin interface {
+	Start(ctx context.Context, region string, session *ssm.StartSessionOutput, input *ssm.StartSessionInput) error
+}
+
+// SessionManagerPluginCmd runs the session-manager-plugin executable which
+// is installed alongside the AWS CLI.
+type SessionManagerPluginCmd struct {
+	// Path of the executable, looked up in the PATH when empty.
+	Path string
+
+	// The endpoint of the SSM client that started the session, which is
+	// resolved from the region when empty.
+	Endpoint string
+
+	// How long to wait for the local port to accept connections. Defaults
+	// to 30 seconds.
+	Timeout time.Duration
+}
+
+func (p *SessionManagerPluginCmd) Start(ctx context.Context, region string, session *ssm.StartSessionOutput, input *ssm.StartSessionInput) error {
+	path := p.Path
+	if path == "" {
+		path = "session-manager-plugin"
+	}
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		resolved, err := endpoints.DefaultResolver().EndpointFor(ssm.EndpointsID, region)
+		if err != nil {
+			return err
+		}
+		endpoint = resolved.URL
+	}
+
+	// The arguments are the ones given by the AWS CLI: the session, the
+	// region, the operation, the profile, the request and the endpoint.
+	cmd := exec.CommandContext(ctx, path, string(sessionJSON), region,
+		"StartSession", "", string(inputJSON), endpoint)
+	cmd.Stdout = pluginLog("stdout")
+	cmd.Stderr = pluginLog("stderr")
+	log.Printf("Starting %s for session %s", path, aws.StringValue(session.SessionId))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Error starting session-manager-plugin: %s", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		log.Printf("session-manager-plugin exited: %v", err)
+		exited <- err
+	}()
+
+	// The plugin listens on the local port once it is connected to the
+	// session.
+	var localPort string
+	if ports := input.Parameters["localPortNumber"]; len(ports) > 0 {
+		localPort = aws.StringValue(ports[0])
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	deadline := time.After(timeout)
+	addr := net.JoinHostPort("127.0.0.1", localPort)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("session-manager-plugin exited before forwarding port %s: %v", localPort, err)
+		case <-deadline:
+			return fmt.Errorf("Timeout waiting for session-manager-plugin to forward port %s", localPort)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// pluginLog writes the output of session-manager-plugin to the log.
+type pluginLog string
+
+func (l pluginLog) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\r\n"), "\n") {
+		log.Printf("session-manager-plugin %s: %s", string(l), line)
+	}
+	return len(p), nil
+}
+
+// StepCreateSSMTunnel starts a Session Manager session on the instance which
+// forwards a local port to the port of the communicator, so that the
+// communicator connects without any inbound rule on the instance.
+//
+// Produces:
+//   sessionPort int - the local port of the tunnel
+type StepCreateSSMTunnel struct {
+	Skip             bool
+	SSMClient        ssmiface.SSMAPI
+	Plugin           SessionManagerPlugin
+	Region           string
+	LocalPortNumber  int
+	RemotePortNumber int
+
+	session *ssm.StartSessionOutput
+	cancel  context.CancelFunc
+}
+
+func (s *StepCreateSSMTunnel) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Skip {
+		return multistep.ActionContinue
+	}
+
+	instance := state.Get("instance").(*ec2.Instance)
+	ui := state.Get("ui").(packer.Ui)
+
+	localPort := s.LocalPortNumber
+	if localPort == 0 {
+		l, err := packernet.ListenRangeConfig{
+			Addr: "127.0.0.1",
+			Min:  8000,
+			Max:  9000,
+		}.Listen(ctx)
+		if err != nil {
+			err := fmt.Errorf("Error finding a port for the session: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		localPort = l.Port
+		l.Close()
+	}
+
+	input := &ssm.StartSessionInput{
+		DocumentName: aws.String("AWS-StartPortForwardingSession"),
+		Target:       instance.InstanceId,
+		Parameters: map[string][]*string{
+			"portNumber":      {aws.String(strconv.Itoa(s.RemotePortNumber))},
+			"localPortNumber": {aws.String(strconv.Itoa(localPort))},
+		},
+	}
+
+	ui.Say(fmt.Sprintf("Starting Session Manager session forwarding port %d to port %d...",
+		localPort, s.RemotePortNumber))
+
+	// The SSM agent of the instance takes a while to register once the
+	// instance is running.
+	err := retry.Config{
+		StartTimeout: 10 * time.Minute,
+		ShouldRetry: func(err error) bool {
+			if awsErr, ok := err.(awserr.Error); ok {
+				return awsErr.Code() == ssm.ErrCodeTargetNotConnected
+			}
+			return false
+		},
+		RetryDelay: (&retry.Backoff{InitialBackoff: 5 * time.Second, MaxBackoff: 30 * time.Second, Multiplier: 2}).Linear,
+	}.Run(ctx, func(ctx context.Context) error {
+		var err error
+		s.session, err = s.SSMClient.StartSession(input)
+		return err
+	})
+	if err != nil {
+		err := fmt.Errorf("Error starting Session Manager session: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	var pluginCtx context.Context
+	pluginCtx, s.cancel = context.WithCancel(ctx)
+	if err := s.Plugin.Start(pluginCtx, s.Region, s.session, input); err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("sessionPort", localPort)
+	return multistep.ActionContinue
+}
+
+func (s *StepCreateSSMTunnel) Cleanup(state multistep.StateBag) {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.session == nil {
+		return
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	ui.Say("Terminating Session Manager session...")
+	_, err := s.SSMClient.TerminateSession(&ssm.TerminateSessionInput{
+		SessionId: s.session.SessionId,
+	})
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error terminating session %s: %s",
+			aws.StringValue(s.session.SessionId), err))
+	}
+	s.session = nil
+}
@@ -0,0 +1,358 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// Define a mock struct to be used in unit tests for the SSM tunnel step.
+type mockSSMConn struct {
+	ssmiface.SSMAPI
+
+	startSessionErr    error
+	startSessionInput  *ssm.StartSessionInput
+	terminatedSessions []string
+}
+
+func (m *mockSSMConn) StartSession(input *ssm.StartSessionInput) (*ssm.StartSessionOutput, error) {
+	m.startSessionInput = input
+	if m.startSessionErr != nil {
+		return nil, m.startSessionErr
+	}
+	return &ssm.StartSessionOutput{
+		SessionId:  aws.String("packer-0123456789"),
+		StreamUrl:  aws.String("wss://ssmmessages.us-east-1.amazonaws.com/v1/data-channel/packer-0123456789"),
+		TokenValue: aws.String("token"),
+	}, nil
+}
+
+func (m *mockSSMConn) TerminateSession(input *ssm.TerminateSessionInput) (*ssm.TerminateSessionOutput, error) {
+	m.terminatedSessions = append(m.terminatedSessions, *input.SessionId)
+	return &ssm.TerminateSessionOutput{SessionId: input.SessionId}, nil
+}
+
+type mockSessionManagerPlugin struct {
+	startErr error
+	ctx      context.Context
+	region   string
+	session  *ssm.StartSessionOutput
+}
+
+func (p *mockSessionManagerPlugin) Start(ctx context.Context, region string, session *ssm.StartSessionOutput, input *ssm.StartSessionInput) error {
+	p.ctx = ctx
+	p.region = region
+	p.session = session
+	return p.startErr
+}
+
+func tSSMState() multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	state.Put("instance", &ec2.Instance{InstanceId: aws.String("i-12345")})
+	return state
+}
+
+func TestStepCreateSSMTunnel_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCreateSSMTunnel)
+	var _ SessionManagerPlugin = new(SessionManagerPluginCmd)
+}
+
+func TestStepCreateSSMTunnel(t *testing.T) {
+	conn := new(mockSSMConn)
+	plugin := new(mockSessionManagerPlugin)
+	state := tSSMState()
+	step := &StepCreateSSMTunnel{
+		SSMClient:        conn,
+		Plugin:           plugin,
+		Region:           "us-east-1",
+		LocalPortNumber:  8022,
+		RemotePortNumber: 22,
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err, ok := state.GetOk("error"); ok {
+		t.Fatalf("err: %s", err)
+	}
+
+	input := conn.startSessionInput
+	if *input.Target != "i-12345" || *input.DocumentName != "AWS-StartPortForwardingSession" {
+		t.Fatalf("bad: %#v", input)
+	}
+	if *input.Parameters["portNumber"][0] != "22" || *input.Parameters["localPortNumber"][0] != "8022" {
+		t.Fatalf("bad: %#v", input.Parameters)
+	}
+	if plugin.region != "us-east-1" || *plugin.session.SessionId != "packer-0123456789" {
+		t.Fatalf("bad: %#v", plugin)
+	}
+	if state.Get("sessionPort").(int) != 8022 {
+		t.Fatalf("bad: %#v", state.Get("sessionPort"))
+	}
+
+	// Cleanup stops the plugin and terminates the session
+	step.Cleanup(state)
+	if plugin.ctx.Err() == nil {
+		t.Fatal("should cancel the plugin")
+	}
+	if len(conn.terminatedSessions) != 1 || conn.terminatedSessions[0] != "packer-0123456789" {
+		t.Fatalf("bad: %#v", conn.terminatedSessions)
+	}
+}
+
+func TestStepCreateSSMTunnel_freePort(t *testing.T) {
+	conn := new(mockSSMConn)
+	state := tSSMState()
+	step := &StepCreateSSMTunnel{
+		SSMClient:        conn,
+		Plugin:           new(mockSessionManagerPlugin),
+		Region:           "us-east-1",
+		RemotePortNumber: 5986,
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer step.Cleanup(state)
+
+	port := state.Get("sessionPort").(int)
+	if port < 8000 || port >= 9000 {
+		t.Fatalf("bad: %d", port)
+	}
+	if *conn.startSessionInput.Parameters["portNumber"][0] != "5986" {
+		t.Fatalf("bad: %#v", conn.startSessionInput.Parameters)
+	}
+}
+
+func TestStepCreateSSMTunnel_skip(t *testing.T) {
+	conn := new(mockSSMConn)
+	state := tSSMState()
+	step := &StepCreateSSMTunnel{
+		Skip:      true,
+		SSMClient: conn,
+		Plugin:    new(mockSessionManagerPlugin),
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+	if conn.startSessionInput != nil || len(conn.terminatedSessions) != 0 {
+		t.Fatal("should not start a session")
+	}
+	if _, ok := state.GetOk("sessionPort"); ok {
+		t.Fatal("should not put the session port")
+	}
+}
+
+func TestStepCreateSSMTunnel_startSessionError(t *testing.T) {
+	conn := &mockSSMConn{
+		startSessionErr: awserr.New("AccessDeniedException", "not authorized", nil),
+	}
+	state := tSSMState()
+	step := &StepCreateSSMTunnel{
+		SSMClient:        conn,
+		Plugin:           new(mockSessionManagerPlugin),
+		LocalPortNumber:  8022,
+		RemotePortNumber: 22,
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	step.Cleanup(state)
+	if len(conn.terminatedSessions) != 0 {
+		t.Fatalf("bad: %#v", conn.terminatedSessions)
+	}
+}
+
+func TestStepCreateSSMTunnel_pluginError(t *testing.T) {
+	conn := new(mockSSMConn)
+	state := tSSMState()
+	step := &StepCreateSSMTunnel{
+		SSMClient:        conn,
+		Plugin:           &mockSessionManagerPlugin{startErr: errors.New("plugin not found")},
+		LocalPortNumber:  8022,
+		RemotePortNumber: 22,
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+
+	// The session that was started is terminated
+	step.Cleanup(state)
+	if len(conn.terminatedSessions) != 1 {
+		t.Fatalf("bad: %#v", conn.terminatedSessions)
+	}
+}
+
+// testPluginCmd returns a SessionManagerPluginCmd running a script that
+// runs TestSessionManagerPluginHelper with mode and the arguments of the
+// plugin.
+func testPluginCmd(t *testing.T, dir, mode string) *SessionManagerPluginCmd {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+	script := fmt.Sprintf("#!/bin/sh\nSSM_PLUGIN_HELPER=%s exec '%s' -test.run=TestSessionManagerPluginHelper -- \"$@\"\n",
+		mode, os.Args[0])
+	path := filepath.Join(dir, "session-manager-plugin")
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return &SessionManagerPluginCmd{Path: path, Endpoint: "https://ssm.example.com", Timeout: 5 * time.Second}
+}
+
+// This is not a real test. This is the session-manager-plugin run by the
+// tests of SessionManagerPluginCmd.
+func TestSessionManagerPluginHelper(t *testing.T) {
+	mode := os.Getenv("SSM_PLUGIN_HELPER")
+	if mode == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	args = args[1:]
+	fmt.Fprintf(os.Stderr, "endpoint %s\n", args[5])
+
+	switch mode {
+	case "exit":
+		fmt.Fprintln(os.Stderr, "Cannot perform start session")
+		os.Exit(1)
+	case "hang":
+		time.Sleep(time.Minute)
+	case "listen":
+		// the endpoint is the one of the SSM client
+		if args[5] != "https://ssm.example.com" {
+			os.Exit(5)
+		}
+		var input ssm.StartSessionInput
+		if err := json.Unmarshal([]byte(args[4]), &input); err != nil {
+			os.Exit(2)
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:"+*input.Parameters["localPortNumber"][0])
+		if err != nil {
+			os.Exit(3)
+		}
+		fmt.Println("Waiting for connections...")
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				os.Exit(4)
+			}
+			conn.Close()
+		}
+	}
+}
+
+func testPluginSession(t *testing.T) (*ssm.StartSessionOutput, *ssm.StartSessionInput) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	session := &ssm.StartSessionOutput{SessionId: aws.String("packer-0123456789")}
+	input := &ssm.StartSessionInput{
+		Parameters: map[string][]*string{
+			"localPortNumber": {aws.String(fmt.Sprint(port))},
+		},
+	}
+	return session, input
+}
+
+func TestSessionManagerPluginCmd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session, input := testPluginSession(t)
+
+	plugin := testPluginCmd(t, dir, "listen")
+	if err := plugin.Start(ctx, "us-east-1", session, input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// the port is forwarded once Start returns
+	conn, err := net.Dial("tcp", "127.0.0.1:"+*input.Parameters["localPortNumber"][0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.Close()
+}
+
+func TestSessionManagerPluginCmd_exit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	session, input := testPluginSession(t)
+	plugin := testPluginCmd(t, dir, "exit")
+	err = plugin.Start(context.Background(), "us-east-1", session, input)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "exited") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestSessionManagerPluginCmd_timeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session, input := testPluginSession(t)
+	plugin := testPluginCmd(t, dir, "hang")
+	plugin.Timeout = time.Second
+	err = plugin.Start(ctx, "us-east-1", session, input)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "Timeout") {
+		t.Fatalf("bad: %s", err)
+	}
+}
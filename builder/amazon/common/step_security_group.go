@@ -21,6 +21,9 @@ type StepSecurityGroup struct {
 	SecurityGroupFilter    SecurityGroupFilterOptions
 	SecurityGroupIds       []string
 	TemporarySGSourceCidrs []string
+	// Skip the inbound rule for the communicator, which connects through
+	// a Session Manager session.
+	SkipSSHRuleCreation bool
 
 	createdGroupId string
 }
@@ -119,6 +122,14 @@ func (s *StepSecurityGroup) Run(ctx context.Context, state multistep.StateBag) m
 		return multistep.ActionHalt
 	}
 
+	// Set some state data for use in future steps
+	state.Put("securityGroupIds", []string{s.createdGroupId})
+
+	if s.SkipSSHRuleCreation {
+		log.Printf("Skipping the inbound rule of temporary security group %s", s.createdGroupId)
+		return multistep.ActionContinue
+	}
+
 	// map the list of temporary security group CIDRs bundled with config to
 	// types expected by EC2.
 	groupIpRanges := []*ec2.IpRange{}
@@ -154,9 +165,6 @@ func (s *StepSecurityGroup) Run(ctx context.Context, state multistep.StateBag) m
 		return multistep.ActionHalt
 	}
 
-	// Set some state data for use in future steps
-	state.Put("securityGroupIds", []string{s.createdGroupId})
-
 	return multistep.ActionContinue
 }
 
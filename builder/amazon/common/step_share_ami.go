@@ -0,0 +1,281 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// The operations another account needs on the key of a snapshot to launch
+// instances from an AMI, or to copy it.
+var shareGrantOperations = []string{
+	kms.GrantOperationDecrypt,
+	kms.GrantOperationDescribeKey,
+	kms.GrantOperationCreateGrant,
+	kms.GrantOperationGenerateDataKeyWithoutPlaintext,
+	kms.GrantOperationReEncryptFrom,
+	kms.GrantOperationReEncryptTo,
+}
+
+// StepShareAMI shares the AMIs of every region with the accounts of the
+// ami_share block: it grants the accounts the use of the KMS keys of the
+// encrypted snapshots, and gives them the permission to create volumes from
+// the snapshots and to launch the AMIs. The AMIs were re-encrypted with the
+// keys of ami_share by StepAMIRegionCopy.
+type StepShareAMI struct {
+	AccessConfig *AccessConfig
+	Share        AMIShareConfig
+
+	getRegionConn func(*AccessConfig, string) (ec2iface.EC2API, error)
+	getKMSConn    func(*AccessConfig, string) (kmsiface.KMSAPI, error)
+
+	// The grants created by the step, retired when the build fails
+	grants []shareGrant
+}
+
+// shareGrant is a grant of a KMS key created by StepShareAMI.
+type shareGrant struct {
+	region  string
+	keyId   string
+	grantId string
+}
+
+func (s *StepShareAMI) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Share.Accounts) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	amis := state.Get("amis").(map[string]string)
+
+	if s.getRegionConn == nil {
+		s.getRegionConn = getRegionConn
+	}
+	if s.getKMSConn == nil {
+		s.getKMSConn = getKMSConn
+	}
+
+	regions := make([]string, 0, len(amis))
+	for region := range amis {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	for _, region := range regions {
+		ui.Say(fmt.Sprintf("Sharing AMI (%s) in %s with %s...",
+			amis[region], region, strings.Join(s.Share.Accounts, ", ")))
+		if err := s.shareAMI(ui, region, amis[region]); err != nil {
+			err := fmt.Errorf("Error sharing AMI (%s) in %s: %s", amis[region], region, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepShareAMI) Cleanup(state multistep.StateBag) {
+	// The permissions go along with the AMIs and snapshots, which are
+	// deregistered and deleted when the build fails. The grants live on the
+	// keys, and are retired.
+	if len(s.grants) == 0 {
+		return
+	}
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	ui.Say("Retiring the grants of the KMS keys...")
+	for _, g := range s.grants {
+		kmsconn, err := s.getKMSConn(s.AccessConfig, g.region)
+		if err == nil {
+			_, err = kmsconn.RetireGrant(&kms.RetireGrantInput{
+				KeyId:   aws.String(g.keyId),
+				GrantId: aws.String(g.grantId),
+			})
+		}
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error retiring grant %s of KMS key %s: %s", g.grantId, g.keyId, err))
+		}
+	}
+	s.grants = nil
+}
+
+func (s *StepShareAMI) shareAMI(ui packer.Ui, region, ami string) error {
+	regionconn, err := s.getRegionConn(s.AccessConfig, region)
+	if err != nil {
+		return err
+	}
+
+	imageResp, err := regionconn.DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(ami)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(imageResp.Images) == 0 {
+		return fmt.Errorf("AMI not found")
+	}
+
+	var snapshotIds []*string
+	for _, b := range imageResp.Images[0].BlockDeviceMappings {
+		if b.Ebs != nil && b.Ebs.SnapshotId != nil {
+			snapshotIds = append(snapshotIds, b.Ebs.SnapshotId)
+		}
+	}
+
+	var userIds []*ec2.CreateVolumePermission
+	var launchPermissions []*ec2.LaunchPermission
+	for _, account := range s.Share.Accounts {
+		userIds = append(userIds, &ec2.CreateVolumePermission{UserId: aws.String(account)})
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{UserId: aws.String(account)})
+	}
+
+	if len(snapshotIds) > 0 {
+		snapshotResp, err := regionconn.DescribeSnapshots(&ec2.DescribeSnapshotsInput{
+			SnapshotIds: snapshotIds,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Several snapshots are usually encrypted with the same key
+		keyIds := make(map[string]bool)
+		for _, snapshot := range snapshotResp.Snapshots {
+			if aws.BoolValue(snapshot.Encrypted) && snapshot.KmsKeyId != nil {
+				keyIds[*snapshot.KmsKeyId] = true
+			}
+		}
+		for keyId := range keyIds {
+			if err := s.grantKey(ui, region, ami, keyId); err != nil {
+				return err
+			}
+		}
+
+		for _, snapshotId := range snapshotIds {
+			ui.Message(fmt.Sprintf("Sharing snapshot %s", *snapshotId))
+			_, err := regionconn.ModifySnapshotAttribute(&ec2.ModifySnapshotAttributeInput{
+				SnapshotId: snapshotId,
+				Attribute:  aws.String("createVolumePermission"),
+				CreateVolumePermission: &ec2.CreateVolumePermissionModifications{
+					Add: userIds,
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("Error sharing snapshot %s: %s", *snapshotId, err)
+			}
+		}
+	}
+
+	_, err = regionconn.ModifyImageAttribute(&ec2.ModifyImageAttributeInput{
+		ImageId:   aws.String(ami),
+		Attribute: aws.String("launchPermission"),
+		LaunchPermission: &ec2.LaunchPermissionModifications{
+			Add: launchPermissions,
+		},
+	})
+	return err
+}
+
+// grantKey grants the accounts the use of a customer managed key.
+func (s *StepShareAMI) grantKey(ui packer.Ui, region, ami, keyId string) error {
+	kmsconn, err := s.getKMSConn(s.AccessConfig, region)
+	if err != nil {
+		return err
+	}
+
+	keyResp, err := kmsconn.DescribeKey(&kms.DescribeKeyInput{KeyId: aws.String(keyId)})
+	if err != nil {
+		return fmt.Errorf("Error describing KMS key %s: %s", keyId, err)
+	}
+	key := keyResp.KeyMetadata
+	if aws.StringValue(key.KeyManager) == kms.KeyManagerTypeAws {
+		return fmt.Errorf("the snapshots are encrypted with the AWS managed key %s, "+
+			"which cannot be shared: set a customer managed key in ami_share.kms_key_ids", keyId)
+	}
+
+	// Grants are given to the root of the accounts, which delegate them
+	partition := "aws"
+	if parts := strings.Split(aws.StringValue(key.Arn), ":"); len(parts) > 1 {
+		partition = parts[1]
+	}
+	granted, err := grantedPrincipals(kmsconn, aws.StringValue(key.Arn))
+	if err != nil {
+		return fmt.Errorf("Error listing the grants of KMS key %s: %s", keyId, err)
+	}
+	for _, account := range s.Share.Accounts {
+		principal := fmt.Sprintf("arn:%s:iam::%s:root", partition, account)
+		if granted[principal] {
+			ui.Message(fmt.Sprintf("%s already has the use of KMS key %s", account, aws.StringValue(key.KeyId)))
+			continue
+		}
+
+		ui.Message(fmt.Sprintf("Granting %s the use of KMS key %s", account, aws.StringValue(key.KeyId)))
+		resp, err := kmsconn.CreateGrant(&kms.CreateGrantInput{
+			KeyId:            key.Arn,
+			GranteePrincipal: aws.String(principal),
+			Name:             aws.String(fmt.Sprintf("packer-%s-%s", ami, account)),
+			Operations:       aws.StringSlice(shareGrantOperations),
+		})
+		if err != nil {
+			return fmt.Errorf("Error granting KMS key %s to %s: %s", keyId, account, err)
+		}
+		s.grants = append(s.grants, shareGrant{
+			region:  region,
+			keyId:   aws.StringValue(key.Arn),
+			grantId: aws.StringValue(resp.GrantId),
+		})
+	}
+	return nil
+}
+
+// grantedPrincipals returns the principals that already have a grant of the
+// key for all the operations of shareGrantOperations, without constraints.
+func grantedPrincipals(kmsconn kmsiface.KMSAPI, keyId string) (map[string]bool, error) {
+	granted := make(map[string]bool)
+	err := kmsconn.ListGrantsPages(&kms.ListGrantsInput{KeyId: aws.String(keyId)},
+		func(page *kms.ListGrantsResponse, lastPage bool) bool {
+			for _, g := range page.Grants {
+				if g.Constraints != nil || g.GranteePrincipal == nil {
+					continue
+				}
+				operations := make(map[string]bool)
+				for _, op := range g.Operations {
+					operations[aws.StringValue(op)] = true
+				}
+				all := true
+				for _, op := range shareGrantOperations {
+					all = all && operations[op]
+				}
+				if all {
+					granted[*g.GranteePrincipal] = true
+				}
+			}
+			return true
+		})
+	return granted, err
+}
+
+func getKMSConn(config *AccessConfig, region string) (kmsiface.KMSAPI, error) {
+	session, err := config.Session()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting KMS connection: %s", err)
+	}
+
+	return kms.New(session.Copy(&aws.Config{
+		Region: aws.String(region),
+	})), nil
+}
@@ -0,0 +1,325 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// Define mock structs to be used in unit tests for the share step.
+type mockEC2Conn_Share struct {
+	ec2iface.EC2API
+
+	region               string
+	unencrypted          bool
+	snapshotPermissions  map[string][]string
+	launchPermissions    map[string][]string
+	describedSnapshotIds []string
+}
+
+func (m *mockEC2Conn_Share) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	return &ec2.DescribeImagesOutput{
+		Images: []*ec2.Image{{
+			ImageId: input.ImageIds[0],
+			BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+				{
+					DeviceName: aws.String("/dev/sda1"),
+					Ebs:        &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-root-" + m.region)},
+				},
+				{
+					DeviceName: aws.String("/dev/sdb"),
+					Ebs:        &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-data-" + m.region)},
+				},
+				{
+					DeviceName:  aws.String("/dev/sdc"),
+					VirtualName: aws.String("ephemeral0"),
+				},
+			},
+		}},
+	}, nil
+}
+
+func (m *mockEC2Conn_Share) DescribeSnapshots(input *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	output := &ec2.DescribeSnapshotsOutput{}
+	for _, id := range input.SnapshotIds {
+		m.describedSnapshotIds = append(m.describedSnapshotIds, *id)
+		snapshot := &ec2.Snapshot{SnapshotId: id, Encrypted: aws.Bool(false)}
+		if !m.unencrypted {
+			snapshot.Encrypted = aws.Bool(true)
+			snapshot.KmsKeyId = aws.String("arn:aws:kms:" + m.region + ":111111111111:key/shared")
+		}
+		output.Snapshots = append(output.Snapshots, snapshot)
+	}
+	return output, nil
+}
+
+func (m *mockEC2Conn_Share) ModifySnapshotAttribute(input *ec2.ModifySnapshotAttributeInput) (*ec2.ModifySnapshotAttributeOutput, error) {
+	if *input.Attribute != "createVolumePermission" {
+		panic("bad attribute " + *input.Attribute)
+	}
+	for _, p := range input.CreateVolumePermission.Add {
+		m.snapshotPermissions[*input.SnapshotId] = append(m.snapshotPermissions[*input.SnapshotId], *p.UserId)
+	}
+	return &ec2.ModifySnapshotAttributeOutput{}, nil
+}
+
+func (m *mockEC2Conn_Share) ModifyImageAttribute(input *ec2.ModifyImageAttributeInput) (*ec2.ModifyImageAttributeOutput, error) {
+	for _, p := range input.LaunchPermission.Add {
+		m.launchPermissions[*input.ImageId] = append(m.launchPermissions[*input.ImageId], *p.UserId)
+	}
+	return &ec2.ModifyImageAttributeOutput{}, nil
+}
+
+type mockKMSConn struct {
+	kmsiface.KMSAPI
+
+	keyManager string
+	existing   []*kms.GrantListEntry
+	grants     []*kms.CreateGrantInput
+	retired    []string
+}
+
+func (m *mockKMSConn) DescribeKey(input *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error) {
+	return &kms.DescribeKeyOutput{
+		KeyMetadata: &kms.KeyMetadata{
+			Arn:        input.KeyId,
+			KeyId:      aws.String("shared"),
+			KeyManager: aws.String(m.keyManager),
+		},
+	}, nil
+}
+
+func (m *mockKMSConn) CreateGrant(input *kms.CreateGrantInput) (*kms.CreateGrantOutput, error) {
+	m.grants = append(m.grants, input)
+	return &kms.CreateGrantOutput{GrantId: aws.String(fmt.Sprintf("grant-%d", len(m.grants)))}, nil
+}
+
+func (m *mockKMSConn) ListGrantsPages(input *kms.ListGrantsInput, fn func(*kms.ListGrantsResponse, bool) bool) error {
+	var grants []*kms.GrantListEntry
+	for _, g := range m.existing {
+		if *g.KeyId == *input.KeyId {
+			grants = append(grants, g)
+		}
+	}
+	fn(&kms.ListGrantsResponse{Grants: grants}, true)
+	return nil
+}
+
+func (m *mockKMSConn) RetireGrant(input *kms.RetireGrantInput) (*kms.RetireGrantOutput, error) {
+	m.retired = append(m.retired, *input.KeyId+" "+*input.GrantId)
+	return &kms.RetireGrantOutput{}, nil
+}
+
+func testShareStep(ec2Conns map[string]*mockEC2Conn_Share, kmsConn *mockKMSConn) *StepShareAMI {
+	return &StepShareAMI{
+		AccessConfig: testAccessConfig(),
+		Share: AMIShareConfig{
+			Accounts: []string{"123456789012", "210987654321"},
+		},
+		getRegionConn: func(_ *AccessConfig, region string) (ec2iface.EC2API, error) {
+			return ec2Conns[region], nil
+		},
+		getKMSConn: func(*AccessConfig, string) (kmsiface.KMSAPI, error) {
+			return kmsConn, nil
+		},
+	}
+}
+
+func testShareState() multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	state.Put("amis", map[string]string{
+		"us-east-1": "ami-east",
+		"eu-west-1": "ami-west",
+	})
+	return state
+}
+
+func testShareEC2Conns() map[string]*mockEC2Conn_Share {
+	conns := make(map[string]*mockEC2Conn_Share)
+	for _, region := range []string{"us-east-1", "eu-west-1"} {
+		conns[region] = &mockEC2Conn_Share{
+			region:              region,
+			snapshotPermissions: make(map[string][]string),
+			launchPermissions:   make(map[string][]string),
+		}
+	}
+	return conns
+}
+
+func TestStepShareAMI_impl(t *testing.T) {
+	var _ multistep.Step = new(StepShareAMI)
+}
+
+func TestStepShareAMI(t *testing.T) {
+	ec2Conns := testShareEC2Conns()
+	kmsConn := &mockKMSConn{keyManager: kms.KeyManagerTypeCustomer}
+	step := testShareStep(ec2Conns, kmsConn)
+	state := testShareState()
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %s", action, state.Get("error"))
+	}
+
+	accounts := []string{"123456789012", "210987654321"}
+	for region, conn := range ec2Conns {
+		ami := map[string]string{"us-east-1": "ami-east", "eu-west-1": "ami-west"}[region]
+		if !reflect.DeepEqual(conn.launchPermissions, map[string][]string{ami: accounts}) {
+			t.Fatalf("bad %s: %#v", region, conn.launchPermissions)
+		}
+		expected := map[string][]string{
+			"snap-root-" + region: accounts,
+			"snap-data-" + region: accounts,
+		}
+		if !reflect.DeepEqual(conn.snapshotPermissions, expected) {
+			t.Fatalf("bad %s: %#v", region, conn.snapshotPermissions)
+		}
+	}
+
+	// The key of each region is granted once to each account
+	var grants []string
+	for _, g := range kmsConn.grants {
+		grants = append(grants, *g.KeyId+" "+*g.GranteePrincipal)
+		if len(g.Operations) != len(shareGrantOperations) {
+			t.Fatalf("bad: %#v", g.Operations)
+		}
+	}
+	sort.Strings(grants)
+	expected := []string{
+		"arn:aws:kms:eu-west-1:111111111111:key/shared arn:aws:iam::123456789012:root",
+		"arn:aws:kms:eu-west-1:111111111111:key/shared arn:aws:iam::210987654321:root",
+		"arn:aws:kms:us-east-1:111111111111:key/shared arn:aws:iam::123456789012:root",
+		"arn:aws:kms:us-east-1:111111111111:key/shared arn:aws:iam::210987654321:root",
+	}
+	if !reflect.DeepEqual(grants, expected) {
+		t.Fatalf("bad: %#v", grants)
+	}
+}
+
+func TestStepShareAMI_unencrypted(t *testing.T) {
+	ec2Conns := testShareEC2Conns()
+	for _, conn := range ec2Conns {
+		conn.unencrypted = true
+	}
+	kmsConn := &mockKMSConn{keyManager: kms.KeyManagerTypeCustomer}
+	step := testShareStep(ec2Conns, kmsConn)
+	state := testShareState()
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(kmsConn.grants) != 0 {
+		t.Fatalf("bad: %#v", kmsConn.grants)
+	}
+	if len(ec2Conns["us-east-1"].snapshotPermissions) != 2 {
+		t.Fatalf("bad: %#v", ec2Conns["us-east-1"].snapshotPermissions)
+	}
+}
+
+func TestStepShareAMI_awsManagedKey(t *testing.T) {
+	ec2Conns := testShareEC2Conns()
+	kmsConn := &mockKMSConn{keyManager: kms.KeyManagerTypeAws}
+	step := testShareStep(ec2Conns, kmsConn)
+	state := testShareState()
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if len(kmsConn.grants) != 0 {
+		t.Fatalf("bad: %#v", kmsConn.grants)
+	}
+}
+
+func TestStepShareAMI_noAccounts(t *testing.T) {
+	step := &StepShareAMI{}
+	state := testShareState()
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepShareAMI_existingGrant(t *testing.T) {
+	ec2Conns := testShareEC2Conns()
+	kmsConn := &mockKMSConn{
+		keyManager: kms.KeyManagerTypeCustomer,
+		existing: []*kms.GrantListEntry{
+			{
+				KeyId:            aws.String("arn:aws:kms:us-east-1:111111111111:key/shared"),
+				GranteePrincipal: aws.String("arn:aws:iam::123456789012:root"),
+				Operations:       aws.StringSlice(shareGrantOperations),
+			},
+			{
+				// a grant of fewer operations is not enough
+				KeyId:            aws.String("arn:aws:kms:us-east-1:111111111111:key/shared"),
+				GranteePrincipal: aws.String("arn:aws:iam::210987654321:root"),
+				Operations:       aws.StringSlice([]string{kms.GrantOperationDecrypt}),
+			},
+		},
+	}
+	step := testShareStep(ec2Conns, kmsConn)
+	state := testShareState()
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %s", action, state.Get("error"))
+	}
+
+	var grants []string
+	for _, g := range kmsConn.grants {
+		grants = append(grants, *g.KeyId+" "+*g.GranteePrincipal)
+	}
+	sort.Strings(grants)
+	expected := []string{
+		"arn:aws:kms:eu-west-1:111111111111:key/shared arn:aws:iam::123456789012:root",
+		"arn:aws:kms:eu-west-1:111111111111:key/shared arn:aws:iam::210987654321:root",
+		"arn:aws:kms:us-east-1:111111111111:key/shared arn:aws:iam::210987654321:root",
+	}
+	if !reflect.DeepEqual(grants, expected) {
+		t.Fatalf("bad: %#v", grants)
+	}
+}
+
+func TestStepShareAMI_cleanup(t *testing.T) {
+	ec2Conns := testShareEC2Conns()
+	kmsConn := &mockKMSConn{keyManager: kms.KeyManagerTypeCustomer}
+	step := testShareStep(ec2Conns, kmsConn)
+	state := testShareState()
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %s", action, state.Get("error"))
+	}
+
+	// The grants of a successful build are kept
+	step.Cleanup(state)
+	if len(kmsConn.retired) != 0 {
+		t.Fatalf("bad: %#v", kmsConn.retired)
+	}
+
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+	if len(kmsConn.retired) != 4 {
+		t.Fatalf("bad: %#v", kmsConn.retired)
+	}
+	for i, r := range kmsConn.retired {
+		if r != *kmsConn.grants[i].KeyId+" "+fmt.Sprintf("grant-%d", i+1) {
+			t.Fatalf("bad: %#v", kmsConn.retired)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	awscommon "github.com/hashicorp/packer/builder/amazon/common"
 	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/helper/communicator"
@@ -126,6 +127,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	}
 
 	ec2conn := ec2.New(session)
+	ssmconn := ssm.New(session)
 
 	// Setup the state bag and initial state for the steps
 	state := new(multistep.BasicStateBag)
@@ -216,6 +218,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			SecurityGroupIds:       b.config.SecurityGroupIds,
 			CommConfig:             &b.config.RunConfig.Comm,
 			TemporarySGSourceCidrs: b.config.TemporarySGSourceCidrs,
+			SkipSSHRuleCreation:    b.config.SSHInterface == "session_manager",
 		},
 		&awscommon.StepCleanupVolumes{
 			LaunchMappings: b.config.LaunchMappings,
@@ -227,6 +230,14 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Timeout:   b.config.WindowsPasswordTimeout,
 			BuildName: b.config.PackerBuildName,
 		},
+		&awscommon.StepCreateSSMTunnel{
+			Skip:             b.config.SSHInterface != "session_manager",
+			SSMClient:        ssmconn,
+			Plugin:           &awscommon.SessionManagerPluginCmd{Endpoint: ssmconn.Endpoint},
+			Region:           *ec2conn.Config.Region,
+			LocalPortNumber:  b.config.SessionManagerPort,
+			RemotePortNumber: b.config.Comm.Port(),
+		},
 		&communicator.StepConnect{
 			Config: &b.config.RunConfig.Comm,
 			Host: awscommon.SSHHost(
@@ -235,6 +246,8 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 				b.config.Comm.SSHHost,
 			),
 			SSHConfig: b.config.RunConfig.Comm.SSHConfigFunc(),
+			SSHPort:   awscommon.Port(b.config.SSHInterface, b.config.Comm.Port()),
+			WinRMPort: awscommon.Port(b.config.SSHInterface, b.config.Comm.Port()),
 		},
 		&common.StepProvision{},
 		&common.StepCleanupTempKeys{
@@ -268,6 +281,10 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			OriginalRegion:     *ec2conn.Config.Region,
 			AMISkipBuildRegion: b.config.AMISkipBuildRegion,
 		},
+		&awscommon.StepShareAMI{
+			AccessConfig: &b.config.AccessConfig,
+			Share:        b.config.AMIShare,
+		},
 		&awscommon.StepModifyAMIAttributes{
 			Description:    b.config.AMIDescription,
 			Users:          b.config.AMIUsers,
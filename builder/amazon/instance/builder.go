@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	awscommon "github.com/hashicorp/packer/builder/amazon/common"
 	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/helper/communicator"
@@ -229,6 +230,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		return nil, err
 	}
 	ec2conn := ec2.New(session)
+	ssmconn := ssm.New(session)
 
 	// Setup the state bag and initial state for the steps
 	state := new(multistep.BasicStateBag)
@@ -312,6 +314,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			SecurityGroupFilter:    b.config.SecurityGroupFilter,
 			SecurityGroupIds:       b.config.SecurityGroupIds,
 			TemporarySGSourceCidrs: b.config.TemporarySGSourceCidrs,
+			SkipSSHRuleCreation:    b.config.SSHInterface == "session_manager",
 		},
 		instanceStep,
 		&awscommon.StepGetPassword{
@@ -320,6 +323,14 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Timeout:   b.config.WindowsPasswordTimeout,
 			BuildName: b.config.PackerBuildName,
 		},
+		&awscommon.StepCreateSSMTunnel{
+			Skip:             b.config.SSHInterface != "session_manager",
+			SSMClient:        ssmconn,
+			Plugin:           &awscommon.SessionManagerPluginCmd{Endpoint: ssmconn.Endpoint},
+			Region:           *ec2conn.Config.Region,
+			LocalPortNumber:  b.config.SessionManagerPort,
+			RemotePortNumber: b.config.Comm.Port(),
+		},
 		&communicator.StepConnect{
 			Config: &b.config.RunConfig.Comm,
 			Host: awscommon.SSHHost(
@@ -328,6 +339,8 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 				b.config.Comm.SSHHost,
 			),
 			SSHConfig: b.config.RunConfig.Comm.SSHConfigFunc(),
+			SSHPort:   awscommon.Port(b.config.SSHInterface, b.config.Comm.Port()),
+			WinRMPort: awscommon.Port(b.config.SSHInterface, b.config.Comm.Port()),
 		},
 		&common.StepProvision{},
 		&common.StepCleanupTempKeys{
@@ -360,6 +373,10 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Name:              b.config.AMIName,
 			OriginalRegion:    *ec2conn.Config.Region,
 		},
+		&awscommon.StepShareAMI{
+			AccessConfig: &b.config.AccessConfig,
+			Share:        b.config.AMIShare,
+		},
 		&awscommon.StepModifyAMIAttributes{
 			Description:    b.config.AMIDescription,
 			Users:          b.config.AMIUsers,
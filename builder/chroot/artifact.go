@@ -0,0 +1,36 @@
+package chroot
+
+import (
+	"fmt"
+	"os"
+)
+
+// Artifact is the provisioned image in the output directory.
+type Artifact struct {
+	dir string
+	f   []string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return a.f
+}
+
+func (*Artifact) Id() string {
+	return "Image"
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("Image files in directory: %s", a.dir)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return nil
+}
+
+func (a *Artifact) Destroy() error {
+	return os.RemoveAll(a.dir)
+}
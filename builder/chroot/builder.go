@@ -0,0 +1,313 @@
+//go:generate struct-markdown
+
+// The chroot package is able to provision a local disk image without booting
+// a virtual machine. It does this by attaching the image to a loop device,
+// mounting its root partition and chrooting into that directory.
+package chroot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// The unique ID for this builder
+const BuilderId = "packer.chroot"
+
+// Config is the configuration that is chained through the steps and settable
+// from the template.
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// Path to a local raw or qcow2 disk image, for example the output of the
+	// QEMU builder. The image is copied into output_directory and the copy
+	// is provisioned, so the source image is left untouched. Either
+	// source_image or device_path must be set.
+	SourceImage string `mapstructure:"source_image" required:"true"`
+	// The format of source_image, raw or qcow2. By default, it is detected
+	// from the content of the image.
+	SourceImageFormat string `mapstructure:"source_image_format" required:"false"`
+	// The path to a block device, such as a loop device attached beforehand,
+	// which is provisioned in place instead of a copy of source_image. No
+	// artifact is produced in this mode.
+	DevicePath string `mapstructure:"device_path" required:"false"`
+	// The format of the resulting image, raw or qcow2. Defaults to the format
+	// of source_image. Converting to or from qcow2 requires qemu-img.
+	Format string `mapstructure:"format" required:"false"`
+	// The name of the resulting image file in output_directory. Defaults to
+	// packer-BUILDNAME.FORMAT, where BUILDNAME is the name of the build.
+	ImageName string `mapstructure:"image_name" required:"false"`
+	// This is the path to the directory where the resulting image will be
+	// created. This defaults to output-BUILDNAME where BUILDNAME is the name
+	// of the build.
+	OutputDir string `mapstructure:"output_directory" required:"false"`
+	// This is a list of devices to mount into the chroot environment. This
+	// configuration parameter requires some additional documentation which is
+	// in the Chroot Mounts section. Please read that section for more
+	// information on how to use this.
+	ChrootMounts [][]string `mapstructure:"chroot_mounts" required:"false"`
+	// How to run shell commands. This defaults to {{.Command}}. This may be
+	// useful to set if you want to set environmental variables or perhaps run
+	// it with sudo or so on. This is a configuration template where the
+	// .Command variable is replaced with the command to be run. Defaults to
+	// {{.Command}}.
+	CommandWrapper string `mapstructure:"command_wrapper" required:"false"`
+	// Paths to files on the host that will be copied into the chroot
+	// environment prior to provisioning. Defaults to /etc/resolv.conf so that
+	// DNS lookups work. Pass an empty list to skip copying /etc/resolv.conf.
+	// You may need to do this if you're building an image that uses systemd.
+	CopyFiles []string `mapstructure:"copy_files" required:"false"`
+	// Options to supply the mount command when mounting devices. Each option
+	// will be prefixed with -o and supplied to the mount command ran by
+	// Packer. Because this command is ran in a shell, user discretion is
+	// advised. See this manual page for the mount command for valid file
+	// system specific options.
+	MountOptions []string `mapstructure:"mount_options" required:"false"`
+	// The partition number containing the / partition. By default this is the
+	// first partition of the image, (for example, loop0p1) but you can
+	// designate the entire block device by setting "mount_partition": "0" in
+	// your config, which will mount loop0 instead.
+	MountPartition string `mapstructure:"mount_partition" required:"false"`
+	// The path where the image will be mounted. This is where the chroot
+	// environment will be. This defaults to
+	// /mnt/packer-chroot/{{.Device}}. This is a configuration template where
+	// the .Device variable is replaced with the name of the device where the
+	// image is attached.
+	MountPath string `mapstructure:"mount_path" required:"false"`
+	// As pre_mount_commands, but the commands are executed after mounting the
+	// root device and before the extra mount and copy steps. The device and
+	// mount path are provided by {{.Device}} and {{.MountPath}}.
+	PostMountCommands []string `mapstructure:"post_mount_commands" required:"false"`
+	// A series of commands to execute after attaching the image and before
+	// mounting the chroot, for example to grow the root partition. The path
+	// to the device is provided by {{.Device}}.
+	PreMountCommands []string `mapstructure:"pre_mount_commands" required:"false"`
+
+	ctx interpolate.Context
+}
+
+type wrappedCommandTemplate struct {
+	Command string
+}
+
+type Builder struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	err := config.Decode(&b.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &b.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"command_wrapper",
+				"post_mount_commands",
+				"pre_mount_commands",
+				"mount_path",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Defaults
+	if len(b.config.ChrootMounts) == 0 {
+		b.config.ChrootMounts = [][]string{
+			{"proc", "proc", "/proc"},
+			{"sysfs", "sysfs", "/sys"},
+			{"bind", "/dev", "/dev"},
+			{"devpts", "devpts", "/dev/pts"},
+			{"binfmt_misc", "binfmt_misc", "/proc/sys/fs/binfmt_misc"},
+		}
+	}
+
+	// set default copy file if we're not giving our own
+	if b.config.CopyFiles == nil {
+		b.config.CopyFiles = []string{"/etc/resolv.conf"}
+	}
+
+	if b.config.CommandWrapper == "" {
+		b.config.CommandWrapper = "{{.Command}}"
+	}
+
+	if b.config.MountPath == "" {
+		b.config.MountPath = "/mnt/packer-chroot/{{.Device}}"
+	}
+
+	if b.config.MountPartition == "" {
+		b.config.MountPartition = "1"
+	}
+
+	if b.config.OutputDir == "" {
+		b.config.OutputDir = fmt.Sprintf("output-%s", b.config.PackerBuildName)
+	}
+
+	// Accumulate any errors or warnings
+	var errs *packer.MultiError
+	var warns []string
+
+	for _, mounts := range b.config.ChrootMounts {
+		if len(mounts) != 3 {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("Each chroot_mounts entry should be three elements."))
+			break
+		}
+	}
+
+	if b.config.SourceImage != "" && b.config.DevicePath != "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("Only one of source_image or device_path can be set."))
+	} else if b.config.SourceImage != "" {
+		errs = packer.MultiErrorAppend(errs, b.config.prepareSourceImage()...)
+	} else if b.config.DevicePath == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("source_image or device_path is required."))
+	} else if b.config.Format != "" || b.config.ImageName != "" {
+		warns = append(warns, "format and image_name are unused when device_path is set")
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return warns, errs
+	}
+
+	return warns, nil
+}
+
+// prepareSourceImage validates source_image and sets the defaults of the
+// resulting image.
+func (c *Config) prepareSourceImage() []error {
+	var errs []error
+
+	format, _, err := chroot.ImageInfo(c.SourceImage)
+	if err != nil {
+		return append(errs, fmt.Errorf("Error reading source_image: %s", err))
+	}
+
+	switch c.SourceImageFormat {
+	case "":
+		c.SourceImageFormat = format
+	case "raw":
+	case "qcow2":
+		if format != "qcow2" {
+			errs = append(errs, fmt.Errorf("%s is not a qcow2 image", c.SourceImage))
+		}
+	default:
+		errs = append(errs, errors.New("source_image_format must be raw or qcow2"))
+	}
+
+	if c.Format == "" {
+		c.Format = c.SourceImageFormat
+	}
+	if c.Format != "raw" && c.Format != "qcow2" {
+		errs = append(errs, errors.New("format must be raw or qcow2"))
+	}
+
+	if c.ImageName == "" {
+		c.ImageName = fmt.Sprintf("packer-%s.%s", c.PackerBuildName, c.Format)
+	}
+
+	return errs
+}
+
+func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("The chroot builder only works on Linux environments.")
+	}
+
+	wrappedCommand := func(command string) (string, error) {
+		ictx := b.config.ctx
+		ictx.Data = &wrappedCommandTemplate{Command: command}
+		return interpolate.Render(b.config.CommandWrapper, &ictx)
+	}
+
+	// Setup the state bag and initial state for the steps
+	state := new(multistep.BasicStateBag)
+	state.Put("config", &b.config)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+	state.Put("wrappedCommand", chroot.CommandWrapper(wrappedCommand))
+
+	// Build the steps
+	var steps []multistep.Step
+
+	if b.config.SourceImage != "" {
+		steps = append(steps,
+			&common.StepOutputDir{
+				Force: b.config.PackerForce,
+				Path:  b.config.OutputDir,
+			},
+			&StepPrepareImage{},
+		)
+	}
+
+	steps = append(steps,
+		&StepAttachImage{},
+		&chroot.StepPreMountCommands{
+			Commands: b.config.PreMountCommands,
+			Ctx:      b.config.ctx,
+		},
+		&chroot.StepMountDevice{
+			MountOptions:   b.config.MountOptions,
+			MountPartition: b.config.MountPartition,
+			MountPath:      b.config.MountPath,
+			Ctx:            b.config.ctx,
+		},
+		&chroot.StepPostMountCommands{
+			Commands: b.config.PostMountCommands,
+			Ctx:      b.config.ctx,
+		},
+		&chroot.StepMountExtra{
+			ChrootMounts: b.config.ChrootMounts,
+		},
+		&chroot.StepCopyFiles{
+			Files: b.config.CopyFiles,
+		},
+		&chroot.StepChrootProvision{},
+		&chroot.StepEarlyCleanup{},
+	)
+
+	if b.config.SourceImage != "" {
+		steps = append(steps, &StepConvertImage{})
+	}
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner.Run(ctx, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	// The device is provisioned in place, there is no image to return
+	if b.config.SourceImage == "" {
+		return nil, nil
+	}
+
+	artifact := &Artifact{
+		dir: b.config.OutputDir,
+		f:   []string{filepath.Join(b.config.OutputDir, b.config.ImageName)},
+	}
+
+	return artifact, nil
+}
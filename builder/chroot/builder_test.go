@@ -0,0 +1,201 @@
+package chroot
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"device_path": "/dev/loop7",
+	}
+}
+
+// testImages writes a raw image of 3 MB and a qcow2 header of a 5 GB image.
+func testImages(t *testing.T) (string, string, string) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw := filepath.Join(dir, "disk.raw")
+	if err := ioutil.WriteFile(raw, make([]byte, 3*1024*1024), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	header := make([]byte, 104)
+	copy(header, "QFI\xfb")
+	binary.BigEndian.PutUint32(header[4:8], 3)
+	binary.BigEndian.PutUint64(header[24:32], 5*1024*1024*1024)
+	qcow2 := filepath.Join(dir, "disk.qcow2")
+	if err := ioutil.WriteFile(qcow2, header, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return dir, raw, qcow2
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("Builder should be a builder")
+	}
+}
+
+func TestBuilderPrepare_DevicePath(t *testing.T) {
+	b := &Builder{}
+	config := testConfig()
+
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.MountPath != "/mnt/packer-chroot/{{.Device}}" {
+		t.Fatalf("bad: %s", b.config.MountPath)
+	}
+	if b.config.MountPartition != "1" {
+		t.Fatalf("bad: %s", b.config.MountPartition)
+	}
+
+	// Test bad
+	delete(config, "device_path")
+	b = &Builder{}
+	_, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_SourceImage(t *testing.T) {
+	dir, raw, qcow2 := testImages(t)
+	defer os.RemoveAll(dir)
+
+	b := &Builder{}
+	config := map[string]interface{}{
+		"source_image":      raw,
+		"packer_build_name": "foo",
+	}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.SourceImageFormat != "raw" || b.config.Format != "raw" {
+		t.Fatalf("bad: %s, %s", b.config.SourceImageFormat, b.config.Format)
+	}
+	if b.config.ImageName != "packer-foo.raw" {
+		t.Fatalf("bad: %s", b.config.ImageName)
+	}
+	if b.config.OutputDir != "output-foo" {
+		t.Fatalf("bad: %s", b.config.OutputDir)
+	}
+
+	b = &Builder{}
+	config = map[string]interface{}{
+		"source_image": qcow2,
+		"format":       "raw",
+		"image_name":   "disk.img",
+	}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.SourceImageFormat != "qcow2" || b.config.Format != "raw" {
+		t.Fatalf("bad: %s, %s", b.config.SourceImageFormat, b.config.Format)
+	}
+	if b.config.ImageName != "disk.img" {
+		t.Fatalf("bad: %s", b.config.ImageName)
+	}
+}
+
+func TestBuilderPrepare_SourceImageErrors(t *testing.T) {
+	dir, raw, _ := testImages(t)
+	defer os.RemoveAll(dir)
+
+	cases := map[string]map[string]interface{}{
+		"missing image": {
+			"source_image": filepath.Join(dir, "missing.raw"),
+		},
+		"not qcow2": {
+			"source_image":        raw,
+			"source_image_format": "qcow2",
+		},
+		"bad source format": {
+			"source_image":        raw,
+			"source_image_format": "vmdk",
+		},
+		"bad format": {
+			"source_image": raw,
+			"format":       "vmdk",
+		},
+		"device_path": {
+			"source_image": raw,
+			"device_path":  "/dev/loop7",
+		},
+	}
+
+	for name, config := range cases {
+		b := &Builder{}
+		if _, err := b.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_ChrootMountsBadDefaults(t *testing.T) {
+	b := &Builder{}
+	config := testConfig()
+
+	config["chroot_mounts"] = [][]string{
+		{"bad"},
+	}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_CopyFiles(t *testing.T) {
+	b := &Builder{}
+	config := testConfig()
+
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Errorf("err: %s", err)
+	}
+
+	if len(b.config.CopyFiles) != 1 || b.config.CopyFiles[0] != "/etc/resolv.conf" {
+		t.Errorf("Was expecting default value for copy_files.")
+	}
+
+	b = &Builder{}
+	config["copy_files"] = []string{}
+	if _, err := b.Prepare(config); err != nil {
+		t.Errorf("err: %s", err)
+	}
+	if len(b.config.CopyFiles) > 0 {
+		t.Errorf("Was expecting no default value for copy_files. Found %v",
+			b.config.CopyFiles)
+	}
+}
@@ -0,0 +1,102 @@
+package chroot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepAttachImage attaches the image to a free loop device, scanning its
+// partition table. With device_path, the device is used as is.
+//
+// Produces:
+//   device string - The location where the image is attached.
+//   attach_cleanup CleanupFunc
+type StepAttachImage struct {
+	device string
+}
+
+func (s *StepAttachImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	if config.DevicePath != "" {
+		ui.Say(fmt.Sprintf("Using device %s...", config.DevicePath))
+		state.Put("device", config.DevicePath)
+		state.Put("attach_cleanup", s)
+		return multistep.ActionContinue
+	}
+
+	imagePath := state.Get("image_path").(string)
+
+	ui.Say("Attaching the image to a loop device...")
+	attachCommand, err := wrappedCommand(
+		fmt.Sprintf("losetup --find --show --partscan '%s'", imagePath))
+	if err != nil {
+		err := fmt.Errorf("Error creating attach command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd := chroot.ShellCommand(attachCommand)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		err := fmt.Errorf(
+			"Error attaching image: %s\nStderr: %s", err, stderr.String())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.device = strings.TrimSpace(stdout.String())
+	log.Printf("Image attached to %s", s.device)
+	ui.Message(fmt.Sprintf("Attached to %s", s.device))
+
+	state.Put("device", s.device)
+	state.Put("attach_cleanup", s)
+	return multistep.ActionContinue
+}
+
+func (s *StepAttachImage) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packer.Ui)
+	if err := s.CleanupFunc(state); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *StepAttachImage) CleanupFunc(state multistep.StateBag) error {
+	if s.device == "" {
+		return nil
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	ui.Say(fmt.Sprintf("Detaching loop device %s...", s.device))
+	detachCommand, err := wrappedCommand(fmt.Sprintf("losetup --detach %s", s.device))
+	if err != nil {
+		return fmt.Errorf("Error creating detach command: %s", err)
+	}
+
+	stderr := new(bytes.Buffer)
+	cmd := chroot.ShellCommand(detachCommand)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf(
+			"Error detaching loop device: %s\nStderr: %s", err, stderr.String())
+	}
+
+	s.device = ""
+	return nil
+}
@@ -0,0 +1,158 @@
+package chroot
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// mockCommands records the commands run by the steps, and replaces them
+// with the shell command of the first prefix they match, or with true.
+type mockCommands struct {
+	commands []string
+	results  map[string]string
+}
+
+func (m *mockCommands) wrap(command string) (string, error) {
+	m.commands = append(m.commands, command)
+	for prefix, result := range m.results {
+		if strings.HasPrefix(command, prefix) {
+			return result, nil
+		}
+	}
+	return "true", nil
+}
+
+func testState(config *Config, m *mockCommands) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("config", config)
+	state.Put("ui", &packer.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)})
+	state.Put("wrappedCommand", chroot.CommandWrapper(m.wrap))
+	return state
+}
+
+func TestAttachImageCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{}
+	raw = new(StepAttachImage)
+	if _, ok := raw.(chroot.Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestStepAttachImage(t *testing.T) {
+	m := &mockCommands{results: map[string]string{
+		"losetup --find": "echo /dev/loop3",
+	}}
+	state := testState(new(Config), m)
+	state.Put("image_path", "/tmp/disk.raw")
+
+	step := new(StepAttachImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	if device := state.Get("device"); device != "/dev/loop3" {
+		t.Fatalf("bad: %#v", device)
+	}
+
+	step.Cleanup(state)
+	expected := []string{
+		"losetup --find --show --partscan '/tmp/disk.raw'",
+		"losetup --detach /dev/loop3",
+	}
+	if !reflect.DeepEqual(m.commands, expected) {
+		t.Fatalf("bad: %#v", m.commands)
+	}
+
+	// the device is detached once
+	step.Cleanup(state)
+	if len(m.commands) != 2 {
+		t.Fatalf("bad: %#v", m.commands)
+	}
+}
+
+func TestStepAttachImage_devicePath(t *testing.T) {
+	m := new(mockCommands)
+	state := testState(&Config{DevicePath: "/dev/sdb"}, m)
+
+	step := new(StepAttachImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	if device := state.Get("device"); device != "/dev/sdb" {
+		t.Fatalf("bad: %#v", device)
+	}
+
+	// the device is not ours to detach
+	step.Cleanup(state)
+	if len(m.commands) != 0 {
+		t.Fatalf("bad: %#v", m.commands)
+	}
+}
+
+func TestStepAttachImage_attachFails(t *testing.T) {
+	m := &mockCommands{results: map[string]string{
+		"losetup --find": "echo 'no free loop device' >&2; exit 1",
+	}}
+	state := testState(new(Config), m)
+	state.Put("image_path", "/tmp/disk.raw")
+
+	step := new(StepAttachImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.(error).Error(), "no free loop device") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// nothing to detach
+	step.Cleanup(state)
+	if len(m.commands) != 1 {
+		t.Fatalf("bad: %#v", m.commands)
+	}
+}
+
+func TestStepAttachImage_detachFails(t *testing.T) {
+	m := &mockCommands{results: map[string]string{
+		"losetup --find":   "echo /dev/loop3",
+		"losetup --detach": "echo 'device is busy' >&2; exit 1",
+	}}
+	state := testState(new(Config), m)
+	state.Put("image_path", "/tmp/disk.raw")
+
+	step := new(StepAttachImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+
+	err := step.CleanupFunc(state)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "device is busy") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// the device is still attached, so the cleanup tries again
+	delete(m.results, "losetup --detach")
+	if err := step.CleanupFunc(state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{
+		"losetup --find --show --partscan '/tmp/disk.raw'",
+		"losetup --detach /dev/loop3",
+		"losetup --detach /dev/loop3",
+	}
+	if !reflect.DeepEqual(m.commands, expected) {
+		t.Fatalf("bad: %#v", m.commands)
+	}
+}
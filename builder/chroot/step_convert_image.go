@@ -0,0 +1,50 @@
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepConvertImage converts the provisioned raw image to the format of the
+// resulting image, once it is detached.
+type StepConvertImage struct{}
+
+func (s *StepConvertImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	imagePath := state.Get("image_path").(string)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	if config.Format == "raw" {
+		return multistep.ActionContinue
+	}
+
+	output := filepath.Join(config.OutputDir, config.ImageName)
+	ui.Say(fmt.Sprintf("Converting the image to %s...", config.Format))
+	ictx := config.ctx
+	ictx.Data = nil
+	command := fmt.Sprintf("qemu-img convert -f raw -O %s '%s' '%s'", config.Format, imagePath, output)
+	if err := chroot.RunLocalCommands([]string{command}, wrappedCommand, ictx, ui); err != nil {
+		err := fmt.Errorf("Error converting image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if err := os.Remove(imagePath); err != nil {
+		err := fmt.Errorf("Error removing raw image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepConvertImage) Cleanup(state multistep.StateBag) {}
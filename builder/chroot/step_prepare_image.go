@@ -0,0 +1,66 @@
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepPrepareImage copies the source image into the output directory as a
+// raw image, which can be attached to a loop device.
+//
+// Produces:
+//   image_path string - The path of the raw image to provision.
+type StepPrepareImage struct{}
+
+func (s *StepPrepareImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	source, err := filepath.Abs(config.SourceImage)
+	if err != nil {
+		err := fmt.Errorf("Error preparing image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// A qcow2 image is provisioned as a raw copy which is converted back
+	// by StepConvertImage.
+	imagePath := filepath.Join(config.OutputDir, config.ImageName)
+	if config.Format != "raw" {
+		imagePath += ".raw"
+	}
+
+	ui.Say(fmt.Sprintf("Copying %s image %s to %s...", config.SourceImageFormat, source, imagePath))
+	ictx := config.ctx
+	ictx.Data = nil
+	if err := chroot.RunLocalCommands([]string{copyImageCommand(config.SourceImageFormat, source, imagePath)},
+		wrappedCommand, ictx, ui); err != nil {
+		err := fmt.Errorf("Error preparing image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("image_path", imagePath)
+	return multistep.ActionContinue
+}
+
+func (s *StepPrepareImage) Cleanup(state multistep.StateBag) {
+	// The copy goes along with the output directory.
+}
+
+// copyImageCommand returns the command copying an image as a raw image,
+// keeping its holes.
+func copyImageCommand(format, source, dest string) string {
+	if format == "qcow2" {
+		return fmt.Sprintf("qemu-img convert -f qcow2 -O raw '%s' '%s'", source, dest)
+	}
+	return fmt.Sprintf("cp --sparse=always '%s' '%s'", source, dest)
+}
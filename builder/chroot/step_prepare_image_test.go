@@ -0,0 +1,113 @@
+package chroot
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestCopyImageCommand(t *testing.T) {
+	command := copyImageCommand("qcow2", "/tmp/disk.qcow2", "output/disk.raw")
+	if command != "qemu-img convert -f qcow2 -O raw '/tmp/disk.qcow2' 'output/disk.raw'" {
+		t.Fatalf("bad: %s", command)
+	}
+
+	command = copyImageCommand("raw", "/tmp/disk.raw", "output/disk.raw")
+	if command != "cp --sparse=always '/tmp/disk.raw' 'output/disk.raw'" {
+		t.Fatalf("bad: %s", command)
+	}
+}
+
+func TestStepPrepareImage(t *testing.T) {
+	dir, raw, _ := testImages(t)
+	defer os.RemoveAll(dir)
+
+	// the copy is run for real
+	m := &mockCommands{results: map[string]string{
+		"cp ": "cp '" + raw + "' '" + filepath.Join(dir, "packer.raw") + "'",
+	}}
+	config := &Config{
+		SourceImage:       raw,
+		SourceImageFormat: "raw",
+		Format:            "raw",
+		ImageName:         "packer.raw",
+		OutputDir:         dir,
+	}
+	state := testState(config, m)
+
+	step := new(StepPrepareImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	imagePath := state.Get("image_path").(string)
+	if imagePath != filepath.Join(dir, "packer.raw") {
+		t.Fatalf("bad: %s", imagePath)
+	}
+	if !reflect.DeepEqual(m.commands, []string{copyImageCommand("raw", raw, imagePath)}) {
+		t.Fatalf("bad: %#v", m.commands)
+	}
+	if _, err := ioutil.ReadFile(imagePath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestStepPrepareImage_qcow2(t *testing.T) {
+	m := new(mockCommands)
+	config := &Config{
+		SourceImage:       "/tmp/disk.qcow2",
+		SourceImageFormat: "qcow2",
+		Format:            "qcow2",
+		ImageName:         "packer.qcow2",
+		OutputDir:         "/tmp/output",
+	}
+	state := testState(config, m)
+
+	step := new(StepPrepareImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+
+	// the raw copy is converted back to qcow2 by StepConvertImage
+	imagePath := state.Get("image_path").(string)
+	if imagePath != "/tmp/output/packer.qcow2.raw" {
+		t.Fatalf("bad: %s", imagePath)
+	}
+	if !reflect.DeepEqual(m.commands, []string{copyImageCommand("qcow2", "/tmp/disk.qcow2", imagePath)}) {
+		t.Fatalf("bad: %#v", m.commands)
+	}
+}
+
+func TestStepPrepareImage_copyFails(t *testing.T) {
+	m := &mockCommands{results: map[string]string{
+		"cp ": "echo 'No space left on device' >&2; exit 1",
+	}}
+	config := &Config{
+		SourceImage:       "/tmp/disk.raw",
+		SourceImageFormat: "raw",
+		Format:            "raw",
+		ImageName:         "packer.raw",
+		OutputDir:         "/tmp/output",
+	}
+	state := testState(config, m)
+
+	step := new(StepPrepareImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.(error).Error(), "No space left on device") {
+		t.Fatalf("bad: %s", err)
+	}
+	if _, ok := state.GetOk("image_path"); ok {
+		t.Fatal("should not have an image path")
+	}
+}
@@ -60,9 +60,12 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	steps := []multistep.Step{
 		&stepPrepareConfig{},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:       b.config.HTTPDir,
+			HTTPContent:   b.config.HTTPContent,
+			HTTPPortMin:   b.config.HTTPPortMin,
+			HTTPPortMax:   b.config.HTTPPortMax,
+			HTTPTemplates: b.config.HTTPTemplates,
+			Ctx:           b.config.ctx,
 		},
 		&stepKeypair{
 			Debug:        b.config.PackerDebug,
@@ -75,6 +78,9 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Debug: b.config.PackerDebug,
 		},
 		&stepSetupNetworking{},
+		&common.StepWaitForHTTPCallback{
+			Timeout: b.config.WaitForHTTPCallback,
+		},
 		&stepDetachIso{},
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
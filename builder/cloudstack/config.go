@@ -172,6 +172,7 @@ func NewConfig(raws ...interface{}) (*Config, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"user_data",
+				"http_content",
 			},
 		},
 	}, raws...)
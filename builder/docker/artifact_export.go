@@ -3,16 +3,36 @@ package docker
 import (
 	"fmt"
 	"os"
+
+	"github.com/hashicorp/packer/helper/multistep"
 )
 
 // ExportArtifact is an Artifact implementation for when a container is
-// exported from docker into a single flat file.
+// exported from docker into a single flat file, or when the committed image
+// is exported as a docker-archive or OCI layout.
 type ExportArtifact struct {
-	path string
+	builderId string
+	path      string
+
+	// The digests of the exported image, nil for flat exports.
+	digests *imageDigests
 }
 
-func (*ExportArtifact) BuilderId() string {
-	return BuilderId
+// NewExportArtifact returns the artifact of the file written to path by the
+// builder builderId, with the digests put in state by StepExportImage.
+func NewExportArtifact(builderId string, path string, state multistep.StateBag) *ExportArtifact {
+	a := &ExportArtifact{builderId: builderId, path: path}
+	if digests, ok := state.GetOk("image_digests"); ok {
+		a.digests = digests.(*imageDigests)
+	}
+	return a
+}
+
+func (a *ExportArtifact) BuilderId() string {
+	if a.builderId == "" {
+		return BuilderId
+	}
+	return a.builderId
 }
 
 func (a *ExportArtifact) Files() []string {
@@ -27,7 +47,23 @@ func (a *ExportArtifact) String() string {
 	return fmt.Sprintf("Exported Docker file: %s", a.path)
 }
 
+// State exposes the digests of exported images: ImageDigest is the digest of
+// the OCI manifest, or the image ID for docker archives, ConfigDigest is the
+// digest of the image configuration and LayerDigests are the digests of the
+// uncompressed layers.
 func (a *ExportArtifact) State(name string) interface{} {
+	if a.digests == nil {
+		return nil
+	}
+
+	switch name {
+	case "ImageDigest":
+		return a.digests.Image
+	case "ConfigDigest":
+		return a.digests.Config
+	case "LayerDigests":
+		return a.digests.Layers
+	}
 	return nil
 }
 
@@ -9,3 +9,18 @@ import (
 func TestExportArtifact_impl(t *testing.T) {
 	var _ packer.Artifact = new(ExportArtifact)
 }
+
+func TestExportArtifact_State(t *testing.T) {
+	a := &ExportArtifact{path: "foo"}
+	if a.State("ImageDigest") != nil {
+		t.Fatal("rootfs exports have no digest")
+	}
+
+	a.digests = &imageDigests{Image: "sha256:a", Config: "sha256:b", Layers: []string{"sha256:c"}}
+	if a.State("ImageDigest") != "sha256:a" || a.State("ConfigDigest") != "sha256:b" {
+		t.Fatalf("bad: %#v", a.digests)
+	}
+	if layers := a.State("LayerDigests").([]string); len(layers) != 1 || layers[0] != "sha256:c" {
+		t.Fatalf("bad: %#v", layers)
+	}
+}
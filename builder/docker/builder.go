@@ -31,7 +31,14 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 }
 
 func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
-	driver := &DockerDriver{Ctx: &b.config.ctx, Ui: ui}
+	var driver Driver = &DockerDriver{Ctx: &b.config.ctx, Ui: ui}
+	if b.config.UseEngineAPI {
+		apiDriver, err := NewAPIDriver(ui, &b.config.ctx)
+		if err != nil {
+			return nil, err
+		}
+		driver = apiDriver
+	}
 	if err := driver.Verify(); err != nil {
 		return nil, err
 	}
@@ -67,8 +74,13 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		log.Print("[DEBUG] Container will be committed")
 		steps = append(steps, new(StepCommit))
 	} else if b.config.ExportPath != "" {
-		log.Printf("[DEBUG] Container will be exported to %s", b.config.ExportPath)
-		steps = append(steps, new(StepExport))
+		log.Printf("[DEBUG] Container will be exported to %s as %s",
+			b.config.ExportPath, b.config.ExportFormat)
+		if b.config.ExportFormat == ExportFormatRootfs {
+			steps = append(steps, new(StepExport))
+		} else {
+			steps = append(steps, new(StepCommit), new(StepExportImage))
+		}
 	} else {
 		return nil, errArtifactNotUsed
 	}
@@ -105,7 +117,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Driver:         driver,
 		}
 	} else {
-		artifact = &ExportArtifact{path: b.config.ExportPath}
+		artifact = NewExportArtifact(BuilderId, b.config.ExportPath, state)
 	}
 
 	return artifact, nil
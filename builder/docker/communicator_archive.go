@@ -0,0 +1,347 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// ArchiveCommunicator is a communicator that only talks to the Docker daemon:
+// files are copied as tar archives with the equivalent of `docker cp`, and
+// commands run with `docker exec`. Unlike Communicator, it needs no
+// directory shared with the container, so it works with remote daemons and
+// rootless setups.
+type ArchiveCommunicator struct {
+	ContainerID string
+	Driver      Driver
+	Config      *Config
+	EntryPoint  []string
+
+	// A local directory for the temporary files of uploads
+	HostDir string
+
+	lock sync.Mutex
+}
+
+var _ packer.Communicator = new(ArchiveCommunicator)
+
+func (c *ArchiveCommunicator) Start(ctx context.Context, remote *packer.RemoteCmd) error {
+	config := &ExecConfig{
+		Cmd:    append(append([]string{}, c.EntryPoint...), fmt.Sprintf("(%s)", remote.Command)),
+		User:   c.Config.ExecUser,
+		Tty:    c.Config.Pty,
+		Stdin:  remote.Stdin,
+		Stdout: remote.Stdout,
+		Stderr: remote.Stderr,
+	}
+
+	// Run the actual command in a goroutine so that Start doesn't block
+	go func() {
+		// For Docker, remote communication must be serialized since it
+		// only supports single execution.
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		exitStatus, err := c.Driver.ExecContainer(c.ContainerID, config)
+		if err != nil {
+			log.Printf("Error executing: %s", err)
+			exitStatus = 254
+		}
+		remote.SetExited(exitStatus)
+	}()
+
+	return nil
+}
+
+// Upload uploads a file to the container
+func (c *ArchiveCommunicator) Upload(dst string, src io.Reader, fi *os.FileInfo) error {
+	if fi == nil {
+		// The size of the file must be known to write the archive
+		tempfile, err := ioutil.TempFile(c.HostDir, "upload")
+		if err != nil {
+			return fmt.Errorf("Failed to open temp file for writing: %s", err)
+		}
+		defer os.Remove(tempfile.Name())
+		defer tempfile.Close()
+
+		if _, err := io.Copy(tempfile, src); err != nil {
+			return fmt.Errorf("Failed to copy upload file to tempfile: %s", err)
+		}
+		tempfile.Seek(0, 0)
+		info, err := tempfile.Stat()
+		if err != nil {
+			return fmt.Errorf("Error getting tempfile info: %s", err)
+		}
+		src, fi = tempfile, &info
+	}
+
+	header, err := tar.FileInfoHeader(*fi, "")
+	if err != nil {
+		return err
+	}
+	header.Name = path.Base(dst)
+
+	log.Printf("Copying to %s on container %s.", dst, c.ContainerID)
+	return c.uploadArchive(path.Dir(dst), func(tw *tar.Writer) error {
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, src)
+		return err
+	})
+}
+
+// UploadDir uploads the directory src to dst. As with the other
+// communicators, the content of src is uploaded into dst when src ends with
+// a slash, and src itself is uploaded into dst otherwise. The missing
+// directories of dst are created.
+func (c *ArchiveCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	// The archive is extracted in the parent directory of dst, so that dst
+	// is created if needed.
+	dir, prefix := path.Dir(dst), path.Base(dst)
+	if dst == "/" {
+		dir, prefix = "/", ""
+	}
+	if !strings.HasSuffix(src, "/") {
+		prefix = path.Join(prefix, filepath.Base(src))
+	}
+
+	log.Printf("Copying directory %s to %s on container %s.", src, dst, c.ContainerID)
+	return c.uploadArchive(dir, func(tw *tar.Writer) error {
+		return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(src, p)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				return nil
+			}
+			rel = filepath.ToSlash(rel)
+			if excluded(rel, exclude) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			var link string
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(p); err != nil {
+					return err
+				}
+			}
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = path.Join(prefix, rel)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+	})
+}
+
+// uploadArchive streams the archive written by write to the dst directory of
+// the container.
+func (c *ArchiveCommunicator) uploadArchive(dst string, write func(*tar.Writer) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := write(tw)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err := c.Driver.UploadArchive(c.ContainerID, dst, pr, c.Config.FixUploadOwner)
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("Failed to upload to '%s' in container: %s", dst, err)
+	}
+	return nil
+}
+
+// Download downloads the file src of the container.
+func (c *ArchiveCommunicator) Download(src string, dst io.Writer) error {
+	log.Printf("Downloading file from container: %s:%s", c.ContainerID, src)
+	return c.downloadArchive(src, func(tr *tar.Reader) error {
+		header, err := tr.Next()
+		if err != nil {
+			return fmt.Errorf("Failed to read header from tar stream: %s", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			return fmt.Errorf("%s is a directory", src)
+		}
+		_, err = io.Copy(dst, tr)
+		return err
+	})
+}
+
+// DownloadDir downloads the content of the directory src of the container
+// into the local directory dst.
+func (c *ArchiveCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	log.Printf("Downloading directory from container: %s:%s", c.ContainerID, src)
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return err
+	}
+	return c.downloadArchive(src, func(tr *tar.Reader) error {
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			// The entries are in a directory named after src
+			name := path.Clean(header.Name)
+			i := strings.Index(name, "/")
+			if i == -1 {
+				continue
+			}
+			rel := name[i+1:]
+			if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+				log.Printf("Skipping %s, outside of the directory", header.Name)
+				continue
+			}
+			if excluded(rel, exclude) {
+				continue
+			}
+
+			// The symbolic links of the archive must not redirect the
+			// entries outside of dst.
+			p := filepath.Join(root, filepath.FromSlash(rel))
+			if err := checkInside(root, p); err != nil {
+				return err
+			}
+			mode := os.FileMode(header.Mode).Perm()
+			switch header.Typeflag {
+			case tar.TypeDir:
+				if err := os.MkdirAll(p, mode|0700); err != nil {
+					return err
+				}
+			case tar.TypeReg, tar.TypeRegA:
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					return err
+				}
+				if fi, err := os.Lstat(p); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+					if err := os.Remove(p); err != nil {
+						return err
+					}
+				}
+				f, err := os.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(f, tr)
+				f.Close()
+				if err != nil {
+					return err
+				}
+			case tar.TypeSymlink:
+				os.Remove(p)
+				if err := os.Symlink(header.Linkname, p); err != nil {
+					return err
+				}
+			default:
+				log.Printf("Skipping %s of type %c", name, header.Typeflag)
+			}
+		}
+	})
+}
+
+// checkInside returns an error when the deepest existing parent directory
+// of p, with its symbolic links resolved, is not in the directory root.
+func checkInside(root string, p string) error {
+	dir := filepath.Dir(p)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("Refusing to write %s outside of %s", p, root)
+	}
+	return nil
+}
+
+// downloadArchive streams the archive of src in the container to read.
+func (c *ArchiveCommunicator) downloadArchive(src string, read func(*tar.Reader) error) error {
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		err := c.Driver.DownloadArchive(c.ContainerID, src, pw)
+		pw.CloseWithError(err)
+		errCh <- err
+	}()
+
+	err := read(tar.NewReader(pr))
+	// drain the archive so that the download completes
+	io.Copy(ioutil.Discard, pr)
+	if dlErr := <-errCh; dlErr != nil {
+		return fmt.Errorf("Failed to download '%s' from container: %s", src, dlErr)
+	}
+	return err
+}
+
+// excluded returns true when the slash separated path rel, one of its
+// parent directories, or one of their base names matches one of the exclude
+// patterns.
+func excluded(rel string, exclude []string) bool {
+	for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+		for _, pattern := range exclude {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, path.Base(p)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testArchiveCommunicator(t *testing.T) (*ArchiveCommunicator, *MockDriver) {
+	driver := &MockDriver{}
+	config := testConfigStruct(t)
+	config.FixUploadOwner = true
+	return &ArchiveCommunicator{
+		ContainerID: "c0ffee",
+		Driver:      driver,
+		Config:      config,
+		EntryPoint:  []string{"/bin/sh", "-c"},
+	}, driver
+}
+
+// tarNames returns the names of the entries of a tarball.
+func tarNames(t *testing.T, b []byte) []string {
+	var names []string
+	for name := range readTestTar(t, bytes.NewReader(b)) {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestArchiveCommunicator_impl(t *testing.T) {
+	var _ packer.Communicator = new(ArchiveCommunicator)
+}
+
+func TestArchiveCommunicator_Start(t *testing.T) {
+	c, driver := testArchiveCommunicator(t)
+	c.Config.ExecUser = "app"
+	c.Config.Pty = true
+	driver.ExecStdout = "out"
+	driver.ExecExitStatus = 42
+
+	var stdout bytes.Buffer
+	cmd := &packer.RemoteCmd{Command: "false", Stdout: &stdout}
+	if err := c.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if status := cmd.Wait(); status != 42 {
+		t.Fatalf("bad exit status: %d", status)
+	}
+
+	if driver.ExecId != "c0ffee" || stdout.String() != "out" {
+		t.Fatalf("bad: %s %q", driver.ExecId, stdout.String())
+	}
+	expected := &ExecConfig{
+		Cmd:    []string{"/bin/sh", "-c", "(false)"},
+		User:   "app",
+		Tty:    true,
+		Stdout: &stdout,
+	}
+	if !reflect.DeepEqual(driver.ExecConfig, expected) {
+		t.Fatalf("bad: %#v", driver.ExecConfig)
+	}
+}
+
+func TestArchiveCommunicator_Upload(t *testing.T) {
+	c, driver := testArchiveCommunicator(t)
+
+	if err := c.Upload("/tmp/script.sh", strings.NewReader("echo hello"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if driver.UploadArchiveDst != "/tmp" || !driver.UploadArchiveFixOwner {
+		t.Fatalf("bad: %s %t", driver.UploadArchiveDst, driver.UploadArchiveFixOwner)
+	}
+	files := readTestTar(t, bytes.NewReader(driver.UploadArchiveData))
+	if !reflect.DeepEqual(files, map[string]string{"script.sh": "echo hello"}) {
+		t.Fatalf("bad: %#v", files)
+	}
+}
+
+func TestArchiveCommunicator_UploadDir(t *testing.T) {
+	src, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	os.MkdirAll(filepath.Join(src, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "sub", "file"), []byte("content"), 0644)
+	ioutil.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("master"), 0644)
+
+	cases := []struct {
+		src      string
+		dst      string
+		dir      string
+		expected []string
+	}{
+		// the content of src is uploaded into dst
+		{src + "/", "/srv/app", "/srv", []string{"app/sub/", "app/sub/file"}},
+		// src itself is uploaded into dst
+		{src, "/srv", "/", []string{"srv/" + filepath.Base(src) + "/sub/", "srv/" + filepath.Base(src) + "/sub/file"}},
+	}
+	for _, tc := range cases {
+		c, driver := testArchiveCommunicator(t)
+		if err := c.UploadDir(tc.dst, tc.src, []string{".git"}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if driver.UploadArchiveDst != tc.dir {
+			t.Fatalf("%s: bad: %s", tc.src, driver.UploadArchiveDst)
+		}
+		names := tarNames(t, driver.UploadArchiveData)
+		if len(names) != len(tc.expected) {
+			t.Fatalf("%s: bad: %#v", tc.src, names)
+		}
+		for _, name := range tc.expected {
+			found := false
+			for _, n := range names {
+				found = found || n == name
+			}
+			if !found {
+				t.Fatalf("%s: missing %s in %#v", tc.src, name, names)
+			}
+		}
+	}
+}
+
+func TestArchiveCommunicator_Download(t *testing.T) {
+	c, driver := testArchiveCommunicator(t)
+	driver.DownloadArchiveReader = bytes.NewReader(testTar(t, []testTarEntry{
+		{"hosts", "127.0.0.1 localhost"},
+	}))
+
+	var buf bytes.Buffer
+	if err := c.Download("/etc/hosts", &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if driver.DownloadArchiveSrc != "/etc/hosts" || buf.String() != "127.0.0.1 localhost" {
+		t.Fatalf("bad: %s %q", driver.DownloadArchiveSrc, buf.String())
+	}
+
+	// directories can not be downloaded as a file
+	c, driver = testArchiveCommunicator(t)
+	driver.DownloadArchiveReader = bytes.NewReader(testTar(t, []testTarEntry{{"etc/", ""}}))
+	if err := c.Download("/etc", &buf); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestArchiveCommunicator_DownloadDir(t *testing.T) {
+	dst, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dst)
+
+	c, driver := testArchiveCommunicator(t)
+	driver.DownloadArchiveReader = bytes.NewReader(testTar(t, []testTarEntry{
+		{"logs/", ""},
+		{"logs/build.log", "done"},
+		{"logs/tmp/", ""},
+		{"logs/tmp/cache", "skipped"},
+		{"logs/../../escape", "escaped"},
+	}))
+	if err := c.DownloadDir("/var/logs", dst, []string{"tmp"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dst, "build.log"))
+	if err != nil || string(b) != "done" {
+		t.Fatalf("bad: %s %s", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "tmp")); !os.IsNotExist(err) {
+		t.Fatalf("tmp should be excluded: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dst), "escape")); !os.IsNotExist(err) {
+		t.Fatalf("should not escape dst: %v", err)
+	}
+}
+
+func TestArchiveCommunicator_DownloadDirSymlink(t *testing.T) {
+	dst, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dst)
+	outside, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(outside)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	headers := []*tar.Header{
+		{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "logs/current", Typeflag: tar.TypeSymlink, Linkname: "build.log"},
+		{Name: "logs/evil", Typeflag: tar.TypeSymlink, Linkname: outside},
+		{Name: "logs/evil/pwned", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+	}
+	for _, hdr := range headers {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte("x"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c, driver := testArchiveCommunicator(t)
+	driver.DownloadArchiveReader = bytes.NewReader(buf.Bytes())
+	if err := c.DownloadDir("/var/logs", dst, nil); err == nil {
+		t.Fatal("should have error")
+	}
+
+	if link, err := os.Readlink(filepath.Join(dst, "current")); err != nil || link != "build.log" {
+		t.Fatalf("bad: %s %s", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); !os.IsNotExist(err) {
+		t.Fatalf("should not write outside of dst: %v", err)
+	}
+}
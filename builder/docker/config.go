@@ -3,8 +3,13 @@
 package docker
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/helper/communicator"
@@ -18,7 +23,20 @@ var (
 	errArtifactNotUsed     = fmt.Errorf("No instructions given for handling the artifact; expected commit, discard, or export_path")
 	errArtifactUseConflict = fmt.Errorf("Cannot specify more than one of commit, discard, and export_path")
 	errExportPathNotFile   = fmt.Errorf("export_path must be a file, not a directory")
+	errSquashNotExported   = fmt.Errorf("squash requires an export_format of docker-archive or oci")
 	errImageNotSpecified   = fmt.Errorf("Image must be specified")
+
+	exposedPortRe = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(/(tcp|udp|sctp))?$`)
+	platformRe    = regexp.MustCompile(`^[a-z0-9_]+/[a-z0-9_]+(/[a-z0-9_]+)?$`)
+)
+
+const (
+	ExportFormatRootfs        = "rootfs"
+	ExportFormatDockerArchive = "docker-archive"
+	ExportFormatOCI           = "oci"
+
+	FileTransferMount   = "mount"
+	FileTransferArchive = "archive"
 )
 
 type Config struct {
@@ -33,6 +51,28 @@ type Config struct {
 	Changes []string `mapstructure:"changes"`
 	// If true, the container will be committed to an image rather than exported.
 	Commit bool `mapstructure:"commit" required:"true"`
+	// Labels to set on the committed image, as with the `LABEL` Dockerfile
+	// instruction.
+	Labels map[string]string `mapstructure:"labels" required:"false"`
+	// Environment variables to set in the committed image, as with the `ENV`
+	// Dockerfile instruction.
+	Env map[string]string `mapstructure:"env" required:"false"`
+	// The entrypoint of the committed image, in exec form. Example:
+	// `["/usr/bin/app", "--config", "/etc/app.conf"]`. This replaces the
+	// entrypoint set by `run_command`.
+	Entrypoint []string `mapstructure:"entrypoint" required:"false"`
+	// The default arguments of the committed image, in exec form, as with
+	// the `CMD` Dockerfile instruction.
+	Cmd []string `mapstructure:"cmd" required:"false"`
+	// Ports exposed by the committed image, as with the `EXPOSE` Dockerfile
+	// instruction. Example: `["80", "8080/tcp", "53/udp"]`.
+	ExposedPorts []string `mapstructure:"exposed_ports" required:"false"`
+	// The user (and optionally group) the committed image runs as, as with
+	// the `USER` Dockerfile instruction.
+	User string `mapstructure:"user" required:"false"`
+	// The working directory of the committed image, as with the `WORKDIR`
+	// Dockerfile instruction.
+	WorkDir string `mapstructure:"workdir" required:"false"`
 
 	// The directory inside container to mount temp directory from host server
 	// for work [file provisioner](/docs/provisioners/file.html). This defaults
@@ -42,17 +82,39 @@ type Config struct {
 	// the [artifice
 	// post-processor](https://www.packer.io/docs/post-processors/artifice.html).
 	Discard bool `mapstructure:"discard" required:"true"`
+	// How the `docker` communicator transfers files. With `mount`, the
+	// default, a temporary directory of the host is mounted in the container
+	// at `container_dir`. With `archive`, files are copied as tar archives
+	// through the Docker daemon, as `docker cp` does, and nothing is mounted:
+	// use it when the Docker daemon is remote or rootless. `archive` is not
+	// supported for Windows containers. Defaults to `archive`, the only
+	// supported value, with `use_engine_api`.
+	FileTransfer string `mapstructure:"file_transfer" required:"false"`
 	// Username (UID) to run remote commands with. You can also set the group
 	// name/ID if you want: (UID or UID:GID). You may need this if you get
 	// permission errors trying to run the shell or other provisioners.
 	ExecUser string `mapstructure:"exec_user" required:"false"`
 	// The path where the final container will be exported as a tar file.
 	ExportPath string `mapstructure:"export_path" required:"true"`
+	// The format of the file written to `export_path`. `rootfs`, the
+	// default, is the flat tarball of the container filesystem made by
+	// `docker export`. `docker-archive` is an image that can be loaded with
+	// `docker load`, and `oci` is the tarball of an [OCI image
+	// layout](https://github.com/opencontainers/image-spec/blob/master/image-layout.md).
+	// Both image formats keep the metadata of the image, like `changes`.
+	ExportFormat string `mapstructure:"export_format" required:"false"`
 	// The base image for the Docker container that will be started. This image
 	// will be pulled from the Docker registry if it doesn't already exist.
 	Image string `mapstructure:"image" required:"true"`
 	// Set a message for the commit.
 	Message string `mapstructure:"message" required:"true"`
+	// The platform of the image to pull and run, in the `os/arch[/variant]`
+	// format, for example `linux/arm64`. The committed image has the
+	// platform of the container. Building for another architecture than the
+	// one of the host requires QEMU to be registered with `binfmt_misc`, and
+	// the `--platform` flag of Docker, which is experimental before Docker
+	// 20.10.
+	Platform string `mapstructure:"platform" required:"false"`
 	// If true, run the docker container with the `--privileged` flag. This
 	// defaults to false if not set.
 	Privileged bool `mapstructure:"privileged" required:"false"`
@@ -74,6 +136,11 @@ type Config struct {
 	// docker image embeds a binary intended to be run often, you should
 	// consider changing the default entrypoint to point to it.
 	RunCommand []string `mapstructure:"run_command" required:"false"`
+	// If true, the layers added on top of the base image are merged into a
+	// single layer holding the changes made by the provisioners, while the
+	// layers of the base image are kept. This requires an `export_format` of
+	// `docker-archive` or `oci`. Defaults to false.
+	Squash bool `mapstructure:"squash" required:"false"`
 	// A mapping of additional volumes to mount into this container. The key of
 	// the object is the host path, the value is the container path.
 	Volumes map[string]string `mapstructure:"volumes" required:"false"`
@@ -81,6 +148,13 @@ type Config struct {
 	// container is running as. If false, the owner will depend on the version
 	// of docker installed in the system. Defaults to true.
 	FixUploadOwner bool `mapstructure:"fix_upload_owner" required:"false"`
+	// If true, Packer talks to the Docker daemon with the Docker Engine API
+	// instead of running the `docker` command. The daemon is found at
+	// `DOCKER_HOST`, which defaults to `unix:///var/run/docker.sock`, and
+	// `DOCKER_TLS_VERIFY` and `DOCKER_CERT_PATH` configure TLS as they do
+	// for the `docker` command. Only the common `docker run` flags are
+	// supported in `run_command`. Defaults to false.
+	UseEngineAPI bool `mapstructure:"use_engine_api" required:"false"`
 	// If "true", tells Packer that you are building a Windows container
 	// running on a windows host. This is necessary for building Windows
 	// containers, because our normal docker bindings do not work for them.
@@ -126,6 +200,17 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		return nil, nil, err
 	}
 
+	if errs := c.Prepare(&c.ctx, md.Keys); errs != nil {
+		return nil, nil, errs
+	}
+
+	return c, nil, nil
+}
+
+// Prepare sets the defaults of a decoded configuration and validates it.
+// keys are the keys set in the template. It is exported for the builders
+// reusing the configuration of the Docker builder.
+func (c *Config) Prepare(ctx *interpolate.Context, keys []string) error {
 	// Defaults
 	if len(c.RunCommand) == 0 {
 		c.RunCommand = []string{"-d", "-i", "-t", "--entrypoint=/bin/sh", "--", "{{.Image}}"}
@@ -136,7 +221,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 
 	// Default Pull if it wasn't set
 	hasPull := false
-	for _, k := range md.Keys {
+	for _, k := range keys {
 		if k == "pull" {
 			hasPull = true
 			break
@@ -156,7 +241,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	}
 
 	var errs *packer.MultiError
-	if es := c.Comm.Prepare(&c.ctx); len(es) > 0 {
+	if es := c.Comm.Prepare(ctx); len(es) > 0 {
 		errs = packer.MultiErrorAppend(errs, es...)
 	}
 	if c.Image == "" {
@@ -177,6 +262,45 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		}
 	}
 
+	switch c.FileTransfer {
+	case "":
+		c.FileTransfer = FileTransferMount
+		if c.UseEngineAPI {
+			c.FileTransfer = FileTransferArchive
+		}
+	case FileTransferMount:
+		if c.UseEngineAPI {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"file_transfer mount is not supported with use_engine_api, "+
+					"as the Docker daemon may be remote"))
+		}
+	case FileTransferArchive:
+		if c.WindowsContainer {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"file_transfer archive is not supported for Windows containers"))
+		}
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"Invalid file_transfer %q, expected mount or archive", c.FileTransfer))
+	}
+
+	switch c.ExportFormat {
+	case "":
+		c.ExportFormat = ExportFormatRootfs
+	case ExportFormatRootfs, ExportFormatDockerArchive, ExportFormatOCI:
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"Invalid export_format %q, expected rootfs, docker-archive or oci", c.ExportFormat))
+	}
+	if c.Squash {
+		if c.ExportPath == "" || c.ExportFormat == ExportFormatRootfs {
+			errs = packer.MultiErrorAppend(errs, errSquashNotExported)
+		}
+		if c.WindowsContainer {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("squash is not supported for Windows containers"))
+		}
+	}
+
 	if c.ContainerDir == "" {
 		if c.WindowsContainer {
 			c.ContainerDir = "c:/packer-files"
@@ -185,13 +309,79 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		}
 	}
 
+	for k := range c.Labels {
+		if k == "" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("labels keys must not be empty"))
+		}
+	}
+	for k := range c.Env {
+		if k == "" || strings.ContainsAny(k, "= \t") {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Invalid env variable name %q", k))
+		}
+	}
+	for _, p := range c.ExposedPorts {
+		if !exposedPortRe.MatchString(p) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Invalid exposed port %q, expected PORT[/PROTOCOL]", p))
+		}
+	}
+	if c.Platform != "" && !platformRe.MatchString(c.Platform) {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("Invalid platform %q, expected os/arch[/variant]", c.Platform))
+	}
+
 	if c.EcrLogin && c.LoginServer == "" {
 		errs = packer.MultiErrorAppend(errs, fmt.Errorf("ECR login requires login server to be provided."))
 	}
 
 	if errs != nil && len(errs.Errors) > 0 {
-		return nil, nil, errs
+		return errs
 	}
 
-	return c, nil, nil
+	return nil
+}
+
+// CommitChanges returns the Dockerfile instructions applied to the committed
+// image: the ones compiled from the structured fields, followed by the raw
+// `changes` which can override them.
+func (c *Config) CommitChanges() []string {
+	var changes []string
+
+	for _, k := range sortedKeys(c.Labels) {
+		changes = append(changes, fmt.Sprintf("LABEL %s=%s", strconv.Quote(k), strconv.Quote(c.Labels[k])))
+	}
+	for _, k := range sortedKeys(c.Env) {
+		changes = append(changes, fmt.Sprintf("ENV %s=%s", k, strconv.Quote(c.Env[k])))
+	}
+	if len(c.Entrypoint) > 0 {
+		changes = append(changes, "ENTRYPOINT "+execForm(c.Entrypoint))
+	}
+	if len(c.Cmd) > 0 {
+		changes = append(changes, "CMD "+execForm(c.Cmd))
+	}
+	if len(c.ExposedPorts) > 0 {
+		changes = append(changes, "EXPOSE "+strings.Join(c.ExposedPorts, " "))
+	}
+	if c.User != "" {
+		changes = append(changes, "USER "+c.User)
+	}
+	if c.WorkDir != "" {
+		changes = append(changes, "WORKDIR "+c.WorkDir)
+	}
+
+	return append(changes, c.Changes...)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// execForm formats args as the JSON array of the exec form of Dockerfile
+// instructions.
+func execForm(args []string) string {
+	b, _ := json.Marshal(args)
+	return string(b)
 }
@@ -3,6 +3,7 @@ package docker
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -141,3 +142,138 @@ func TestConfigPrepare_pull(t *testing.T) {
 		t.Fatal("should not pull")
 	}
 }
+
+func TestConfigPrepare_metadata(t *testing.T) {
+	raw := testConfig()
+	raw["labels"] = map[string]string{
+		"version":     "1.0",
+		"description": `a "quoted" value`,
+	}
+	raw["env"] = map[string]string{"PATH": "/opt/app/bin:/usr/bin", "HOME": "/home/app"}
+	raw["entrypoint"] = []string{"/usr/bin/app", "--config", "/etc/app.conf"}
+	raw["cmd"] = []string{"serve"}
+	raw["exposed_ports"] = []string{"80", "8000-8010/tcp", "53/udp"}
+	raw["user"] = "app"
+	raw["workdir"] = "/srv"
+	raw["changes"] = []string{"VOLUME /data"}
+
+	c, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+
+	expected := []string{
+		`LABEL "description"="a \"quoted\" value"`,
+		`LABEL "version"="1.0"`,
+		`ENV HOME="/home/app"`,
+		`ENV PATH="/opt/app/bin:/usr/bin"`,
+		`ENTRYPOINT ["/usr/bin/app","--config","/etc/app.conf"]`,
+		`CMD ["serve"]`,
+		`EXPOSE 80 8000-8010/tcp 53/udp`,
+		`USER app`,
+		`WORKDIR /srv`,
+		`VOLUME /data`,
+	}
+	if changes := c.CommitChanges(); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("bad changes:\n%#v\nexpected:\n%#v", changes, expected)
+	}
+}
+
+func TestConfigPrepare_metadataInvalid(t *testing.T) {
+	tests := map[string]interface{}{
+		"labels":        map[string]string{"": "value"},
+		"env":           map[string]string{"A=B": "value"},
+		"exposed_ports": []string{"http"},
+		"platform":      "arm64",
+	}
+	for k, v := range tests {
+		raw := testConfig()
+		raw[k] = v
+		_, warns, errs := NewConfig(raw)
+		if errs == nil {
+			t.Fatalf("%s: should error", k)
+		}
+		testConfigErr(t, warns, errs)
+	}
+
+	raw := testConfig()
+	raw["platform"] = "linux/arm/v7"
+	_, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+}
+
+func TestConfigPrepare_exportFormat(t *testing.T) {
+	raw := testConfig()
+	c, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if c.ExportFormat != ExportFormatRootfs {
+		t.Fatalf("bad: %s", c.ExportFormat)
+	}
+
+	for _, format := range []string{"docker-archive", "oci"} {
+		raw := testConfig()
+		raw["export_format"] = format
+		raw["squash"] = true
+		_, warns, errs := NewConfig(raw)
+		testConfigOk(t, warns, errs)
+	}
+
+	raw = testConfig()
+	raw["export_format"] = "qcow2"
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+}
+
+func TestConfigPrepare_squash(t *testing.T) {
+	// Rootfs exports have no layers
+	raw := testConfig()
+	raw["squash"] = true
+	_, warns, errs := NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// Neither have committed images
+	raw = testConfig()
+	delete(raw, "export_path")
+	raw["commit"] = true
+	raw["squash"] = true
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+}
+
+func TestConfigPrepare_fileTransfer(t *testing.T) {
+	raw := testConfig()
+	c, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if c.FileTransfer != FileTransferMount {
+		t.Fatalf("bad: %s", c.FileTransfer)
+	}
+
+	raw = testConfig()
+	raw["file_transfer"] = "archive"
+	_, warns, errs = NewConfig(raw)
+	testConfigOk(t, warns, errs)
+
+	raw = testConfig()
+	raw["use_engine_api"] = true
+	c, warns, errs = NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if c.FileTransfer != FileTransferArchive {
+		t.Fatalf("bad: %s", c.FileTransfer)
+	}
+
+	raw = testConfig()
+	raw["use_engine_api"] = true
+	raw["file_transfer"] = "mount"
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	raw = testConfig()
+	raw["file_transfer"] = "ftp"
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// docker cp does not work with running Windows containers
+	raw = testConfig()
+	raw["file_transfer"] = "archive"
+	raw["windows_container"] = true
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+}
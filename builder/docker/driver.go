@@ -13,12 +13,27 @@ type Driver interface {
 	// Commit the container to a tag
 	Commit(id string, author string, changes []string, message string) (string, error)
 
+	// ContainerUser returns the user the container runs as.
+	ContainerUser(id string) (string, error)
+
 	// Delete an image that is imported into Docker
 	DeleteImage(id string) error
 
+	// DownloadArchive writes the tar archive of the file or directory at
+	// src in the container to dst.
+	DownloadArchive(id string, src string, dst io.Writer) error
+
+	// ExecContainer runs a command in a running container and returns its
+	// exit status.
+	ExecContainer(id string, config *ExecConfig) (int, error)
+
 	// Export exports the container with the given ID to the given writer.
 	Export(id string, dst io.Writer) error
 
+	// ImageLayers returns the digests of the uncompressed layers of an
+	// image, from the bottom one to the top one.
+	ImageLayers(id string) ([]string, error)
+
 	// Import imports a container from a tar file
 	Import(path string, changes []string, repo string) (string, error)
 
@@ -33,8 +48,9 @@ type Driver interface {
 	// Logout. This can only be called if Login succeeded.
 	Logout(repo string) error
 
-	// Pull should pull down the given image.
-	Pull(image string) error
+	// Pull should pull down the given image. When platform is set, the
+	// image of this platform is pulled.
+	Pull(image string, platform string) error
 
 	// Push pushes an image to a Docker index/registry.
 	Push(name string) error
@@ -55,6 +71,11 @@ type Driver interface {
 	// TagImage tags the image with the given ID
 	TagImage(id string, repo string, force bool) error
 
+	// UploadArchive extracts the tar archive read from src into the dst
+	// directory of the container. When fixOwner is true, the extracted files
+	// are owned by the user of the container.
+	UploadArchive(id string, dst string, src io.Reader, fixOwner bool) error
+
 	// Verify verifies that the driver can run
 	Verify() error
 
@@ -68,6 +89,21 @@ type ContainerConfig struct {
 	RunCommand []string
 	Volumes    map[string]string
 	Privileged bool
+	Platform   string
+}
+
+// ExecConfig is the configuration of a command run in a container.
+type ExecConfig struct {
+	Cmd  []string
+	User string
+
+	// Tty allocates a pseudo-TTY for the command, in which case its
+	// standard error is sent to Stdout.
+	Tty bool
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
 // This is the template that is used for the RunCommand in the ContainerConfig.
@@ -0,0 +1,813 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/go-homedir"
+)
+
+// The address of the Docker daemon when DOCKER_HOST is not set.
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// APIError is returned by the APIDriver when the Docker Engine API answers
+// a request with an error, or reports an error while streaming a response.
+type APIError struct {
+	// The HTTP status code of the response. It is 200 for errors reported
+	// in the middle of a stream, such as a failed pull.
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Docker Engine API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound returns true when err is an APIError telling that the object
+// requested, such as an image or a container, does not exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// APIDriver is a Driver talking to the Docker Engine API instead of running
+// the docker CLI. The daemon is reached at DOCKER_HOST, which defaults to
+// the local unix socket; DOCKER_TLS_VERIFY and DOCKER_CERT_PATH configure
+// TLS the same way they do for the docker CLI.
+type APIDriver struct {
+	Ui  packer.Ui
+	Ctx *interpolate.Context
+
+	client  *http.Client
+	baseURL string
+
+	// dial opens a connection to the daemon, for the requests hijacking
+	// the connection to stream the input and output of commands.
+	dial func() (net.Conn, error)
+
+	// The API version prefix of every request, for example "/v1.40". When
+	// empty, the latest version supported by the daemon is used.
+	apiVersion string
+
+	l    sync.Mutex
+	auth map[string]string
+}
+
+// NewAPIDriver returns an APIDriver for the daemon configured by the
+// environment.
+func NewAPIDriver(ui packer.Ui, ctx *interpolate.Context) (*APIDriver, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = defaultDockerHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid DOCKER_HOST %q: %s", host, err)
+	}
+
+	d := &APIDriver{
+		Ui:   ui,
+		Ctx:  ctx,
+		auth: make(map[string]string),
+	}
+	if v := os.Getenv("DOCKER_API_VERSION"); v != "" {
+		d.apiVersion = "/v" + strings.TrimPrefix(v, "v")
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", socket)
+		}
+		d.dial = func() (net.Conn, error) {
+			return net.Dial("unix", socket)
+		}
+		d.baseURL = "http://docker"
+	case "tcp", "http", "https":
+		scheme := "http"
+		if u.Scheme == "https" || os.Getenv("DOCKER_TLS_VERIFY") != "" || os.Getenv("DOCKER_TLS") != "" {
+			scheme = "https"
+			transport.TLSClientConfig, err = dockerTLSConfig()
+			if err != nil {
+				return nil, err
+			}
+		}
+		d.baseURL = scheme + "://" + u.Host
+		tlsConfig, addr := transport.TLSClientConfig, u.Host
+		d.dial = func() (net.Conn, error) {
+			if tlsConfig == nil {
+				return net.Dial("tcp", addr)
+			}
+			config := tlsConfig.Clone()
+			if config.ServerName == "" {
+				config.ServerName, _, _ = net.SplitHostPort(addr)
+			}
+			return tls.Dial("tcp", addr, config)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported DOCKER_HOST %q: the Docker Engine API "+
+			"can only be reached over unix sockets or TCP", host)
+	}
+	d.client = &http.Client{Transport: transport}
+
+	return d, nil
+}
+
+// dockerTLSConfig loads the certificates of DOCKER_CERT_PATH, which defaults
+// to ~/.docker. The server certificate is verified, unless DOCKER_TLS is set
+// without DOCKER_TLS_VERIFY, as the docker CLI does with --tls.
+func dockerTLSConfig() (*tls.Config, error) {
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		certPath = filepath.Join(home, ".docker")
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: os.Getenv("DOCKER_TLS_VERIFY") == "" && os.Getenv("DOCKER_TLS") != "",
+	}
+	if ca, err := ioutil.ReadFile(filepath.Join(certPath, "ca.pem")); err == nil {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No certificate found in %s", filepath.Join(certPath, "ca.pem"))
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	certFile := filepath.Join(certPath, "cert.pem")
+	keyFile := filepath.Join(certPath, "key.pem")
+	if _, err := os.Stat(certFile); err == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading Docker client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// request sends a request to the API and returns the response when it is
+// successful. Otherwise the response is closed and an APIError returned.
+func (d *APIDriver) request(method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	u := d.baseURL + d.apiVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	log.Printf("Docker Engine API request: %s %s", method, path)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 400 {
+		return resp, nil
+	}
+	return nil, readAPIError(resp)
+}
+
+// readAPIError closes the response of a failed request and returns the
+// APIError it describes.
+func readAPIError(resp *http.Response) error {
+	defer resp.Body.Close()
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	b, _ := ioutil.ReadAll(resp.Body)
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(b, &msg); err == nil && msg.Message != "" {
+		apiErr.Message = msg.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(b))
+	}
+	return apiErr
+}
+
+// requestJSON sends in as the JSON body of a request, and decodes the JSON
+// response into out when it is not nil.
+func (d *APIDriver) requestJSON(method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	header := http.Header{}
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.request(method, path, query, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonMessage is a message of the progress streams of the API.
+type jsonMessage struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Progress    string `json:"progress"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// readStream reads the progress stream of a response until its end. Status
+// updates are shown in the Ui, without the progress bars: only the changes
+// of status of every layer are shown. The last status is returned.
+func (d *APIDriver) readStream(resp *http.Response) (string, error) {
+	defer resp.Body.Close()
+
+	statuses := make(map[string]string)
+	var last string
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var m jsonMessage
+		if err := dec.Decode(&m); err == io.EOF {
+			return last, nil
+		} else if err != nil {
+			return "", err
+		}
+
+		if m.ErrorDetail != nil && m.ErrorDetail.Message != "" {
+			return "", &APIError{StatusCode: resp.StatusCode, Message: m.ErrorDetail.Message}
+		}
+		if m.Error != "" {
+			return "", &APIError{StatusCode: resp.StatusCode, Message: m.Error}
+		}
+		if m.Status == "" {
+			continue
+		}
+		last = m.Status
+
+		if statuses[m.ID] == m.Status {
+			continue
+		}
+		statuses[m.ID] = m.Status
+		if d.Ui == nil {
+			continue
+		}
+		if m.ID != "" {
+			d.Ui.Message(fmt.Sprintf("%s: %s", m.ID, m.Status))
+		} else {
+			d.Ui.Message(m.Status)
+		}
+	}
+}
+
+// hijack sends a request whose connection is taken over by the daemon to
+// stream the input and output of a command. The connection and a reader of
+// the output are returned.
+func (d *APIDriver) hijack(method, path string, in interface{}) (net.Conn, io.Reader, error) {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest(method, d.baseURL+d.apiVersion+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	log.Printf("Docker Engine API request: %s %s", method, path)
+	conn, err := d.dial()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode >= 400 {
+		conn.Close()
+		return nil, nil, readAPIError(resp)
+	}
+
+	return conn, br, nil
+}
+
+// demuxStream copies the multiplexed output of a command without TTY to
+// stdout and stderr. Every frame starts with a header made of the stream
+// (1 for stdout, 2 for stderr), three unused bytes, and the big endian size
+// of the frame.
+func demuxStream(r io.Reader, stdout, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Error reading the output of the command: %s", err)
+		}
+
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return fmt.Errorf("Error reading the output of the command: %s", err)
+		}
+	}
+}
+
+// registryAuth returns the X-Registry-Auth header of the requests to the
+// registry hosting image.
+func (d *APIDriver) registryAuth(image string) http.Header {
+	header := http.Header{}
+	auth, ok := d.auth[registryHost(imageRegistry(image))]
+	if !ok {
+		// an empty configuration, push requires the header
+		auth = base64.URLEncoding.EncodeToString([]byte("{}"))
+	}
+	header.Set("X-Registry-Auth", auth)
+	return header
+}
+
+func (d *APIDriver) Commit(id string, author string, changes []string, message string) (string, error) {
+	query := url.Values{}
+	query.Set("container", id)
+	if author != "" {
+		query.Set("author", author)
+	}
+	if message != "" {
+		query.Set("comment", message)
+	}
+	for _, change := range changes {
+		query.Add("changes", change)
+	}
+
+	log.Printf("Committing container %s with changes: %v", id, changes)
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	if err := d.requestJSON("POST", "/commit", query, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (d *APIDriver) DeleteImage(id string) error {
+	log.Printf("Deleting image: %s", id)
+	if err := d.requestJSON("DELETE", "/images/"+id, nil, nil, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *APIDriver) DownloadArchive(id string, src string, dst io.Writer) error {
+	query := url.Values{}
+	query.Set("path", src)
+
+	log.Printf("Downloading %s from container %s", src, id)
+	resp, err := d.request("GET", "/containers/"+id+"/archive", query, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(dst, resp.Body)
+	return err
+}
+
+func (d *APIDriver) ExecContainer(id string, config *ExecConfig) (int, error) {
+	create := map[string]interface{}{
+		"AttachStdin":  config.Stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          config.Tty,
+		"User":         config.User,
+		"Cmd":          config.Cmd,
+	}
+	var exec struct {
+		ID string `json:"Id"`
+	}
+	log.Printf("Executing in container %s: %v", id, config.Cmd)
+	if err := d.requestJSON("POST", "/containers/"+id+"/exec", nil, create, &exec); err != nil {
+		return 0, err
+	}
+
+	conn, output, err := d.hijack("POST", "/exec/"+exec.ID+"/start",
+		map[string]interface{}{"Detach": false, "Tty": config.Tty})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if config.Stdin != nil {
+		go func() {
+			io.Copy(conn, config.Stdin)
+			// let the command know its input is over
+			if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+				cw.CloseWrite()
+			}
+		}()
+	}
+
+	stdout, stderr := config.Stdout, config.Stderr
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	if config.Tty {
+		// the output of TTYs is not multiplexed
+		_, err = io.Copy(stdout, output)
+	} else {
+		err = demuxStream(output, stdout, stderr)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var inspect struct {
+		ExitCode int
+	}
+	if err := d.requestJSON("GET", "/exec/"+exec.ID+"/json", nil, nil, &inspect); err != nil {
+		return 0, err
+	}
+	return inspect.ExitCode, nil
+}
+
+func (d *APIDriver) Export(id string, dst io.Writer) error {
+	log.Printf("Exporting container: %s", id)
+	resp, err := d.request("GET", "/containers/"+id+"/export", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(dst, resp.Body)
+	return err
+}
+
+func (d *APIDriver) Import(path string, changes []string, repo string) (string, error) {
+	// There should be only one artifact of the Docker builder
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	query := url.Values{}
+	query.Set("fromSrc", "-")
+	name, tag := splitRepoTag(repo)
+	if name != "" {
+		query.Set("repo", name)
+	}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	for _, change := range changes {
+		query.Add("changes", change)
+	}
+
+	log.Printf("Importing tarball %s as %s", path, repo)
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-tar")
+	resp, err := d.request("POST", "/images/create", query, file, header)
+	if err != nil {
+		return "", err
+	}
+	// the last status of the stream is the ID of the image
+	id, err := d.readStream(resp)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (d *APIDriver) ContainerUser(id string) (string, error) {
+	var container struct {
+		Config struct {
+			User string
+		}
+	}
+	if err := d.requestJSON("GET", "/containers/"+id+"/json", nil, nil, &container); err != nil {
+		return "", fmt.Errorf("Failed to inspect the container: %s", err)
+	}
+	return container.Config.User, nil
+}
+
+func (d *APIDriver) ImageLayers(id string) ([]string, error) {
+	var image struct {
+		RootFS struct {
+			Layers []string
+		}
+	}
+	if err := d.requestJSON("GET", "/images/"+id+"/json", nil, nil, &image); err != nil {
+		return nil, err
+	}
+	return image.RootFS.Layers, nil
+}
+
+func (d *APIDriver) IPAddress(id string) (string, error) {
+	var container struct {
+		NetworkSettings struct {
+			IPAddress string
+		}
+	}
+	if err := d.requestJSON("GET", "/containers/"+id+"/json", nil, nil, &container); err != nil {
+		return "", err
+	}
+	return container.NetworkSettings.IPAddress, nil
+}
+
+func (d *APIDriver) Login(repo, user, pass string) error {
+	d.l.Lock()
+
+	auth := map[string]string{
+		"username":      user,
+		"password":      pass,
+		"serveraddress": repo,
+	}
+	var resp struct {
+		Status        string
+		IdentityToken string
+	}
+	if err := d.requestJSON("POST", "/auth", nil, auth, &resp); err != nil {
+		d.l.Unlock()
+		return err
+	}
+	if resp.Status != "" && d.Ui != nil {
+		d.Ui.Message(resp.Status)
+	}
+	if resp.IdentityToken != "" {
+		auth = map[string]string{
+			"identitytoken": resp.IdentityToken,
+			"serveraddress": repo,
+		}
+	}
+
+	b, err := json.Marshal(auth)
+	if err != nil {
+		d.l.Unlock()
+		return err
+	}
+	d.auth[registryHost(repo)] = base64.URLEncoding.EncodeToString(b)
+	return nil
+}
+
+func (d *APIDriver) Logout(repo string) error {
+	delete(d.auth, registryHost(repo))
+	d.l.Unlock()
+	return nil
+}
+
+func (d *APIDriver) Pull(image string, platform string) error {
+	query := url.Values{}
+	name, tag := splitRepoTag(image)
+	if tag == "" && !strings.Contains(name, "@") {
+		// without a tag, every tag of the repository would be pulled
+		tag = "latest"
+	}
+	query.Set("fromImage", name)
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	if platform != "" {
+		query.Set("platform", platform)
+	}
+
+	resp, err := d.request("POST", "/images/create", query, nil, d.registryAuth(image))
+	if err != nil {
+		return err
+	}
+	_, err = d.readStream(resp)
+	return err
+}
+
+func (d *APIDriver) Push(name string) error {
+	repo, tag := splitRepoTag(name)
+	query := url.Values{}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+
+	resp, err := d.request("POST", "/images/"+repo+"/push", query, nil, d.registryAuth(name))
+	if err != nil {
+		return err
+	}
+	_, err = d.readStream(resp)
+	return err
+}
+
+func (d *APIDriver) SaveImage(id string, dst io.Writer) error {
+	log.Printf("Exporting image: %s", id)
+	resp, err := d.request("GET", "/images/"+id+"/get", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(dst, resp.Body)
+	return err
+}
+
+func (d *APIDriver) StartContainer(config *ContainerConfig) (string, error) {
+	// Build up the template data
+	var tplData startContainerTemplate
+	tplData.Image = config.Image
+	ictx := *d.Ctx
+	ictx.Data = &tplData
+
+	args := make([]string, 0, len(config.RunCommand))
+	for _, v := range config.RunCommand {
+		v, err := interpolate.Render(v, &ictx)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, v)
+	}
+	d.Ui.Message(fmt.Sprintf(
+		"Run command: docker run %s", strings.Join(args, " ")))
+
+	create, name, err := parseRunCommand(args)
+	if err != nil {
+		return "", err
+	}
+	create.HostConfig.Privileged = create.HostConfig.Privileged || config.Privileged
+	for host, guest := range config.Volumes {
+		create.HostConfig.Binds = append(create.HostConfig.Binds, fmt.Sprintf("%s:%s", host, guest))
+	}
+
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+	if config.Platform != "" {
+		query.Set("platform", config.Platform)
+	}
+
+	log.Printf("Creating container: %#v", create)
+	var resp struct {
+		ID       string `json:"Id"`
+		Warnings []string
+	}
+	if err := d.requestJSON("POST", "/containers/create", query, create, &resp); err != nil {
+		return "", err
+	}
+	for _, w := range resp.Warnings {
+		d.Ui.Message(fmt.Sprintf("Warning: %s", w))
+	}
+
+	log.Printf("Starting container: %s", resp.ID)
+	if err := d.requestJSON("POST", "/containers/"+resp.ID+"/start", nil, nil, nil); err != nil {
+		return "", err
+	}
+
+	return resp.ID, nil
+}
+
+func (d *APIDriver) StopContainer(id string) error {
+	return d.requestJSON("POST", "/containers/"+id+"/stop", nil, nil, nil)
+}
+
+func (d *APIDriver) KillContainer(id string) error {
+	if err := d.requestJSON("POST", "/containers/"+id+"/kill", nil, nil, nil); err != nil {
+		return err
+	}
+
+	return d.requestJSON("DELETE", "/containers/"+id, nil, nil, nil)
+}
+
+func (d *APIDriver) TagImage(id string, repo string, force bool) error {
+	// the API always replaces existing tags, force is only meaningful
+	// for the CLI of old versions of Docker.
+	name, tag := splitRepoTag(repo)
+	query := url.Values{}
+	query.Set("repo", name)
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+
+	if err := d.requestJSON("POST", "/images/"+id+"/tag", query, nil, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *APIDriver) UploadArchive(id string, dst string, src io.Reader, fixOwner bool) error {
+	query := url.Values{}
+	query.Set("path", dst)
+	if fixOwner {
+		query.Set("copyUIDGID", "1")
+	}
+
+	log.Printf("Uploading archive to %s in container %s", dst, id)
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-tar")
+	resp, err := d.request("PUT", "/containers/"+id+"/archive", query, src, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (d *APIDriver) Verify() error {
+	if err := d.requestJSON("GET", "/_ping", nil, nil, nil); err != nil {
+		return fmt.Errorf("Error connecting to the Docker daemon at %s: %s", d.baseURL, err)
+	}
+	return nil
+}
+
+func (d *APIDriver) Version() (*version.Version, error) {
+	var resp struct {
+		Version string
+	}
+	if err := d.requestJSON("GET", "/version", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return version.NewVersion(resp.Version)
+}
+
+// splitRepoTag splits the tag from a repository name. The tag is empty
+// when there is none.
+func splitRepoTag(repo string) (string, string) {
+	if strings.Contains(repo, "@") {
+		// a digest
+		return repo, ""
+	}
+	i := strings.LastIndex(repo, ":")
+	if i == -1 || strings.Contains(repo[i+1:], "/") {
+		// no tag, or the port of a registry
+		return repo, ""
+	}
+	return repo[:i], repo[i+1:]
+}
+
+// imageRegistry returns the registry hosting image, which is empty for the
+// Docker Hub.
+func imageRegistry(image string) string {
+	i := strings.Index(image, "/")
+	if i == -1 {
+		return ""
+	}
+	host := image[:i]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return ""
+}
+
+// registryHost normalizes the address of a registry so that the addresses
+// used to login match the registries of image names.
+func registryHost(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	if i := strings.Index(registry, "/"); i != -1 {
+		registry = registry[:i]
+	}
+	switch registry {
+	case "", "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return registry
+}
@@ -0,0 +1,575 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// fakeDockerAPI is a fake Docker Engine API server recording the requests
+// it receives.
+type fakeDockerAPI struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	requests []*http.Request
+	bodies   map[string][]byte
+}
+
+func newFakeDockerAPI(t *testing.T, handlers map[string]http.HandlerFunc) *fakeDockerAPI {
+	f := &fakeDockerAPI{bodies: make(map[string][]byte)}
+	f.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		key := r.Method + " " + r.URL.Path
+
+		f.lock.Lock()
+		f.requests = append(f.requests, r)
+		f.bodies[key] = body
+		f.lock.Unlock()
+
+		h, ok := handlers[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"message": "page not found: %s"}`, key)
+			return
+		}
+		h(w, r)
+	}))
+	return f
+}
+
+// request returns the last request received for key, which is the method
+// and path of the request, or nil.
+func (f *fakeDockerAPI) request(key string) *http.Request {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		if r := f.requests[i]; r.Method+" "+r.URL.Path == key {
+			return r
+		}
+	}
+	return nil
+}
+
+func writeJSON(v interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func writeStream(messages ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		for _, m := range messages {
+			fmt.Fprintln(w, m)
+		}
+	}
+}
+
+func testAPIDriver(t *testing.T, f *fakeDockerAPI) (*APIDriver, *bytes.Buffer) {
+	f.Start()
+	os.Setenv("DOCKER_HOST", "tcp://"+f.Listener.Addr().String())
+	defer os.Unsetenv("DOCKER_HOST")
+
+	out := new(bytes.Buffer)
+	ui := &packer.BasicUi{Reader: new(bytes.Buffer), Writer: out}
+	d, err := NewAPIDriver(ui, &interpolate.Context{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return d, out
+}
+
+func TestAPIDriver_impl(t *testing.T) {
+	var _ Driver = new(APIDriver)
+}
+
+func TestAPIDriver_unixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available")
+	}
+	dir, err := ioutil.TempDir("", "packer-docker")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"GET /_ping":   writeJSON("OK"),
+		"GET /version": writeJSON(map[string]string{"Version": "19.03.5", "ApiVersion": "1.40"}),
+	})
+	f.Listener.Close()
+	f.Listener = l
+	f.Start()
+	defer f.Close()
+
+	os.Setenv("DOCKER_HOST", "unix://"+socket)
+	defer os.Unsetenv("DOCKER_HOST")
+	d, err := NewAPIDriver(nil, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := d.Verify(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	v, err := d.Version()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v.String() != "19.3.5" {
+		t.Fatalf("bad version: %s", v)
+	}
+}
+
+func TestAPIDriver_unsupportedHost(t *testing.T) {
+	os.Setenv("DOCKER_HOST", "npipe:////./pipe/docker_engine")
+	defer os.Unsetenv("DOCKER_HOST")
+	if _, err := NewAPIDriver(nil, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestAPIDriver_Pull(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"POST /images/create": writeStream(
+			`{"status":"Pulling from library/ubuntu","id":"18.04"}`,
+			`{"status":"Pulling fs layer","id":"5bed26d33875"}`,
+			`{"status":"Downloading","progressDetail":{"current":1,"total":2},"progress":"[=>  ]","id":"5bed26d33875"}`,
+			`{"status":"Downloading","progressDetail":{"current":2,"total":2},"progress":"[===>]","id":"5bed26d33875"}`,
+			`{"status":"Pull complete","id":"5bed26d33875"}`,
+			`{"status":"Status: Downloaded newer image for ubuntu:18.04"}`,
+		),
+	})
+	defer f.Close()
+	d, out := testAPIDriver(t, f)
+
+	if err := d.Pull("ubuntu", "linux/arm64"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	q := f.request("POST /images/create").URL.Query()
+	if q.Get("fromImage") != "ubuntu" || q.Get("tag") != "latest" || q.Get("platform") != "linux/arm64" {
+		t.Fatalf("bad query: %s", q.Encode())
+	}
+	if n := strings.Count(out.String(), "5bed26d33875: Downloading"); n != 1 {
+		t.Fatalf("progress should be shown once per status, got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out.String(), "Status: Downloaded newer image for ubuntu:18.04") {
+		t.Fatalf("bad output:\n%s", out)
+	}
+}
+
+func TestAPIDriver_PullError(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"POST /images/create": writeStream(
+			`{"status":"Pulling from library/nope","id":"latest"}`,
+			`{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}`,
+		),
+	})
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	err := d.Pull("nope:latest", "")
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("expected an APIError, got %#v", err)
+	}
+	if apiErr.Message != "manifest unknown" {
+		t.Fatalf("bad message: %s", apiErr.Message)
+	}
+}
+
+func TestAPIDriver_notFound(t *testing.T) {
+	f := newFakeDockerAPI(t, nil)
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	err := d.DeleteImage("sha256:1234")
+	if !IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %#v", err)
+	}
+	if !strings.Contains(err.Error(), "page not found") {
+		t.Fatalf("the message of the API should be kept: %s", err)
+	}
+}
+
+func TestAPIDriver_ContainerUser(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"GET /containers/abc/json": writeJSON(map[string]interface{}{
+			"Config": map[string]string{"User": "packer"},
+		}),
+	})
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	user, err := d.ContainerUser("abc")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if user != "packer" {
+		t.Fatalf("bad: %s", user)
+	}
+}
+
+func TestDockerTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	vars := []string{"DOCKER_CERT_PATH", "DOCKER_TLS", "DOCKER_TLS_VERIFY"}
+	for _, v := range vars {
+		old, ok := os.LookupEnv(v)
+		if ok {
+			defer os.Setenv(v, old)
+		} else {
+			defer os.Unsetenv(v)
+		}
+	}
+
+	cases := []struct {
+		tls, verify string
+		insecure    bool
+	}{
+		{"", "", false},
+		{"", "1", false},
+		{"1", "1", false},
+		{"1", "", true},
+	}
+	for _, tc := range cases {
+		os.Setenv("DOCKER_CERT_PATH", dir)
+		os.Setenv("DOCKER_TLS", tc.tls)
+		os.Setenv("DOCKER_TLS_VERIFY", tc.verify)
+
+		config, err := dockerTLSConfig()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if config.InsecureSkipVerify != tc.insecure {
+			t.Fatalf("DOCKER_TLS=%q DOCKER_TLS_VERIFY=%q: bad: %t", tc.tls, tc.verify, config.InsecureSkipVerify)
+		}
+	}
+}
+
+func TestAPIDriver_StartContainer(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"POST /containers/create":       writeJSON(map[string]interface{}{"Id": "c0ffee", "Warnings": []string{}}),
+		"POST /containers/c0ffee/start": func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+	})
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	id, err := d.StartContainer(&ContainerConfig{
+		Image:      "ubuntu",
+		RunCommand: []string{"-dit", "--name", "build", "--entrypoint=/bin/sh", "-e", "A=b", "--", "{{.Image}}", "-l"},
+		Volumes:    map[string]string{"/tmp/packer": "/packer-files"},
+		Privileged: true,
+		Platform:   "linux/arm64",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "c0ffee" {
+		t.Fatalf("bad id: %s", id)
+	}
+
+	q := f.request("POST /containers/create").URL.Query()
+	if q.Get("name") != "build" || q.Get("platform") != "linux/arm64" {
+		t.Fatalf("bad query: %s", q.Encode())
+	}
+	var create containerCreate
+	if err := json.Unmarshal(f.bodies["POST /containers/create"], &create); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := containerCreate{
+		Image:      "ubuntu",
+		Cmd:        []string{"-l"},
+		Entrypoint: []string{"/bin/sh"},
+		Env:        []string{"A=b"},
+		Tty:        true,
+		OpenStdin:  true,
+		HostConfig: containerHostConfig{
+			Binds:      []string{"/tmp/packer:/packer-files"},
+			Privileged: true,
+		},
+	}
+	if !reflect.DeepEqual(create, expected) {
+		t.Fatalf("bad container:\n%#v\nexpected:\n%#v", create, expected)
+	}
+	if f.request("POST /containers/c0ffee/start") == nil {
+		t.Fatal("the container should be started")
+	}
+}
+
+func TestAPIDriver_Commit(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"POST /commit": writeJSON(map[string]string{"Id": "sha256:abcd"}),
+	})
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	id, err := d.Commit("c0ffee", "packer", []string{"USER app", "WORKDIR /srv"}, "built by packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "sha256:abcd" {
+		t.Fatalf("bad id: %s", id)
+	}
+
+	q := f.request("POST /commit").URL.Query()
+	if q.Get("container") != "c0ffee" || q.Get("author") != "packer" || q.Get("comment") != "built by packer" {
+		t.Fatalf("bad query: %s", q.Encode())
+	}
+	if !reflect.DeepEqual(q["changes"], []string{"USER app", "WORKDIR /srv"}) {
+		t.Fatalf("bad changes: %#v", q["changes"])
+	}
+}
+
+func TestAPIDriver_Import(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"POST /images/create": writeStream(`{"status":"sha256:beef"}`),
+	})
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	tf, err := ioutil.TempFile("", "packer-docker")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	tf.Write([]byte("tarball"))
+	tf.Close()
+
+	id, err := d.Import(tf.Name(), []string{"CMD /bin/sh"}, "registry.example.com:5000/app:1.0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "sha256:beef" {
+		t.Fatalf("bad id: %s", id)
+	}
+
+	q := f.request("POST /images/create").URL.Query()
+	if q.Get("fromSrc") != "-" || q.Get("repo") != "registry.example.com:5000/app" || q.Get("tag") != "1.0" {
+		t.Fatalf("bad query: %s", q.Encode())
+	}
+	if string(f.bodies["POST /images/create"]) != "tarball" {
+		t.Fatalf("bad body: %s", f.bodies["POST /images/create"])
+	}
+}
+
+func TestAPIDriver_LoginPush(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"POST /auth": writeJSON(map[string]string{"Status": "Login Succeeded"}),
+		"POST /images/registry.example.com/app/push": writeStream(`{"status":"Pushed","id":"5bed26d33875"}`),
+	})
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	if err := d.Login("https://registry.example.com", "user", "secret"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := d.Push("registry.example.com/app:1.0"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := d.Logout("https://registry.example.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r := f.request("POST /images/registry.example.com/app/push")
+	if r.URL.Query().Get("tag") != "1.0" {
+		t.Fatalf("bad query: %s", r.URL.RawQuery)
+	}
+	b, err := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var auth map[string]string
+	if err := json.Unmarshal(b, &auth); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if auth["username"] != "user" || auth["password"] != "secret" {
+		t.Fatalf("bad auth: %#v", auth)
+	}
+
+	// the credentials are forgotten after logout
+	if err := d.Push("registry.example.com/app:1.0"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r = f.request("POST /images/registry.example.com/app/push")
+	if b, _ := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth")); string(b) != "{}" {
+		t.Fatalf("bad auth: %s", b)
+	}
+}
+
+// writeExecStream hijacks the connection of an exec start request, reads the
+// input of the command until it is closed, and writes it back multiplexed
+// on stdout, along with a line on stderr.
+func writeExecStream(w http.ResponseWriter, r *http.Request) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\n" +
+		"Content-Type: application/vnd.docker.multiplexed-stream\r\n" +
+		"Connection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	buf.Flush()
+
+	stdin, _ := ioutil.ReadAll(buf)
+	frame := func(stream byte, b []byte) {
+		header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(b)))
+		conn.Write(append(header, b...))
+	}
+	frame(1, stdin)
+	frame(2, []byte("warning\n"))
+}
+
+func TestAPIDriver_ExecContainer(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"POST /containers/c0ffee/exec": writeJSON(map[string]string{"Id": "e1"}),
+		"POST /exec/e1/start":          writeExecStream,
+		"GET /exec/e1/json":            writeJSON(map[string]interface{}{"ExitCode": 3, "Running": false}),
+	})
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	var stdout, stderr bytes.Buffer
+	status, err := d.ExecContainer("c0ffee", &ExecConfig{
+		Cmd:    []string{"/bin/sh", "-c", "cat"},
+		User:   "app",
+		Stdin:  strings.NewReader("hello"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if status != 3 {
+		t.Fatalf("bad exit status: %d", status)
+	}
+	if stdout.String() != "hello" || stderr.String() != "warning\n" {
+		t.Fatalf("bad output: %q %q", stdout.String(), stderr.String())
+	}
+
+	var create struct {
+		AttachStdin bool
+		Tty         bool
+		User        string
+		Cmd         []string
+	}
+	if err := json.Unmarshal(f.bodies["POST /containers/c0ffee/exec"], &create); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !create.AttachStdin || create.Tty || create.User != "app" || len(create.Cmd) != 3 {
+		t.Fatalf("bad exec: %#v", create)
+	}
+}
+
+func TestAPIDriver_archive(t *testing.T) {
+	f := newFakeDockerAPI(t, map[string]http.HandlerFunc{
+		"PUT /containers/c0ffee/archive": func(w http.ResponseWriter, r *http.Request) {},
+		"GET /containers/c0ffee/archive": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-tar")
+			w.Write([]byte("tarball"))
+		},
+	})
+	defer f.Close()
+	d, _ := testAPIDriver(t, f)
+
+	if err := d.UploadArchive("c0ffee", "/tmp", strings.NewReader("upload"), true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	q := f.request("PUT /containers/c0ffee/archive").URL.Query()
+	if q.Get("path") != "/tmp" || q.Get("copyUIDGID") != "1" {
+		t.Fatalf("bad query: %s", q.Encode())
+	}
+	if string(f.bodies["PUT /containers/c0ffee/archive"]) != "upload" {
+		t.Fatalf("bad body: %s", f.bodies["PUT /containers/c0ffee/archive"])
+	}
+
+	var buf bytes.Buffer
+	if err := d.DownloadArchive("c0ffee", "/etc/hosts", &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if buf.String() != "tarball" {
+		t.Fatalf("bad: %s", buf.String())
+	}
+	if q := f.request("GET /containers/c0ffee/archive").URL.Query(); q.Get("path") != "/etc/hosts" {
+		t.Fatalf("bad query: %s", q.Encode())
+	}
+
+	if err := d.UploadArchive("deadbeef", "/tmp", strings.NewReader("upload"), false); !IsNotFound(err) {
+		t.Fatalf("should be not found: %v", err)
+	}
+}
+
+func TestParseRunCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		image   string
+		cmd     []string
+		wantErr bool
+	}{
+		{[]string{"-d", "-i", "-t", "--entrypoint=/bin/sh", "--", "ubuntu"}, "ubuntu", nil, false},
+		{[]string{"-dit", "--rm", "ubuntu", "sleep", "infinity"}, "ubuntu", []string{"sleep", "infinity"}, false},
+		{[]string{"--volume", "/a:/b", "-u", "root", "--network=host", "ubuntu"}, "ubuntu", nil, false},
+		{[]string{"--gpus", "all", "ubuntu"}, "", nil, true},
+		{[]string{"--name"}, "", nil, true},
+		{[]string{"-d"}, "", nil, true},
+	}
+	for _, tt := range tests {
+		c, _, err := parseRunCommand(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseRunCommand(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if c.Image != tt.image || !reflect.DeepEqual(c.Cmd, tt.cmd) {
+			t.Fatalf("parseRunCommand(%v) = %s %v", tt.args, c.Image, c.Cmd)
+		}
+	}
+}
+
+func TestSplitRepoTag(t *testing.T) {
+	tests := []struct {
+		in, repo, tag string
+	}{
+		{"ubuntu", "ubuntu", ""},
+		{"ubuntu:18.04", "ubuntu", "18.04"},
+		{"localhost:5000/app", "localhost:5000/app", ""},
+		{"localhost:5000/app:1.0", "localhost:5000/app", "1.0"},
+		{"ubuntu@sha256:abcd", "ubuntu@sha256:abcd", ""},
+	}
+	for _, tt := range tests {
+		repo, tag := splitRepoTag(tt.in)
+		if repo != tt.repo || tag != tt.tag {
+			t.Fatalf("splitRepoTag(%q) = %q, %q", tt.in, repo, tag)
+		}
+	}
+}
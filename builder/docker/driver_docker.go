@@ -2,6 +2,7 @@ package docker
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/packer/packer"
@@ -20,12 +22,26 @@ type DockerDriver struct {
 	Ui  packer.Ui
 	Ctx *interpolate.Context
 
+	// The command run to talk to the container engine, "docker" when
+	// empty. Engines with a compatible command line, like podman, can be
+	// driven by setting it.
+	Executable string
+
 	l sync.Mutex
 }
 
+// Command returns the command running the container engine with args.
+func (d *DockerDriver) Command(args ...string) *exec.Cmd {
+	executable := d.Executable
+	if executable == "" {
+		executable = "docker"
+	}
+	return exec.Command(executable, args...)
+}
+
 func (d *DockerDriver) DeleteImage(id string) error {
 	var stderr bytes.Buffer
-	cmd := exec.Command("docker", "rmi", id)
+	cmd := d.Command("rmi", id)
 	cmd.Stderr = &stderr
 
 	log.Printf("Deleting image: %s", id)
@@ -59,7 +75,7 @@ func (d *DockerDriver) Commit(id string, author string, changes []string, messag
 	args = append(args, id)
 
 	log.Printf("Committing container with args: %v", args)
-	cmd := exec.Command("docker", args...)
+	cmd := d.Command(args...)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
@@ -76,9 +92,65 @@ func (d *DockerDriver) Commit(id string, author string, changes []string, messag
 	return strings.TrimSpace(stdout.String()), nil
 }
 
+func (d *DockerDriver) DownloadArchive(id string, src string, dst io.Writer) error {
+	var stderr bytes.Buffer
+	cmd := d.Command("cp", fmt.Sprintf("%s:%s", id, src), "-")
+	cmd.Stdout = dst
+	cmd.Stderr = &stderr
+
+	log.Printf("Downloading %s from container %s", src, id)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error downloading '%s': %s\nStderr: %s",
+			src, err, stderr.String())
+	}
+
+	return nil
+}
+
+func (d *DockerDriver) ExecContainer(id string, config *ExecConfig) (int, error) {
+	args := []string{"exec"}
+	if config.Stdin != nil {
+		args = append(args, "-i")
+	}
+	if config.Tty {
+		if config.Stdin != nil {
+			// docker refuses to attach a TTY to an input that is not one
+			log.Printf("Not allocating a TTY for a command reading its input")
+		} else {
+			args = append(args, "-t")
+		}
+	}
+	if config.User != "" {
+		args = append(args, "-u", config.User)
+	}
+	args = append(args, id)
+	args = append(args, config.Cmd...)
+
+	cmd := d.Command(args...)
+	cmd.Stdin = config.Stdin
+	cmd.Stdout = config.Stdout
+	cmd.Stderr = config.Stderr
+
+	log.Printf("Executing: %s", strings.Join(cmd.Args, " "))
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// There is no process-independent way to get the REAL
+		// exit status so we just try to go deeper.
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), nil
+		}
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, nil
+}
+
 func (d *DockerDriver) Export(id string, dst io.Writer) error {
 	var stderr bytes.Buffer
-	cmd := exec.Command("docker", "export", id)
+	cmd := d.Command("export", id)
 	cmd.Stdout = dst
 	cmd.Stderr = &stderr
 
@@ -108,7 +180,7 @@ func (d *DockerDriver) Import(path string, changes []string, repo string) (strin
 	args = append(args, "-")
 	args = append(args, repo)
 
-	cmd := exec.Command("docker", args...)
+	cmd := d.Command(args...)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	stdin, err := cmd.StdinPipe()
@@ -142,10 +214,37 @@ func (d *DockerDriver) Import(path string, changes []string, repo string) (strin
 	return strings.TrimSpace(stdout.String()), nil
 }
 
+func (d *DockerDriver) ContainerUser(id string) (string, error) {
+	var stderr, stdout bytes.Buffer
+	cmd := d.Command("inspect", "--format", "{{.Config.User}}", id)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Failed to inspect the container: %s\nStderr: %s", err, stderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (d *DockerDriver) ImageLayers(id string) ([]string, error) {
+	var stderr, stdout bytes.Buffer
+	cmd := d.Command("inspect", "--type", "image", "--format", "{{json .RootFS.Layers}}", id)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Error inspecting image: %s\nStderr: %s", err, stderr.String())
+	}
+
+	var layers []string
+	if err := json.Unmarshal(stdout.Bytes(), &layers); err != nil {
+		return nil, fmt.Errorf("Error reading the layers of the image: %s", err)
+	}
+	return layers, nil
+}
+
 func (d *DockerDriver) IPAddress(id string) (string, error) {
 	var stderr, stdout bytes.Buffer
-	cmd := exec.Command(
-		"docker",
+	cmd := d.Command(
 		"inspect",
 		"--format",
 		"{{ .NetworkSettings.IPAddress }}",
@@ -178,7 +277,7 @@ func (d *DockerDriver) Login(repo, user, pass string) error {
 		return err
 	}
 
-	cmd := exec.Command("docker")
+	cmd := d.Command()
 	cmd.Args = append(cmd.Args, "login")
 
 	if user != "" {
@@ -220,25 +319,30 @@ func (d *DockerDriver) Logout(repo string) error {
 		args = append(args, repo)
 	}
 
-	cmd := exec.Command("docker", args...)
+	cmd := d.Command(args...)
 	err := runAndStream(cmd, d.Ui)
 	d.l.Unlock()
 	return err
 }
 
-func (d *DockerDriver) Pull(image string) error {
-	cmd := exec.Command("docker", "pull", image)
+func (d *DockerDriver) Pull(image string, platform string) error {
+	args := []string{"pull"}
+	if platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	args = append(args, image)
+	cmd := d.Command(args...)
 	return runAndStream(cmd, d.Ui)
 }
 
 func (d *DockerDriver) Push(name string) error {
-	cmd := exec.Command("docker", "push", name)
+	cmd := d.Command("push", name)
 	return runAndStream(cmd, d.Ui)
 }
 
 func (d *DockerDriver) SaveImage(id string, dst io.Writer) error {
 	var stderr bytes.Buffer
-	cmd := exec.Command("docker", "save", id)
+	cmd := d.Command("save", id)
 	cmd.Stdout = dst
 	cmd.Stderr = &stderr
 
@@ -268,6 +372,9 @@ func (d *DockerDriver) StartContainer(config *ContainerConfig) (string, error) {
 	if config.Privileged {
 		args = append(args, "--privileged")
 	}
+	if config.Platform != "" {
+		args = append(args, "--platform", config.Platform)
+	}
 	for host, guest := range config.Volumes {
 		args = append(args, "-v", fmt.Sprintf("%s:%s", host, guest))
 	}
@@ -279,12 +386,11 @@ func (d *DockerDriver) StartContainer(config *ContainerConfig) (string, error) {
 
 		args = append(args, v)
 	}
-	d.Ui.Message(fmt.Sprintf(
-		"Run command: docker %s", strings.Join(args, " ")))
-
 	// Start the container
 	var stdout, stderr bytes.Buffer
-	cmd := exec.Command("docker", args...)
+	cmd := d.Command(args...)
+	d.Ui.Message(fmt.Sprintf(
+		"Run command: %s", strings.Join(cmd.Args, " ")))
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
@@ -308,18 +414,18 @@ func (d *DockerDriver) StartContainer(config *ContainerConfig) (string, error) {
 }
 
 func (d *DockerDriver) StopContainer(id string) error {
-	if err := exec.Command("docker", "stop", id).Run(); err != nil {
+	if err := d.Command("stop", id).Run(); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (d *DockerDriver) KillContainer(id string) error {
-	if err := exec.Command("docker", "kill", id).Run(); err != nil {
+	if err := d.Command("kill", id).Run(); err != nil {
 		return err
 	}
 
-	return exec.Command("docker", "rm", id).Run()
+	return d.Command("rm", id).Run()
 }
 
 func (d *DockerDriver) TagImage(id string, repo string, force bool) error {
@@ -356,7 +462,7 @@ func (d *DockerDriver) TagImage(id string, repo string, force bool) error {
 	args = append(args, id, repo)
 
 	var stderr bytes.Buffer
-	cmd := exec.Command("docker", args...)
+	cmd := d.Command(args...)
 	cmd.Stderr = &stderr
 
 	if err := cmd.Start(); err != nil {
@@ -372,6 +478,27 @@ func (d *DockerDriver) TagImage(id string, repo string, force bool) error {
 	return nil
 }
 
+func (d *DockerDriver) UploadArchive(id string, dst string, src io.Reader, fixOwner bool) error {
+	args := []string{"cp"}
+	if fixOwner {
+		args = append(args, "-a")
+	}
+	args = append(args, "-", fmt.Sprintf("%s:%s", id, dst))
+
+	var stderr bytes.Buffer
+	cmd := d.Command(args...)
+	cmd.Stdin = src
+	cmd.Stderr = &stderr
+
+	log.Printf("Uploading archive to %s in container %s", dst, id)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error uploading to '%s': %s\nStderr: %s",
+			dst, err, stderr.String())
+	}
+
+	return nil
+}
+
 func (d *DockerDriver) Verify() error {
 	if _, err := exec.LookPath("docker"); err != nil {
 		return err
@@ -381,7 +508,7 @@ func (d *DockerDriver) Verify() error {
 }
 
 func (d *DockerDriver) Version() (*version.Version, error) {
-	output, err := exec.Command("docker", "-v").Output()
+	output, err := d.Command("-v").Output()
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package docker
 
 import (
 	"io"
+	"io/ioutil"
 
 	"github.com/hashicorp/go-version"
 )
@@ -10,13 +11,39 @@ import (
 type MockDriver struct {
 	CommitCalled      bool
 	CommitContainerId string
+	CommitChanges     []string
 	CommitImageId     string
 	CommitErr         error
 
+	ContainerUserCalled bool
+	ContainerUserID     string
+	ContainerUserResult string
+	ContainerUserErr    error
+
 	DeleteImageCalled bool
 	DeleteImageId     string
 	DeleteImageErr    error
 
+	DownloadArchiveCalled bool
+	DownloadArchiveId     string
+	DownloadArchiveSrc    string
+	DownloadArchiveReader io.Reader
+	DownloadArchiveErr    error
+
+	ExecCalled     bool
+	ExecId         string
+	ExecConfig     *ExecConfig
+	ExecStdin      []byte
+	ExecStdout     string
+	ExecStderr     string
+	ExecExitStatus int
+	ExecErr        error
+
+	ImageLayersCalled bool
+	ImageLayersId     string
+	ImageLayersResult []string
+	ImageLayersErr    error
+
 	ImportCalled bool
 	ImportPath   string
 	ImportRepo   string
@@ -57,6 +84,13 @@ type MockDriver struct {
 	TagImageForce   bool
 	TagImageErr     error
 
+	UploadArchiveCalled   bool
+	UploadArchiveId       string
+	UploadArchiveDst      string
+	UploadArchiveData     []byte
+	UploadArchiveFixOwner bool
+	UploadArchiveErr      error
+
 	ExportReader io.Reader
 	ExportError  error
 	PullError    error
@@ -69,6 +103,7 @@ type MockDriver struct {
 	ExportID     string
 	PullCalled   bool
 	PullImage    string
+	PullPlatform string
 	StartCalled  bool
 	StartConfig  *ContainerConfig
 	StopCalled   bool
@@ -82,6 +117,7 @@ type MockDriver struct {
 func (d *MockDriver) Commit(id string, author string, changes []string, message string) (string, error) {
 	d.CommitCalled = true
 	d.CommitContainerId = id
+	d.CommitChanges = changes
 	return d.CommitImageId, d.CommitErr
 }
 
@@ -132,9 +168,10 @@ func (d *MockDriver) Logout(r string) error {
 	return d.LogoutErr
 }
 
-func (d *MockDriver) Pull(image string) error {
+func (d *MockDriver) Pull(image string, platform string) error {
 	d.PullCalled = true
 	d.PullImage = image
+	d.PullPlatform = platform
 	return d.PullError
 }
 
@@ -144,6 +181,18 @@ func (d *MockDriver) Push(name string) error {
 	return d.PushErr
 }
 
+func (d *MockDriver) ContainerUser(id string) (string, error) {
+	d.ContainerUserCalled = true
+	d.ContainerUserID = id
+	return d.ContainerUserResult, d.ContainerUserErr
+}
+
+func (d *MockDriver) ImageLayers(id string) ([]string, error) {
+	d.ImageLayersCalled = true
+	d.ImageLayersId = id
+	return d.ImageLayersResult, d.ImageLayersErr
+}
+
 func (d *MockDriver) SaveImage(id string, dst io.Writer) error {
 	d.SaveImageCalled = true
 	d.SaveImageId = id
@@ -158,6 +207,57 @@ func (d *MockDriver) SaveImage(id string, dst io.Writer) error {
 	return d.SaveImageError
 }
 
+func (d *MockDriver) DownloadArchive(id string, src string, dst io.Writer) error {
+	d.DownloadArchiveCalled = true
+	d.DownloadArchiveId = id
+	d.DownloadArchiveSrc = src
+
+	if d.DownloadArchiveReader != nil {
+		if _, err := io.Copy(dst, d.DownloadArchiveReader); err != nil {
+			return err
+		}
+	}
+
+	return d.DownloadArchiveErr
+}
+
+func (d *MockDriver) ExecContainer(id string, config *ExecConfig) (int, error) {
+	d.ExecCalled = true
+	d.ExecId = id
+	d.ExecConfig = config
+
+	if config.Stdin != nil {
+		b, err := ioutil.ReadAll(config.Stdin)
+		if err != nil {
+			return 0, err
+		}
+		d.ExecStdin = b
+	}
+	if config.Stdout != nil {
+		io.WriteString(config.Stdout, d.ExecStdout)
+	}
+	if config.Stderr != nil {
+		io.WriteString(config.Stderr, d.ExecStderr)
+	}
+
+	return d.ExecExitStatus, d.ExecErr
+}
+
+func (d *MockDriver) UploadArchive(id string, dst string, src io.Reader, fixOwner bool) error {
+	d.UploadArchiveCalled = true
+	d.UploadArchiveId = id
+	d.UploadArchiveDst = dst
+	d.UploadArchiveFixOwner = fixOwner
+
+	b, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	d.UploadArchiveData = b
+
+	return d.UploadArchiveErr
+}
+
 func (d *MockDriver) StartContainer(config *ContainerConfig) (string, error) {
 	d.StartCalled = true
 	d.StartConfig = config
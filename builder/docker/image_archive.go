@@ -0,0 +1,531 @@
+package docker
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ociImageIndexMediaType    = "application/vnd.oci.image.index.v1+json"
+	ociImageManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ociImageConfigMediaType   = "application/vnd.oci.image.config.v1+json"
+	ociImageLayerMediaType    = "application/vnd.oci.image.layer.v1.tar"
+
+	// The prefix of the files marking the deletion of a file of a lower
+	// layer, and the name of the file marking a directory whose content
+	// hides the one of lower layers.
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// imageArchive is an image read from a `docker save` archive, extracted in
+// dir.
+type imageArchive struct {
+	dir string
+
+	// The image configuration
+	config []byte
+
+	// The paths of the uncompressed layers, relative to dir, from the
+	// bottom one to the top one.
+	layers []string
+}
+
+// dockerArchiveManifest is the manifest.json file of `docker save`
+// archives.
+type dockerArchiveManifest []struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// ociDescriptor describes a blob of an OCI image layout.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// imageDigests are the digests of an exported image, exposed in the state
+// of the artifact.
+type imageDigests struct {
+	// The digest of the OCI manifest, or the ID of the image for docker
+	// archives.
+	Image  string
+	Config string
+	Layers []string
+}
+
+// readImageArchive extracts the `docker save` archive r in dir. The layers
+// shared by several entries of the archive are symbolic links to the first
+// one, they are resolved to copies of it.
+func readImageArchive(r io.Reader, dir string) (*imageArchive, error) {
+	links := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if hdr.Typeflag == tar.TypeSymlink {
+			links[name] = path.Clean(path.Join(path.Dir(name), hdr.Linkname))
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(dst)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for name := range links {
+		target := name
+		for i := 0; i <= len(links); i++ {
+			next, ok := links[target]
+			if !ok {
+				break
+			}
+			target = next
+		}
+		src := filepath.Join(dir, filepath.FromSlash(target))
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, err
+		}
+		if err := copyFile(src, dst); err != nil {
+			return nil, fmt.Errorf("Error resolving link %s of the image archive: %s", name, err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid image archive: %s", err)
+	}
+	var manifest dockerArchiveManifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("Invalid image archive manifest: %s", err)
+	}
+	if len(manifest) != 1 {
+		return nil, fmt.Errorf("Expected one image in the archive, found %d", len(manifest))
+	}
+
+	a := &imageArchive{dir: dir, layers: manifest[0].Layers}
+	a.config, err = ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(manifest[0].Config)))
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// copyFile copies the regular file src to dst.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (a *imageArchive) layerPath(i int) string {
+	return filepath.Join(a.dir, filepath.FromSlash(a.layers[i]))
+}
+
+// squash merges the layers added on top of the base image, whose layers
+// have the base digests, into a single layer, and updates the configuration
+// of the image accordingly. The layers of the base image are kept as is.
+func (a *imageArchive) squash(base []string) error {
+	diffIDs, err := configDiffIDs(a.config)
+	if err != nil {
+		return err
+	}
+	keep := 0
+	for keep < len(base) && keep < len(diffIDs) && keep < len(a.layers) && base[keep] == diffIDs[keep] {
+		keep++
+	}
+	if len(a.layers)-keep < 2 {
+		return nil
+	}
+
+	// Walk the layers from the top one to find the layer providing every
+	// path, taking the deletions of upper layers into account.
+	winners := make(map[string]int)
+	deleted := make(map[string]bool)
+	opaque := make(map[string]bool)
+	hidden := func(p string) bool {
+		if deleted[p] {
+			return true
+		}
+		for dir := path.Dir(p); ; dir = path.Dir(dir) {
+			if deleted[dir] || opaque[dir] {
+				return true
+			}
+			if dir == "/" {
+				return false
+			}
+		}
+	}
+	for i := len(a.layers) - 1; i >= keep; i-- {
+		layerDeleted := make(map[string]bool)
+		layerOpaque := make(map[string]bool)
+		err := walkLayer(a.layerPath(i), func(hdr *tar.Header, _ io.Reader) error {
+			p := path.Clean("/" + hdr.Name)
+			name := path.Base(p)
+			whiteout := true
+			switch {
+			case name == whiteoutOpaque:
+				layerOpaque[path.Dir(p)] = true
+			case strings.HasPrefix(name, whiteoutPrefix):
+				layerDeleted[path.Join(path.Dir(p), strings.TrimPrefix(name, whiteoutPrefix))] = true
+			default:
+				whiteout = false
+			}
+			// The whiteouts still apply to the layers of the base image.
+			if whiteout && keep == 0 {
+				return nil
+			}
+			if _, ok := winners[p]; !ok && !hidden(p) {
+				winners[p] = i
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for p := range layerDeleted {
+			deleted[p] = true
+		}
+		for p := range layerOpaque {
+			opaque[p] = true
+		}
+	}
+
+	// Write the files from the bottom layer to the top one, so that the
+	// parent directories usually come first.
+	squashed := filepath.Join(a.dir, "squashed.tar")
+	f, err := os.Create(squashed)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for i := keep; i < len(a.layers); i++ {
+		err := walkLayer(a.layerPath(i), func(hdr *tar.Header, r io.Reader) error {
+			if w, ok := winners[path.Clean("/"+hdr.Name)]; !ok || w != i {
+				return nil
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err := io.Copy(tw, r)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	diffID, _, err := fileDigest(squashed)
+	if err != nil {
+		return err
+	}
+	config, err := squashConfig(a.config, diffIDs[:keep], diffID)
+	if err != nil {
+		return err
+	}
+	a.config = config
+	a.layers = append(a.layers[:keep:keep], "squashed.tar")
+	return nil
+}
+
+// configDiffIDs returns the digests of the layers listed in an image
+// configuration.
+func configDiffIDs(b []byte) ([]string, error) {
+	var config struct {
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("Invalid image configuration: %s", err)
+	}
+	return config.RootFS.DiffIDs, nil
+}
+
+// squashConfig updates an image configuration for its layers being merged
+// in the layer with the diffID digest, on top of the kept layers. Fields
+// unknown to Packer are kept.
+func squashConfig(b []byte, kept []string, diffID string) ([]byte, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("Invalid image configuration: %s", err)
+	}
+
+	config["rootfs"] = map[string]interface{}{
+		"type":     "layers",
+		"diff_ids": append(append([]string{}, kept...), diffID),
+	}
+
+	// The history must have as many entries creating a layer as there are
+	// layers: keep the history, but the entries of the merged layers no
+	// longer create a layer.
+	var history []interface{}
+	layers := 0
+	if h, ok := config["history"].([]interface{}); ok {
+		for _, entry := range h {
+			if e, ok := entry.(map[string]interface{}); ok {
+				if empty, _ := e["empty_layer"].(bool); !empty {
+					if layers >= len(kept) {
+						e["empty_layer"] = true
+					}
+					layers++
+				}
+			}
+			history = append(history, entry)
+		}
+	}
+	history = append(history, map[string]interface{}{
+		"created":    time.Now().UTC().Format(time.RFC3339Nano),
+		"created_by": "packer squash",
+		"comment":    "the layers added to the base image merged by Packer",
+	})
+	config["history"] = history
+
+	return json.Marshal(config)
+}
+
+// walkLayer calls fn for every entry of the layer tarball at p.
+func walkLayer(p string, fn func(*tar.Header, io.Reader) error) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading layer %s: %s", filepath.Base(p), err)
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeDockerArchive writes the image as a `docker load` archive.
+func (a *imageArchive) writeDockerArchive(w io.Writer) (*imageDigests, error) {
+	tw := tar.NewWriter(w)
+	digests := &imageDigests{Config: sha256Digest(a.config)}
+	digests.Image = digests.Config
+
+	configName := strings.TrimPrefix(digests.Config, "sha256:") + ".json"
+	if err := writeTarFile(tw, configName, a.config); err != nil {
+		return nil, err
+	}
+
+	manifest := dockerArchiveManifest{{Config: configName, RepoTags: []string{}}}
+	for i := range a.layers {
+		digest, _, err := fileDigest(a.layerPath(i))
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(digest, "sha256:") + "/layer.tar"
+		if err := writeTarFileFrom(tw, name, a.layerPath(i)); err != nil {
+			return nil, err
+		}
+		manifest[0].Layers = append(manifest[0].Layers, name)
+		digests.Layers = append(digests.Layers, digest)
+	}
+
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarFile(tw, "manifest.json", b); err != nil {
+		return nil, err
+	}
+	return digests, tw.Close()
+}
+
+// writeOCILayout writes the image as the tarball of an OCI image layout.
+// Layers are stored uncompressed.
+func (a *imageArchive) writeOCILayout(w io.Writer) (*imageDigests, error) {
+	tw := tar.NewWriter(w)
+	if err := writeTarFile(tw, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return nil, err
+	}
+
+	digests := &imageDigests{Config: sha256Digest(a.config)}
+	manifest := struct {
+		SchemaVersion int             `json:"schemaVersion"`
+		MediaType     string          `json:"mediaType"`
+		Config        ociDescriptor   `json:"config"`
+		Layers        []ociDescriptor `json:"layers"`
+	}{
+		SchemaVersion: 2,
+		MediaType:     ociImageManifestMediaType,
+		Config: ociDescriptor{
+			MediaType: ociImageConfigMediaType,
+			Digest:    digests.Config,
+			Size:      int64(len(a.config)),
+		},
+		Layers: []ociDescriptor{},
+	}
+	if err := writeTarFile(tw, ociBlobPath(digests.Config), a.config); err != nil {
+		return nil, err
+	}
+
+	for i := range a.layers {
+		digest, size, err := fileDigest(a.layerPath(i))
+		if err != nil {
+			return nil, err
+		}
+		if err := writeTarFileFrom(tw, ociBlobPath(digest), a.layerPath(i)); err != nil {
+			return nil, err
+		}
+		manifest.Layers = append(manifest.Layers, ociDescriptor{
+			MediaType: ociImageLayerMediaType,
+			Digest:    digest,
+			Size:      size,
+		})
+		digests.Layers = append(digests.Layers, digest)
+	}
+
+	mb, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	digests.Image = sha256Digest(mb)
+	if err := writeTarFile(tw, ociBlobPath(digests.Image), mb); err != nil {
+		return nil, err
+	}
+
+	index := struct {
+		SchemaVersion int             `json:"schemaVersion"`
+		MediaType     string          `json:"mediaType"`
+		Manifests     []ociDescriptor `json:"manifests"`
+	}{
+		SchemaVersion: 2,
+		MediaType:     ociImageIndexMediaType,
+		Manifests: []ociDescriptor{{
+			MediaType: ociImageManifestMediaType,
+			Digest:    digests.Image,
+			Size:      int64(len(mb)),
+		}},
+	}
+	ib, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarFile(tw, "index.json", ib); err != nil {
+		return nil, err
+	}
+	return digests, tw.Close()
+}
+
+func ociBlobPath(digest string) string {
+	return "blobs/sha256/" + strings.TrimPrefix(digest, "sha256:")
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// fileDigest returns the sha256 digest and the size of the file at p.
+func fileDigest(p string) (string, int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, b []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: time.Unix(0, 0),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
+
+func writeTarFileFrom(tw *tar.Writer, name string, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    fi.Size(),
+		ModTime: time.Unix(0, 0),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,418 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type testTarEntry struct {
+	name    string
+	content string
+}
+
+func testTar(t *testing.T, entries []testTarEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content))}
+		if e.name[len(e.name)-1] == '/' {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return buf.Bytes()
+}
+
+// testLowerLayer and testUpperLayer are two layers, the upper layer
+// deleting a file and hiding a directory of the lower one.
+func testLowerLayer(t *testing.T) []byte {
+	return testTar(t, []testTarEntry{
+		{"etc/", ""},
+		{"etc/passwd", "root"},
+		{"etc/hosts", "localhost"},
+		{"var/", ""},
+		{"var/cache/", ""},
+		{"var/cache/a", "a"},
+	})
+}
+
+func testUpperLayer(t *testing.T) []byte {
+	return testTar(t, []testTarEntry{
+		{"etc/", ""},
+		{"etc/passwd", "root\nuser"},
+		{"etc/.wh.hosts", ""},
+		{"var/cache/", ""},
+		{"var/cache/.wh..wh..opq", ""},
+		{"var/cache/b", "b"},
+	})
+}
+
+// testDockerArchiveLayers returns a `docker save` archive of an image made
+// of layers, stored in the layerN directories.
+func testDockerArchiveLayers(t *testing.T, layers ...[]byte) []byte {
+	var diffIDs, history, names []string
+	var entries []testTarEntry
+	for i, layer := range layers {
+		name := fmt.Sprintf("layer%d/layer.tar", i)
+		diffIDs = append(diffIDs, `"`+sha256Digest(layer)+`"`)
+		history = append(history, fmt.Sprintf(`{"created_by":"step %d"}`, i))
+		names = append(names, `"`+name+`"`)
+		entries = append(entries, testTarEntry{name, string(layer)})
+	}
+	config := `{"architecture":"amd64","os":"linux","config":{"Cmd":["/bin/sh"]},` +
+		`"rootfs":{"type":"layers","diff_ids":[` + strings.Join(diffIDs, ",") + `]},` +
+		`"history":[` + strings.Join(history, ",") + `]}`
+
+	return testTar(t, append([]testTarEntry{
+		{"manifest.json", `[{"Config":"config.json","RepoTags":null,"Layers":[` + strings.Join(names, ",") + `]}]`},
+		{"config.json", config},
+	}, entries...))
+}
+
+// testDockerArchive returns a `docker save` archive of the two test layers.
+func testDockerArchive(t *testing.T) []byte {
+	return testDockerArchiveLayers(t, testLowerLayer(t), testUpperLayer(t))
+}
+
+func testImageArchive(t *testing.T) *imageArchive {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	a, err := readImageArchive(bytes.NewReader(testDockerArchive(t)), dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("err: %s", err)
+	}
+	return a
+}
+
+// readTestTar returns the content of the regular files and directories of a
+// tarball, keyed by name.
+func readTestTar(t *testing.T, r io.Reader) map[string]string {
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		files[hdr.Name] = string(b)
+	}
+}
+
+func TestReadImageArchive(t *testing.T) {
+	a := testImageArchive(t)
+	defer os.RemoveAll(a.dir)
+
+	if !reflect.DeepEqual(a.layers, []string{"layer0/layer.tar", "layer1/layer.tar"}) {
+		t.Fatalf("bad: %#v", a.layers)
+	}
+	if !bytes.Contains(a.config, []byte(`"rootfs"`)) {
+		t.Fatalf("bad: %s", a.config)
+	}
+
+	if _, err := readImageArchive(bytes.NewReader(testTar(t, nil)), a.dir+"/empty"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestImageArchive_squash(t *testing.T) {
+	a := testImageArchive(t)
+	defer os.RemoveAll(a.dir)
+
+	if err := a.squash(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(a.layers) != 1 {
+		t.Fatalf("bad: %#v", a.layers)
+	}
+
+	f, err := os.Open(a.layerPath(0))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+	files := readTestTar(t, f)
+	expected := map[string]string{
+		"etc/":        "",
+		"etc/passwd":  "root\nuser",
+		"var/":        "",
+		"var/cache/":  "",
+		"var/cache/b": "b",
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	diffID, _, err := fileDigest(a.layerPath(0))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var config struct {
+		Config struct{ Cmd []string }
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		}
+		History []struct {
+			CreatedBy  string `json:"created_by"`
+			EmptyLayer bool   `json:"empty_layer"`
+		}
+	}
+	if err := json.Unmarshal(a.config, &config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(config.RootFS.DiffIDs, []string{diffID}) {
+		t.Fatalf("bad: %#v", config.RootFS.DiffIDs)
+	}
+	if !reflect.DeepEqual(config.Config.Cmd, []string{"/bin/sh"}) {
+		t.Fatalf("bad: %#v", config.Config.Cmd)
+	}
+	if len(config.History) != 3 {
+		t.Fatalf("bad: %#v", config.History)
+	}
+	for i, h := range config.History {
+		if h.EmptyLayer != (i < 2) {
+			t.Fatalf("bad history %d: %#v", i, h)
+		}
+	}
+}
+
+func TestReadImageArchive_sharedLayer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// docker save stores a layer used twice once, the second entry being a
+	// symbolic link to the first one.
+	lower := testLowerLayer(t)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := []testTarEntry{
+		{"manifest.json", `[{"Config":"config.json","RepoTags":null,"Layers":["a/layer.tar","b/layer.tar"]}]`},
+		{"config.json", `{"rootfs":{"type":"layers","diff_ids":[]}}`},
+		{"a/layer.tar", string(lower)},
+	}
+	for _, e := range files {
+		tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content))})
+		tw.Write([]byte(e.content))
+	}
+	tw.WriteHeader(&tar.Header{Name: "b/layer.tar", Typeflag: tar.TypeSymlink, Linkname: "../a/layer.tar"})
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	a, err := readImageArchive(&buf, dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b, err := ioutil.ReadFile(a.layerPath(1))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(b, lower) {
+		t.Fatal("the shared layer should be resolved")
+	}
+}
+
+func TestImageArchive_squashOnBase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	lower := testLowerLayer(t)
+	upper := testUpperLayer(t)
+	top := testTar(t, []testTarEntry{
+		{"etc/", ""},
+		{"etc/motd", "hello"},
+	})
+	a, err := readImageArchive(bytes.NewReader(testDockerArchiveLayers(t, lower, upper, top)), dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := a.squash([]string{sha256Digest(lower)}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(a.layers) != 2 || a.layers[0] != "layer0/layer.tar" {
+		t.Fatalf("bad: %#v", a.layers)
+	}
+
+	f, err := os.Open(a.layerPath(1))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+	files := readTestTar(t, f)
+	expected := map[string]string{
+		"etc/":                   "",
+		"etc/passwd":             "root\nuser",
+		"etc/.wh.hosts":          "",
+		"etc/motd":               "hello",
+		"var/cache/":             "",
+		"var/cache/.wh..wh..opq": "",
+		"var/cache/b":            "b",
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	diffID, _, err := fileDigest(a.layerPath(1))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var config struct {
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		}
+		History []struct {
+			EmptyLayer bool `json:"empty_layer"`
+		}
+	}
+	if err := json.Unmarshal(a.config, &config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(config.RootFS.DiffIDs, []string{sha256Digest(lower), diffID}) {
+		t.Fatalf("bad: %#v", config.RootFS.DiffIDs)
+	}
+	if len(config.History) != 4 {
+		t.Fatalf("bad: %#v", config.History)
+	}
+	for i, h := range config.History {
+		if h.EmptyLayer != (i == 1 || i == 2) {
+			t.Fatalf("bad history %d: %#v", i, h)
+		}
+	}
+
+	// Nothing is squashed when a single layer is added to the base
+	b := &imageArchive{dir: dir, config: a.config, layers: a.layers}
+	if err := b.squash([]string{sha256Digest(lower)}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(b.config, a.config) || len(b.layers) != 2 {
+		t.Fatalf("bad: %#v", b.layers)
+	}
+}
+
+func TestImageArchive_writeDockerArchive(t *testing.T) {
+	a := testImageArchive(t)
+	defer os.RemoveAll(a.dir)
+
+	var buf bytes.Buffer
+	digests, err := a.writeDockerArchive(&buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if digests.Image != sha256Digest(a.config) || digests.Config != digests.Image {
+		t.Fatalf("bad: %#v", digests)
+	}
+	if len(digests.Layers) != 2 {
+		t.Fatalf("bad: %#v", digests.Layers)
+	}
+
+	// The written archive can be read back
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	b, err := readImageArchive(&buf, dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(b.config, a.config) || len(b.layers) != 2 {
+		t.Fatalf("bad: %#v", b)
+	}
+}
+
+func TestImageArchive_writeOCILayout(t *testing.T) {
+	a := testImageArchive(t)
+	defer os.RemoveAll(a.dir)
+
+	var buf bytes.Buffer
+	digests, err := a.writeOCILayout(&buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	files := readTestTar(t, &buf)
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{
+		ociBlobPath(digests.Config),
+		ociBlobPath(digests.Layers[0]),
+		ociBlobPath(digests.Layers[1]),
+		ociBlobPath(digests.Image),
+		"index.json",
+		"oci-layout",
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	// Every blob matches its digest
+	for name, content := range files {
+		if name != "index.json" && name != "oci-layout" && ociBlobPath(sha256Digest([]byte(content))) != name {
+			t.Fatalf("bad digest: %s", name)
+		}
+	}
+
+	var index struct {
+		Manifests []ociDescriptor
+	}
+	if err := json.Unmarshal([]byte(files["index.json"]), &index); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Digest != digests.Image {
+		t.Fatalf("bad: %#v", index)
+	}
+
+	var manifest struct {
+		Config ociDescriptor
+		Layers []ociDescriptor
+	}
+	if err := json.Unmarshal([]byte(files[ociBlobPath(digests.Image)]), &manifest); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if manifest.Config.MediaType != ociImageConfigMediaType || manifest.Config.Digest != digests.Config {
+		t.Fatalf("bad: %#v", manifest.Config)
+	}
+	for i, l := range manifest.Layers {
+		if l.MediaType != ociImageLayerMediaType || l.Digest != digests.Layers[i] {
+			t.Fatalf("bad: %#v", l)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package docker
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// containerCreate is the body of the container creation request of the
+// Docker Engine API.
+type containerCreate struct {
+	Image      string
+	Cmd        []string          `json:",omitempty"`
+	Entrypoint []string          `json:",omitempty"`
+	Env        []string          `json:",omitempty"`
+	User       string            `json:",omitempty"`
+	WorkingDir string            `json:",omitempty"`
+	Hostname   string            `json:",omitempty"`
+	Labels     map[string]string `json:",omitempty"`
+	Tty        bool
+	OpenStdin  bool
+	HostConfig containerHostConfig
+}
+
+type containerHostConfig struct {
+	Binds       []string `json:",omitempty"`
+	Privileged  bool
+	NetworkMode string `json:",omitempty"`
+	AutoRemove  bool
+	CapAdd      []string `json:",omitempty"`
+	CapDrop     []string `json:",omitempty"`
+	SecurityOpt []string `json:",omitempty"`
+}
+
+// parseRunCommand turns the arguments of `docker run` into a container
+// creation request, so that the APIDriver understands the run_command of
+// the builder. Only the flags that make sense for a build container are
+// supported; the name of the container is returned separately since it is
+// not part of the request body. The container is always started detached.
+func parseRunCommand(args []string) (*containerCreate, string, error) {
+	c := &containerCreate{}
+	var name string
+
+	// value returns the value of a flag, either after "=" or as the next
+	// argument.
+	i := 0
+	value := func(flag, inline string, hasInline bool) (string, error) {
+		if hasInline {
+			return inline, nil
+		}
+		if i+1 >= len(args) {
+			return "", fmt.Errorf("run_command flag %s requires a value", flag)
+		}
+		i++
+		return args[i], nil
+	}
+
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+
+		flag, inline, hasInline := arg, "", false
+		if j := strings.Index(arg, "="); j != -1 && strings.HasPrefix(arg, "--") {
+			flag, inline, hasInline = arg[:j], arg[j+1:], true
+		}
+
+		// combined short boolean flags, like -dit
+		if !strings.HasPrefix(flag, "--") && len(flag) > 2 && strings.Trim(flag[1:], "dit") == "" {
+			for _, f := range flag[1:] {
+				setRunBoolFlag(c, "-"+string(f))
+			}
+			continue
+		}
+
+		switch flag {
+		case "-d", "--detach", "-i", "--interactive", "-t", "--tty", "--privileged", "--rm":
+			setRunBoolFlag(c, flag)
+		case "--entrypoint":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.Entrypoint = []string{v}
+		case "-v", "--volume":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.HostConfig.Binds = append(c.HostConfig.Binds, v)
+		case "-e", "--env":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.Env = append(c.Env, v)
+		case "-l", "--label":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			if c.Labels == nil {
+				c.Labels = make(map[string]string)
+			}
+			parts := strings.SplitN(v, "=", 2)
+			c.Labels[parts[0]] = ""
+			if len(parts) == 2 {
+				c.Labels[parts[0]] = parts[1]
+			}
+		case "-u", "--user":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.User = v
+		case "-w", "--workdir":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.WorkingDir = v
+		case "-h", "--hostname":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.Hostname = v
+		case "--name":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			name = v
+		case "--network", "--net":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.HostConfig.NetworkMode = v
+		case "--cap-add":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.HostConfig.CapAdd = append(c.HostConfig.CapAdd, v)
+		case "--cap-drop":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.HostConfig.CapDrop = append(c.HostConfig.CapDrop, v)
+		case "--security-opt":
+			v, err := value(flag, inline, hasInline)
+			if err != nil {
+				return nil, "", err
+			}
+			c.HostConfig.SecurityOpt = append(c.HostConfig.SecurityOpt, v)
+		default:
+			return nil, "", fmt.Errorf("run_command flag %s is not supported "+
+				"when using the Docker Engine API", flag)
+		}
+	}
+
+	if i >= len(args) {
+		return nil, "", fmt.Errorf("run_command does not contain the image to run")
+	}
+	c.Image = args[i]
+	if len(args) > i+1 {
+		c.Cmd = args[i+1:]
+	}
+
+	return c, name, nil
+}
+
+func setRunBoolFlag(c *containerCreate, flag string) {
+	switch flag {
+	case "-d", "--detach":
+		// the API driver always starts containers detached
+	case "-i", "--interactive":
+		c.OpenStdin = true
+	case "-t", "--tty":
+		c.Tty = true
+	case "--privileged":
+		c.HostConfig.Privileged = true
+	case "--rm":
+		c.HostConfig.AutoRemove = true
+	default:
+		log.Printf("Ignoring run_command flag %s", flag)
+	}
+}
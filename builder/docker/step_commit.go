@@ -30,7 +30,7 @@ func (s *StepCommit) Run(ctx context.Context, state multistep.StateBag) multiste
 		}
 	}
 	ui.Say("Committing the container")
-	imageId, err := driver.Commit(containerId, config.Author, config.Changes, config.Message)
+	imageId, err := driver.Commit(containerId, config.Author, config.CommitChanges(), config.Message)
 	if err != nil {
 		state.Put("error", err)
 		ui.Error(err.Error())
@@ -3,6 +3,7 @@ package docker
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer/helper/multistep"
@@ -66,3 +67,25 @@ func TestStepCommit_error(t *testing.T) {
 		t.Fatal("shouldn't save image ID")
 	}
 }
+
+func TestStepCommit_changes(t *testing.T) {
+	state := testStepCommitState(t)
+	step := new(StepCommit)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.WorkDir = "/app"
+	config.Changes = []string{"USER nobody"}
+	driver := state.Get("driver").(*MockDriver)
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// verify the structured fields are compiled before the raw changes
+	expected := []string{"WORKDIR /app", "USER nobody"}
+	if !reflect.DeepEqual(driver.CommitChanges, expected) {
+		t.Fatalf("bad: %#v", driver.CommitChanges)
+	}
+}
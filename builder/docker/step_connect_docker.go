@@ -2,9 +2,6 @@ package docker
 
 import (
 	"context"
-	"fmt"
-	"os/exec"
-	"strings"
 
 	"github.com/hashicorp/packer/helper/multistep"
 )
@@ -17,6 +14,18 @@ func (s *StepConnectDocker) Run(ctx context.Context, state multistep.StateBag) m
 	driver := state.Get("driver").(Driver)
 	tempDir := state.Get("temp_dir").(string)
 
+	if config.FileTransfer == FileTransferArchive {
+		comm := &ArchiveCommunicator{
+			ContainerID: containerId,
+			Driver:      driver,
+			Config:      config,
+			EntryPoint:  []string{"/bin/sh", "-c"},
+			HostDir:     tempDir,
+		}
+		state.Put("communicator", comm)
+		return multistep.ActionContinue
+	}
+
 	// Get the version so we can pass it to the communicator
 	version, err := driver.Version()
 	if err != nil {
@@ -24,7 +33,7 @@ func (s *StepConnectDocker) Run(ctx context.Context, state multistep.StateBag) m
 		return multistep.ActionHalt
 	}
 
-	containerUser, err := getContainerUser(containerId)
+	containerUser, err := driver.ContainerUser(containerId)
 	if err != nil {
 		state.Put("error", err)
 		return multistep.ActionHalt
@@ -61,16 +70,3 @@ func (s *StepConnectDocker) Run(ctx context.Context, state multistep.StateBag) m
 }
 
 func (s *StepConnectDocker) Cleanup(state multistep.StateBag) {}
-
-func getContainerUser(containerId string) (string, error) {
-	inspectArgs := []string{"docker", "inspect", "--format", "{{.Config.User}}", containerId}
-	stdout, err := exec.Command(inspectArgs[0], inspectArgs[1:]...).Output()
-	if err != nil {
-		errStr := fmt.Sprintf("Failed to inspect the container: %s", err)
-		if ee, ok := err.(*exec.ExitError); ok {
-			errStr = fmt.Sprintf("%s, %s", errStr, ee.Stderr)
-		}
-		return "", fmt.Errorf(errStr)
-	}
-	return strings.TrimSpace(string(stdout)), nil
-}
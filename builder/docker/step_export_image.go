@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepExportImage exports the image committed by StepCommit to a
+// docker-archive or OCI layout file, squashing its layers if requested. The
+// committed image is only used for the export and is deleted on cleanup.
+type StepExportImage struct {
+	imageId string
+}
+
+func (s *StepExportImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	s.imageId = state.Get("image_id").(string)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	dir, err := ioutil.TempDir("", "packer-docker-export")
+	if err != nil {
+		return halt(fmt.Errorf("Error creating temporary directory: %s", err))
+	}
+	defer os.RemoveAll(dir)
+
+	ui.Say("Saving the image")
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(driver.SaveImage(s.imageId, pw))
+	}()
+	archive, err := readImageArchive(pr, dir)
+	pr.CloseWithError(err)
+	if err != nil {
+		return halt(fmt.Errorf("Error saving the image: %s", err))
+	}
+
+	if config.Squash {
+		base, err := driver.ImageLayers(config.Image)
+		if err != nil {
+			return halt(fmt.Errorf("Error reading the layers of the base image: %s", err))
+		}
+		ui.Say(fmt.Sprintf("Squashing the layers added to the %d layers of the base image", len(base)))
+		if err := archive.squash(base); err != nil {
+			return halt(fmt.Errorf("Error squashing the image: %s", err))
+		}
+	}
+
+	// Make the directory we're exporting to if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(config.ExportPath), 0755); err != nil {
+		return halt(err)
+	}
+	f, err := os.Create(config.ExportPath)
+	if err != nil {
+		return halt(fmt.Errorf("Error creating output file: %s", err))
+	}
+
+	ui.Say(fmt.Sprintf("Exporting the image as %s", config.ExportFormat))
+	var digests *imageDigests
+	if config.ExportFormat == ExportFormatOCI {
+		digests, err = archive.writeOCILayout(f)
+	} else {
+		digests, err = archive.writeDockerArchive(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(config.ExportPath)
+		return halt(fmt.Errorf("Error exporting the image: %s", err))
+	}
+
+	state.Put("image_digests", digests)
+	ui.Message(fmt.Sprintf("Image digest: %s", digests.Image))
+
+	return multistep.ActionContinue
+}
+
+func (s *StepExportImage) Cleanup(state multistep.StateBag) {
+	if s.imageId == "" {
+		return
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	if err := driver.DeleteImage(s.imageId); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting the temporary image %s: %s", s.imageId, err))
+	}
+}
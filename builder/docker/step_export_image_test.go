@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func testStepExportImageState(t *testing.T) multistep.StateBag {
+	state := testState(t)
+	state.Put("image_id", "foo")
+	return state
+}
+
+func TestStepExportImage_impl(t *testing.T) {
+	var _ multistep.Step = new(StepExportImage)
+}
+
+func TestStepExportImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{ExportFormatDockerArchive, ExportFormatOCI} {
+		state := testStepExportImageState(t)
+		step := new(StepExportImage)
+
+		config := state.Get("config").(*Config)
+		config.ExportPath = filepath.Join(dir, format, "image.tar")
+		config.ExportFormat = format
+		config.Squash = true
+		driver := state.Get("driver").(*MockDriver)
+		driver.SaveImageReader = bytes.NewReader(testDockerArchive(t))
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("%s: bad action: %#v", format, action)
+		}
+		if !driver.ImageLayersCalled || driver.ImageLayersId != config.Image {
+			t.Fatalf("%s: should read the layers of the base image", format)
+		}
+		if driver.SaveImageId != "foo" {
+			t.Fatalf("%s: bad: %#v", format, driver.SaveImageId)
+		}
+
+		digests := state.Get("image_digests").(*imageDigests)
+		if len(digests.Layers) != 1 {
+			t.Fatalf("%s: should be squashed: %#v", format, digests)
+		}
+		if _, err := os.Stat(config.ExportPath); err != nil {
+			t.Fatalf("%s: err: %s", format, err)
+		}
+
+		// The temporary image is deleted
+		step.Cleanup(state)
+		if !driver.DeleteImageCalled || driver.DeleteImageId != "foo" {
+			t.Fatalf("%s: should delete the image", format)
+		}
+	}
+}
+
+func TestStepExportImage_error(t *testing.T) {
+	state := testStepExportImageState(t)
+	step := new(StepExportImage)
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	config := state.Get("config").(*Config)
+	config.ExportPath = tf.Name()
+	config.ExportFormat = ExportFormatOCI
+	driver := state.Get("driver").(*MockDriver)
+	driver.SaveImageError = errors.New("foo")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+
+	step.Cleanup(state)
+	if !driver.DeleteImageCalled {
+		t.Fatal("should delete the image")
+	}
+}
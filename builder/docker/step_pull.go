@@ -21,7 +21,11 @@ func (s *StepPull) Run(ctx context.Context, state multistep.StateBag) multistep.
 		return multistep.ActionContinue
 	}
 
-	ui.Say(fmt.Sprintf("Pulling Docker image: %s", config.Image))
+	if config.Platform != "" {
+		ui.Say(fmt.Sprintf("Pulling Docker image: %s (%s)", config.Image, config.Platform))
+	} else {
+		ui.Say(fmt.Sprintf("Pulling Docker image: %s", config.Image))
+	}
 
 	if config.EcrLogin {
 		ui.Message("Fetching ECR credentials...")
@@ -59,7 +63,7 @@ func (s *StepPull) Run(ctx context.Context, state multistep.StateBag) multistep.
 		}()
 	}
 
-	if err := driver.Pull(config.Image); err != nil {
+	if err := driver.Pull(config.Image, config.Platform); err != nil {
 		err := fmt.Errorf("Error pulling Docker image: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
@@ -102,3 +102,23 @@ func TestStepPull_noPull(t *testing.T) {
 		t.Fatal("shouldn't have pulled")
 	}
 }
+
+func TestStepPull_platform(t *testing.T) {
+	state := testState(t)
+	step := new(StepPull)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.Platform = "linux/arm64"
+	driver := state.Get("driver").(*MockDriver)
+
+	// run the step
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// verify we did the right thing
+	if driver.PullPlatform != "linux/arm64" {
+		t.Fatalf("bad: %#v", driver.PullPlatform)
+	}
+}
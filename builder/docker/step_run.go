@@ -23,12 +23,15 @@ func (s *StepRun) Run(ctx context.Context, state multistep.StateBag) multistep.S
 		RunCommand: config.RunCommand,
 		Volumes:    make(map[string]string),
 		Privileged: config.Privileged,
+		Platform:   config.Platform,
 	}
 
 	for host, container := range config.Volumes {
 		runConfig.Volumes[host] = container
 	}
-	runConfig.Volumes[tempDir] = config.ContainerDir
+	if config.FileTransfer == FileTransferMount {
+		runConfig.Volumes[tempDir] = config.ContainerDir
+	}
 
 	ui.Say("Starting docker container...")
 	containerId, err := driver.StartContainer(&runConfig)
@@ -3,6 +3,7 @@ package docker
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer/helper/multistep"
@@ -24,6 +25,7 @@ func TestStepRun(t *testing.T) {
 	defer step.Cleanup(state)
 
 	config := state.Get("config").(*Config)
+	config.Platform = "linux/arm64"
 	driver := state.Get("driver").(*MockDriver)
 	driver.StartID = "foo"
 
@@ -39,6 +41,9 @@ func TestStepRun(t *testing.T) {
 	if driver.StartConfig.Image != config.Image {
 		t.Fatalf("bad: %#v", driver.StartConfig.Image)
 	}
+	if driver.StartConfig.Platform != config.Platform {
+		t.Fatalf("bad: %#v", driver.StartConfig.Platform)
+	}
 
 	// verify the ID is saved
 	idRaw, ok := state.GetOk("container_id")
@@ -66,6 +71,27 @@ func TestStepRun(t *testing.T) {
 	}
 }
 
+func TestStepRun_archiveFileTransfer(t *testing.T) {
+	state := testStepRunState(t)
+	step := new(StepRun)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.FileTransfer = FileTransferArchive
+	config.Volumes = map[string]string{"/data": "/data"}
+	driver := state.Get("driver").(*MockDriver)
+	driver.StartID = "foo"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// only the configured volumes are mounted
+	if !reflect.DeepEqual(driver.StartConfig.Volumes, config.Volumes) {
+		t.Fatalf("bad: %#v", driver.StartConfig.Volumes)
+	}
+}
+
 func TestStepRun_error(t *testing.T) {
 	state := testStepRunState(t)
 	step := new(StepRun)
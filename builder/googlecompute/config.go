@@ -78,7 +78,23 @@ type Config struct {
 	// image name. The image family always returns its latest image that is not
 	// deprecated.
 	ImageFamily string `mapstructure:"image_family" required:"false"`
-	// Key/value pair labels to apply to the created image.
+	// If true, the other active images of image_family are marked
+	// DEPRECATED once the image is created, with the image as their
+	// replacement. Images which are already deprecated, obsolete or deleted
+	// are left untouched.
+	ImageDeprecatePrevious bool `mapstructure:"image_deprecate_previous" required:"false"`
+	// The time after which the images deprecated by image_deprecate_previous
+	// are scheduled to be OBSOLETE, as a duration such as "720h". GCE only
+	// records the date: the state of the images does not change by itself.
+	ImageObsoleteAfter string `mapstructure:"image_obsolete_after" required:"false"`
+	// The time after which the images deprecated by image_deprecate_previous
+	// are scheduled to be DELETED, as a duration such as "2160h". GCE only
+	// records the date: the images are not deleted by themselves.
+	ImageDeleteAfter string `mapstructure:"image_delete_after" required:"false"`
+	// Key/value pair labels to apply to the created image. The values are
+	// [templates](/docs/templates/engine.html) which can use the [build
+	// template data](#build-template-data) of the source image, for example
+	// `{{ .SourceImage }}`.
 	ImageLabels map[string]string `mapstructure:"image_labels" required:"false"`
 	// Licenses to apply to the created image.
 	ImageLicenses []string `mapstructure:"image_licenses" required:"false"`
@@ -183,6 +199,8 @@ type Config struct {
 
 	Account            *jwt.Config
 	stateTimeout       time.Duration
+	imageObsoleteAfter time.Duration
+	imageDeleteAfter   time.Duration
 	imageAlreadyExists bool
 	ctx                interpolate.Context
 }
@@ -196,6 +214,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"run_command",
+				"image_labels",
 			},
 		},
 	}, raws...)
@@ -280,6 +299,21 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		}
 	}
 
+	if c.ImageDeprecatePrevious && c.ImageFamily == "" {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("image_deprecate_previous requires image_family to be set"))
+	}
+
+	if c.ImageObsoleteAfter != "" || c.ImageDeleteAfter != "" {
+		if !c.ImageDeprecatePrevious {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("image_obsolete_after and image_delete_after require image_deprecate_previous to be true"))
+		}
+		if es := c.calcDeprecationDates(); len(es) > 0 {
+			errs = packer.MultiErrorAppend(errs, es...)
+		}
+	}
+
 	if c.InstanceName == "" {
 		c.InstanceName = fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
 	}
@@ -383,6 +417,38 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	return c, nil, nil
 }
 
+// calcDeprecationDates parses the durations after which the previous images
+// of the family are scheduled to be obsolete and deleted.
+func (c *Config) calcDeprecationDates() []error {
+	var errs []error
+
+	if c.ImageObsoleteAfter != "" {
+		d, err := time.ParseDuration(c.ImageObsoleteAfter)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing image_obsolete_after: %s", err))
+		} else if d <= 0 {
+			errs = append(errs, errors.New("image_obsolete_after must be positive"))
+		}
+		c.imageObsoleteAfter = d
+	}
+
+	if c.ImageDeleteAfter != "" {
+		d, err := time.ParseDuration(c.ImageDeleteAfter)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing image_delete_after: %s", err))
+		} else if d <= 0 {
+			errs = append(errs, errors.New("image_delete_after must be positive"))
+		}
+		c.imageDeleteAfter = d
+	}
+
+	if c.imageObsoleteAfter > 0 && c.imageDeleteAfter > 0 && c.imageDeleteAfter < c.imageObsoleteAfter {
+		errs = append(errs, errors.New("image_delete_after must not be shorter than image_obsolete_after"))
+	}
+
+	return errs
+}
+
 func (c *Config) CalcTimeout() error {
 	stateTimeout, err := time.ParseDuration(c.RawStateTimeout)
 	if err != nil {
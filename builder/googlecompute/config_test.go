@@ -362,6 +362,71 @@ func TestConfigPrepareServiceAccount(t *testing.T) {
 	}
 }
 
+func TestConfigPrepareImageDeprecation(t *testing.T) {
+	cases := []struct {
+		Keys   []string
+		Values []interface{}
+		Err    bool
+	}{
+		{
+			[]string{"image_deprecate_previous", "image_obsolete_after", "image_delete_after"},
+			[]interface{}{true, "720h", "2160h"},
+			false,
+		},
+		{
+			[]string{"image_deprecate_previous", "image_family"},
+			[]interface{}{true, nil},
+			true,
+		},
+		{
+			[]string{"image_deprecate_previous", "image_obsolete_after"},
+			[]interface{}{false, "720h"},
+			true,
+		},
+		{
+			[]string{"image_deprecate_previous", "image_delete_after"},
+			[]interface{}{true, "SO BAD"},
+			true,
+		},
+		{
+			[]string{"image_deprecate_previous", "image_obsolete_after"},
+			[]interface{}{true, "-1h"},
+			true,
+		},
+		{
+			[]string{"image_deprecate_previous", "image_obsolete_after", "image_delete_after"},
+			[]interface{}{true, "720h", "24h"},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		raw, tempfile := testConfig(t)
+		defer os.Remove(tempfile)
+
+		errStr := ""
+		for k := range tc.Keys {
+
+			// Create the string for error reporting
+			// convert value to string if it can be converted
+			errStr += fmt.Sprintf("%s:%v, ", tc.Keys[k], tc.Values[k])
+			if tc.Values[k] == nil {
+				delete(raw, tc.Keys[k])
+			} else {
+				raw[tc.Keys[k]] = tc.Values[k]
+			}
+		}
+
+		_, warns, errs := NewConfig(raw)
+
+		if tc.Err {
+			testConfigErr(t, warns, errs, strings.TrimRight(errStr, ", "))
+		} else {
+			testConfigOk(t, warns, errs)
+		}
+	}
+}
+
 func TestConfigPrepareStartupScriptFile(t *testing.T) {
 	config := map[string]interface{}{
 		"project_id":          "project",
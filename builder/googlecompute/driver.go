@@ -18,6 +18,10 @@ type Driver interface {
 	// DeleteImage deletes the image with the given name.
 	DeleteImage(name string) <-chan error
 
+	// DeprecateImage sets the deprecation status of the image with the
+	// given name.
+	DeprecateImage(name string, status *compute.DeprecationStatus) <-chan error
+
 	// DeleteInstance deletes the given instance, keeping the boot disk.
 	DeleteInstance(zone, name string) (<-chan error, error)
 
@@ -49,6 +53,10 @@ type Driver interface {
 	// occurs calling the API, this method returns false.
 	ImageExists(name string) bool
 
+	// ListFamilyImages lists the images of the given family in the project,
+	// including the deprecated ones.
+	ListFamilyImages(family string) ([]*Image, error)
+
 	// RunInstance takes the given config and launches an instance.
 	RunInstance(*InstanceConfig) (<-chan error, error)
 
@@ -189,6 +189,18 @@ func (d *driverGCE) DeleteImage(name string) <-chan error {
 	return errCh
 }
 
+func (d *driverGCE) DeprecateImage(name string, status *compute.DeprecationStatus) <-chan error {
+	errCh := make(chan error, 1)
+	op, err := d.service.Images.Deprecate(d.projectId, name, status).Do()
+	if err != nil {
+		errCh <- err
+	} else {
+		go waitForState(errCh, "DONE", d.refreshGlobalOp(op))
+	}
+
+	return errCh
+}
+
 func (d *driverGCE) DeleteInstance(zone, name string) (<-chan error, error) {
 	op, err := d.service.Instances.Delete(d.projectId, zone, name).Do()
 	if err != nil {
@@ -267,13 +279,25 @@ func (d *driverGCE) GetImageFromProject(project, name string, fromFamily bool) (
 	} else if image == nil || image.SelfLink == "" {
 		return nil, fmt.Errorf("Image, %s, could not be found in project: %s", name, project)
 	} else {
-		return &Image{
-			Licenses:  image.Licenses,
-			Name:      image.Name,
-			ProjectId: project,
-			SelfLink:  image.SelfLink,
-			SizeGb:    image.DiskSizeGb,
-		}, nil
+		return newImage(project, image), nil
+	}
+}
+
+func newImage(project string, image *compute.Image) *Image {
+	var state string
+	if image.Deprecated != nil {
+		state = image.Deprecated.State
+	}
+
+	return &Image{
+		DeprecationState: state,
+		Family:           image.Family,
+		Labels:           image.Labels,
+		Licenses:         image.Licenses,
+		Name:             image.Name,
+		ProjectId:        project,
+		SelfLink:         image.SelfLink,
+		SizeGb:           image.DiskSizeGb,
 	}
 }
 
@@ -344,6 +368,23 @@ func (d *driverGCE) ImageExists(name string) bool {
 	return err == nil
 }
 
+func (d *driverGCE) ListFamilyImages(family string) ([]*Image, error) {
+	var images []*Image
+	err := d.service.Images.List(d.projectId).
+		Filter(fmt.Sprintf("family = %q", family)).
+		Pages(context.TODO(), func(list *compute.ImageList) error {
+			for _, image := range list.Items {
+				images = append(images, newImage(d.projectId, image))
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
 func (d *driverGCE) RunInstance(c *InstanceConfig) (<-chan error, error) {
 	// Get the zone
 	d.ui.Message(fmt.Sprintf("Loading zone: %s", c.Zone))
@@ -26,6 +26,10 @@ type DriverMock struct {
 	DeleteImageName  string
 	DeleteImageErrCh <-chan error
 
+	DeprecateImageNames  []string
+	DeprecateImageStatus *compute.DeprecationStatus
+	DeprecateImageErrCh  <-chan error
+
 	DeleteInstanceZone  string
 	DeleteInstanceName  string
 	DeleteInstanceErrCh <-chan error
@@ -71,6 +75,10 @@ type DriverMock struct {
 	ImageExistsName   string
 	ImageExistsResult bool
 
+	ListFamilyImagesFamily string
+	ListFamilyImagesResult []*Image
+	ListFamilyImagesErr    error
+
 	RunInstanceConfig *InstanceConfig
 	RunInstanceErrCh  <-chan error
 	RunInstanceErr    error
@@ -146,6 +154,20 @@ func (d *DriverMock) DeleteImage(name string) <-chan error {
 	return resultCh
 }
 
+func (d *DriverMock) DeprecateImage(name string, status *compute.DeprecationStatus) <-chan error {
+	d.DeprecateImageNames = append(d.DeprecateImageNames, name)
+	d.DeprecateImageStatus = status
+
+	resultCh := d.DeprecateImageErrCh
+	if resultCh == nil {
+		ch := make(chan error)
+		close(ch)
+		resultCh = ch
+	}
+
+	return resultCh
+}
+
 func (d *DriverMock) DeleteInstance(zone, name string) (<-chan error, error) {
 	d.DeleteInstanceZone = zone
 	d.DeleteInstanceName = name
@@ -217,6 +239,11 @@ func (d *DriverMock) ImageExists(name string) bool {
 	return d.ImageExistsResult
 }
 
+func (d *DriverMock) ListFamilyImages(family string) ([]*Image, error) {
+	d.ListFamilyImagesFamily = family
+	return d.ListFamilyImagesResult, d.ListFamilyImagesErr
+}
+
 func (d *DriverMock) RunInstance(c *InstanceConfig) (<-chan error, error) {
 	d.RunInstanceConfig = c
 
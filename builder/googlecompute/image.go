@@ -5,12 +5,15 @@ import (
 )
 
 type Image struct {
-	Labels    map[string]string
-	Licenses  []string
-	Name      string
-	ProjectId string
-	SelfLink  string
-	SizeGb    int64
+	// The deprecation state of the image, empty for an active image.
+	DeprecationState string
+	Family           string
+	Labels           map[string]string
+	Licenses         []string
+	Name             string
+	ProjectId        string
+	SelfLink         string
+	SizeGb           int64
 }
 
 func (i *Image) IsWindows() bool {
@@ -0,0 +1,32 @@
+package googlecompute
+
+import (
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+// BuildInfoTemplate is the data available to the templates of image_labels.
+type BuildInfoTemplate struct {
+	BuildZone          string
+	SourceImage        string
+	SourceImageFamily  string
+	SourceImageLabels  map[string]string
+	SourceImageProject string
+}
+
+func extractBuildInfo(zone string, state multistep.StateBag) *BuildInfoTemplate {
+	rawSourceImage, hasSourceImage := state.GetOk("source_image")
+	if !hasSourceImage {
+		return &BuildInfoTemplate{
+			BuildZone: zone,
+		}
+	}
+
+	sourceImage := rawSourceImage.(*Image)
+	return &BuildInfoTemplate{
+		BuildZone:          zone,
+		SourceImage:        sourceImage.Name,
+		SourceImageFamily:  sourceImage.Family,
+		SourceImageLabels:  sourceImage.Labels,
+		SourceImageProject: sourceImage.ProjectId,
+	}
+}
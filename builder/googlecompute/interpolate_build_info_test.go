@@ -0,0 +1,42 @@
+package googlecompute
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestInterpolateBuildInfo_extractBuildInfo_noSourceImage(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	buildInfo := extractBuildInfo("us-east1-a", state)
+
+	expected := BuildInfoTemplate{
+		BuildZone: "us-east1-a",
+	}
+	if !reflect.DeepEqual(*buildInfo, expected) {
+		t.Fatalf("Unexpected BuildInfoTemplate: expected %#v got %#v\n", expected, *buildInfo)
+	}
+}
+
+func TestInterpolateBuildInfo_extractBuildInfo_withSourceImage(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("source_image", &Image{
+		Family:    "debian-9",
+		Labels:    map[string]string{"key-1": "value-1"},
+		Name:      "debian-9-stretch-v20191014",
+		ProjectId: "debian-cloud",
+	})
+	buildInfo := extractBuildInfo("us-east1-a", state)
+
+	expected := BuildInfoTemplate{
+		BuildZone:          "us-east1-a",
+		SourceImage:        "debian-9-stretch-v20191014",
+		SourceImageFamily:  "debian-9",
+		SourceImageLabels:  map[string]string{"key-1": "value-1"},
+		SourceImageProject: "debian-cloud",
+	}
+	if !reflect.DeepEqual(*buildInfo, expected) {
+		t.Fatalf("Unexpected BuildInfoTemplate: expected %#v got %#v\n", expected, *buildInfo)
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	compute "google.golang.org/api/compute/v1"
 )
 
 // StepCreateImage represents a Packer build step that creates GCE machine
@@ -36,11 +38,26 @@ func (s *StepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 		}
 	}
 
+	// The labels are rendered with the build info of the source image
+	labels := make(map[string]string, len(config.ImageLabels))
+	ictx := config.ctx
+	ictx.Data = extractBuildInfo(config.Zone, state)
+	for key, value := range config.ImageLabels {
+		renderedValue, err := interpolate.Render(value, &ictx)
+		if err != nil {
+			err := fmt.Errorf("Error processing image label: %s:%s - %s", key, value, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		labels[key] = renderedValue
+	}
+
 	ui.Say("Creating image...")
 
 	imageCh, errCh := driver.CreateImage(
 		config.ImageName, config.ImageDescription, config.ImageFamily, config.Zone,
-		config.DiskName, config.ImageLabels, config.ImageLicenses, config.ImageEncryptionKey)
+		config.DiskName, labels, config.ImageLicenses, config.ImageEncryptionKey)
 	var err error
 	select {
 	case err = <-errCh:
@@ -55,9 +72,64 @@ func (s *StepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 		return multistep.ActionHalt
 	}
 
-	state.Put("image", <-imageCh)
+	image := <-imageCh
+	state.Put("image", image)
+
+	if config.ImageDeprecatePrevious {
+		if err := s.deprecatePreviousImages(config, driver, ui, image); err != nil {
+			err := fmt.Errorf("Error deprecating previous images: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	return multistep.ActionContinue
 }
 
+// deprecatePreviousImages marks the other active images of the family
+// DEPRECATED, with the new image as their replacement, and schedules the
+// dates they become OBSOLETE and DELETED.
+func (s *StepCreateImage) deprecatePreviousImages(config *Config, driver Driver, ui packer.Ui, image *Image) error {
+	images, err := driver.ListFamilyImages(config.ImageFamily)
+	if err != nil {
+		return err
+	}
+
+	status := &compute.DeprecationStatus{
+		State:       "DEPRECATED",
+		Replacement: image.SelfLink,
+	}
+	now := time.Now().UTC()
+	if config.imageObsoleteAfter > 0 {
+		status.Obsolete = now.Add(config.imageObsoleteAfter).Format(time.RFC3339)
+	}
+	if config.imageDeleteAfter > 0 {
+		status.Deleted = now.Add(config.imageDeleteAfter).Format(time.RFC3339)
+	}
+
+	for _, previous := range images {
+		if previous.Name == image.Name {
+			continue
+		}
+		if previous.DeprecationState != "" && previous.DeprecationState != "ACTIVE" {
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Deprecating image %s...", previous.Name))
+		errCh := driver.DeprecateImage(previous.Name, status)
+		select {
+		case err = <-errCh:
+		case <-time.After(config.stateTimeout):
+			err = errors.New("time out while waiting for image to be deprecated")
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", previous.Name, err)
+		}
+	}
+
+	return nil
+}
+
 // Cleanup.
 func (s *StepCreateImage) Cleanup(state multistep.StateBag) {}
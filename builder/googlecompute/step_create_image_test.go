@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/stretchr/testify/assert"
@@ -69,3 +70,84 @@ func TestStepCreateImage_errorOnChannel(t *testing.T) {
 	_, ok = state.GetOk("image_name")
 	assert.False(t, ok, "State should not have a resulting image.")
 }
+
+func TestStepCreateImage_labelsBuildInfo(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*DriverMock)
+
+	c.ImageLabels = map[string]string{
+		"source-image":   "{{ .SourceImage }}",
+		"source-project": "{{ .SourceImageProject }}",
+		"team":           "infra",
+	}
+	state.Put("source_image", &Image{
+		Name:      "debian-9-stretch-v20191014",
+		ProjectId: "debian-cloud",
+	})
+
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, action, multistep.ActionContinue, "Step did not pass.")
+
+	expected := map[string]string{
+		"source-image":   "debian-9-stretch-v20191014",
+		"source-project": "debian-cloud",
+		"team":           "infra",
+	}
+	assert.Equal(t, expected, d.CreateImageLabels, "Incorrect image_labels passed to driver.")
+}
+
+func TestStepCreateImage_deprecatePrevious(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*DriverMock)
+
+	c.ImageDeprecatePrevious = true
+	c.imageObsoleteAfter = 24 * time.Hour
+	d.CreateImageResultSelfLink = "https://www.googleapis.com/compute/v1/projects/test/global/images/new"
+	d.ListFamilyImagesResult = []*Image{
+		{Name: c.ImageName},
+		{Name: "active"},
+		{Name: "explicitly-active", DeprecationState: "ACTIVE"},
+		{Name: "deprecated", DeprecationState: "DEPRECATED"},
+		{Name: "obsolete", DeprecationState: "OBSOLETE"},
+	}
+
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, action, multistep.ActionContinue, "Step did not pass.")
+
+	assert.Equal(t, c.ImageFamily, d.ListFamilyImagesFamily, "Incorrect family passed to driver.")
+	assert.Equal(t, []string{"active", "explicitly-active"}, d.DeprecateImageNames, "Incorrect images deprecated.")
+
+	status := d.DeprecateImageStatus
+	assert.Equal(t, "DEPRECATED", status.State, "Incorrect deprecation state.")
+	assert.Equal(t, d.CreateImageResultSelfLink, status.Replacement, "Incorrect replacement image.")
+	assert.Equal(t, "", status.Deleted, "Deleted date should not be set.")
+	obsolete, err := time.Parse(time.RFC3339, status.Obsolete)
+	assert.NoError(t, err, "Obsolete date should be a RFC 3339 date.")
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), obsolete, time.Minute, "Incorrect obsolete date.")
+}
+
+func TestStepCreateImage_deprecatePreviousError(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateImage)
+	defer step.Cleanup(state)
+
+	c := state.Get("config").(*Config)
+	d := state.Get("driver").(*DriverMock)
+
+	c.ImageDeprecatePrevious = true
+	d.ListFamilyImagesErr = errors.New("error")
+
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, action, multistep.ActionHalt, "Step should not have passed.")
+	_, ok := state.GetOk("error")
+	assert.True(t, ok, "State should have an error.")
+	assert.Empty(t, d.DeprecateImageNames, "No image should be deprecated.")
+}
@@ -106,6 +106,7 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 	}
 
 	ui.Say(fmt.Sprintf("Using image: %s", sourceImage.Name))
+	state.Put("source_image", sourceImage)
 
 	if sourceImage.IsWindows() && c.Comm.Type == "winrm" && c.Comm.WinRMPassword == "" {
 		state.Put("create_windows_password", true)
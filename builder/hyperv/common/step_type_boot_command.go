@@ -76,6 +76,7 @@ func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionHalt
 	}
 
+	ctx = bootcommand.WithHTTPCallback(ctx, common.HTTPCallback(state))
 	if err := seq.Do(ctx, d); err != nil {
 		err := fmt.Errorf("Error running boot command: %s", err)
 		state.Put("error", err)
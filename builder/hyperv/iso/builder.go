@@ -205,6 +205,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"http_content",
 			},
 		},
 	}, raws...)
@@ -467,6 +468,8 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Url:          b.config.ISOUrls,
 			Extension:    b.config.TargetExtension,
 			TargetPath:   b.config.TargetPath,
+			Connections:  b.config.DownloadConnections,
+			RateLimit:    b.config.DownloadRateLimit,
 		},
 		&common.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
@@ -474,9 +477,12 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:       b.config.HTTPDir,
+			HTTPContent:   b.config.HTTPContent,
+			HTTPPortMin:   b.config.HTTPPortMin,
+			HTTPPortMax:   b.config.HTTPPortMax,
+			HTTPTemplates: b.config.HTTPTemplates,
+			Ctx:           b.config.ctx,
 		},
 		&hypervcommon.StepCreateSwitch{
 			SwitchName: b.config.SwitchName,
@@ -539,6 +545,9 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Ctx:           b.config.ctx,
 			GroupInterval: b.config.BootConfig.BootGroupInterval,
 		},
+		&common.StepWaitForHTTPCallback{
+			Timeout: b.config.WaitForHTTPCallback,
+		},
 
 		// configure the communicator ssh, winrm
 		&communicator.StepConnect{
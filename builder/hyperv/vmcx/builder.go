@@ -181,6 +181,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"http_content",
 			},
 		},
 	}, raws...)
@@ -489,6 +490,8 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 				Url:          b.config.ISOUrls,
 				Extension:    b.config.TargetExtension,
 				TargetPath:   b.config.TargetPath,
+				Connections:  b.config.DownloadConnections,
+				RateLimit:    b.config.DownloadRateLimit,
 			},
 		)
 	}
@@ -500,9 +503,12 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:       b.config.HTTPDir,
+			HTTPContent:   b.config.HTTPContent,
+			HTTPPortMin:   b.config.HTTPPortMin,
+			HTTPPortMax:   b.config.HTTPPortMax,
+			HTTPTemplates: b.config.HTTPTemplates,
+			Ctx:           b.config.ctx,
 		},
 		&hypervcommon.StepCreateSwitch{
 			SwitchName: b.config.SwitchName,
@@ -563,6 +569,9 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Ctx:           b.config.ctx,
 			GroupInterval: b.config.BootConfig.BootGroupInterval,
 		},
+		&common.StepWaitForHTTPCallback{
+			Timeout: b.config.WaitForHTTPCallback,
+		},
 
 		// configure the communicator ssh, winrm
 		&communicator.StepConnect{
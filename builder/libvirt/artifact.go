@@ -0,0 +1,38 @@
+package libvirt
+
+import (
+	"fmt"
+	"os"
+)
+
+// Artifact is the result of running the libvirt builder: the disks of the
+// domain and its definition.
+type Artifact struct {
+	dir   string
+	f     []string
+	state map[string]interface{}
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return a.f
+}
+
+func (*Artifact) Id() string {
+	return "VM"
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("Domain disks and definition in directory: %s", a.dir)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return a.state[name]
+}
+
+func (a *Artifact) Destroy() error {
+	return os.RemoveAll(a.dir)
+}
@@ -0,0 +1,168 @@
+// The libvirt package contains a packer.Builder implementation that builds
+// a transient libvirt domain, and exports its disks and definition.
+package libvirt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/communicator"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// The unique ID for this builder
+const BuilderId = "packer.libvirt"
+
+type Builder struct {
+	config *Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	c, warnings, errs := NewConfig(raws...)
+	if errs != nil {
+		return warnings, errs
+	}
+	b.config = c
+
+	return warnings, nil
+}
+
+func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
+	driver, err := newDriver(b.config.LibvirtURI)
+	if err != nil {
+		return nil, fmt.Errorf("Failed creating libvirt driver: %s", err)
+	}
+
+	steps := []multistep.Step{
+		&common.StepDownload{
+			Checksum:     b.config.ISOChecksum,
+			ChecksumType: b.config.ISOChecksumType,
+			Description:  "ISO",
+			Extension:    b.config.TargetExtension,
+			ResultKey:    "iso_path",
+			TargetPath:   b.config.TargetPath,
+			Url:          b.config.ISOUrls,
+			Connections:  b.config.DownloadConnections,
+			RateLimit:    b.config.DownloadRateLimit,
+		},
+		&common.StepOutputDir{
+			Force: b.config.PackerForce,
+			Path:  b.config.OutputDir,
+		},
+		new(stepCreateDisks),
+		&common.StepHTTPServer{
+			HTTPDir:       b.config.HTTPDir,
+			HTTPContent:   b.config.HTTPContent,
+			HTTPPortMin:   b.config.HTTPPortMin,
+			HTTPPortMax:   b.config.HTTPPortMax,
+			HTTPTemplates: b.config.HTTPTemplates,
+			Ctx:           b.config.ctx,
+		},
+		new(stepCreateDomain),
+		new(stepTypeBootCommand),
+		&common.StepWaitForHTTPCallback{
+			Timeout: b.config.WaitForHTTPCallback,
+		},
+		&communicator.StepConnect{
+			Config:    &b.config.Comm,
+			Host:      commHost(b.config.Comm.Host(), b.config.IPAddressSource),
+			SSHConfig: b.config.Comm.SSHConfigFunc(),
+		},
+		new(common.StepProvision),
+		&common.StepCleanupTempKeys{
+			Comm: &b.config.Comm,
+		},
+		new(stepShutdown),
+		new(stepExport),
+	}
+
+	// Setup the state bag
+	state := new(multistep.BasicStateBag)
+	state.Put("config", b.config)
+	state.Put("debug", b.config.PackerDebug)
+	state.Put("driver", driver)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+
+	// Run
+	b.runner = common.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
+	b.runner.Run(ctx, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	// Compile the artifact list
+	files := make([]string, 0, 5)
+	visit := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+
+		return nil
+	}
+
+	if err := filepath.Walk(b.config.OutputDir, visit); err != nil {
+		return nil, err
+	}
+
+	artifact := &Artifact{
+		dir:   b.config.OutputDir,
+		f:     files,
+		state: make(map[string]interface{}),
+	}
+
+	artifact.state["diskName"] = b.config.VMName
+	artifact.state["diskPaths"] = state.Get("disk_paths")
+	artifact.state["diskType"] = b.config.Format
+	artifact.state["domainType"] = b.config.DomainType
+	artifact.state["domainXMLPath"] = state.Get("domain_xml_path")
+
+	return artifact, nil
+}
+
+func newDriver(uri string) (Driver, error) {
+	virshPath, err := exec.LookPath("virsh")
+	if err != nil {
+		return nil, err
+	}
+
+	qemuImgPath, err := exec.LookPath("qemu-img")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("virsh path: %s, qemu-img path: %s", virshPath, qemuImgPath)
+	driver := &VirshDriver{
+		URI:         uri,
+		VirshPath:   virshPath,
+		QemuImgPath: qemuImgPath,
+	}
+
+	if err := driver.Verify(); err != nil {
+		return nil, err
+	}
+
+	return driver, nil
+}
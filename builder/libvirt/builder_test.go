@@ -0,0 +1,184 @@
+package libvirt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"iso_checksum":            "foo",
+		"iso_checksum_type":       "md5",
+		"iso_url":                 "http://www.google.com/",
+		"ssh_username":            "foo",
+		packer.BuildNameConfigKey: "foo",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Error("Builder must implement builder.")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.LibvirtURI != "qemu:///system" {
+		t.Errorf("bad libvirt uri: %s", b.config.LibvirtURI)
+	}
+	if b.config.DomainType != "kvm" {
+		t.Errorf("bad domain type: %s", b.config.DomainType)
+	}
+	if b.config.VMName != "packer-foo" {
+		t.Errorf("bad vm name: %s", b.config.VMName)
+	}
+	if b.config.OutputDir != "output-foo" {
+		t.Errorf("bad output dir: %s", b.config.OutputDir)
+	}
+	if b.config.CpuCount != 1 || b.config.MemorySize != 512 {
+		t.Errorf("bad cpus and memory: %d, %d", b.config.CpuCount, b.config.MemorySize)
+	}
+	if b.config.Firmware != "bios" || b.config.Format != "qcow2" || b.config.Graphics != "vnc" {
+		t.Errorf("bad: %#v", b.config)
+	}
+	if !reflect.DeepEqual(b.config.Disks, []Disk{{Size: "40960M", Bus: "virtio", Cache: "default"}}) {
+		t.Errorf("bad disks: %#v", b.config.Disks)
+	}
+	if !reflect.DeepEqual(b.config.NetworkInterfaces, []NetworkInterface{{Network: "default", Model: "virtio"}}) {
+		t.Errorf("bad network interfaces: %#v", b.config.NetworkInterfaces)
+	}
+	if b.config.IPAddressSource != "lease" {
+		t.Errorf("bad ip address source: %s", b.config.IPAddressSource)
+	}
+}
+
+func TestBuilderPrepare_Disks(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// The size of the disks after the first is required
+	config["disks"] = []map[string]interface{}{
+		{"size": "10G"},
+		{"bus": "sata"},
+	}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad bus
+	config["disks"] = []map[string]interface{}{
+		{"bus": "floppy"},
+	}
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The first disk keeps the size of the disk image
+	config["disks"] = []map[string]interface{}{
+		{"cache": "none"},
+		{"size": "1G", "bus": "scsi"},
+	}
+	config["disk_image"] = true
+	b = Builder{}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	expected := []Disk{
+		{Bus: "virtio", Cache: "none"},
+		{Size: "1G", Bus: "scsi", Cache: "default"},
+	}
+	if !reflect.DeepEqual(b.config.Disks, expected) {
+		t.Fatalf("bad: %#v", b.config.Disks)
+	}
+}
+
+func TestBuilderPrepare_NetworkInterfaces(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["network_interfaces"] = []map[string]interface{}{
+		{"network": "default", "bridge": "br0"},
+	}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The address of a bridged domain is read from the ARP table
+	config["network_interfaces"] = []map[string]interface{}{
+		{"bridge": "br0", "model": "e1000"},
+		{"network": "isolated"},
+	}
+	b = Builder{}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	expected := []NetworkInterface{
+		{Bridge: "br0", Model: "e1000"},
+		{Network: "isolated", Model: "virtio"},
+	}
+	if !reflect.DeepEqual(b.config.NetworkInterfaces, expected) {
+		t.Fatalf("bad: %#v", b.config.NetworkInterfaces)
+	}
+	if b.config.IPAddressSource != "arp" {
+		t.Fatalf("bad: %s", b.config.IPAddressSource)
+	}
+
+	config["ip_address_source"] = "dns"
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Graphics(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["graphics"] = "sdl"
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The boot command is typed over VNC
+	config["graphics"] = "spice"
+	config["boot_command"] = []string{"<enter>"}
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	delete(config, "boot_command")
+	b = Builder{}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_Invalid(t *testing.T) {
+	for k, v := range map[string]string{
+		"firmware":  "coreboot",
+		"format":    "vmdk",
+		"cdrom_bus": "virtio",
+	} {
+		var b Builder
+		config := testConfig()
+		config[k] = v
+		if _, err := b.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", k)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+//go:generate struct-markdown
+
+package libvirt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
+	"github.com/hashicorp/packer/common/shutdowncommand"
+	"github.com/hashicorp/packer/helper/communicator"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+var diskBus = map[string]bool{
+	"virtio": true,
+	"sata":   true,
+	"scsi":   true,
+	"ide":    true,
+}
+
+var diskCache = map[string]bool{
+	"default":      true,
+	"none":         true,
+	"writethrough": true,
+	"writeback":    true,
+	"directsync":   true,
+	"unsafe":       true,
+}
+
+var ipAddressSource = map[string]bool{
+	"lease": true,
+	"arp":   true,
+	"agent": true,
+}
+
+// A disk of the domain.
+type Disk struct {
+	// The size of the disk, with the suffixes of qemu-img such as `M` for
+	// megabytes or `G` for gigabytes. The first disk defaults to `40960M`;
+	// when `disk_image` is true it defaults to the size of the image, which
+	// is grown, and never shrunk, to `size`.
+	Size string `mapstructure:"size" required:"false"`
+	// The bus the disk is attached to: `virtio`, `sata`, `scsi` or `ide`.
+	// Defaults to `virtio`.
+	Bus string `mapstructure:"bus" required:"false"`
+	// The cache mode of the disk: `default`, `none`, `writethrough`,
+	// `writeback`, `directsync` or `unsafe`. Defaults to `default`, the
+	// choice of the hypervisor.
+	Cache string `mapstructure:"cache" required:"false"`
+}
+
+// A network interface of the domain.
+type NetworkInterface struct {
+	// The libvirt network the interface is attached to. Defaults to
+	// `default` when `bridge` is not set.
+	Network string `mapstructure:"network" required:"false"`
+	// The host bridge the interface is attached to, instead of a libvirt
+	// network.
+	Bridge string `mapstructure:"bridge" required:"false"`
+	// The model of the interface, such as `virtio` or `e1000`. Defaults to
+	// `virtio`.
+	Model string `mapstructure:"model" required:"false"`
+	// The MAC address of the interface. By default libvirt generates one.
+	MAC string `mapstructure:"mac" required:"false"`
+}
+
+type Config struct {
+	common.PackerConfig            `mapstructure:",squash"`
+	common.HTTPConfig              `mapstructure:",squash"`
+	common.ISOConfig               `mapstructure:",squash"`
+	bootcommand.VNCConfig          `mapstructure:",squash"`
+	shutdowncommand.ShutdownConfig `mapstructure:",squash"`
+	Comm                           communicator.Config `mapstructure:",squash"`
+	// The URI of the libvirt connection, as given to `virsh --connect`.
+	// Defaults to `qemu:///system`.
+	LibvirtURI string `mapstructure:"libvirt_uri" required:"false"`
+	// The type of the domain, such as `kvm` or `qemu` for a domain without
+	// hardware acceleration. Defaults to `kvm`.
+	DomainType string `mapstructure:"domain_type" required:"false"`
+	// The name of the domain, and of the files of its disks and definition.
+	// By default this is `packer-BUILDNAME`, where "BUILDNAME" is the name of
+	// the build.
+	VMName string `mapstructure:"vm_name" required:"false"`
+	// The number of cpus of the domain. Defaults to `1`.
+	CpuCount int `mapstructure:"cpus" required:"false"`
+	// The amount of memory of the domain in megabytes. Defaults to `512`.
+	MemorySize int `mapstructure:"memory" required:"false"`
+	// The machine type of the domain, such as `q35`. Defaults to the
+	// default machine of the hypervisor.
+	MachineType string `mapstructure:"machine_type" required:"false"`
+	// The firmware of the domain: `bios` or `efi`. With `efi`, libvirt
+	// selects the UEFI firmware and creates the NVRAM of the domain. Defaults
+	// to `bios`.
+	Firmware string `mapstructure:"firmware" required:"false"`
+	// The disks of the domain, created in `output_directory` and named
+	// after `vm_name` and `format`, such as `packer-ubuntu.qcow2`, with `-#`
+	// appended to the name of the disks after the first. By default the
+	// domain has a single 40960M virtio disk.
+	Disks []Disk `mapstructure:"disks" required:"false"`
+	// The format of the disks: `qcow2` or `raw`. Defaults to `qcow2`.
+	Format string `mapstructure:"format" required:"false"`
+	// Packer defaults to installing from the ISO of `iso_url`. When this is
+	// true, `iso_url` is instead a bootable disk image, which is converted to
+	// the first disk of the domain.
+	DiskImage bool `mapstructure:"disk_image" required:"false"`
+	// The bus the ISO is attached to: `sata`, `scsi` or `ide`. Defaults to
+	// `sata`.
+	CDROMBus string `mapstructure:"cdrom_bus" required:"false"`
+	// The network interfaces of the domain. By default the domain has a
+	// single virtio interface on the `default` libvirt network.
+	NetworkInterfaces []NetworkInterface `mapstructure:"network_interfaces" required:"false"`
+	// Where the IP address of the communicator is read from: `lease`, the
+	// DHCP leases of the libvirt network, `arp`, the ARP table of the host,
+	// or `agent`, the QEMU guest agent. Defaults to `lease`, or `arp` when
+	// the first interface is on a bridge. This is ignored when `ssh_host` or
+	// `winrm_host` is set.
+	IPAddressSource string `mapstructure:"ip_address_source" required:"false"`
+	// The graphics of the domain: `vnc`, `spice` or `none`. The boot
+	// command is typed over VNC, so it requires `vnc`. Defaults to `vnc`.
+	Graphics string `mapstructure:"graphics" required:"false"`
+	// The IP address the VNC server of the domain listens on. Defaults to
+	// `127.0.0.1`.
+	VNCBindAddress string `mapstructure:"vnc_bind_address" required:"false"`
+	// This is the path to the directory where the disks and the definition
+	// of the domain are created. This directory must not exist or be empty
+	// prior to running the builder. By default this is `output-BUILDNAME`
+	// where "BUILDNAME" is the name of the build.
+	OutputDir string `mapstructure:"output_directory" required:"false"`
+
+	ctx interpolate.Context
+}
+
+func NewConfig(raws ...interface{}) (*Config, []string, error) {
+	c := new(Config)
+	err := config.Decode(c, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"boot_command",
+				"http_content",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs *packer.MultiError
+	var warnings []string
+	errs = packer.MultiErrorAppend(errs, c.ShutdownConfig.Prepare(&c.ctx)...)
+	errs = packer.MultiErrorAppend(errs, c.VNCConfig.Prepare(&c.ctx)...)
+
+	isoWarnings, isoErrs := c.ISOConfig.Prepare(&c.ctx)
+	warnings = append(warnings, isoWarnings...)
+	errs = packer.MultiErrorAppend(errs, isoErrs...)
+
+	errs = packer.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
+	if es := c.Comm.Prepare(&c.ctx); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if c.LibvirtURI == "" {
+		c.LibvirtURI = "qemu:///system"
+	}
+	if c.DomainType == "" {
+		c.DomainType = "kvm"
+	}
+	if c.VMName == "" {
+		c.VMName = fmt.Sprintf("packer-%s", c.PackerBuildName)
+	}
+	if c.CpuCount < 1 {
+		c.CpuCount = 1
+	}
+	if c.MemorySize < 10 {
+		c.MemorySize = 512
+	}
+	if c.Firmware == "" {
+		c.Firmware = "bios"
+	}
+	if c.Format == "" {
+		c.Format = "qcow2"
+	}
+	if c.CDROMBus == "" {
+		c.CDROMBus = "sata"
+	}
+	if c.Graphics == "" {
+		c.Graphics = "vnc"
+	}
+	if c.VNCBindAddress == "" {
+		c.VNCBindAddress = "127.0.0.1"
+	}
+	if c.OutputDir == "" {
+		c.OutputDir = fmt.Sprintf("output-%s", c.PackerBuildName)
+	}
+
+	if len(c.Disks) == 0 {
+		c.Disks = []Disk{{}}
+	}
+	for i := range c.Disks {
+		d := &c.Disks[i]
+		if d.Size == "" && !(i == 0 && c.DiskImage) {
+			if i > 0 {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("disks[%d]: size must be specified", i))
+			}
+			d.Size = "40960M"
+		}
+		if d.Bus == "" {
+			d.Bus = "virtio"
+		}
+		if d.Cache == "" {
+			d.Cache = "default"
+		}
+		if !diskBus[d.Bus] {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("disks[%d]: unrecognized bus %q", i, d.Bus))
+		}
+		if !diskCache[d.Cache] {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("disks[%d]: unrecognized cache mode %q", i, d.Cache))
+		}
+	}
+
+	if len(c.NetworkInterfaces) == 0 {
+		c.NetworkInterfaces = []NetworkInterface{{}}
+	}
+	for i := range c.NetworkInterfaces {
+		n := &c.NetworkInterfaces[i]
+		if n.Network != "" && n.Bridge != "" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_interfaces[%d]: network and bridge can't both be set", i))
+		}
+		if n.Network == "" && n.Bridge == "" {
+			n.Network = "default"
+		}
+		if n.Model == "" {
+			n.Model = "virtio"
+		}
+	}
+
+	if c.IPAddressSource == "" {
+		c.IPAddressSource = "lease"
+		if c.NetworkInterfaces[0].Bridge != "" {
+			c.IPAddressSource = "arp"
+		}
+	}
+	if !ipAddressSource[c.IPAddressSource] {
+		errs = packer.MultiErrorAppend(errs, errors.New("ip_address_source must be one of lease, arp or agent"))
+	}
+
+	if c.Firmware != "bios" && c.Firmware != "efi" {
+		errs = packer.MultiErrorAppend(errs, errors.New("firmware must be one of bios or efi"))
+	}
+	if c.Format != "qcow2" && c.Format != "raw" {
+		errs = packer.MultiErrorAppend(errs, errors.New("invalid format, only 'qcow2' or 'raw' are allowed"))
+	}
+	if c.CDROMBus != "sata" && c.CDROMBus != "scsi" && c.CDROMBus != "ide" {
+		errs = packer.MultiErrorAppend(errs, errors.New("cdrom_bus must be one of sata, scsi or ide"))
+	}
+	switch c.Graphics {
+	case "vnc":
+	case "spice", "none":
+		if len(c.BootCommand) > 0 && !c.DisableVNC {
+			errs = packer.MultiErrorAppend(errs, errors.New("boot_command requires graphics to be vnc"))
+		}
+	default:
+		errs = packer.MultiErrorAppend(errs, errors.New("graphics must be one of vnc, spice or none"))
+	}
+
+	if !c.PackerForce {
+		if _, err := os.Stat(c.OutputDir); err == nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Output directory '%s' already exists. It must not exist.", c.OutputDir))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warnings, errs
+	}
+
+	return c, warnings, nil
+}
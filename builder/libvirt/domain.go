@@ -0,0 +1,226 @@
+package libvirt
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// domain is the XML definition of a libvirt domain, limited to what the
+// builder defines and reads back.
+type domain struct {
+	XMLName xml.Name      `xml:"domain"`
+	Type    string        `xml:"type,attr"`
+	Name    string        `xml:"name"`
+	Memory  domainMemory  `xml:"memory"`
+	VCPU    int           `xml:"vcpu"`
+	OS      domainOS      `xml:"os"`
+	Devices domainDevices `xml:"devices"`
+}
+
+type domainMemory struct {
+	Unit  string `xml:"unit,attr"`
+	Value int    `xml:",chardata"`
+}
+
+type domainOS struct {
+	Firmware string         `xml:"firmware,attr,omitempty"`
+	Type     domainOSType   `xml:"type"`
+	Boot     []domainOSBoot `xml:"boot"`
+}
+
+type domainOSType struct {
+	Machine string `xml:"machine,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+type domainOSBoot struct {
+	Dev string `xml:"dev,attr"`
+}
+
+type domainDevices struct {
+	Disks      []domainDisk      `xml:"disk"`
+	Interfaces []domainInterface `xml:"interface"`
+	Graphics   []domainGraphics  `xml:"graphics"`
+	Video      *domainVideo      `xml:"video"`
+}
+
+type domainDisk struct {
+	Type     string           `xml:"type,attr"`
+	Device   string           `xml:"device,attr"`
+	Driver   domainDiskDriver `xml:"driver"`
+	Source   domainDiskSource `xml:"source"`
+	Target   domainDiskTarget `xml:"target"`
+	ReadOnly *struct{}        `xml:"readonly"`
+}
+
+type domainDiskDriver struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr"`
+	Cache string `xml:"cache,attr,omitempty"`
+}
+
+type domainDiskSource struct {
+	File string `xml:"file,attr"`
+}
+
+type domainDiskTarget struct {
+	Dev string `xml:"dev,attr"`
+	Bus string `xml:"bus,attr"`
+}
+
+type domainInterface struct {
+	Type   string                `xml:"type,attr"`
+	MAC    *domainInterfaceMAC   `xml:"mac"`
+	Source domainInterfaceSource `xml:"source"`
+	Model  domainInterfaceModel  `xml:"model"`
+}
+
+type domainInterfaceMAC struct {
+	Address string `xml:"address,attr"`
+}
+
+type domainInterfaceSource struct {
+	Network string `xml:"network,attr,omitempty"`
+	Bridge  string `xml:"bridge,attr,omitempty"`
+}
+
+type domainInterfaceModel struct {
+	Type string `xml:"type,attr"`
+}
+
+type domainGraphics struct {
+	Type     string `xml:"type,attr"`
+	Port     int    `xml:"port,attr"`
+	AutoPort string `xml:"autoport,attr"`
+	Listen   string `xml:"listen,attr,omitempty"`
+}
+
+type domainVideo struct {
+	Model domainVideoModel `xml:"model"`
+}
+
+type domainVideoModel struct {
+	Type string `xml:"type,attr"`
+}
+
+// diskPrefixes are the prefixes of the names of the disks on each bus.
+var diskPrefixes = map[string]string{
+	"virtio": "vd",
+	"sata":   "sd",
+	"scsi":   "sd",
+	"ide":    "hd",
+}
+
+// newDomain returns the definition of the domain of the build, with the
+// disks at diskPaths and the ISO at isoPath, if it is not empty.
+func newDomain(config *Config, diskPaths []string, isoPath string) *domain {
+	d := &domain{
+		Type:   config.DomainType,
+		Name:   config.VMName,
+		Memory: domainMemory{Unit: "MiB", Value: config.MemorySize},
+		VCPU:   config.CpuCount,
+		OS: domainOS{
+			Type: domainOSType{Machine: config.MachineType, Value: "hvm"},
+			Boot: []domainOSBoot{{Dev: "hd"}},
+		},
+	}
+	if config.Firmware == "efi" {
+		d.OS.Firmware = "efi"
+	}
+
+	// the disks of the buses sharing a prefix are numbered together
+	count := make(map[string]int)
+	target := func(bus string) domainDiskTarget {
+		prefix := diskPrefixes[bus]
+		dev := fmt.Sprintf("%s%c", prefix, 'a'+count[prefix])
+		count[prefix]++
+		return domainDiskTarget{Dev: dev, Bus: bus}
+	}
+
+	for i, path := range diskPaths {
+		disk := config.Disks[i]
+		cache := disk.Cache
+		if cache == "default" {
+			cache = ""
+		}
+		d.Devices.Disks = append(d.Devices.Disks, domainDisk{
+			Type:   "file",
+			Device: "disk",
+			Driver: domainDiskDriver{Name: "qemu", Type: config.Format, Cache: cache},
+			Source: domainDiskSource{File: path},
+			Target: target(disk.Bus),
+		})
+	}
+
+	if isoPath != "" {
+		d.Devices.Disks = append(d.Devices.Disks, domainDisk{
+			Type:     "file",
+			Device:   "cdrom",
+			Driver:   domainDiskDriver{Name: "qemu", Type: "raw"},
+			Source:   domainDiskSource{File: isoPath},
+			Target:   target(config.CDROMBus),
+			ReadOnly: &struct{}{},
+		})
+		// an empty disk is skipped, so the ISO boots until the OS is
+		// installed
+		d.OS.Boot = append(d.OS.Boot, domainOSBoot{Dev: "cdrom"})
+	}
+
+	for _, n := range config.NetworkInterfaces {
+		iface := domainInterface{
+			Type:   "network",
+			Source: domainInterfaceSource{Network: n.Network},
+			Model:  domainInterfaceModel{Type: n.Model},
+		}
+		if n.Bridge != "" {
+			iface.Type = "bridge"
+			iface.Source = domainInterfaceSource{Bridge: n.Bridge}
+		}
+		if n.MAC != "" {
+			iface.MAC = &domainInterfaceMAC{Address: n.MAC}
+		}
+		d.Devices.Interfaces = append(d.Devices.Interfaces, iface)
+	}
+
+	if config.Graphics != "none" {
+		d.Devices.Graphics = []domainGraphics{{
+			Type:     config.Graphics,
+			Port:     -1,
+			AutoPort: "yes",
+			Listen:   config.VNCBindAddress,
+		}}
+		d.Devices.Video = &domainVideo{Model: domainVideoModel{Type: "vga"}}
+	}
+
+	return d
+}
+
+// withoutCDROMs returns a copy of the domain without its CD-ROMs, and
+// booting from its disks only.
+func (d *domain) withoutCDROMs() *domain {
+	c := *d
+	c.Devices.Disks = nil
+	for _, disk := range d.Devices.Disks {
+		if disk.Device != "cdrom" {
+			c.Devices.Disks = append(c.Devices.Disks, disk)
+		}
+	}
+	c.OS.Boot = []domainOSBoot{{Dev: "hd"}}
+	return &c
+}
+
+func (d *domain) marshal() (string, error) {
+	out, err := xml.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+func parseDomain(definition string) (*domain, error) {
+	d := new(domain)
+	if err := xml.Unmarshal([]byte(definition), d); err != nil {
+		return nil, fmt.Errorf("Error parsing the definition of the domain: %s", err)
+	}
+	return d, nil
+}
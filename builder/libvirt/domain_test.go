@@ -0,0 +1,110 @@
+package libvirt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewDomain(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["firmware"] = "efi"
+	config["machine_type"] = "q35"
+	config["disks"] = []map[string]interface{}{
+		{"size": "10G", "cache": "none"},
+		{"size": "1G", "bus": "sata"},
+	}
+	config["network_interfaces"] = []map[string]interface{}{
+		{},
+		{"bridge": "br0", "mac": "52:54:00:12:34:56"},
+	}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d := newDomain(b.config, []string{"/out/packer-foo.qcow2", "/out/packer-foo-1.qcow2"}, "/cache/install.iso")
+	definition, err := d.marshal()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, s := range []string{
+		`<domain type="kvm">`,
+		`<name>packer-foo</name>`,
+		`<memory unit="MiB">512</memory>`,
+		`<os firmware="efi">`,
+		`<type machine="q35">hvm</type>`,
+		`<boot dev="hd"></boot>`,
+		`<boot dev="cdrom"></boot>`,
+		`<driver name="qemu" type="qcow2" cache="none"></driver>`,
+		`<source file="/out/packer-foo.qcow2"></source>`,
+		`<target dev="vda" bus="virtio"></target>`,
+		`<target dev="sda" bus="sata"></target>`,
+		`<source file="/cache/install.iso"></source>`,
+		`<target dev="sdb" bus="sata"></target>`,
+		`<source network="default"></source>`,
+		`<interface type="bridge">`,
+		`<mac address="52:54:00:12:34:56"></mac>`,
+		`<source bridge="br0"></source>`,
+		`<graphics type="vnc" port="-1" autoport="yes" listen="127.0.0.1"></graphics>`,
+	} {
+		if !strings.Contains(definition, s) {
+			t.Fatalf("bad: %s not in definition:\n%s", s, definition)
+		}
+	}
+
+	// The exported definition has no ISO
+	exported, err := d.withoutCDROMs().marshal()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(exported, "install.iso") || strings.Contains(exported, "cdrom") {
+		t.Fatalf("bad: %s", exported)
+	}
+	if len(d.Devices.Disks) != 3 {
+		t.Fatal("the definition of the build should not change")
+	}
+}
+
+func TestNewDomain_noGraphics(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["graphics"] = "none"
+	config["disk_image"] = true
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d := newDomain(b.config, []string{"/out/packer-foo.qcow2"}, "")
+	if len(d.Devices.Graphics) != 0 || d.Devices.Video != nil {
+		t.Fatalf("bad: %#v", d.Devices)
+	}
+	if len(d.OS.Boot) != 1 || d.OS.Firmware != "" {
+		t.Fatalf("bad: %#v", d.OS)
+	}
+}
+
+func TestParseDomain(t *testing.T) {
+	d, err := parseDomain(`<domain type='kvm' id='3'>
+  <name>packer-foo</name>
+  <devices>
+    <interface type='network'>
+      <mac address='52:54:00:6e:4d:5e'/>
+      <source network='default'/>
+      <model type='virtio'/>
+    </interface>
+    <graphics type='vnc' port='5901' autoport='yes' listen='127.0.0.1'>
+      <listen type='address' address='127.0.0.1'/>
+    </graphics>
+  </devices>
+</domain>`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if d.Name != "packer-foo" || d.Devices.Graphics[0].Port != 5901 {
+		t.Fatalf("bad: %#v", d)
+	}
+	if d.Devices.Interfaces[0].MAC.Address != "52:54:00:6e:4d:5e" {
+		t.Fatalf("bad: %#v", d.Devices.Interfaces)
+	}
+}
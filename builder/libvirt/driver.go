@@ -0,0 +1,237 @@
+package libvirt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// A driver is able to talk to libvirt and perform certain operations with
+// it.
+type Driver interface {
+	// CreateDomain creates and starts a transient domain from its XML
+	// definition. The domain is removed from libvirt once it is stopped.
+	CreateDomain(xml string) error
+
+	// DestroyDomain stops a domain forcefully.
+	DestroyDomain(name string) error
+
+	// ShutdownDomain asks the guest of a domain to shut down.
+	ShutdownDomain(name string) error
+
+	// DomainState returns the state of a domain, such as "running" or
+	// "shut off", or an empty string if the domain does not exist.
+	DomainState(name string) (string, error)
+
+	// DomainXML returns the live XML definition of a domain.
+	DomainXML(name string) (string, error)
+
+	// InterfaceAddresses returns the IPv4 addresses of the interfaces of a
+	// domain, read from the DHCP leases of its networks, the ARP table of
+	// the host or the guest agent.
+	InterfaceAddresses(name string, source string) ([]string, error)
+
+	// NetworkAddress returns the IPv4 address of the host on a libvirt
+	// network.
+	NetworkAddress(network string) (string, error)
+
+	// QemuImg executes the given command via qemu-img
+	QemuImg(args ...string) error
+
+	// Verify checks to make sure that this driver should function
+	// properly. If there is any indication the driver can't function,
+	// this will return an error.
+	Verify() error
+
+	// Version reads the version of libvirt that is installed.
+	Version() (string, error)
+}
+
+// VirshDriver is a Driver that runs virsh.
+type VirshDriver struct {
+	// The URI of the libvirt connection.
+	URI string
+
+	VirshPath   string
+	QemuImgPath string
+}
+
+func (d *VirshDriver) CreateDomain(definition string) error {
+	f, err := ioutil.TempFile("", "packer-libvirt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(definition)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	_, err = d.virsh("create", f.Name())
+	return err
+}
+
+func (d *VirshDriver) DestroyDomain(name string) error {
+	_, err := d.virsh("destroy", name)
+	return err
+}
+
+func (d *VirshDriver) ShutdownDomain(name string) error {
+	_, err := d.virsh("shutdown", name)
+	return err
+}
+
+func (d *VirshDriver) DomainState(name string) (string, error) {
+	out, err := d.virsh("domstate", name)
+	if err != nil {
+		if isDomainNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+func (d *VirshDriver) DomainXML(name string) (string, error) {
+	return d.virsh("dumpxml", name)
+}
+
+func (d *VirshDriver) InterfaceAddresses(name string, source string) ([]string, error) {
+	out, err := d.virsh("domifaddr", name, "--source", source)
+	if err != nil {
+		return nil, err
+	}
+	return parseDomIfAddr(out), nil
+}
+
+func (d *VirshDriver) NetworkAddress(network string) (string, error) {
+	out, err := d.virsh("net-dumpxml", network)
+	if err != nil {
+		return "", err
+	}
+
+	var def struct {
+		IPs []struct {
+			Address string `xml:"address,attr"`
+			Family  string `xml:"family,attr"`
+		} `xml:"ip"`
+	}
+	if err := xml.Unmarshal([]byte(out), &def); err != nil {
+		return "", fmt.Errorf("Error parsing the definition of network %s: %s", network, err)
+	}
+	for _, ip := range def.IPs {
+		if ip.Family == "" || ip.Family == "ipv4" {
+			return ip.Address, nil
+		}
+	}
+	return "", fmt.Errorf("Network %s has no IPv4 address", network)
+}
+
+func (d *VirshDriver) QemuImg(args ...string) error {
+	var stdout, stderr bytes.Buffer
+
+	log.Printf("Executing qemu-img: %#v", args)
+	cmd := exec.Command(d.QemuImgPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	stdoutString := strings.TrimSpace(stdout.String())
+	stderrString := strings.TrimSpace(stderr.String())
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("QemuImg error: %s", stderrString)
+	}
+
+	log.Printf("stdout: %s", stdoutString)
+	log.Printf("stderr: %s", stderrString)
+
+	return err
+}
+
+func (d *VirshDriver) Verify() error {
+	if _, err := d.virsh("uri"); err != nil {
+		return fmt.Errorf("Error connecting to libvirt at %s: %s", d.URI, err)
+	}
+	return nil
+}
+
+func (d *VirshDriver) Version() (string, error) {
+	out, err := d.virsh("version")
+	if err != nil {
+		return "", err
+	}
+
+	versionRe := regexp.MustCompile(`(?m)^Using library: libvirt ([0-9.]+)`)
+	matches := versionRe.FindStringSubmatch(out)
+	if matches == nil {
+		return "", fmt.Errorf("No version found: %s", out)
+	}
+
+	log.Printf("libvirt version: %s", matches[1])
+	return matches[1], nil
+}
+
+// virsh runs virsh on the connection of the driver, and returns its
+// trimmed output.
+func (d *VirshDriver) virsh(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	args = append([]string{"--quiet", "--connect", d.URI}, args...)
+	log.Printf("Executing virsh: %#v", args)
+	cmd := exec.Command(d.VirshPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	stdoutString := strings.TrimSpace(stdout.String())
+	stderrString := strings.TrimSpace(stderr.String())
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("virsh error: %s", stderrString)
+	}
+
+	log.Printf("stdout: %s", stdoutString)
+	log.Printf("stderr: %s", stderrString)
+
+	return stdoutString, err
+}
+
+// isDomainNotFound returns whether a virsh error is about a domain that
+// does not exist.
+func isDomainNotFound(err error) bool {
+	return strings.Contains(err.Error(), "failed to get domain") ||
+		strings.Contains(err.Error(), "Domain not found")
+}
+
+// parseDomIfAddr parses the IPv4 addresses out of the table printed by
+// virsh domifaddr:
+//
+//    Name       MAC address          Protocol     Address
+//   -------------------------------------------------------------------------------
+//    vnet0      52:54:00:6e:4d:5e    ipv4         192.168.122.10/24
+//    -          -                    ipv6         fe80::5054:ff:fe6e:4d5e/64
+func parseDomIfAddr(out string) []string {
+	var addrs []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[2] != "ipv4" {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(fields[3])
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, ip.String())
+	}
+	return addrs
+}
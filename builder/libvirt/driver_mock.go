@@ -0,0 +1,135 @@
+package libvirt
+
+import "sync"
+
+// DriverMock is a Driver that records its calls. Like a transient domain,
+// the mocked domain disappears once it is destroyed or shut down.
+type DriverMock struct {
+	sync.Mutex
+
+	CreateDomainCalled bool
+	CreateDomainXML    string
+	CreateDomainErr    error
+
+	DestroyDomainCalled bool
+	DestroyDomainName   string
+	DestroyDomainErr    error
+
+	ShutdownDomainCalled bool
+	ShutdownDomainName   string
+	ShutdownDomainErr    error
+
+	DomainStateName   string
+	DomainStateResult string
+	DomainStateErr    error
+
+	DomainXMLName   string
+	DomainXMLResult string
+	DomainXMLErr    error
+
+	InterfaceAddressesName   string
+	InterfaceAddressesSource string
+	InterfaceAddressesResult []string
+	InterfaceAddressesErr    error
+
+	NetworkAddressNetwork string
+	NetworkAddressResult  string
+	NetworkAddressErr     error
+
+	QemuImgCalls [][]string
+	QemuImgErr   error
+
+	VerifyCalled bool
+	VerifyErr    error
+
+	VersionCalled bool
+	VersionResult string
+	VersionErr    error
+}
+
+func (d *DriverMock) CreateDomain(xml string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.CreateDomainCalled = true
+	d.CreateDomainXML = xml
+	if d.CreateDomainErr == nil {
+		d.DomainStateResult = "running"
+	}
+	return d.CreateDomainErr
+}
+
+func (d *DriverMock) DestroyDomain(name string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.DestroyDomainCalled = true
+	d.DestroyDomainName = name
+	if d.DestroyDomainErr == nil {
+		d.DomainStateResult = ""
+	}
+	return d.DestroyDomainErr
+}
+
+func (d *DriverMock) ShutdownDomain(name string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.ShutdownDomainCalled = true
+	d.ShutdownDomainName = name
+	if d.ShutdownDomainErr == nil {
+		d.DomainStateResult = ""
+	}
+	return d.ShutdownDomainErr
+}
+
+func (d *DriverMock) DomainState(name string) (string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.DomainStateName = name
+	return d.DomainStateResult, d.DomainStateErr
+}
+
+func (d *DriverMock) DomainXML(name string) (string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.DomainXMLName = name
+	return d.DomainXMLResult, d.DomainXMLErr
+}
+
+func (d *DriverMock) InterfaceAddresses(name string, source string) ([]string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.InterfaceAddressesName = name
+	d.InterfaceAddressesSource = source
+	return d.InterfaceAddressesResult, d.InterfaceAddressesErr
+}
+
+func (d *DriverMock) NetworkAddress(network string) (string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.NetworkAddressNetwork = network
+	return d.NetworkAddressResult, d.NetworkAddressErr
+}
+
+func (d *DriverMock) QemuImg(args ...string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.QemuImgCalls = append(d.QemuImgCalls, args)
+	return d.QemuImgErr
+}
+
+func (d *DriverMock) Verify() error {
+	d.VerifyCalled = true
+	return d.VerifyErr
+}
+
+func (d *DriverMock) Version() (string, error) {
+	d.VersionCalled = true
+	return d.VersionResult, d.VersionErr
+}
@@ -0,0 +1,107 @@
+package libvirt
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestVirshDriver_impl(t *testing.T) {
+	var _ Driver = new(VirshDriver)
+	var _ Driver = new(DriverMock)
+}
+
+func TestParseDomIfAddr(t *testing.T) {
+	out := ` Name       MAC address          Protocol     Address
+-------------------------------------------------------------------------------
+ vnet0      52:54:00:6e:4d:5e    ipv4         192.168.122.10/24
+ -          -                    ipv6         fe80::5054:ff:fe6e:4d5e/64
+ vnet1      52:54:00:12:34:56    ipv4         10.0.0.5/8
+`
+	expected := []string{"192.168.122.10", "10.0.0.5"}
+	if addrs := parseDomIfAddr(out); !reflect.DeepEqual(addrs, expected) {
+		t.Fatalf("bad: %#v", addrs)
+	}
+
+	if addrs := parseDomIfAddr(""); len(addrs) != 0 {
+		t.Fatalf("bad: %#v", addrs)
+	}
+}
+
+// testVirshDriver returns a driver on the test driver of libvirt, which
+// runs within virsh: the changes to its domains are lost once virsh exits.
+func testVirshDriver(t *testing.T) *VirshDriver {
+	virshPath, err := exec.LookPath("virsh")
+	if err != nil {
+		t.Skip("virsh not found")
+	}
+	return &VirshDriver{URI: "test:///default", VirshPath: virshPath}
+}
+
+func TestVirshDriver_testDefault(t *testing.T) {
+	driver := testVirshDriver(t)
+
+	if err := driver.Verify(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := driver.Version(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The test driver has a running domain named test
+	state, err := driver.DomainState("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if state != "running" {
+		t.Fatalf("bad: %s", state)
+	}
+
+	state, err = driver.DomainState("packer-missing")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if state != "" {
+		t.Fatalf("bad: %s", state)
+	}
+
+	definition, err := driver.DomainXML("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if d, err := parseDomain(definition); err != nil || d.Name != "test" {
+		t.Fatalf("bad: %s, %#v", err, d)
+	}
+
+	if err := driver.DestroyDomain("test"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	address, err := driver.NetworkAddress("default")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if address != "192.168.122.1" {
+		t.Fatalf("bad: %s", address)
+	}
+}
+
+func TestVirshDriver_CreateDomain(t *testing.T) {
+	driver := testVirshDriver(t)
+
+	var b Builder
+	config := testConfig()
+	config["domain_type"] = "test"
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The test driver accepts the definition of the builder
+	definition, err := newDomain(b.config, []string{"/tmp/packer-foo.qcow2"}, "/tmp/install.iso").marshal()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := driver.CreateDomain(definition); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
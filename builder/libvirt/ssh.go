@@ -0,0 +1,33 @@
+package libvirt
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+// commHost returns the host of the communicator: the configured host, or
+// the first IPv4 address of the domain read from source.
+func commHost(host string, source string) func(multistep.StateBag) (string, error) {
+	return func(state multistep.StateBag) (string, error) {
+		if host != "" {
+			log.Printf("Using host value: %s", host)
+			return host, nil
+		}
+
+		driver := state.Get("driver").(Driver)
+		name := state.Get("domain_name").(string)
+
+		addrs, err := driver.InterfaceAddresses(name, source)
+		if err != nil {
+			return "", err
+		}
+		if len(addrs) == 0 {
+			return "", fmt.Errorf("The domain has no IP address yet")
+		}
+
+		log.Printf("IP address of the domain from %s: %s", source, addrs[0])
+		return addrs[0], nil
+	}
+}
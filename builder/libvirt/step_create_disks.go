@@ -0,0 +1,73 @@
+package libvirt
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// This step creates the disks of the domain in the output directory. With
+// disk_image, the first disk is converted from the image.
+//
+// Uses:
+//   config   *Config
+//   driver   Driver
+//   iso_path string
+//   ui       packer.Ui
+//
+// Produces:
+//   disk_paths []string - The absolute paths of the disks.
+type stepCreateDisks struct{}
+
+func (s *stepCreateDisks) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	outputDir, err := filepath.Abs(config.OutputDir)
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Creating the disks of the domain...")
+	var paths []string
+	for i, disk := range config.Disks {
+		name := fmt.Sprintf("%s.%s", config.VMName, config.Format)
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d.%s", config.VMName, i, config.Format)
+		}
+		path := filepath.Join(outputDir, name)
+
+		var commands [][]string
+		if i == 0 && config.DiskImage {
+			isoPath := state.Get("iso_path").(string)
+			commands = append(commands, []string{"convert", "-O", config.Format, isoPath, path})
+			if disk.Size != "" {
+				commands = append(commands, []string{"resize", "-f", config.Format, path, disk.Size})
+			}
+		} else {
+			commands = append(commands, []string{"create", "-f", config.Format, path, disk.Size})
+		}
+
+		for _, command := range commands {
+			log.Printf("[INFO] Running qemu-img %s for disk %s", command[0], path)
+			if err := driver.QemuImg(command...); err != nil {
+				err := fmt.Errorf("Error creating disk %s: %s", name, err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+		paths = append(paths, path)
+	}
+
+	state.Put("disk_paths", paths)
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateDisks) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,72 @@
+package libvirt
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepCreateDisks_impl(t *testing.T) {
+	var _ multistep.Step = new(stepCreateDisks)
+}
+
+func TestStepCreateDisks(t *testing.T) {
+	config := testConfig()
+	config["disks"] = []map[string]interface{}{
+		{},
+		{"size": "1G"},
+	}
+	state := testState(t, config)
+	step := new(stepCreateDisks)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	outputDir, _ := filepath.Abs("output-foo")
+	paths := []string{
+		filepath.Join(outputDir, "packer-foo.qcow2"),
+		filepath.Join(outputDir, "packer-foo-1.qcow2"),
+	}
+	if !reflect.DeepEqual(state.Get("disk_paths"), paths) {
+		t.Fatalf("bad: %#v", state.Get("disk_paths"))
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	expected := [][]string{
+		{"create", "-f", "qcow2", paths[0], "40960M"},
+		{"create", "-f", "qcow2", paths[1], "1G"},
+	}
+	if !reflect.DeepEqual(driver.QemuImgCalls, expected) {
+		t.Fatalf("bad: %#v", driver.QemuImgCalls)
+	}
+}
+
+func TestStepCreateDisks_diskImage(t *testing.T) {
+	config := testConfig()
+	config["disk_image"] = true
+	config["format"] = "raw"
+	config["disks"] = []map[string]interface{}{
+		{"size": "20G"},
+	}
+	state := testState(t, config)
+	state.Put("iso_path", "/cache/image.qcow2")
+	step := new(stepCreateDisks)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	path := state.Get("disk_paths").([]string)[0]
+	driver := state.Get("driver").(*DriverMock)
+	expected := [][]string{
+		{"convert", "-O", "raw", "/cache/image.qcow2", path},
+		{"resize", "-f", "raw", path, "20G"},
+	}
+	if !reflect.DeepEqual(driver.QemuImgCalls, expected) {
+		t.Fatalf("bad: %#v", driver.QemuImgCalls)
+	}
+}
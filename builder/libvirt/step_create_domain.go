@@ -0,0 +1,97 @@
+package libvirt
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// This step defines and starts the transient domain of the build.
+//
+// Uses:
+//   config     *Config
+//   disk_paths []string
+//   driver     Driver
+//   iso_path   string
+//   ui         packer.Ui
+//
+// Produces:
+//   domain      *domain - The definition of the domain.
+//   domain_name string  - The name of the domain.
+type stepCreateDomain struct {
+	name string
+}
+
+func (s *stepCreateDomain) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	diskPaths := state.Get("disk_paths").([]string)
+
+	var isoPath string
+	if !config.DiskImage {
+		path, err := filepath.Abs(state.Get("iso_path").(string))
+		if err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+		isoPath = path
+	}
+
+	if existing, err := driver.DomainState(config.VMName); err != nil || existing != "" {
+		if err == nil {
+			err = fmt.Errorf("A domain named %s already exists", config.VMName)
+		}
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	d := newDomain(config, diskPaths, isoPath)
+	definition, err := d.marshal()
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	log.Printf("Domain definition:\n%s", definition)
+
+	ui.Say(fmt.Sprintf("Starting domain %s...", config.VMName))
+	if err := driver.CreateDomain(definition); err != nil {
+		err := fmt.Errorf("Error starting the domain: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.name = config.VMName
+
+	state.Put("domain", d)
+	state.Put("domain_name", config.VMName)
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateDomain) Cleanup(state multistep.StateBag) {
+	if s.name == "" {
+		return
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	domainState, err := driver.DomainState(s.name)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error reading the state of the domain: %s", err))
+		return
+	}
+	if domainState == "" || domainState == "shut off" {
+		return
+	}
+
+	ui.Say("Destroying the domain...")
+	if err := driver.DestroyDomain(s.name); err != nil {
+		ui.Error(fmt.Sprintf("Error destroying the domain: %s", err))
+	}
+}
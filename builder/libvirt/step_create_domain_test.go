@@ -0,0 +1,137 @@
+package libvirt
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepCreateDomain_impl(t *testing.T) {
+	var _ multistep.Step = new(stepCreateDomain)
+}
+
+func TestStepCreateDomain(t *testing.T) {
+	state := testState(t, testConfig())
+	state.Put("disk_paths", []string{"/out/packer-foo.qcow2"})
+	state.Put("iso_path", "/cache/install.iso")
+	step := new(stepCreateDomain)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	if !driver.CreateDomainCalled {
+		t.Fatal("should create the domain")
+	}
+	for _, s := range []string{"<name>packer-foo</name>", "/out/packer-foo.qcow2", "/cache/install.iso"} {
+		if !strings.Contains(driver.CreateDomainXML, s) {
+			t.Fatalf("bad: %s not in %s", s, driver.CreateDomainXML)
+		}
+	}
+	if state.Get("domain_name").(string) != "packer-foo" {
+		t.Fatalf("bad: %#v", state.Get("domain_name"))
+	}
+	if _, ok := state.Get("domain").(*domain); !ok {
+		t.Fatal("should put the domain")
+	}
+
+	// The running domain is destroyed
+	step.Cleanup(state)
+	if !driver.DestroyDomainCalled || driver.DestroyDomainName != "packer-foo" {
+		t.Fatal("should destroy the domain")
+	}
+}
+
+func TestStepCreateDomain_exists(t *testing.T) {
+	state := testState(t, testConfig())
+	state.Put("disk_paths", []string{"/out/packer-foo.qcow2"})
+	state.Put("iso_path", "/cache/install.iso")
+	driver := state.Get("driver").(*DriverMock)
+	driver.DomainStateResult = "running"
+	step := new(stepCreateDomain)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.CreateDomainCalled {
+		t.Fatal("should not create the domain")
+	}
+
+	// A domain that was not created by the step is left alone
+	step.Cleanup(state)
+	if driver.DestroyDomainCalled {
+		t.Fatal("should not destroy the domain")
+	}
+}
+
+func TestStepCreateDomain_error(t *testing.T) {
+	state := testState(t, testConfig())
+	state.Put("disk_paths", []string{"/out/packer-foo.qcow2"})
+	state.Put("iso_path", "/cache/install.iso")
+	driver := state.Get("driver").(*DriverMock)
+	driver.CreateDomainErr = errors.New("bad definition")
+	step := new(stepCreateDomain)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestCommHost(t *testing.T) {
+	state := testState(t, testConfig())
+	state.Put("domain_name", "packer-foo")
+	driver := state.Get("driver").(*DriverMock)
+
+	if _, err := commHost("", "lease")(state); err == nil {
+		t.Fatal("should have error without address")
+	}
+
+	driver.InterfaceAddressesResult = []string{"192.168.122.10", "10.0.0.5"}
+	host, err := commHost("", "lease")(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if host != "192.168.122.10" || driver.InterfaceAddressesSource != "lease" {
+		t.Fatalf("bad: %s, %s", host, driver.InterfaceAddressesSource)
+	}
+
+	host, err = commHost("10.1.1.1", "lease")(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if host != "10.1.1.1" {
+		t.Fatalf("bad: %s", host)
+	}
+}
+
+func TestDomainVNCAddress(t *testing.T) {
+	driver := new(DriverMock)
+	driver.DomainXMLResult = `<domain type='kvm'><name>packer-foo</name><devices>
+<graphics type='vnc' port='5901' autoport='yes' listen='0.0.0.0'/>
+</devices></domain>`
+
+	address, err := domainVNCAddress(driver, "packer-foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if address != "127.0.0.1:5901" {
+		t.Fatalf("bad: %s", address)
+	}
+
+	driver.DomainXMLResult = `<domain type='kvm'><name>packer-foo</name><devices>
+<graphics type='spice' port='5901' autoport='yes'/>
+</devices></domain>`
+	if _, err := domainVNCAddress(driver, "packer-foo"); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,49 @@
+package libvirt
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// This step writes the definition of the domain next to its disks, without
+// the ISO, so the domain can be defined again with `virsh define`.
+//
+// Uses:
+//   config *Config
+//   domain *domain
+//   ui     packer.Ui
+//
+// Produces:
+//   domain_xml_path string - The path of the definition.
+type stepExport struct{}
+
+func (s *stepExport) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	d := state.Get("domain").(*domain)
+	ui := state.Get("ui").(packer.Ui)
+
+	definition, err := d.withoutCDROMs().marshal()
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	path := filepath.Join(config.OutputDir, config.VMName+".xml")
+	ui.Say(fmt.Sprintf("Writing the definition of the domain to %s...", path))
+	if err := ioutil.WriteFile(path, []byte(definition), 0644); err != nil {
+		err := fmt.Errorf("Error writing the definition of the domain: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("domain_xml_path", path)
+	return multistep.ActionContinue
+}
+
+func (s *stepExport) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,92 @@
+package libvirt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// This step shuts down the domain, with the shutdown command when there is
+// one, or else by asking the guest to shut down through libvirt.
+//
+// Uses:
+//   communicator packer.Communicator
+//   config       *Config
+//   domain_name  string
+//   driver       Driver
+//   ui           packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepShutdown struct{}
+
+func (s *stepShutdown) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	name := state.Get("domain_name").(string)
+
+	comm, ok := state.Get("communicator").(packer.Communicator)
+	switch {
+	case !ok:
+		ui.Say("Waiting for shutdown...")
+	case config.ShutdownCommand != "":
+		ui.Say("Gracefully halting virtual machine...")
+		log.Printf("Executing shutdown command: %s", config.ShutdownCommand)
+		cmd := &packer.RemoteCmd{Command: config.ShutdownCommand}
+		if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+			err := fmt.Errorf("Failed to send shutdown command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	default:
+		ui.Say("Halting the virtual machine...")
+		if err := driver.ShutdownDomain(name); err != nil {
+			err := fmt.Errorf("Error shutting down the domain: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	log.Printf("Waiting max %s for shutdown to complete", config.ShutdownTimeout)
+	if err := waitForShutdown(ctx, driver, name, config.ShutdownTimeout); err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Println("VM shut down.")
+	return multistep.ActionContinue
+}
+
+func (s *stepShutdown) Cleanup(state multistep.StateBag) {}
+
+// waitForShutdown waits until the domain is shut off, or removed from
+// libvirt as transient domains are once they stop.
+func waitForShutdown(ctx context.Context, driver Driver, name string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		domainState, err := driver.DomainState(name)
+		if err != nil {
+			return fmt.Errorf("Error reading the state of the domain: %s", err)
+		}
+		if domainState == "" || domainState == "shut off" {
+			return nil
+		}
+
+		select {
+		case <-time.After(time.Second):
+		case <-deadline:
+			return errors.New("Timeout while waiting for machine to shut down.")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package libvirt
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestStepShutdown_impl(t *testing.T) {
+	var _ multistep.Step = new(stepShutdown)
+}
+
+func TestStepShutdown_command(t *testing.T) {
+	config := testConfig()
+	config["shutdown_command"] = "poweroff"
+	state := testState(t, config)
+	state.Put("domain_name", "packer-foo")
+	comm := new(packer.MockCommunicator)
+	state.Put("communicator", comm)
+	driver := state.Get("driver").(*DriverMock)
+	driver.DomainStateResult = "shut off"
+	step := new(stepShutdown)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.StartCmd.Command != "poweroff" {
+		t.Fatalf("bad: %#v", comm.StartCmd)
+	}
+	if driver.ShutdownDomainCalled {
+		t.Fatal("should not shut down the domain with libvirt")
+	}
+}
+
+func TestStepShutdown_libvirt(t *testing.T) {
+	state := testState(t, testConfig())
+	state.Put("domain_name", "packer-foo")
+	state.Put("communicator", new(packer.MockCommunicator))
+	driver := state.Get("driver").(*DriverMock)
+	driver.DomainStateResult = "running"
+	step := new(stepShutdown)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !driver.ShutdownDomainCalled || driver.ShutdownDomainName != "packer-foo" {
+		t.Fatal("should shut down the domain")
+	}
+}
+
+func TestStepShutdown_timeout(t *testing.T) {
+	config := testConfig()
+	config["shutdown_timeout"] = "1ms"
+	state := testState(t, config)
+	state.Put("domain_name", "packer-foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.DomainStateResult = "running"
+	step := new(stepShutdown)
+
+	// Without communicator, the domain must shut down by itself
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testConfig()
+	config["output_directory"] = filepath.Join(dir, "output")
+	state := testState(t, config)
+	c := state.Get("config").(*Config)
+	if err := os.MkdirAll(c.OutputDir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("domain", newDomain(c, []string{"/out/packer-foo.qcow2"}, "/cache/install.iso"))
+	step := new(stepExport)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	path := filepath.Join(c.OutputDir, "packer-foo.xml")
+	if state.Get("domain_xml_path") != path {
+		t.Fatalf("bad: %#v", state.Get("domain_xml_path"))
+	}
+	definition, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(definition), "/out/packer-foo.qcow2") || strings.Contains(string(definition), "install.iso") {
+		t.Fatalf("bad: %s", definition)
+	}
+}
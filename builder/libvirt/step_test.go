@@ -0,0 +1,26 @@
+package libvirt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+func testState(t *testing.T, raw map[string]interface{}) multistep.StateBag {
+	var b Builder
+	if _, err := b.Prepare(raw); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("config", b.config)
+	state.Put("debug", false)
+	state.Put("driver", new(DriverMock))
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
@@ -0,0 +1,201 @@
+package libvirt
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/go-vnc"
+)
+
+type bootCommandTemplateData struct {
+	HTTPIP   string
+	HTTPPort int
+	Name     string
+}
+
+// This step "types" the boot command into the domain over the VNC server
+// of libvirt.
+//
+// Uses:
+//   config      *Config
+//   domain_name string
+//   driver      Driver
+//   http_port   int
+//   ui          packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepTypeBootCommand struct{}
+
+func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	debug := state.Get("debug").(bool)
+	driver := state.Get("driver").(Driver)
+	httpPort := state.Get("http_port").(int)
+	ui := state.Get("ui").(packer.Ui)
+	name := state.Get("domain_name").(string)
+
+	if config.DisableVNC || len(config.BootCommand) == 0 {
+		log.Println("Skipping boot command step...")
+		return multistep.ActionContinue
+	}
+
+	// Wait the for the vm to boot.
+	if int64(config.BootWait) > 0 {
+		ui.Say(fmt.Sprintf("Waiting %s for boot...", config.BootWait.String()))
+		select {
+		case <-time.After(config.BootWait):
+			break
+		case <-ctx.Done():
+			return multistep.ActionHalt
+		}
+	}
+
+	var pauseFn multistep.DebugPauseFn
+	if debug {
+		pauseFn = state.Get("pauseFn").(multistep.DebugPauseFn)
+	}
+
+	vncAddress, err := domainVNCAddress(driver, name)
+	if err != nil {
+		err := fmt.Errorf("Error reading the VNC address of the domain: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Connect to VNC
+	ui.Say(fmt.Sprintf("Connecting to VM via VNC (%s)", vncAddress))
+
+	nc, err := net.Dial("tcp", vncAddress)
+	if err != nil {
+		err := fmt.Errorf("Error connecting to VNC: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer nc.Close()
+
+	auth := []vnc.ClientAuth{new(vnc.ClientAuthNone)}
+	c, err := vnc.Client(nc, &vnc.ClientConfig{Auth: auth, Exclusive: false})
+	if err != nil {
+		err := fmt.Errorf("Error handshaking with VNC: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer c.Close()
+
+	log.Printf("Connected to VNC desktop: %s", c.DesktopName)
+
+	hostIP, err := hostAddress(driver, config.NetworkInterfaces[0])
+	if err != nil {
+		err := fmt.Errorf("Error reading the address of the host: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	common.SetHTTPIP(hostIP)
+	configCtx := config.ctx
+	configCtx.Data = &bootCommandTemplateData{
+		hostIP,
+		httpPort,
+		config.VMName,
+	}
+
+	d := bootcommand.NewVNCDriver(c, config.VNCConfig.BootKeyInterval)
+
+	ui.Say("Typing the boot command over VNC...")
+	command, err := interpolate.Render(config.VNCConfig.FlatBootCommand(), &configCtx)
+	if err != nil {
+		err := fmt.Errorf("Error preparing boot command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	seq, err := bootcommand.GenerateExpressionSequence(command)
+	if err != nil {
+		err := fmt.Errorf("Error generating boot command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ctx = bootcommand.WithHTTPCallback(ctx, common.HTTPCallback(state))
+	if err := seq.Do(ctx, d); err != nil {
+		err := fmt.Errorf("Error running boot command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if pauseFn != nil {
+		pauseFn(multistep.DebugLocationAfterRun, fmt.Sprintf("boot_command: %s", command), state)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (*stepTypeBootCommand) Cleanup(multistep.StateBag) {}
+
+// domainVNCAddress returns the address of the VNC server of a running
+// domain, read from its live definition where libvirt has set the port.
+func domainVNCAddress(driver Driver, name string) (string, error) {
+	definition, err := driver.DomainXML(name)
+	if err != nil {
+		return "", err
+	}
+	d, err := parseDomain(definition)
+	if err != nil {
+		return "", err
+	}
+
+	for _, g := range d.Devices.Graphics {
+		if g.Type != "vnc" {
+			continue
+		}
+		if g.Port <= 0 {
+			return "", fmt.Errorf("The VNC server of the domain has no port")
+		}
+		listen := g.Listen
+		if listen == "" || listen == "0.0.0.0" {
+			listen = "127.0.0.1"
+		}
+		return net.JoinHostPort(listen, strconv.Itoa(g.Port)), nil
+	}
+	return "", fmt.Errorf("The domain has no VNC server")
+}
+
+// hostAddress returns the IP address of the host on the network of an
+// interface of the domain, the address the guest reaches the HTTP server
+// at.
+func hostAddress(driver Driver, iface NetworkInterface) (string, error) {
+	if iface.Network != "" {
+		return driver.NetworkAddress(iface.Network)
+	}
+
+	bridge, err := net.InterfaceByName(iface.Bridge)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := bridge.Addrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return ipnet.IP.String(), nil
+		}
+	}
+	return "", fmt.Errorf("Bridge %s has no IPv4 address", iface.Bridge)
+}
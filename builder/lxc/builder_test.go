@@ -54,3 +54,55 @@ func TestBuilder_ImplementsBuilder(t *testing.T) {
 		t.Fatalf("Builder should be a builder")
 	}
 }
+
+func TestBuilderPrepare_ArtifactFormat(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.ArtifactFormat != ArtifactFormatLXC {
+		t.Fatalf("bad: %s", b.config.ArtifactFormat)
+	}
+
+	config["artifact_format"] = "squashfs"
+	b = Builder{}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	config["artifact_format"] = "qcow2"
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatalf("should have error")
+	}
+}
+
+func TestBuilderPrepare_Unprivileged(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["unprivileged"] = true
+	config["idmap"] = []string{"u 0 100000 65536", "g 0 100000 65536"}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	command := b.config.hostCommand("touch", "/tmp/.tmpfs")
+	if command[0] != "lxc-usernsexec" || command[len(command)-2] != "touch" {
+		t.Fatalf("bad: %#v", command)
+	}
+
+	// Bad, only UIDs are mapped
+	config["idmap"] = []string{"u 0 100000 65536"}
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatalf("should have error")
+	}
+
+	// Bad, privileged container
+	config = testConfig()
+	config["idmap"] = []string{"u 0 100000 65536", "g 0 100000 65536"}
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatalf("should have error")
+	}
+}
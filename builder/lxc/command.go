@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
+	"os/user"
+	"path/filepath"
 	"strings"
 )
 
@@ -39,3 +41,17 @@ func RunCommand(args ...string) error {
 
 	return err
 }
+
+// containerDir returns the directory of the container name, in the LXC
+// directory of the user running Packer.
+func containerDir(name string) string {
+	// TODO: read from env
+	lxc_dir := "/var/lib/lxc"
+	user, err := user.Current()
+	if err != nil {
+		log.Print("Cannot find current user. Falling back to /var/lib/lxc...")
+	} else if user.Uid != "0" && user.HomeDir != "" {
+		lxc_dir = filepath.Join(user.HomeDir, ".local", "share", "lxc")
+	}
+	return filepath.Join(lxc_dir, name)
+}
@@ -21,6 +21,11 @@ type LxcAttachCommunicator struct {
 	ContainerName string
 	AttachOptions []string
 	CmdWrapper    CommandWrapper
+
+	// The lxc-usernsexec command accessing the root filesystem of
+	// unprivileged containers, whose files are owned by the IDs of their
+	// user namespace
+	UsernsExec []string
 }
 
 func (c *LxcAttachCommunicator) Start(ctx context.Context, cmd *packer.RemoteCmd) error {
@@ -105,7 +110,13 @@ func (c *LxcAttachCommunicator) UploadDir(dst string, src string, exclude []stri
 	// TODO: remove any file copied if it appears in `exclude`
 	dest := filepath.Join(c.RootFs, dst)
 	log.Printf("Uploading directory '%s' to rootfs '%s'", src, dest)
-	cpCmd, err := c.CmdWrapper(fmt.Sprintf("cp -R %s/. %s", src, dest))
+	cp := fmt.Sprintf("cp -R %s/. %s", src, dest)
+	if len(c.UsernsExec) > 0 {
+		// the files are given to the root of the container
+		cp = fmt.Sprintf("tar -cf - -C %s . | %s tar --no-same-owner -xf - -C %s",
+			src, strings.Join(c.UsernsExec, " "), dest)
+	}
+	cpCmd, err := c.CmdWrapper(cp)
 	if err != nil {
 		return err
 	}
@@ -116,6 +127,12 @@ func (c *LxcAttachCommunicator) UploadDir(dst string, src string, exclude []stri
 func (c *LxcAttachCommunicator) Download(src string, w io.Writer) error {
 	src = filepath.Join(c.RootFs, src)
 	log.Printf("Downloading from rootfs dir: %s", src)
+	if len(c.UsernsExec) > 0 {
+		args := append(append([]string{}, c.UsernsExec...), "cat", src)
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = w
+		return cmd.Run()
+	}
 	f, err := os.Open(src)
 	if err != nil {
 		return err
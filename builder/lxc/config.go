@@ -5,6 +5,8 @@ package lxc
 import (
 	"fmt"
 	"os"
+	"os/user"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/packer/common"
@@ -14,6 +16,12 @@ import (
 	"github.com/mitchellh/mapstructure"
 )
 
+const (
+	ArtifactFormatLXC      = "lxc"
+	ArtifactFormatRootfs   = "rootfs"
+	ArtifactFormatSquashfs = "squashfs"
+)
+
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 	// The path to the lxc configuration file.
@@ -62,8 +70,31 @@ type Config struct {
 	// container to reach. Note some distributions (Ubuntu) simulate run levels
 	// and may report 5 rather than 3.
 	TargetRunlevel int `mapstructure:"target_runlevel" required:"false"`
+	// If true, the container is an unprivileged container, whose IDs are
+	// mapped to subordinate IDs of the user running Packer in a user
+	// namespace, and Packer does not need to run as root. The files of the
+	// root filesystem are accessed with `lxc-usernsexec`. Defaults to false.
+	Unprivileged bool `mapstructure:"unprivileged" required:"false"`
+	// The ID mappings of unprivileged containers, in the `u|g container_id
+	// host_id count` format of `lxc.idmap`. For instance, `["u 0 100000
+	// 65536", "g 0 100000 65536"]`. Defaults to mapping the root of the
+	// container to the first ranges of subordinate UIDs and GIDs of the user
+	// in /etc/subuid and /etc/subgid.
+	IDMap []string `mapstructure:"idmap" required:"false"`
+	// The format of the exported container. `lxc`, the default, is a
+	// rootfs.tar.gz archive of the rootfs directory of the container, with
+	// its lxc-config configuration file. `rootfs` is a rootfs.tar.gz archive
+	// of the content of the root filesystem, and `squashfs` a rootfs.squashfs
+	// image of it made with `mksquashfs`, which can both be imported into
+	// other systems.
+	ArtifactFormat string `mapstructure:"artifact_format" required:"false"`
 	InitTimeout    time.Duration
 
+	// The flags of lxc-usernsexec, and the user running Packer in the
+	// namespace, for unprivileged containers
+	usernsexec []string
+	nsOwner    string
+
 	ctx interpolate.Context
 }
 
@@ -111,9 +142,62 @@ func NewConfig(raws ...interface{}) (*Config, error) {
 		errs = packer.MultiErrorAppend(errs, fmt.Errorf("LXC Config file appears to be missing: %s", c.ConfigFile))
 	}
 
+	switch c.ArtifactFormat {
+	case "":
+		c.ArtifactFormat = ArtifactFormatLXC
+	case ArtifactFormatLXC, ArtifactFormatRootfs, ArtifactFormatSquashfs:
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"Invalid artifact_format %q, expected lxc, rootfs or squashfs", c.ArtifactFormat))
+	}
+
+	if c.Unprivileged {
+		if err := c.prepareUserns(); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	} else if len(c.IDMap) > 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("idmap can only be set for unprivileged containers"))
+	}
+
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
 	}
 
 	return &c, nil
 }
+
+// prepareUserns sets the ID mappings of unprivileged containers.
+func (c *Config) prepareUserns() error {
+	u, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("Error finding the current user: %s", err)
+	}
+
+	if len(c.IDMap) == 0 {
+		c.IDMap, err = defaultIDMap(u.Username, u.Uid, "/etc/subuid", "/etc/subgid")
+		if err != nil {
+			return err
+		}
+	}
+	maps, err := parseIDMap(c.IDMap)
+	if err != nil {
+		return err
+	}
+
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+	c.usernsexec, c.nsOwner = usernsexecArgs(maps, uid, gid)
+	return nil
+}
+
+// hostCommand returns the command running args on the host, in the user
+// namespace of the container when it is unprivileged, so that args can
+// access the files of its root filesystem.
+func (c *Config) hostCommand(args ...string) []string {
+	if !c.Unprivileged {
+		return args
+	}
+	command := append([]string{"lxc-usernsexec"}, c.usernsexec...)
+	command = append(command, "--")
+	return append(command, args...)
+}
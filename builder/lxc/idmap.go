@@ -0,0 +1,122 @@
+package lxc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// idMapping is an ID mapping of the user namespace of a container, an
+// lxc.idmap of the LXC configuration.
+type idMapping struct {
+	// "u" for UIDs, "g" for GIDs
+	kind      string
+	container int
+	host      int
+	count     int
+}
+
+func (m idMapping) String() string {
+	return fmt.Sprintf("%s %d %d %d", m.kind, m.container, m.host, m.count)
+}
+
+// parseIDMap parses mappings in the "u|g container_id host_id count" format
+// of lxc.idmap. Both the UIDs and the GIDs must be mapped.
+func parseIDMap(entries []string) ([]idMapping, error) {
+	var maps []idMapping
+	var uid, gid bool
+	for _, e := range entries {
+		fields := strings.Fields(e)
+		if len(fields) != 4 || (fields[0] != "u" && fields[0] != "g") {
+			return nil, fmt.Errorf("Invalid idmap %q, expected \"u|g container_id host_id count\"", e)
+		}
+
+		m := idMapping{kind: fields[0]}
+		for i, v := range []*int{&m.container, &m.host, &m.count} {
+			n, err := strconv.Atoi(fields[i+1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("Invalid idmap %q, IDs must be positive integers", e)
+			}
+			*v = n
+		}
+		if m.count == 0 {
+			return nil, fmt.Errorf("Invalid idmap %q, the count can not be 0", e)
+		}
+
+		uid = uid || m.kind == "u"
+		gid = gid || m.kind == "g"
+		maps = append(maps, m)
+	}
+
+	if !uid || !gid {
+		return nil, fmt.Errorf("idmap must map both UIDs (u) and GIDs (g)")
+	}
+	return maps, nil
+}
+
+// defaultIDMap maps the root of the container to the first range of
+// subordinate UIDs and GIDs of the user, read from the subuid and subgid
+// files, where users are named by their name or their ID.
+func defaultIDMap(username, uid, subuid, subgid string) ([]string, error) {
+	var maps []string
+	for _, f := range []struct{ kind, path string }{{"u", subuid}, {"g", subgid}} {
+		start, count, err := subordinateRange(f.path, username, uid)
+		if err != nil {
+			return nil, err
+		}
+		maps = append(maps, fmt.Sprintf("%s 0 %s %s", f.kind, start, count))
+	}
+	return maps, nil
+}
+
+// subordinateRange returns the first range of user in a subuid or subgid
+// file, made of "user:start:count" lines.
+func subordinateRange(path, username, uid string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("Error reading subordinate IDs: %s", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) == 3 && (fields[0] == username || fields[0] == uid) {
+			return fields[1], fields[2], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", fmt.Errorf("Error reading subordinate IDs: %s", err)
+	}
+	return "", "", fmt.Errorf("%s has no subordinate IDs for %s, set idmap", path, username)
+}
+
+// usernsexecArgs returns the flags of lxc-usernsexec running commands in a
+// user namespace with the mappings of the container. The user running
+// Packer is mapped too, past the IDs of the container unless they already
+// map it, so that the commands can write into its directories. The owner,
+// "uid:gid", is the user running Packer in the user namespace.
+func usernsexecArgs(maps []idMapping, uid, gid int) ([]string, string) {
+	var args []string
+	next := 0
+	ids := map[string]int{"u": uid, "g": gid}
+	owner := map[string]int{"u": -1, "g": -1}
+	for _, m := range maps {
+		args = append(args, "-m", fmt.Sprintf("%s:%d:%d:%d", m.kind, m.container, m.host, m.count))
+		if end := m.container + m.count; end > next {
+			next = end
+		}
+		if id := ids[m.kind]; id >= m.host && id < m.host+m.count {
+			owner[m.kind] = m.container + id - m.host
+		}
+	}
+	for _, kind := range []string{"u", "g"} {
+		if owner[kind] < 0 {
+			owner[kind] = next
+			args = append(args, "-m", fmt.Sprintf("%s:%d:%d:1", kind, next, ids[kind]))
+		}
+	}
+	return args, fmt.Sprintf("%d:%d", owner["u"], owner["g"])
+}
@@ -0,0 +1,79 @@
+package lxc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseIDMap(t *testing.T) {
+	maps, err := parseIDMap([]string{"u 0 100000 65536", "g  0 100000  65536"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []idMapping{{"u", 0, 100000, 65536}, {"g", 0, 100000, 65536}}
+	if !reflect.DeepEqual(maps, expected) {
+		t.Fatalf("bad: %#v", maps)
+	}
+
+	bad := [][]string{
+		{"u 0 100000 65536"},
+		{"u 0 100000 65536", "x 0 100000 65536"},
+		{"u 0 100000", "g 0 100000 65536"},
+		{"u 0 100000 -1", "g 0 100000 65536"},
+		{"u 0 100000 0", "g 0 100000 65536"},
+	}
+	for _, entries := range bad {
+		if _, err := parseIDMap(entries); err == nil {
+			t.Fatalf("%#v: should error", entries)
+		}
+	}
+}
+
+func TestDefaultIDMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	subuid := filepath.Join(dir, "subuid")
+	subgid := filepath.Join(dir, "subgid")
+	ioutil.WriteFile(subuid, []byte("alice:100000:65536\npacker:165536:65536\npacker:231072:65536\n"), 0644)
+	ioutil.WriteFile(subgid, []byte("1000:300000:1000\n"), 0644)
+
+	maps, err := defaultIDMap("packer", "1000", subuid, subgid)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(maps, []string{"u 0 165536 65536", "g 0 300000 1000"}) {
+		t.Fatalf("bad: %#v", maps)
+	}
+
+	if _, err := defaultIDMap("bob", "1001", subuid, subgid); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestUsernsexecArgs(t *testing.T) {
+	maps := []idMapping{{"u", 0, 100000, 65536}, {"g", 0, 100000, 65536}}
+	args, owner := usernsexecArgs(maps, 1000, 1000)
+	expected := []string{
+		"-m", "u:0:100000:65536",
+		"-m", "g:0:100000:65536",
+		"-m", "u:65536:1000:1",
+		"-m", "g:65536:1000:1",
+	}
+	if !reflect.DeepEqual(args, expected) || owner != "65536:65536" {
+		t.Fatalf("bad: %#v %s", args, owner)
+	}
+
+	// the user is already mapped
+	maps = append(maps, idMapping{"u", 1000, 1000, 1})
+	args, owner = usernsexecArgs(maps, 1000, 1000)
+	if len(args) != 8 || owner != "1000:65536" {
+		t.Fatalf("bad: %#v %s", args, owner)
+	}
+}
@@ -4,9 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
-	"os/user"
 	"path/filepath"
 
 	"github.com/hashicorp/packer/helper/multistep"
@@ -20,49 +18,60 @@ func (s *stepExport) Run(ctx context.Context, state multistep.StateBag) multiste
 	ui := state.Get("ui").(packer.Ui)
 
 	name := config.ContainerName
+	containerDir := containerDir(name)
 
-	lxc_dir := "/var/lib/lxc"
-	user, err := user.Current()
-	if err != nil {
-		log.Print("Cannot find current user. Falling back to /var/lib/lxc...")
-	}
-	if user.Uid != "0" && user.HomeDir != "" {
-		lxc_dir = filepath.Join(user.HomeDir, ".local", "share", "lxc")
-	}
+	var commands [][]string
+	commands = append(commands, []string{
+		"lxc-stop", "--name", name,
+	})
+
+	var outputPath string
+	switch config.ArtifactFormat {
+	case ArtifactFormatRootfs:
+		outputPath = filepath.Join(config.OutputDir, "rootfs.tar.gz")
+		commands = append(commands, config.hostCommand(
+			"tar", "-C", filepath.Join(containerDir, "rootfs"), "--numeric-owner", "--anchored", "--exclude=./dev/log", "-czf", outputPath, "."))
+	case ArtifactFormatSquashfs:
+		outputPath = filepath.Join(config.OutputDir, "rootfs.squashfs")
+		commands = append(commands, config.hostCommand(
+			"mksquashfs", filepath.Join(containerDir, "rootfs"), outputPath, "-noappend", "-comp", "xz", "-e", "dev/log"))
+	default:
+		outputPath = filepath.Join(config.OutputDir, "rootfs.tar.gz")
+		configFilePath := filepath.Join(config.OutputDir, "lxc-config")
+
+		configFile, err := os.Create(configFilePath)
 
-	containerDir := filepath.Join(lxc_dir, name)
-	outputPath := filepath.Join(config.OutputDir, "rootfs.tar.gz")
-	configFilePath := filepath.Join(config.OutputDir, "lxc-config")
+		if err != nil {
+			err := fmt.Errorf("Error creating config file: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		defer configFile.Close()
 
-	configFile, err := os.Create(configFilePath)
+		originalConfigFile, err := os.Open(config.ConfigFile)
 
-	if err != nil {
-		err := fmt.Errorf("Error creating config file: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
-	}
+		if err != nil {
+			err := fmt.Errorf("Error opening config file: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		defer originalConfigFile.Close()
 
-	originalConfigFile, err := os.Open(config.ConfigFile)
+		_, err = io.Copy(configFile, originalConfigFile)
 
-	if err != nil {
-		err := fmt.Errorf("Error opening config file: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+		commands = append(commands, config.hostCommand(
+			"tar", "-C", containerDir, "--numeric-owner", "--anchored", "--exclude=./rootfs/dev/log", "-czf", outputPath, "./rootfs"))
+		commands = append(commands, []string{
+			"chmod", "+x", configFilePath,
+		})
 	}
 
-	_, err = io.Copy(configFile, originalConfigFile)
-
-	commands := make([][]string, 3)
-	commands[0] = []string{
-		"lxc-stop", "--name", name,
-	}
-	commands[1] = []string{
-		"tar", "-C", containerDir, "--numeric-owner", "--anchored", "--exclude=./rootfs/dev/log", "-czf", outputPath, "./rootfs",
-	}
-	commands[2] = []string{
-		"chmod", "+x", configFilePath,
+	// The archive is created by the root of the user namespace of
+	// unprivileged containers, and given back to the user running Packer.
+	if config.Unprivileged {
+		commands = append(commands, config.hostCommand("chown", config.nsOwner, outputPath))
 	}
 
 	ui.Say("Exporting container...")
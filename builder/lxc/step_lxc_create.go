@@ -3,9 +3,10 @@ package lxc
 import (
 	"context"
 	"fmt"
-	"log"
-	"os/user"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
@@ -19,31 +20,35 @@ func (s *stepLxcCreate) Run(ctx context.Context, state multistep.StateBag) multi
 
 	name := config.ContainerName
 
-	// TODO: read from env
-	lxc_dir := "/var/lib/lxc"
-	user, err := user.Current()
-	if err != nil {
-		log.Print("Cannot find current user. Falling back to /var/lib/lxc...")
-	}
-	if user.Uid != "0" && user.HomeDir != "" {
-		lxc_dir = filepath.Join(user.HomeDir, ".local", "share", "lxc")
-	}
-	rootfs := filepath.Join(lxc_dir, name, "rootfs")
+	rootfs := filepath.Join(containerDir(name), "rootfs")
 
 	if config.PackerForce {
 		s.Cleanup(state)
 	}
 
+	createOptions := config.CreateOptions
+	if config.Unprivileged {
+		configFile, err := writeUnprivilegedConfig(config)
+		if err != nil {
+			err := fmt.Errorf("Error creating container: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		defer os.Remove(configFile)
+		createOptions = append(withoutConfigFile(createOptions), "-f", configFile)
+	}
+
 	commands := make([][]string, 3)
 	commands[0] = append(commands[0], "env")
 	commands[0] = append(commands[0], config.EnvVars...)
 	commands[0] = append(commands[0], "lxc-create")
-	commands[0] = append(commands[0], config.CreateOptions...)
+	commands[0] = append(commands[0], createOptions...)
 	commands[0] = append(commands[0], []string{"-n", name, "-t", config.Name, "--"}...)
 	commands[0] = append(commands[0], config.Parameters...)
 	// prevent tmp from being cleaned on boot, we put provisioning scripts there
 	// todo: wait for init to finish before moving on to provisioning instead of this
-	commands[1] = []string{"touch", filepath.Join(rootfs, "tmp", ".tmpfs")}
+	commands[1] = config.hostCommand("touch", filepath.Join(rootfs, "tmp", ".tmpfs"))
 	commands[2] = append([]string{"lxc-start"}, config.StartOptions...)
 	commands[2] = append(commands[2], []string{"-d", "--name", name}...)
 
@@ -76,3 +81,66 @@ func (s *stepLxcCreate) Cleanup(state multistep.StateBag) {
 		ui.Error(fmt.Sprintf("Error deleting virtual machine: %s", err))
 	}
 }
+
+// writeUnprivilegedConfig writes the configuration file of an unprivileged
+// container, with its ID mappings. The file includes the configuration file
+// of create_options or, like lxc-create, the default configuration of the
+// user.
+func writeUnprivilegedConfig(config *Config) (string, error) {
+	var lines []string
+	if include := configFile(config.CreateOptions); include != "" {
+		lines = append(lines, "lxc.include = "+include)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		if def := filepath.Join(home, ".config", "lxc", "default.conf"); fileExists(def) {
+			lines = append(lines, "lxc.include = "+def)
+		}
+	}
+	for _, m := range config.IDMap {
+		lines = append(lines, "lxc.idmap = "+strings.Join(strings.Fields(m), " "))
+	}
+
+	f, err := ioutil.TempFile("", "packer-lxc-config")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// configFile returns the configuration file of the lxc-create options.
+func configFile(options []string) string {
+	for i, o := range options {
+		switch {
+		case (o == "-f" || o == "--config") && i+1 < len(options):
+			return options[i+1]
+		case strings.HasPrefix(o, "--config="):
+			return strings.TrimPrefix(o, "--config=")
+		}
+	}
+	return ""
+}
+
+// withoutConfigFile returns the lxc-create options without their
+// configuration file.
+func withoutConfigFile(options []string) []string {
+	var result []string
+	for i := 0; i < len(options); i++ {
+		switch o := options[i]; {
+		case o == "-f" || o == "--config":
+			i++
+		case strings.HasPrefix(o, "--config="):
+		default:
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,27 @@
+package lxc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigFile(t *testing.T) {
+	cases := []struct {
+		options  []string
+		file     string
+		stripped []string
+	}{
+		{[]string{"-B", "btrfs"}, "", []string{"-B", "btrfs"}},
+		{[]string{"-f", "/etc/lxc/custom.conf", "-B", "btrfs"}, "/etc/lxc/custom.conf", []string{"-B", "btrfs"}},
+		{[]string{"--config", "/etc/lxc/custom.conf"}, "/etc/lxc/custom.conf", nil},
+		{[]string{"--config=/etc/lxc/custom.conf"}, "/etc/lxc/custom.conf", nil},
+	}
+	for _, tc := range cases {
+		if file := configFile(tc.options); file != tc.file {
+			t.Fatalf("%#v: bad: %s", tc.options, file)
+		}
+		if options := withoutConfigFile(tc.options); !reflect.DeepEqual(options, tc.stripped) {
+			t.Fatalf("%#v: bad: %#v", tc.options, options)
+		}
+	}
+}
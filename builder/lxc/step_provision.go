@@ -25,6 +25,9 @@ func (s *StepProvision) Run(ctx context.Context, state multistep.StateBag) multi
 		RootFs:        mountPath,
 		CmdWrapper:    wrappedCommand,
 	}
+	if config.Unprivileged {
+		comm.UsernsExec = config.hostCommand()
+	}
 
 	// Provision
 	log.Println("Running the provision hook")
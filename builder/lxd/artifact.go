@@ -2,10 +2,15 @@ package lxd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 )
 
 type Artifact struct {
 	id string
+
+	// The files of the exported image, if any
+	files []string
 }
 
 func (*Artifact) BuilderId() string {
@@ -13,7 +18,7 @@ func (*Artifact) BuilderId() string {
 }
 
 func (a *Artifact) Files() []string {
-	return nil
+	return a.files
 }
 
 func (a *Artifact) Id() string {
@@ -21,6 +26,9 @@ func (a *Artifact) Id() string {
 }
 
 func (a *Artifact) String() string {
+	if len(a.files) > 0 {
+		return fmt.Sprintf("image: %s, exported to: %s", a.id, strings.Join(a.files, ", "))
+	}
 	return fmt.Sprintf("image: %s", a.id)
 }
 
@@ -29,6 +37,11 @@ func (a *Artifact) State(name string) interface{} {
 }
 
 func (a *Artifact) Destroy() error {
+	for _, f := range a.files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	_, err := LXDCommand("image", "delete", a.id)
 	return err
 }
@@ -37,10 +37,20 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		return interpolate.Render(b.config.CommandWrapper, &b.config.ctx)
 	}
 
-	steps := []multistep.Step{
+	var steps []multistep.Step
+	if b.config.ExportFormat != "" {
+		steps = append(steps, &common.StepOutputDir{
+			Force: b.config.PackerForce,
+			Path:  b.config.OutputDir,
+		})
+	}
+	steps = append(steps,
 		&stepLxdLaunch{},
 		&StepProvision{},
 		&stepPublish{},
+	)
+	if b.config.ExportFormat != "" {
+		steps = append(steps, &stepExport{})
 	}
 
 	// Setup the state bag
@@ -62,6 +72,9 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	artifact := &Artifact{
 		id: state.Get("imageFingerprint").(string),
 	}
+	if files, ok := state.GetOk("exportFiles"); ok {
+		artifact.files = files.([]string)
+	}
 
 	return artifact, nil
 }
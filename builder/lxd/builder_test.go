@@ -75,3 +75,64 @@ func TestBuilder_ImplementsBuilder(t *testing.T) {
 		t.Fatalf("Builder should be a builder")
 	}
 }
+
+func TestBuilderPrepare_ExportFormat(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["packer_build_name"] = "foo"
+	config["export_format"] = "split"
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.OutputDir != "output-foo" {
+		t.Fatalf("bad: %s", b.config.OutputDir)
+	}
+
+	// no output directory without export
+	b = Builder{}
+	if _, err := b.Prepare(testConfig()); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.OutputDir != "" {
+		t.Fatalf("bad: %s", b.config.OutputDir)
+	}
+
+	config = testConfig()
+	config["export_format"] = "zip"
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatalf("should have error")
+	}
+}
+
+func TestBuilderPrepare_LaunchConfig(t *testing.T) {
+	cases := []struct {
+		config         map[string]string
+		virtualMachine bool
+		err            bool
+	}{
+		{map[string]string{"limits.cpu": "2", "user.foo": "bar"}, false, false},
+		{map[string]string{"security.nesting": "true"}, false, false},
+		{map[string]string{"linux.sysctl.net.ipv4.ip_forward": "1"}, false, false},
+		{map[string]string{"raw.lxc": "lxc.apparmor.profile=unconfined"}, false, false},
+		{map[string]string{"boot.autostart": "false"}, false, false},
+		{map[string]string{"security.secureboot": "false"}, true, false},
+		{map[string]string{"limits.cpus": "2"}, false, true},
+		{map[string]string{"user.": "bar"}, false, true},
+		{map[string]string{"volatile.base_image": "c0ffee"}, false, true},
+		{map[string]string{"volatile.base_image": "c0ffee"}, true, true},
+		{map[string]string{"security.nesting": "true"}, true, true},
+		{map[string]string{"raw.lxc": "lxc.apparmor.profile=unconfined"}, true, true},
+		{map[string]string{"security.secureboot": "false"}, false, true},
+	}
+	for _, tc := range cases {
+		var b Builder
+		config := testConfig()
+		config["launch_config"] = tc.config
+		config["virtual_machine"] = tc.virtualMachine
+		_, err := b.Prepare(config)
+		if (err != nil) != tc.err {
+			t.Fatalf("%#v: bad error: %v", tc.config, err)
+		}
+	}
+}
@@ -12,6 +12,11 @@ import (
 	"github.com/mitchellh/mapstructure"
 )
 
+const (
+	ExportFormatUnified = "unified"
+	ExportFormatSplit   = "split"
+)
+
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 	// The name of the output artifact. Defaults to
@@ -38,6 +43,27 @@ type Config struct {
 	// List of key/value pairs you wish to
 	// pass to lxc launch via --config. Defaults to empty.
 	LaunchConfig map[string]string `mapstructure:"launch_config" required:"false"`
+	// If true, the build instance is an LXD virtual machine instead of a
+	// container, launched with `lxc launch --vm`. The source image must be a
+	// virtual machine image, and the provisioners wait for the LXD agent of
+	// the virtual machine to be running. Defaults to false.
+	VirtualMachine bool `mapstructure:"virtual_machine" required:"false"`
+	// The storage pool of the root disk of the instance, passed to
+	// `lxc launch` via --storage. Defaults to the pool of the profile.
+	StoragePool string `mapstructure:"storage_pool" required:"false"`
+	// The network the instance is attached to, passed to `lxc launch` via
+	// --network. Defaults to the network of the profile.
+	Network string `mapstructure:"network" required:"false"`
+	// If set, the published image is exported into `output_directory`, so
+	// it can be imported on another LXD host with `lxc image import`. Can be
+	// `unified`, a single tarball with the metadata and the root filesystem
+	// of the image, or `split`, a metadata tarball and a separate root
+	// filesystem: a tarball for containers, a qcow2 disk for virtual
+	// machines. Defaults to "", the image is not exported.
+	ExportFormat string `mapstructure:"export_format" required:"false"`
+	// The directory the image is exported into with `export_format`. Defaults
+	// to `output-BUILDNAME`, where "BUILDNAME" is the name of the build.
+	OutputDir string `mapstructure:"output_directory" required:"false"`
 
 	ctx interpolate.Context
 }
@@ -83,6 +109,19 @@ func NewConfig(raws ...interface{}) (*Config, error) {
 		c.InitSleep = "3"
 	}
 
+	switch c.ExportFormat {
+	case "":
+	case ExportFormatUnified, ExportFormatSplit:
+		if c.OutputDir == "" {
+			c.OutputDir = fmt.Sprintf("output-%s", c.PackerBuildName)
+		}
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"Invalid export_format %q, expected unified or split", c.ExportFormat))
+	}
+
+	errs = packer.MultiErrorAppend(errs, validateLaunchConfig(c.LaunchConfig, c.VirtualMachine)...)
+
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
 	}
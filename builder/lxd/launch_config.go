@@ -0,0 +1,130 @@
+package lxd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The kinds of instances an LXD configuration key applies to.
+const (
+	instanceAny = iota
+	instanceContainer
+	instanceVirtualMachine
+)
+
+// instanceConfigKeys are the configuration keys of LXD instances, see
+// https://linuxcontainers.org/lxd/docs/master/instances
+var instanceConfigKeys = map[string]int{
+	"agent.nic_config":                          instanceVirtualMachine,
+	"boot.autostart":                            instanceAny,
+	"boot.autostart.delay":                      instanceAny,
+	"boot.autostart.priority":                   instanceAny,
+	"boot.host_shutdown_timeout":                instanceAny,
+	"boot.stop.priority":                        instanceAny,
+	"cloud-init.network-config":                 instanceAny,
+	"cloud-init.user-data":                      instanceAny,
+	"cloud-init.vendor-data":                    instanceAny,
+	"limits.cpu":                                instanceAny,
+	"limits.cpu.allowance":                      instanceContainer,
+	"limits.cpu.priority":                       instanceContainer,
+	"limits.disk.priority":                      instanceAny,
+	"limits.memory":                             instanceAny,
+	"limits.memory.enforce":                     instanceContainer,
+	"limits.memory.hugepages":                   instanceAny,
+	"limits.memory.swap":                        instanceContainer,
+	"limits.memory.swap.priority":               instanceContainer,
+	"limits.network.priority":                   instanceAny,
+	"limits.processes":                          instanceContainer,
+	"linux.kernel_modules":                      instanceContainer,
+	"migration.incremental.memory":              instanceContainer,
+	"migration.incremental.memory.goal":         instanceContainer,
+	"migration.incremental.memory.iterations":   instanceContainer,
+	"migration.stateful":                        instanceVirtualMachine,
+	"nvidia.driver.capabilities":                instanceContainer,
+	"nvidia.require.cuda":                       instanceContainer,
+	"nvidia.require.driver":                     instanceContainer,
+	"nvidia.runtime":                            instanceContainer,
+	"raw.apparmor":                              instanceAny,
+	"raw.idmap":                                 instanceContainer,
+	"raw.lxc":                                   instanceContainer,
+	"raw.qemu":                                  instanceVirtualMachine,
+	"raw.seccomp":                               instanceContainer,
+	"security.agent.metrics":                    instanceVirtualMachine,
+	"security.csm":                              instanceVirtualMachine,
+	"security.devlxd":                           instanceAny,
+	"security.devlxd.images":                    instanceContainer,
+	"security.idmap.base":                       instanceContainer,
+	"security.idmap.isolated":                   instanceContainer,
+	"security.idmap.size":                       instanceContainer,
+	"security.nesting":                          instanceContainer,
+	"security.privileged":                       instanceContainer,
+	"security.protection.delete":                instanceAny,
+	"security.protection.shift":                 instanceContainer,
+	"security.secureboot":                       instanceVirtualMachine,
+	"security.syscalls.allow":                   instanceContainer,
+	"security.syscalls.deny":                    instanceContainer,
+	"security.syscalls.deny_compat":             instanceContainer,
+	"security.syscalls.deny_default":            instanceContainer,
+	"security.syscalls.intercept.bpf":           instanceContainer,
+	"security.syscalls.intercept.bpf.devices":   instanceContainer,
+	"security.syscalls.intercept.mknod":         instanceContainer,
+	"security.syscalls.intercept.mount":         instanceContainer,
+	"security.syscalls.intercept.mount.allowed": instanceContainer,
+	"security.syscalls.intercept.mount.fuse":    instanceContainer,
+	"security.syscalls.intercept.mount.shift":   instanceContainer,
+	"security.syscalls.intercept.setxattr":      instanceContainer,
+	"snapshots.expiry":                          instanceAny,
+	"snapshots.pattern":                         instanceAny,
+	"snapshots.schedule":                        instanceAny,
+	"snapshots.schedule.stopped":                instanceAny,
+}
+
+// instanceConfigPrefixes are the namespaces of free form configuration
+// keys.
+var instanceConfigPrefixes = map[string]int{
+	"environment.":      instanceAny,
+	"image.":            instanceAny,
+	"limits.hugepages.": instanceContainer,
+	"limits.kernel.":    instanceContainer,
+	"linux.sysctl.":     instanceContainer,
+	"user.":             instanceAny,
+}
+
+// validateLaunchConfig checks that the keys of the launch_config option are
+// LXD configuration keys supported by the kind of the instance.
+func validateLaunchConfig(config map[string]string, virtualMachine bool) []error {
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		if strings.HasPrefix(k, "volatile.") {
+			errs = append(errs, fmt.Errorf(
+				"launch_config key %q is managed by LXD and can not be set", k))
+			continue
+		}
+
+		kind, ok := instanceConfigKeys[k]
+		for prefix, v := range instanceConfigPrefixes {
+			if !ok && strings.HasPrefix(k, prefix) && len(k) > len(prefix) {
+				kind, ok = v, true
+			}
+		}
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf(
+				"launch_config key %q is not an LXD instance configuration key", k))
+		case kind == instanceContainer && virtualMachine:
+			errs = append(errs, fmt.Errorf(
+				"launch_config key %q is only supported by containers", k))
+		case kind == instanceVirtualMachine && !virtualMachine:
+			errs = append(errs, fmt.Errorf(
+				"launch_config key %q is only supported by virtual machines", k))
+		}
+	}
+	return errs
+}
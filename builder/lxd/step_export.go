@@ -0,0 +1,365 @@
+package lxd
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/ulikunitz/xz"
+)
+
+// stepExport exports the published image into the output directory, in the
+// format of export_format.
+type stepExport struct{}
+
+func (s *stepExport) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	fingerprint := state.Get("imageFingerprint").(string)
+
+	// lxc names the exported files after the fingerprint when the target is
+	// a directory, and names them differently for unified and split images.
+	tmp, err := ioutil.TempDir(config.OutputDir, "export")
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer os.RemoveAll(tmp)
+
+	ui.Say("Exporting image...")
+	if _, err := LXDCommand("image", "export", fingerprint, tmp); err != nil {
+		err := fmt.Errorf("Error exporting image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// the name of the output image, without its remote
+	name := config.OutputImage
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	files, err := exportImageFiles(tmp, config.OutputDir, name, config.ExportFormat)
+	if err != nil {
+		err := fmt.Errorf("Error exporting image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	for _, f := range files {
+		ui.Message(fmt.Sprintf("Exported %s", f))
+	}
+
+	state.Put("exportFiles", files)
+	return multistep.ActionContinue
+}
+
+func (s *stepExport) Cleanup(state multistep.StateBag) {}
+
+// exportImageFiles moves the files of an image exported by lxc in dir into
+// outputDir, named after name, and converts them to format.
+func exportImageFiles(dir, outputDir, name, format string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta, rootfs string
+	for _, fi := range infos {
+		if strings.HasPrefix(fi.Name(), "meta-") {
+			meta = fi.Name()
+		} else {
+			rootfs = fi.Name()
+		}
+	}
+	if rootfs == "" {
+		return nil, fmt.Errorf("lxc did not export any file")
+	}
+
+	// outputName keeps the extensions of an exported file
+	outputName := func(file, prefix string) string {
+		ext := ""
+		if i := strings.Index(file, "."); i >= 0 {
+			ext = file[i:]
+		}
+		return filepath.Join(outputDir, prefix+name+ext)
+	}
+
+	switch {
+	case meta == "" && format == ExportFormatSplit:
+		return splitImage(filepath.Join(dir, rootfs), outputDir, name)
+	case meta == "":
+		dst := outputName(rootfs, "")
+		return []string{dst}, os.Rename(filepath.Join(dir, rootfs), dst)
+	case format == ExportFormatSplit:
+		files := []string{outputName(meta[len("meta-"):], "meta-"), outputName(rootfs, "")}
+		if err := os.Rename(filepath.Join(dir, meta), files[0]); err != nil {
+			return nil, err
+		}
+		return files, os.Rename(filepath.Join(dir, rootfs), files[1])
+	default:
+		dst := filepath.Join(outputDir, name+".tar")
+		err := combineImage(filepath.Join(dir, meta), filepath.Join(dir, rootfs), dst)
+		return []string{dst}, err
+	}
+}
+
+// combineImage combines the metadata tarball meta and the root filesystem
+// rootfs of a split image into the unified image tarball dst. The root
+// filesystem of virtual machines is a qcow2 disk, stored as rootfs.img, and
+// the one of containers a tarball, stored under rootfs/. Squashfs root
+// filesystems can not be read and are refused.
+func combineImage(meta, rootfs, dst string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+
+	if err := copyTar(tw, meta, ""); err != nil {
+		return err
+	}
+
+	rf, err := os.Open(rootfs)
+	if err != nil {
+		return err
+	}
+	defer rf.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(rf, magic); err != nil {
+		return err
+	}
+	if _, err := rf.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte("QFI\xfb")):
+		fi, err := rf.Stat()
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    "rootfs.img",
+			Mode:    0600,
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, rf); err != nil {
+			return err
+		}
+	case bytes.Equal(magic, []byte("hsqs")):
+		return fmt.Errorf("the image is stored by LXD as a split image with a " +
+			"squashfs root filesystem, which can not be exported as a unified " +
+			"image, use an export_format of split")
+	default:
+		hdr := &tar.Header{Name: "rootfs/", Typeflag: tar.TypeDir, Mode: 0755}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if err := copyTar(tw, rootfs, "rootfs/"); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// copyTar copies the entries of the tarball src, which may be compressed,
+// into tw, with prefix prepended to their names.
+func copyTar(tw *tar.Writer, src, prefix string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := decompress(f)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %s", src, err)
+		}
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "./")
+		if name == "." {
+			continue
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			name += "/"
+		}
+		hdr.Name = prefix + name
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = prefix + strings.TrimPrefix(path.Clean(hdr.Linkname), "./")
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// splitImage splits the unified image tarball src into the metadata
+// tarball and the root filesystem of a split image, written into dir. The
+// root filesystem of containers is a tarball, and the one of virtual
+// machines a qcow2 disk.
+func splitImage(src, dir, name string) ([]string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decompress(f)
+	if err != nil {
+		return nil, err
+	}
+
+	metaPath := filepath.Join(dir, "meta-"+name+".tar")
+	metaFile, err := os.Create(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer metaFile.Close()
+	meta := tar.NewWriter(metaFile)
+
+	var rootfsPath string
+	var rootfsFile *os.File
+	var rootfs *tar.Writer
+	defer func() {
+		if rootfsFile != nil {
+			rootfsFile.Close()
+		}
+	}()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry := strings.TrimPrefix(path.Clean(hdr.Name), "./")
+
+		switch {
+		case entry == "rootfs.img":
+			// the disk of a virtual machine
+			if rootfsFile != nil {
+				return nil, fmt.Errorf("the image has more than one root filesystem")
+			}
+			rootfsPath = filepath.Join(dir, name+".img")
+			if rootfsFile, err = os.Create(rootfsPath); err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(rootfsFile, tr); err != nil {
+				return nil, err
+			}
+		case entry == "rootfs" || strings.HasPrefix(entry, "rootfs/"):
+			// the filesystem of a container, at the root of the split tarball
+			if rootfs == nil {
+				if rootfsFile != nil {
+					return nil, fmt.Errorf("the image has more than one root filesystem")
+				}
+				rootfsPath = filepath.Join(dir, name+".tar")
+				if rootfsFile, err = os.Create(rootfsPath); err != nil {
+					return nil, err
+				}
+				rootfs = tar.NewWriter(rootfsFile)
+			}
+			if entry == "rootfs" {
+				continue
+			}
+			hdr.Name = strings.TrimPrefix(strings.TrimPrefix(hdr.Name, "./"), "rootfs/")
+			if hdr.Typeflag == tar.TypeLink {
+				link := strings.TrimPrefix(path.Clean(hdr.Linkname), "./")
+				hdr.Linkname = strings.TrimPrefix(link, "rootfs/")
+			}
+			if err := rootfs.WriteHeader(hdr); err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(rootfs, tr); err != nil {
+				return nil, err
+			}
+		default:
+			if err := meta.WriteHeader(hdr); err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(meta, tr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if rootfsFile == nil {
+		return nil, fmt.Errorf("the image has no root filesystem")
+	}
+	if rootfs != nil {
+		if err := rootfs.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := rootfsFile.Close(); err != nil {
+		return nil, err
+	}
+	if err := meta.Close(); err != nil {
+		return nil, err
+	}
+
+	return []string{metaPath, rootfsPath}, nil
+}
+
+// decompress returns a reader of the content of r, which may be compressed
+// with one of the algorithms of LXD images.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(br), nil
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return xz.NewReader(br)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return nil, fmt.Errorf("zstd compressed tarballs are not supported")
+	default:
+		return br, nil
+	}
+}
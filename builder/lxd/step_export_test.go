@@ -0,0 +1,272 @@
+package lxd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type testTarEntry struct {
+	name, content string
+}
+
+func testTar(t *testing.T, w io.Writer, entries []testTarEntry) {
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content))}
+		if e.name[len(e.name)-1] == '/' {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func readTestTar(t *testing.T, path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	entries := map[string]string{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		entries[hdr.Name] = string(b)
+	}
+}
+
+func TestSplitImage_container(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	testTar(t, gw, []testTarEntry{
+		{"metadata.yaml", "architecture: x86_64"},
+		{"templates/", ""},
+		{"templates/hostname.tpl", "{{ container.name }}"},
+		{"rootfs/", ""},
+		{"rootfs/etc/", ""},
+		{"rootfs/etc/hostname", "packer"},
+	})
+	gw.Close()
+	src := filepath.Join(dir, "c0ffee.tar.gz")
+	if err := ioutil.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files, err := splitImage(src, dir, "foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{filepath.Join(dir, "meta-foo.tar"), filepath.Join(dir, "foo.tar")}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	meta := readTestTar(t, files[0])
+	expectedMeta := map[string]string{
+		"metadata.yaml":          "architecture: x86_64",
+		"templates/":             "",
+		"templates/hostname.tpl": "{{ container.name }}",
+	}
+	if !reflect.DeepEqual(meta, expectedMeta) {
+		t.Fatalf("bad: %#v", meta)
+	}
+	rootfs := readTestTar(t, files[1])
+	if !reflect.DeepEqual(rootfs, map[string]string{"etc/": "", "etc/hostname": "packer"}) {
+		t.Fatalf("bad: %#v", rootfs)
+	}
+}
+
+func TestSplitImage_virtualMachine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "c0ffee.tar")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testTar(t, f, []testTarEntry{
+		{"metadata.yaml", "architecture: x86_64"},
+		{"rootfs.img", "QFI\xfb"},
+	})
+	f.Close()
+
+	files, err := splitImage(src, dir, "foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if files[1] != filepath.Join(dir, "foo.img") {
+		t.Fatalf("bad: %#v", files)
+	}
+	b, err := ioutil.ReadFile(files[1])
+	if err != nil || string(b) != "QFI\xfb" {
+		t.Fatalf("bad: %q %v", b, err)
+	}
+	if meta := readTestTar(t, files[0]); len(meta) != 1 {
+		t.Fatalf("bad: %#v", meta)
+	}
+}
+
+func TestSplitImage_noRootfs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "c0ffee.tar")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testTar(t, f, []testTarEntry{{"metadata.yaml", "architecture: x86_64"}})
+	f.Close()
+
+	if _, err := splitImage(src, dir, "foo"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestExportImageFiles(t *testing.T) {
+	cases := []struct {
+		exported []string
+		format   string
+		expected []string
+		err      bool
+	}{
+		{[]string{"c0ffee.tar.gz"}, ExportFormatUnified, []string{"foo.tar.gz"}, false},
+		{[]string{"meta-c0ffee.tar.xz", "c0ffee.squashfs"}, ExportFormatSplit, []string{"meta-foo.tar.xz", "foo.squashfs"}, false},
+		{nil, ExportFormatUnified, nil, true},
+	}
+	for _, tc := range cases {
+		outputDir, err := ioutil.TempDir("", "packer")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer os.RemoveAll(outputDir)
+		dir := filepath.Join(outputDir, "export")
+		os.Mkdir(dir, 0755)
+		for _, f := range tc.exported {
+			ioutil.WriteFile(filepath.Join(dir, f), []byte("image"), 0644)
+		}
+
+		files, err := exportImageFiles(dir, outputDir, "foo", tc.format)
+		if (err != nil) != tc.err {
+			t.Fatalf("%#v: bad error: %v", tc.exported, err)
+		}
+		if tc.err {
+			continue
+		}
+		var expected []string
+		for _, f := range tc.expected {
+			expected = append(expected, filepath.Join(outputDir, f))
+			if _, err := os.Stat(filepath.Join(outputDir, f)); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+		if !reflect.DeepEqual(files, expected) {
+			t.Fatalf("bad: %#v", files)
+		}
+	}
+}
+
+func TestExportImageFiles_combine(t *testing.T) {
+	cases := []struct {
+		rootfs   string
+		content  []byte
+		expected map[string]string
+		err      bool
+	}{
+		{
+			"c0ffee.img",
+			[]byte("QFI\xfbdisk"),
+			map[string]string{"metadata.yaml": "architecture: x86_64", "rootfs.img": "QFI\xfbdisk"},
+			false,
+		},
+		{
+			"c0ffee.tar",
+			nil,
+			map[string]string{
+				"metadata.yaml":       "architecture: x86_64",
+				"rootfs/":             "",
+				"rootfs/etc/":         "",
+				"rootfs/etc/hostname": "packer",
+			},
+			false,
+		},
+		{"c0ffee.squashfs", []byte("hsqs"), nil, true},
+	}
+	for _, tc := range cases {
+		outputDir, err := ioutil.TempDir("", "packer")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer os.RemoveAll(outputDir)
+		dir := filepath.Join(outputDir, "export")
+		os.Mkdir(dir, 0755)
+
+		var meta bytes.Buffer
+		testTar(t, &meta, []testTarEntry{{"metadata.yaml", "architecture: x86_64"}})
+		if err := ioutil.WriteFile(filepath.Join(dir, "meta-c0ffee.tar"), meta.Bytes(), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		content := tc.content
+		if content == nil {
+			var rootfs bytes.Buffer
+			testTar(t, &rootfs, []testTarEntry{{"etc/", ""}, {"etc/hostname", "packer"}})
+			content = rootfs.Bytes()
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, tc.rootfs), content, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		files, err := exportImageFiles(dir, outputDir, "foo", ExportFormatUnified)
+		if (err != nil) != tc.err {
+			t.Fatalf("%s: bad error: %v", tc.rootfs, err)
+		}
+		if tc.err {
+			continue
+		}
+		if !reflect.DeepEqual(files, []string{filepath.Join(outputDir, "foo.tar")}) {
+			t.Fatalf("bad: %#v", files)
+		}
+		if entries := readTestTar(t, files[0]); !reflect.DeepEqual(entries, tc.expected) {
+			t.Fatalf("%s: bad: %#v", tc.rootfs, entries)
+		}
+	}
+}
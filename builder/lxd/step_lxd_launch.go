@@ -11,6 +11,9 @@ import (
 	"github.com/hashicorp/packer/packer"
 )
 
+// agentTimeout is how long the LXD agent of virtual machines has to start.
+const agentTimeout = 5 * time.Minute
+
 type stepLxdLaunch struct{}
 
 func (s *stepLxdLaunch) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -25,6 +28,16 @@ func (s *stepLxdLaunch) Run(ctx context.Context, state multistep.StateBag) multi
 		"launch", "--ephemeral=false", profile, image, name,
 	}
 
+	if config.VirtualMachine {
+		launch_args = append(launch_args, "--vm")
+	}
+	if config.StoragePool != "" {
+		launch_args = append(launch_args, "--storage", config.StoragePool)
+	}
+	if config.Network != "" {
+		launch_args = append(launch_args, "--network", config.Network)
+	}
+
 	for k, v := range config.LaunchConfig {
 		launch_args = append(launch_args, "--config", fmt.Sprintf("%s=%s", k, v))
 	}
@@ -37,6 +50,18 @@ func (s *stepLxdLaunch) Run(ctx context.Context, state multistep.StateBag) multi
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+
+	// Commands run in virtual machines through the LXD agent, which starts
+	// once the virtual machine has booted.
+	if config.VirtualMachine {
+		ui.Say("Waiting for the LXD agent of the virtual machine...")
+		if err := waitForAgent(ctx, name, agentTimeout); err != nil {
+			err := fmt.Errorf("Error waiting for the LXD agent: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
 	sleep_seconds, err := strconv.Atoi(config.InitSleep)
 	if err != nil {
 		err := fmt.Errorf("Error parsing InitSleep into int: %s", err)
@@ -66,3 +91,24 @@ func (s *stepLxdLaunch) Cleanup(state multistep.StateBag) {
 		ui.Error(fmt.Sprintf("Error deleting container: %s", err))
 	}
 }
+
+// waitForAgent polls the instance name until a command can be executed in
+// it, or timeout expires.
+func waitForAgent(ctx context.Context, name string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		_, err := LXDCommand("exec", name, "--", "true")
+		if err == nil {
+			return nil
+		}
+		log.Printf("LXD agent not ready: %s", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the agent did not start: %s", err)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
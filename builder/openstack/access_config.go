@@ -243,7 +243,8 @@ func (c *AccessConfig) computeV2Client() (*gophercloud.ServiceClient, error) {
 	})
 }
 
-func (c *AccessConfig) imageV2Client() (*gophercloud.ServiceClient, error) {
+// ImageV2Client returns a client of the image service, version 2.
+func (c *AccessConfig) ImageV2Client() (*gophercloud.ServiceClient, error) {
 	return openstack.NewImageServiceV2(c.osClient, gophercloud.EndpointOpts{
 		Region:       c.Region,
 		Availability: c.getEndpointType(),
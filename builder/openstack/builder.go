@@ -71,7 +71,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		return nil, fmt.Errorf("Error initializing compute client: %s", err)
 	}
 
-	imageClient, err := b.config.imageV2Client()
+	imageClient, err := b.config.ImageV2Client()
 	if err != nil {
 		return nil, fmt.Errorf("Error initializing image client: %s", err)
 	}
@@ -151,10 +151,22 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		&stepCreateImage{
 			UseBlockStorageVolume: b.config.UseBlockStorageVolume,
 		},
-		&stepUpdateImageTags{},
-		&stepUpdateImageVisibility{},
-		&stepAddImageMembers{},
-		&stepUpdateImageMinDisk{},
+		&StepUpdateImageTags{
+			AccessConfig: &b.config.AccessConfig,
+			Tags:         b.config.ImageTags,
+		},
+		&StepUpdateImageVisibility{
+			AccessConfig: &b.config.AccessConfig,
+			Visibility:   b.config.ImageVisibility,
+		},
+		&StepAddImageMembers{
+			AccessConfig: &b.config.AccessConfig,
+			Members:      b.config.ImageMembers,
+		},
+		&StepUpdateImageMinDisk{
+			AccessConfig: &b.config.AccessConfig,
+			MinDisk:      b.config.ImageMinDisk,
+		},
 	}
 
 	// Run!
@@ -9,25 +9,28 @@ import (
 	"github.com/hashicorp/packer/packer"
 )
 
-type stepAddImageMembers struct{}
+// StepAddImageMembers shares the image with the projects of Members.
+type StepAddImageMembers struct {
+	AccessConfig *AccessConfig
+	Members      []string
+}
 
-func (s *stepAddImageMembers) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepAddImageMembers) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	imageId := state.Get("image").(string)
 	ui := state.Get("ui").(packer.Ui)
-	config := state.Get("config").(*Config)
 
-	if len(config.ImageMembers) == 0 {
+	if len(s.Members) == 0 {
 		return multistep.ActionContinue
 	}
 
-	imageClient, err := config.imageV2Client()
+	imageClient, err := s.AccessConfig.ImageV2Client()
 	if err != nil {
 		err = fmt.Errorf("Error initializing image service client: %s", err)
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
 
-	for _, member := range config.ImageMembers {
+	for _, member := range s.Members {
 		ui.Say(fmt.Sprintf("Adding member '%s' to image %s", member, imageId))
 		r := members.Create(imageClient, imageId, member)
 		if _, err = r.Extract(); err != nil {
@@ -40,6 +43,6 @@ func (s *stepAddImageMembers) Run(ctx context.Context, state multistep.StateBag)
 	return multistep.ActionContinue
 }
 
-func (s *stepAddImageMembers) Cleanup(multistep.StateBag) {
+func (s *StepAddImageMembers) Cleanup(multistep.StateBag) {
 	// No cleanup...
 }
@@ -33,7 +33,7 @@ func (s *stepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 	}
 
 	// We need the v2 image client
-	imageClient, err := config.imageV2Client()
+	imageClient, err := config.ImageV2Client()
 	if err != nil {
 		err = fmt.Errorf("Error initializing image service client: %s", err)
 		state.Put("error", err)
@@ -40,7 +40,7 @@ func (s *StepCreateVolume) Run(ctx context.Context, state multistep.StateBag) mu
 
 	// Get needed volume size from the source image.
 	if volumeSize == 0 {
-		imageClient, err := config.imageV2Client()
+		imageClient, err := config.ImageV2Client()
 		if err != nil {
 			err = fmt.Errorf("Error initializing image client: %s", err)
 			state.Put("error", err)
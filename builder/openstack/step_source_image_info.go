@@ -39,7 +39,7 @@ func (s *StepSourceImageInfo) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionContinue
 	}
 
-	client, err := config.imageV2Client()
+	client, err := config.ImageV2Client()
 	if err != nil {
 		err := fmt.Errorf("error creating image client: %s", err)
 		state.Put("error", err)
@@ -9,31 +9,35 @@ import (
 	"github.com/hashicorp/packer/packer"
 )
 
-type stepUpdateImageMinDisk struct{}
+// StepUpdateImageMinDisk updates the minimum disk size needed to boot the
+// image to MinDisk gigabytes.
+type StepUpdateImageMinDisk struct {
+	AccessConfig *AccessConfig
+	MinDisk      int
+}
 
-func (s *stepUpdateImageMinDisk) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepUpdateImageMinDisk) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
 	imageId := state.Get("image").(string)
 	ui := state.Get("ui").(packer.Ui)
-	config := state.Get("config").(*Config)
 
-	if config.ImageMinDisk == 0 {
+	if s.MinDisk == 0 {
 		return multistep.ActionContinue
 	}
-	imageClient, err := config.imageV2Client()
+	imageClient, err := s.AccessConfig.ImageV2Client()
 	if err != nil {
 		err := fmt.Errorf("Error initializing image service client: %s", err)
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
 
-	ui.Say(fmt.Sprintf("Updating image min disk to %d", config.ImageMinDisk))
+	ui.Say(fmt.Sprintf("Updating image min disk to %d", s.MinDisk))
 
 	r := images.Update(
 		imageClient,
 		imageId,
 		images.UpdateOpts{
 			images.ReplaceImageMinDisk{
-				NewMinDisk: config.ImageMinDisk,
+				NewMinDisk: s.MinDisk,
 			},
 		},
 	)
@@ -47,6 +51,6 @@ func (s *stepUpdateImageMinDisk) Run(_ context.Context, state multistep.StateBag
 	return multistep.ActionContinue
 }
 
-func (s *stepUpdateImageMinDisk) Cleanup(multistep.StateBag) {
+func (s *StepUpdateImageMinDisk) Cleanup(multistep.StateBag) {
 	// No cleanup...
 }
@@ -10,30 +10,33 @@ import (
 	"github.com/hashicorp/packer/packer"
 )
 
-type stepUpdateImageTags struct{}
+// StepUpdateImageTags replaces the tags of the image with Tags.
+type StepUpdateImageTags struct {
+	AccessConfig *AccessConfig
+	Tags         []string
+}
 
-func (s *stepUpdateImageTags) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepUpdateImageTags) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	imageId := state.Get("image").(string)
 	ui := state.Get("ui").(packer.Ui)
-	config := state.Get("config").(*Config)
 
-	if len(config.ImageTags) == 0 {
+	if len(s.Tags) == 0 {
 		return multistep.ActionContinue
 	}
-	imageClient, err := config.imageV2Client()
+	imageClient, err := s.AccessConfig.ImageV2Client()
 	if err != nil {
 		err = fmt.Errorf("Error initializing image service client: %s", err)
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
 
-	ui.Say(fmt.Sprintf("Updating image tags to %s", strings.Join(config.ImageTags, ", ")))
+	ui.Say(fmt.Sprintf("Updating image tags to %s", strings.Join(s.Tags, ", ")))
 	r := imageservice.Update(
 		imageClient,
 		imageId,
 		imageservice.UpdateOpts{
 			imageservice.ReplaceImageTags{
-				NewTags: config.ImageTags,
+				NewTags: s.Tags,
 			},
 		},
 	)
@@ -47,6 +50,6 @@ func (s *stepUpdateImageTags) Run(ctx context.Context, state multistep.StateBag)
 	return multistep.ActionContinue
 }
 
-func (s *stepUpdateImageTags) Cleanup(multistep.StateBag) {
+func (s *StepUpdateImageTags) Cleanup(multistep.StateBag) {
 	// No cleanup...
 }
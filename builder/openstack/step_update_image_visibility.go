@@ -9,30 +9,34 @@ import (
 	"github.com/hashicorp/packer/packer"
 )
 
-type stepUpdateImageVisibility struct{}
+// StepUpdateImageVisibility updates the visibility of the image to
+// Visibility.
+type StepUpdateImageVisibility struct {
+	AccessConfig *AccessConfig
+	Visibility   imageservice.ImageVisibility
+}
 
-func (s *stepUpdateImageVisibility) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepUpdateImageVisibility) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	imageId := state.Get("image").(string)
 	ui := state.Get("ui").(packer.Ui)
-	config := state.Get("config").(*Config)
 
-	if config.ImageVisibility == "" {
+	if s.Visibility == "" {
 		return multistep.ActionContinue
 	}
-	imageClient, err := config.imageV2Client()
+	imageClient, err := s.AccessConfig.ImageV2Client()
 	if err != nil {
 		err = fmt.Errorf("Error initializing image service client: %s", err)
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
 
-	ui.Say(fmt.Sprintf("Updating image visibility to %s", config.ImageVisibility))
+	ui.Say(fmt.Sprintf("Updating image visibility to %s", s.Visibility))
 	r := imageservice.Update(
 		imageClient,
 		imageId,
 		imageservice.UpdateOpts{
 			imageservice.UpdateVisibility{
-				Visibility: config.ImageVisibility,
+				Visibility: s.Visibility,
 			},
 		},
 	)
@@ -46,6 +50,6 @@ func (s *stepUpdateImageVisibility) Run(ctx context.Context, state multistep.Sta
 	return multistep.ActionContinue
 }
 
-func (s *stepUpdateImageVisibility) Cleanup(multistep.StateBag) {
+func (s *StepUpdateImageVisibility) Cleanup(multistep.StateBag) {
 	// No cleanup...
 }
@@ -91,6 +91,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 				"prlctl",
 				"prlctl_post",
 				"parallels_tools_guest_path",
+				"http_content",
 			},
 		},
 	}, raws...)
@@ -194,6 +195,8 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			ResultKey:    "iso_path",
 			TargetPath:   b.config.TargetPath,
 			Url:          b.config.ISOUrls,
+			Connections:  b.config.DownloadConnections,
+			RateLimit:    b.config.DownloadRateLimit,
 		},
 		&parallelscommon.StepOutputDir{
 			Force: b.config.PackerForce,
@@ -205,9 +208,12 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:       b.config.HTTPDir,
+			HTTPContent:   b.config.HTTPContent,
+			HTTPPortMin:   b.config.HTTPPortMin,
+			HTTPPortMax:   b.config.HTTPPortMax,
+			HTTPTemplates: b.config.HTTPTemplates,
+			Ctx:           b.config.ctx,
 		},
 		new(stepCreateVM),
 		new(stepCreateDisk),
@@ -230,6 +236,9 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Ctx:            b.config.ctx,
 			GroupInterval:  b.config.BootConfig.BootGroupInterval,
 		},
+		&common.StepWaitForHTTPCallback{
+			Timeout: b.config.WaitForHTTPCallback,
+		},
 		&communicator.StepConnect{
 			Config:    &b.config.SSHConfig.Comm,
 			Host:      parallelscommon.CommHost(b.config.SSHConfig.Comm.SSHHost),
@@ -0,0 +1,144 @@
+package podman
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/packer/builder/docker"
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/communicator"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// The unique ID for this builder
+const BuilderId = "packer.podman"
+
+// Builder builds images with podman or buildah, reusing the steps of the
+// Docker builder with a driver running these commands.
+type Builder struct {
+	config *Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	c, warnings, errs := NewConfig(raws...)
+	if errs != nil {
+		return warnings, errs
+	}
+	b.config = c
+
+	return warnings, nil
+}
+
+func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
+	var driver docker.Driver
+	if b.config.UseBuildah {
+		driver = &BuildahDriver{
+			Ui:        ui,
+			Format:    b.config.Format,
+			IDMapArgs: b.config.idMapArgs(),
+		}
+	} else {
+		driver = &PodmanDriver{
+			DockerDriver: docker.DockerDriver{
+				Ui:         ui,
+				Ctx:        &b.config.ctx,
+				Executable: "podman",
+			},
+			Format:    b.config.Format,
+			IDMapArgs: b.config.idMapArgs(),
+		}
+	}
+	if err := driver.Verify(); err != nil {
+		return nil, err
+	}
+
+	version, err := driver.Version()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[DEBUG] Engine version: %s", version.String())
+
+	steps := []multistep.Step{
+		&docker.StepTempDir{},
+		&docker.StepPull{},
+		&docker.StepRun{},
+		&communicator.StepConnect{
+			Config:    &b.config.Comm,
+			Host:      commHost(b.config.Comm.SSHHost),
+			SSHConfig: b.config.Comm.SSHConfigFunc(),
+			CustomConnect: map[string]multistep.Step{
+				"docker": &docker.StepConnectDocker{},
+			},
+		},
+		&common.StepProvision{},
+		&common.StepCleanupTempKeys{
+			Comm: &b.config.Comm,
+		},
+	}
+
+	if b.config.Discard {
+		log.Print("[DEBUG] Container will be discarded")
+	} else if b.config.Commit {
+		log.Print("[DEBUG] Container will be committed")
+		steps = append(steps,
+			new(docker.StepCommit),
+			&StepTag{Tags: b.config.Tags},
+		)
+	} else {
+		log.Printf("[DEBUG] Container will be exported to %s as %s",
+			b.config.ExportPath, b.config.ExportFormat)
+		if b.config.ExportFormat == docker.ExportFormatRootfs {
+			steps = append(steps, new(docker.StepExport))
+		} else {
+			steps = append(steps, new(docker.StepCommit), new(docker.StepExportImage))
+		}
+	}
+
+	// Setup the state bag and initial state for the steps. The steps of
+	// the Docker builder read the Docker part of the configuration.
+	state := new(multistep.BasicStateBag)
+	state.Put("config", &b.config.Config)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+	state.Put("driver", driver)
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner.Run(ctx, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If it was cancelled, then just return
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, nil
+	}
+
+	if b.config.Discard {
+		return nil, nil
+	}
+	if b.config.Commit {
+		return &docker.ImportArtifact{
+			IdValue:        state.Get("image_id").(string),
+			BuilderIdValue: BuilderId,
+			Driver:         driver,
+		}, nil
+	}
+	return docker.NewExportArtifact(BuilderId, b.config.ExportPath, state), nil
+}
+
+func commHost(host string) func(multistep.StateBag) (string, error) {
+	return func(state multistep.StateBag) (string, error) {
+		if host != "" {
+			log.Printf("Using ssh_host value: %s", host)
+			return host, nil
+		}
+		containerId := state.Get("container_id").(string)
+		driver := state.Get("driver").(docker.Driver)
+		return driver.IPAddress(containerId)
+	}
+}
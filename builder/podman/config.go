@@ -0,0 +1,175 @@
+//go:generate struct-markdown
+
+package podman
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer/builder/docker"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	FormatOCI    = "oci"
+	FormatDocker = "docker"
+)
+
+var idMapRe = regexp.MustCompile(`^[0-9]+:[0-9]+:[0-9]+$`)
+
+// Config is the configuration of the Docker builder, with the options of
+// podman and buildah. The options of the Docker builder work the same, but
+// files are always transferred with `file_transfer` set to `archive`, since
+// the engine is not a daemon.
+type Config struct {
+	docker.Config `mapstructure:",squash"`
+
+	// If true, the container is created and committed with `buildah`
+	// instead of `podman`. `buildah` does not run a process in the
+	// container, so `run_command` is not used, and the flat `rootfs` export
+	// is not supported. Defaults to false.
+	UseBuildah bool `mapstructure:"use_buildah" required:"false"`
+	// The format of the committed image: `oci`, the default, or `docker`.
+	// Some registries and tools only accept the `docker` format, and the
+	// `docker` format is needed for metadata that OCI images do not have,
+	// like `ONBUILD` or `HEALTHCHECK`.
+	Format string `mapstructure:"format" required:"false"`
+	// The user namespace of the container, passed as `--userns`. For
+	// rootless builds, `keep-id` maps the user running Packer to the same
+	// UID in the container, and `auto` picks an unused range of the
+	// subordinate IDs of the user. Only `host` and `container` are
+	// supported with `use_buildah`. Can not be combined with `uidmap` or
+	// `gidmap`.
+	UserNS string `mapstructure:"userns" required:"false"`
+	// Custom UID mappings of the user namespace of the container, in the
+	// `container_uid:host_uid:amount` format. Example: `["0:1:65536"]` runs
+	// the container with the subordinate UIDs of a rootless user.
+	UIDMap []string `mapstructure:"uidmap" required:"false"`
+	// Custom GID mappings of the user namespace of the container, in the
+	// `container_gid:host_gid:amount` format.
+	GIDMap []string `mapstructure:"gidmap" required:"false"`
+	// Repository names, with an optional tag, given to the committed
+	// image. Example: `["localhost/app:latest", "quay.io/org/app:1.0"]`.
+	Tags []string `mapstructure:"tags" required:"false"`
+
+	ctx interpolate.Context
+}
+
+func NewConfig(raws ...interface{}) (*Config, []string, error) {
+	c := new(Config)
+
+	c.FixUploadOwner = true
+
+	var md mapstructure.Metadata
+	err := config.Decode(c, &config.DecodeOpts{
+		Metadata:           &md,
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"run_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs *packer.MultiError
+
+	// Files can not be shared through a mount with the docker communicator
+	// of podman, which would run docker commands.
+	switch c.FileTransfer {
+	case "":
+		c.FileTransfer = docker.FileTransferArchive
+	case docker.FileTransferArchive:
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"file_transfer %s is not supported by podman, only archive is", c.FileTransfer))
+	}
+	if c.WindowsContainer {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("Windows containers are not supported by podman"))
+	}
+	if c.EcrLogin {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("ecr_login is not supported by podman"))
+	}
+
+	if err := c.Config.Prepare(&c.ctx, md.Keys); err != nil {
+		if merr, ok := err.(*packer.MultiError); ok {
+			errs = packer.MultiErrorAppend(errs, merr.Errors...)
+		} else {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	switch c.Format {
+	case "":
+		c.Format = FormatOCI
+	case FormatOCI, FormatDocker:
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"Invalid format %q, expected oci or docker", c.Format))
+	}
+
+	if c.UserNS != "" {
+		if len(c.UIDMap) > 0 || len(c.GIDMap) > 0 {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("userns can not be combined with uidmap or gidmap"))
+		}
+		mode := strings.SplitN(c.UserNS, ":", 2)[0]
+		valid := []string{"auto", "container", "host", "keep-id", "nomap", "ns", "private"}
+		if c.UseBuildah {
+			valid = []string{"container", "host"}
+		}
+		found := false
+		for _, v := range valid {
+			found = found || mode == v
+		}
+		if !found {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"Invalid userns %q, expected one of %s", c.UserNS, strings.Join(valid, ", ")))
+		}
+	}
+	for _, m := range append(append([]string{}, c.UIDMap...), c.GIDMap...) {
+		if !idMapRe.MatchString(m) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"Invalid ID mapping %q, expected container_id:host_id:amount", m))
+		}
+	}
+
+	if len(c.Tags) > 0 && !c.Commit {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("tags can only be set when commit is true"))
+	}
+	if c.UseBuildah && c.ExportPath != "" && c.ExportFormat == docker.ExportFormatRootfs {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"use_buildah requires an export_format of docker-archive or oci"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, nil, errs
+	}
+
+	return c, nil, nil
+}
+
+// idMapArgs returns the flags setting the user namespace of a container.
+func (c *Config) idMapArgs() []string {
+	var args []string
+	uidFlag, gidFlag := "--uidmap", "--gidmap"
+	if c.UseBuildah {
+		uidFlag, gidFlag = "--userns-uid-map", "--userns-gid-map"
+	}
+	if c.UserNS != "" {
+		args = append(args, "--userns", c.UserNS)
+	}
+	for _, m := range c.UIDMap {
+		args = append(args, uidFlag, m)
+	}
+	for _, m := range c.GIDMap {
+		args = append(args, gidFlag, m)
+	}
+	return args
+}
@@ -0,0 +1,91 @@
+package podman
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/builder/docker"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"image":  "bar",
+		"commit": true,
+	}
+}
+
+func TestConfigPrepare(t *testing.T) {
+	c, warns, errs := NewConfig(testConfig())
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if errs != nil {
+		t.Fatalf("bad: %s", errs)
+	}
+
+	if c.Format != FormatOCI {
+		t.Fatalf("bad: %s", c.Format)
+	}
+	if c.FileTransfer != docker.FileTransferArchive {
+		t.Fatalf("bad: %s", c.FileTransfer)
+	}
+	// the options of the Docker builder are prepared too
+	if !c.Pull || c.Comm.Type != "docker" || len(c.RunCommand) == 0 {
+		t.Fatalf("bad: %#v", c.Config)
+	}
+}
+
+func TestConfigPrepare_invalid(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"format": "v2s1"},
+		{"file_transfer": "mount"},
+		{"windows_container": true},
+		{"userns": "keep-id", "uidmap": []string{"0:1:65536"}},
+		{"userns": "everyone"},
+		{"uidmap": []string{"0:1"}},
+		{"use_buildah": true, "userns": "keep-id"},
+		{"tags": []string{"app:latest"}, "commit": false, "discard": true},
+		{"use_buildah": true, "commit": false, "export_path": "image.tar"},
+		// an error of the Docker configuration
+		{"commit": false},
+	}
+	for _, tc := range tests {
+		raw := testConfig()
+		for k, v := range tc {
+			raw[k] = v
+		}
+		if _, _, errs := NewConfig(raw); errs == nil {
+			t.Fatalf("%#v: should error", tc)
+		}
+	}
+}
+
+func TestConfig_idMapArgs(t *testing.T) {
+	raw := testConfig()
+	raw["uidmap"] = []string{"0:1:65536"}
+	raw["gidmap"] = []string{"0:1:65536"}
+	c, _, errs := NewConfig(raw)
+	if errs != nil {
+		t.Fatalf("bad: %s", errs)
+	}
+	expected := []string{"--uidmap", "0:1:65536", "--gidmap", "0:1:65536"}
+	if args := c.idMapArgs(); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	c.UseBuildah = true
+	expected = []string{"--userns-uid-map", "0:1:65536", "--userns-gid-map", "0:1:65536"}
+	if args := c.idMapArgs(); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	raw = testConfig()
+	raw["userns"] = "keep-id"
+	c, _, errs = NewConfig(raw)
+	if errs != nil {
+		t.Fatalf("bad: %s", errs)
+	}
+	if args := c.idMapArgs(); !reflect.DeepEqual(args, []string{"--userns", "keep-id"}) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
@@ -0,0 +1,413 @@
+package podman
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer/builder/docker"
+	"github.com/hashicorp/packer/packer"
+)
+
+// BuildahDriver is a docker.Driver running `buildah`. Buildah containers
+// are working containers that do not run any process: commands run with
+// `buildah run`.
+type BuildahDriver struct {
+	Ui packer.Ui
+
+	// The format of the committed images, oci or docker
+	Format string
+
+	// The flags setting the user namespace of the containers
+	IDMapArgs []string
+
+	l sync.Mutex
+}
+
+// buildah runs buildah with args, and returns its standard output.
+func (d *BuildahDriver) buildah(stdin io.Reader, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("buildah", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Printf("Executing: buildah %v", args)
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Error running buildah %s: %s\nStderr: %s",
+			args[0], err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+func (d *BuildahDriver) Commit(id string, author string, changes []string, message string) (string, error) {
+	args, err := buildahConfigArgs(changes)
+	if err != nil {
+		return "", err
+	}
+	if author != "" {
+		args = append(args, "--author", author)
+	}
+	if message != "" {
+		args = append(args, "--comment", message)
+	}
+	if len(args) > 0 {
+		args = append(append([]string{"config"}, args...), id)
+		if _, err := d.buildah(nil, args...); err != nil {
+			return "", err
+		}
+	}
+
+	out, err := d.buildah(nil, "commit", "--format", d.Format, id)
+	if err != nil {
+		return "", err
+	}
+	// buildah prints the progress of the commit before the image ID
+	return lastLine(out), nil
+}
+
+func (d *BuildahDriver) DeleteImage(id string) error {
+	log.Printf("Deleting image: %s", id)
+	_, err := d.buildah(nil, "rmi", id)
+	return err
+}
+
+func (d *BuildahDriver) DownloadArchive(id string, src string, dst io.Writer) error {
+	// buildah can only mount the filesystem of containers in the user
+	// namespace of buildah, so the archive is made in the container.
+	var stderr bytes.Buffer
+	cmd := exec.Command("buildah", "run", id, "--",
+		"tar", "-cf", "-", "-C", path.Dir(src), path.Base(src))
+	cmd.Stdout = dst
+	cmd.Stderr = &stderr
+
+	log.Printf("Downloading %s from container %s", src, id)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error downloading '%s': %s\nStderr: %s",
+			src, err, stderr.String())
+	}
+	return nil
+}
+
+func (d *BuildahDriver) ExecContainer(id string, config *docker.ExecConfig) (int, error) {
+	args := []string{"run"}
+	if config.Tty {
+		args = append(args, "--terminal")
+	}
+	if config.User != "" {
+		args = append(args, "--user", config.User)
+	}
+	args = append(args, id, "--")
+	args = append(args, config.Cmd...)
+
+	cmd := exec.Command("buildah", args...)
+	cmd.Stdin = config.Stdin
+	cmd.Stdout = config.Stdout
+	cmd.Stderr = config.Stderr
+
+	log.Printf("Executing: buildah %s", strings.Join(args, " "))
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), nil
+		}
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+func (d *BuildahDriver) Export(id string, dst io.Writer) error {
+	return fmt.Errorf("Exporting the filesystem of containers is not supported by buildah")
+}
+
+func (d *BuildahDriver) Import(path string, changes []string, repo string) (string, error) {
+	return "", fmt.Errorf("Importing filesystems is not supported by buildah")
+}
+
+func (d *BuildahDriver) IPAddress(id string) (string, error) {
+	return "", fmt.Errorf("buildah containers do not have an IP address")
+}
+
+func (d *BuildahDriver) Login(repo, user, pass string) error {
+	d.l.Lock()
+
+	args := []string{"login"}
+	if user != "" {
+		args = append(args, "--username", user)
+	}
+	var stdin io.Reader
+	if pass != "" {
+		args = append(args, "--password-stdin")
+		stdin = strings.NewReader(pass)
+	}
+	if repo != "" {
+		args = append(args, repo)
+	}
+
+	if _, err := d.buildah(stdin, args...); err != nil {
+		d.l.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (d *BuildahDriver) Logout(repo string) error {
+	args := []string{"logout"}
+	if repo != "" {
+		args = append(args, repo)
+	}
+
+	_, err := d.buildah(nil, args...)
+	d.l.Unlock()
+	return err
+}
+
+func (d *BuildahDriver) Pull(image string, platform string) error {
+	args := []string{"pull"}
+	if platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	args = append(args, image)
+
+	d.Ui.Message(fmt.Sprintf("Pulling %s", image))
+	_, err := d.buildah(nil, args...)
+	return err
+}
+
+func (d *BuildahDriver) Push(name string) error {
+	_, err := d.buildah(nil, "push", name)
+	return err
+}
+
+func (d *BuildahDriver) ContainerUser(id string) (string, error) {
+	out, err := d.buildah(nil, "inspect", "--type", "container", "--format", "{{.OCIv1.Config.User}}", id)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (d *BuildahDriver) ImageLayers(id string) ([]string, error) {
+	out, err := d.buildah(nil, "inspect", "--type", "image", "--format", "{{json .OCIv1.RootFS.DiffIDs}}", id)
+	if err != nil {
+		return nil, err
+	}
+
+	var layers []string
+	if err := json.Unmarshal([]byte(out), &layers); err != nil {
+		return nil, fmt.Errorf("Error reading the layers of the image: %s", err)
+	}
+	return layers, nil
+}
+
+func (d *BuildahDriver) SaveImage(id string, dst io.Writer) error {
+	// buildah writes archives to files only
+	f, err := ioutil.TempFile("", "packer-buildah")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	log.Printf("Exporting image: %s", id)
+	if _, err := d.buildah(nil, "push", id, "docker-archive:"+f.Name()); err != nil {
+		return err
+	}
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+func (d *BuildahDriver) StartContainer(config *docker.ContainerConfig) (string, error) {
+	args := []string{"from"}
+	if config.Platform != "" {
+		args = append(args, "--platform", config.Platform)
+	}
+	args = append(args, d.IDMapArgs...)
+	for host, guest := range config.Volumes {
+		args = append(args, "--volume", fmt.Sprintf("%s:%s", host, guest))
+	}
+	if config.Privileged {
+		log.Printf("[WARN] buildah containers can not be privileged, ignoring privileged")
+	}
+	args = append(args, config.Image)
+
+	d.Ui.Message(fmt.Sprintf("Run command: buildah %s", strings.Join(args, " ")))
+	out, err := d.buildah(nil, args...)
+	if err != nil {
+		return "", err
+	}
+	// buildah prints the progress of the pull before the container name
+	return lastLine(out), nil
+}
+
+func (d *BuildahDriver) KillContainer(id string) error {
+	_, err := d.buildah(nil, "rm", id)
+	return err
+}
+
+func (d *BuildahDriver) StopContainer(id string) error {
+	// working containers do not run
+	return nil
+}
+
+func (d *BuildahDriver) TagImage(id string, repo string, force bool) error {
+	_, err := d.buildah(nil, "tag", id, repo)
+	return err
+}
+
+func (d *BuildahDriver) UploadArchive(id string, dst string, src io.Reader, fixOwner bool) error {
+	// buildah add extracts the local archives it copies
+	f, err := ioutil.TempFile("", "packer-buildah-*.tar")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, src)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"add"}
+	if fixOwner {
+		user, err := d.buildah(nil, "inspect", "--type", "container",
+			"--format", "{{.OCIv1.Config.User}}", id)
+		if err != nil {
+			return err
+		}
+		if user = strings.TrimSpace(user); user != "" {
+			args = append(args, "--chown", user)
+		}
+	}
+	args = append(args, id, f.Name(), dst)
+
+	log.Printf("Uploading archive to %s in container %s", dst, id)
+	_, err = d.buildah(nil, args...)
+	return err
+}
+
+func (d *BuildahDriver) Verify() error {
+	if _, err := exec.LookPath("buildah"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *BuildahDriver) Version() (*version.Version, error) {
+	output, err := exec.Command("buildah", "--version").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	match := regexp.MustCompile(version.VersionRegexpRaw).FindSubmatch(output)
+	if match == nil {
+		return nil, fmt.Errorf("unknown version: %s", output)
+	}
+
+	return version.NewVersion(string(match[0]))
+}
+
+// buildahConfigArgs translates the Dockerfile instructions of changes into
+// the flags of `buildah config`, which has no equivalent of the --change
+// flag of `docker commit`.
+func buildahConfigArgs(changes []string) ([]string, error) {
+	var args []string
+	for _, change := range changes {
+		parts := strings.SplitN(strings.TrimSpace(change), " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid change %q", change)
+		}
+		value := strings.TrimSpace(parts[1])
+
+		switch instruction := strings.ToUpper(parts[0]); instruction {
+		case "LABEL", "ENV":
+			flag := "--" + strings.ToLower(instruction)
+			words := splitWords(value)
+			if len(words) > 0 && !strings.Contains(words[0], "=") {
+				// the legacy "ENV key value" form
+				kv := strings.SplitN(value, " ", 2)
+				if len(kv) == 1 {
+					kv = append(kv, "")
+				}
+				args = append(args, flag, kv[0]+"="+strings.TrimSpace(kv[1]))
+				continue
+			}
+			for _, w := range words {
+				args = append(args, flag, w)
+			}
+		case "EXPOSE":
+			for _, p := range strings.Fields(value) {
+				args = append(args, "--port", p)
+			}
+		case "VOLUME":
+			for _, v := range splitWords(strings.Trim(value, "[]")) {
+				args = append(args, "--volume", strings.Trim(v, ","))
+			}
+		case "CMD", "ENTRYPOINT", "USER", "ONBUILD", "SHELL", "STOPSIGNAL":
+			args = append(args, "--"+strings.ToLower(instruction), value)
+		case "WORKDIR":
+			args = append(args, "--workingdir", value)
+		case "MAINTAINER":
+			args = append(args, "--author", value)
+		case "HEALTHCHECK":
+			args = append(args, "--healthcheck", value)
+		default:
+			return nil, fmt.Errorf("Change %q is not supported by buildah", change)
+		}
+	}
+	return args, nil
+}
+
+// splitWords splits s on the whitespaces that are not quoted, and removes
+// the double quotes, which can be escaped with a backslash.
+func splitWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+		case c == '"':
+			quoted = !quoted
+			inWord = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,92 @@
+package podman
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/packer/builder/docker"
+)
+
+// PodmanDriver is a docker.Driver running `podman`, whose command line is
+// compatible with the one of docker. Only the commands using the options
+// specific to podman are overridden.
+type PodmanDriver struct {
+	docker.DockerDriver
+
+	// The format of the committed images, oci or docker
+	Format string
+
+	// The flags setting the user namespace of the containers
+	IDMapArgs []string
+}
+
+func (d *PodmanDriver) Commit(id string, author string, changes []string, message string) (string, error) {
+	args := []string{"commit", "--format", d.Format}
+	if author != "" {
+		args = append(args, "--author", author)
+	}
+	for _, change := range changes {
+		args = append(args, "--change", change)
+	}
+	if message != "" {
+		args = append(args, "--message", message)
+	}
+	args = append(args, id)
+
+	log.Printf("Committing container with args: %v", args)
+	var stdout, stderr bytes.Buffer
+	cmd := d.Command(args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Error committing container: %s\nStderr: %s",
+			err, stderr.String())
+	}
+
+	// podman prints the progress of the commit before the image ID
+	return lastLine(stdout.String()), nil
+}
+
+func (d *PodmanDriver) StartContainer(config *docker.ContainerConfig) (string, error) {
+	// The user namespace flags are given before the flags of run_command,
+	// which end with the image.
+	c := *config
+	c.RunCommand = append(append([]string{}, d.IDMapArgs...), config.RunCommand...)
+	return d.DockerDriver.StartContainer(&c)
+}
+
+func (d *PodmanDriver) UploadArchive(id string, dst string, src io.Reader, fixOwner bool) error {
+	// podman chowns copied files to the user of the container by default
+	args := []string{"cp", fmt.Sprintf("--archive=%t", fixOwner), "-", fmt.Sprintf("%s:%s", id, dst)}
+
+	var stderr bytes.Buffer
+	cmd := d.Command(args...)
+	cmd.Stdin = src
+	cmd.Stderr = &stderr
+
+	log.Printf("Uploading archive to %s in container %s", dst, id)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error uploading to '%s': %s\nStderr: %s",
+			dst, err, stderr.String())
+	}
+
+	return nil
+}
+
+func (d *PodmanDriver) Verify() error {
+	if _, err := exec.LookPath(d.Executable); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// lastLine returns the last non empty line of out.
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,164 @@
+package podman
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/builder/docker"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+func TestPodmanDriver_impl(t *testing.T) {
+	var _ docker.Driver = new(PodmanDriver)
+}
+
+func TestBuildahDriver_impl(t *testing.T) {
+	var _ docker.Driver = new(BuildahDriver)
+}
+
+// testPodman returns the path of a fake podman recording its arguments in
+// the args file of dir, and printing output.
+func testPodman(t *testing.T, dir string, output string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake podman is a shell script")
+	}
+	p := filepath.Join(dir, "podman")
+	script := "#!/bin/sh\nfor a in \"$@\"; do echo \"$a\" >> " + filepath.Join(dir, "args") + "; done\n" +
+		"printf '" + output + "'\n"
+	if err := ioutil.WriteFile(p, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return p
+}
+
+func testPodmanArgs(t *testing.T, dir string) []string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestPodmanDriver_StartContainer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := &PodmanDriver{
+		DockerDriver: docker.DockerDriver{
+			Ui:         &packer.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)},
+			Ctx:        &interpolate.Context{},
+			Executable: testPodman(t, dir, "c0ffee\\n"),
+		},
+		IDMapArgs: []string{"--userns", "keep-id"},
+	}
+	id, err := d.StartContainer(&docker.ContainerConfig{
+		Image:      "fedora",
+		RunCommand: []string{"-d", "-i", "-t", "--", "{{.Image}}"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "c0ffee" {
+		t.Fatalf("bad: %s", id)
+	}
+
+	expected := []string{"run", "--userns", "keep-id", "-d", "-i", "-t", "--", "fedora"}
+	if args := testPodmanArgs(t, dir); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestPodmanDriver_Commit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := &PodmanDriver{
+		DockerDriver: docker.DockerDriver{Executable: testPodman(t, dir, "Writing manifest\\nbeef\\n")},
+		Format:       FormatDocker,
+	}
+	id, err := d.Commit("c0ffee", "", []string{"USER app"}, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "beef" {
+		t.Fatalf("bad: %s", id)
+	}
+
+	expected := []string{"commit", "--format", "docker", "--change", "USER app", "c0ffee"}
+	if args := testPodmanArgs(t, dir); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestBuildahConfigArgs(t *testing.T) {
+	changes := []string{
+		`LABEL "org.opencontainers.image.title"="my app" version=1.0`,
+		`ENV PATH="/app/bin:/usr/bin"`,
+		`ENV HOSTNAME www.example.com`,
+		`ENTRYPOINT ["/app/bin/start"]`,
+		`CMD ["--verbose"]`,
+		`EXPOSE 80 443/tcp`,
+		`USER app`,
+		`WORKDIR /app`,
+		`VOLUME ["/data", "/logs"]`,
+	}
+	expected := []string{
+		"--label", "org.opencontainers.image.title=my app",
+		"--label", "version=1.0",
+		"--env", "PATH=/app/bin:/usr/bin",
+		"--env", "HOSTNAME=www.example.com",
+		"--entrypoint", `["/app/bin/start"]`,
+		"--cmd", `["--verbose"]`,
+		"--port", "80",
+		"--port", "443/tcp",
+		"--user", "app",
+		"--workingdir", "/app",
+		"--volume", "/data",
+		"--volume", "/logs",
+	}
+
+	args, err := buildahConfigArgs(changes)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	if _, err := buildahConfigArgs([]string{"ADD . /app"}); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestPodmanDriver_Verify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := &PodmanDriver{
+		DockerDriver: docker.DockerDriver{Executable: testPodman(t, dir, "")},
+	}
+	if err := d.Verify(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d.Executable = filepath.Join(dir, "missing")
+	if err := d.Verify(); err == nil {
+		t.Fatal("should have error")
+	}
+}
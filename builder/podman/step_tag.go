@@ -0,0 +1,36 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer/builder/docker"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepTag gives the repository names of Tags to the image committed by
+// docker.StepCommit.
+type StepTag struct {
+	Tags []string
+}
+
+func (s *StepTag) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(docker.Driver)
+	imageId := state.Get("image_id").(string)
+	ui := state.Get("ui").(packer.Ui)
+
+	for _, tag := range s.Tags {
+		ui.Say(fmt.Sprintf("Tagging the image as %s", tag))
+		if err := driver.TagImage(imageId, tag, false); err != nil {
+			err := fmt.Errorf("Error tagging the image: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepTag) Cleanup(state multistep.StateBag) {}
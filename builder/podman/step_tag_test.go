@@ -0,0 +1,61 @@
+package podman
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer/builder/docker"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+func testState(t *testing.T) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("driver", &docker.MockDriver{})
+	state.Put("image_id", "c0ffee")
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
+
+func TestStepTag_impl(t *testing.T) {
+	var _ multistep.Step = new(StepTag)
+}
+
+func TestStepTag(t *testing.T) {
+	state := testState(t)
+	step := &StepTag{Tags: []string{"localhost/app:latest"}}
+	defer step.Cleanup(state)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*docker.MockDriver)
+	if !driver.TagImageCalled {
+		t.Fatal("should have tagged")
+	}
+	if driver.TagImageImageId != "c0ffee" || driver.TagImageRepo != "localhost/app:latest" {
+		t.Fatalf("bad: %s %s", driver.TagImageImageId, driver.TagImageRepo)
+	}
+}
+
+func TestStepTag_error(t *testing.T) {
+	state := testState(t)
+	step := &StepTag{Tags: []string{"localhost/app:latest"}}
+	defer step.Cleanup(state)
+
+	driver := state.Get("driver").(*docker.MockDriver)
+	driver.TagImageErr = errors.New("foo")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -69,9 +69,13 @@ type Config struct {
 	// the name to give it. If left blank, will default to "packer_" plus your
 	// buildname.
 	BoxName string `mapstructure:"box_name" required:"false"`
-	// The vagrant provider.
-	// This parameter is required when source_path have more than one provider,
-	// or when using vagrant-cloud post-processor. Defaults to unset.
+	// The vagrant provider. When set, it is passed to `vagrant box add` and
+	// `vagrant up`, which is required when source_path has more than one
+	// provider. Defaults to unset, letting Vagrant pick the provider of the
+	// box. The artifact reports the provider found in the metadata of the
+	// packaged box, which is what the vagrant-cloud post-processor
+	// publishes. To build a box for several providers, add one vagrant
+	// builder per provider, each with its own output_dir.
 	Provider string `mapstructure:"provider" required:"false"`
 
 	Communicator string `mapstructure:"communicator"`
@@ -83,15 +87,23 @@ type Config struct {
 	// Whether to halt, suspend, or destroy the box when the build has
 	// completed. Defaults to "halt"
 	TeardownMethod string `mapstructure:"teardown_method" required:"false"`
-	// What box version to use when initializing Vagrant.
+	// What box version to use when initializing Vagrant. The version is
+	// passed to `vagrant box add` and pinned in the generated Vagrantfile, so
+	// the build uses this version even when newer ones are installed. It
+	// can only be set when source_path is the name of a box from Vagrant
+	// Cloud or another box catalog.
 	BoxVersion string `mapstructure:"box_version" required:"false"`
 	// a path to a golang template for a vagrantfile. Our default template can
-	// be found here. So far the only template variables available to you are
-	// {{ .BoxName }} and {{ .SyncedFolder }}, which correspond to the Packer
-	// options box_name and synced_folder.
+	// be found here. The template variables available to you are
+	// {{ .SourceBox }}, {{ .BoxName }}, {{ .BoxVersion }}, {{ .Provider }}
+	// and {{ .SyncedFolder }}, which correspond to the Packer options
+	// source_path, box_name, box_version, provider and synced_folder. The
+	// source machine must be defined as "source".
 	Template string `mapstructure:"template" required:"false"`
-
-	SyncedFolder string `mapstructure:"synced_folder"`
+	// Path to a folder to sync to /vagrant inside the source machine. By
+	// default no folder is synced. A relative path is relative to the
+	// directory Packer is run from.
+	SyncedFolder string `mapstructure:"synced_folder" required:"false"`
 	// Don't call "vagrant add" to add the box to your local environment; this
 	// is necessary if you want to launch a box that is already added to your
 	// vagrant environment.
@@ -122,9 +134,14 @@ type Config struct {
 	AddInsecure bool `mapstructure:"add_insecure" required:"false"`
 	// if true, Packer will not call vagrant package to
 	// package your base box into its own standalone .box file.
-	SkipPackage       bool     `mapstructure:"skip_package" required:"false"`
-	OutputVagrantfile string   `mapstructure:"output_vagrantfile"`
-	PackageInclude    []string `mapstructure:"package_include"`
+	SkipPackage bool `mapstructure:"skip_package" required:"false"`
+	// Path to a Vagrantfile to package in the box, as with the
+	// --vagrantfile option of vagrant package. The settings of this
+	// Vagrantfile apply to the machines created from the box.
+	OutputVagrantfile string `mapstructure:"output_vagrantfile" required:"false"`
+	// Paths to additional files to package in the box, as with the
+	// --include option of vagrant package.
+	PackageInclude []string `mapstructure:"package_include" required:"false"`
 
 	ctx interpolate.Context
 }
@@ -182,6 +199,37 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
+	if b.config.BoxVersion != "" && (b.config.GlobalID != "" || strings.HasSuffix(b.config.SourceBox, ".box")) {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("box_version can only be set when source_path is the name of a box"))
+	}
+
+	// Vagrant runs in the output directory, so the paths are made absolute
+	var paths []*string
+	if b.config.Template != "" {
+		paths = append(paths, &b.config.Template)
+	}
+	if b.config.OutputVagrantfile != "" {
+		paths = append(paths, &b.config.OutputVagrantfile)
+	}
+	for i := range b.config.PackageInclude {
+		paths = append(paths, &b.config.PackageInclude[i])
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(*path); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("%s: %s", *path, err))
+			continue
+		}
+		if abs, err := filepath.Abs(*path); err == nil {
+			*path = abs
+		}
+	}
+	if b.config.SyncedFolder != "" {
+		if abs, err := filepath.Abs(b.config.SyncedFolder); err == nil {
+			b.config.SyncedFolder = abs
+		}
+	}
+
 	if b.config.TeardownMethod == "" {
 		// If we're using a box that's already opened on the system, don't
 		// automatically destroy it. If we open the box ourselves, then go ahead
@@ -243,6 +291,8 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Extension:    "box",
 			ResultKey:    "box_path",
 			Url:          []string{b.config.SourceBox},
+			Connections:  b.config.DownloadConnections,
+			RateLimit:    b.config.DownloadRateLimit,
 		})
 	}
 	steps = append(steps,
@@ -255,6 +305,8 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			SyncedFolder: b.config.SyncedFolder,
 			SourceBox:    b.config.SourceBox,
 			BoxName:      b.config.BoxName,
+			BoxVersion:   b.config.BoxVersion,
+			Provider:     b.config.Provider,
 			OutputDir:    b.config.OutputDir,
 			GlobalID:     b.config.GlobalID,
 		},
@@ -291,6 +343,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Include:     b.config.PackageInclude,
 			Vagrantfile: b.config.OutputVagrantfile,
 			GlobalID:    b.config.GlobalID,
+			OutputDir:   b.config.OutputDir,
 		})
 
 	// Run the steps.
@@ -311,7 +364,14 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		return nil, errors.New("Build was halted.")
 	}
 
-	return NewArtifact(b.config.Provider, b.config.OutputDir), nil
+	// The provider of the packaged box, as Vagrant may have used another
+	// one than the configured provider.
+	provider := b.config.Provider
+	if p, ok := state.GetOk("box_provider"); ok {
+		provider = p.(string)
+	}
+
+	return NewArtifact(provider, b.config.OutputDir), nil
 }
 
 // Cancel.
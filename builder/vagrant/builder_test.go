@@ -1,6 +1,10 @@
 package vagrant
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -89,3 +93,93 @@ func TestBuilder_Prepare_ValidateSource(t *testing.T) {
 		}
 	}
 }
+
+func TestBuilder_Prepare_BoxVersion(t *testing.T) {
+	b := &Builder{}
+	config := map[string]interface{}{
+		"source_path":  "hashicorp/bionic64",
+		"box_version":  "1.0.282",
+		"communicator": "ssh",
+	}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A box file has no version
+	config["source_path"] = "https://example.com/bionic64.box"
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilder_Prepare_Provider(t *testing.T) {
+	b := &Builder{}
+	config := map[string]interface{}{
+		"source_path":  "hashicorp/bionic64",
+		"communicator": "ssh",
+	}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Vagrant picks the provider of the box
+	if b.config.Provider != "" {
+		t.Fatalf("bad: %s", b.config.Provider)
+	}
+
+	config["provider"] = "libvirt"
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b.config.Provider != "libvirt" {
+		t.Fatalf("bad: %s", b.config.Provider)
+	}
+}
+
+func TestBuilder_Prepare_Paths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"Vagrantfile.tpl", "Vagrantfile", "README.md"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte{}, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+
+	b := &Builder{}
+	config := map[string]interface{}{
+		"source_path":        "hashicorp/bionic64",
+		"communicator":       "ssh",
+		"template":           "Vagrantfile.tpl",
+		"output_vagrantfile": "Vagrantfile",
+		"package_include":    []string{"README.md"},
+		"synced_folder":      "src",
+	}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The paths are absolute since vagrant runs in the output directory
+	abs := func(path string) string {
+		abs, _ := filepath.Abs(path)
+		return abs
+	}
+	if b.config.Template != abs("Vagrantfile.tpl") || b.config.OutputVagrantfile != abs("Vagrantfile") {
+		t.Fatalf("bad: %s, %s", b.config.Template, b.config.OutputVagrantfile)
+	}
+	if !reflect.DeepEqual(b.config.PackageInclude, []string{abs("README.md")}) {
+		t.Fatalf("bad: %#v", b.config.PackageInclude)
+	}
+	if b.config.SyncedFolder != abs("src") {
+		t.Fatalf("bad: %s", b.config.SyncedFolder)
+	}
+
+	config["package_include"] = []string{"missing.txt"}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
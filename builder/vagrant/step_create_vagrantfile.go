@@ -19,11 +19,16 @@ type StepCreateVagrantfile struct {
 	GlobalID     string
 	SourceBox    string
 	BoxName      string
+	BoxVersion   string
+	Provider     string
 }
 
 var DEFAULT_TEMPLATE = `Vagrant.configure("2") do |config|
   config.vm.define "source", autostart: false do |source|
 	source.vm.box = "{{.SourceBox}}"
+	{{- if ne .BoxVersion ""}}
+	source.vm.box_version = "{{.BoxVersion}}"
+	{{- end}}
   end
   config.vm.define "output" do |output|
 	output.vm.box = "{{.BoxName}}"
@@ -40,6 +45,8 @@ type VagrantfileOptions struct {
 	SyncedFolder string
 	SourceBox    string
 	BoxName      string
+	BoxVersion   string
+	Provider     string
 }
 
 func (s *StepCreateVagrantfile) createVagrantfile() (string, error) {
@@ -49,6 +56,7 @@ func (s *StepCreateVagrantfile) createVagrantfile() (string, error) {
 		retErr := fmt.Errorf("Error creating vagrantfile %s", err.Error())
 		return "", retErr
 	}
+	defer templateFile.Close()
 
 	var tpl *template.Template
 	if s.Template == "" {
@@ -66,6 +74,8 @@ func (s *StepCreateVagrantfile) createVagrantfile() (string, error) {
 		SyncedFolder: s.SyncedFolder,
 		BoxName:      s.BoxName,
 		SourceBox:    s.SourceBox,
+		BoxVersion:   s.BoxVersion,
+		Provider:     s.Provider,
 	}
 
 	err = tpl.Execute(templateFile, opts)
@@ -77,3 +77,67 @@ end`
 		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, actual)
 	}
 }
+
+func TestCreateFile_boxVersion(t *testing.T) {
+	testy := StepCreateVagrantfile{
+		OutputDir:  "./",
+		SourceBox:  "hashicorp/bionic64",
+		BoxName:    "bananas",
+		BoxVersion: "1.0.282",
+	}
+	templatePath, err := testy.createVagrantfile()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.Remove(templatePath)
+	contents, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	actual := string(contents)
+	expected := `Vagrant.configure("2") do |config|
+  config.vm.define "source", autostart: false do |source|
+	source.vm.box = "hashicorp/bionic64"
+	source.vm.box_version = "1.0.282"
+  end
+  config.vm.define "output" do |output|
+	output.vm.box = "bananas"
+	output.vm.box_url = "file://package.box"
+  end
+  config.vm.synced_folder ".", "/vagrant", disabled: true
+end`
+	if ok := strings.Compare(actual, expected); ok != 0 {
+		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, actual)
+	}
+}
+
+func TestCreateFile_customTemplate(t *testing.T) {
+	tpl, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.Remove(tpl.Name())
+	tpl.WriteString(`{{.SourceBox}} {{.BoxVersion}} {{.Provider}} {{.SyncedFolder}}`)
+	tpl.Close()
+
+	testy := StepCreateVagrantfile{
+		Template:     tpl.Name(),
+		OutputDir:    "./",
+		SourceBox:    "hashicorp/bionic64",
+		BoxVersion:   "1.0.282",
+		Provider:     "libvirt",
+		SyncedFolder: "/src",
+	}
+	templatePath, err := testy.createVagrantfile()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.Remove(templatePath)
+	contents, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if actual := string(contents); actual != "hashicorp/bionic64 1.0.282 libvirt /src" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
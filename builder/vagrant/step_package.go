@@ -1,7 +1,15 @@
 package vagrant
 
 import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/hashicorp/packer/helper/multistep"
@@ -13,6 +21,7 @@ type StepPackage struct {
 	Include     []string
 	Vagrantfile string
 	GlobalID    string
+	OutputDir   string
 }
 
 func (s *StepPackage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -45,8 +54,69 @@ func (s *StepPackage) Run(ctx context.Context, state multistep.StateBag) multist
 		return multistep.ActionHalt
 	}
 
+	// The provider of the box is the one Vagrant used, which may not be
+	// the one set in the configuration.
+	provider, err := boxProvider(filepath.Join(s.OutputDir, "package.box"))
+	if err != nil {
+		err = fmt.Errorf("Error reading the provider of the packaged box: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("box_provider", provider)
+
 	return multistep.ActionContinue
 }
 
+// boxProvider returns the provider in the metadata.json file of a box, which
+// is a tar archive, compressed with gzip or not.
+func boxProvider(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	magic, err := br.Peek(2)
+	if err != nil {
+		return "", err
+	}
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return "", err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("metadata.json not found in %s", path)
+		}
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimPrefix(hdr.Name, "./") != "metadata.json" {
+			continue
+		}
+
+		var metadata struct {
+			Provider string `json:"provider"`
+		}
+		if err := json.NewDecoder(tr).Decode(&metadata); err != nil {
+			return "", fmt.Errorf("Error parsing metadata.json: %s", err)
+		}
+		if metadata.Provider == "" {
+			return "", fmt.Errorf("metadata.json of %s has no provider", path)
+		}
+		return metadata.Provider, nil
+	}
+}
+
 func (s *StepPackage) Cleanup(state multistep.StateBag) {
 }
@@ -0,0 +1,98 @@
+package vagrant
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeBox(t *testing.T, path string, compress bool, files map[string]string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if compress {
+		gw := gzip.NewWriter(f)
+		defer gw.Close()
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for name, contents := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func TestBoxProvider(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	tests := []struct {
+		Compress bool
+		Files    map[string]string
+		Expected string
+		Err      bool
+	}{
+		{
+			Compress: true,
+			Files: map[string]string{
+				"box.ovf":       "",
+				"metadata.json": `{"provider": "virtualbox"}`,
+			},
+			Expected: "virtualbox",
+		},
+		{
+			Files: map[string]string{
+				"./metadata.json": `{"provider": "libvirt"}`,
+			},
+			Expected: "libvirt",
+		},
+		{
+			Compress: true,
+			Files: map[string]string{
+				"box.ovf": "",
+			},
+			Err: true,
+		},
+		{
+			Files: map[string]string{
+				"metadata.json": `{}`,
+			},
+			Err: true,
+		},
+	}
+	for i, test := range tests {
+		path := filepath.Join(td, "package.box")
+		writeBox(t, path, test.Compress, test.Files)
+
+		provider, err := boxProvider(path)
+		if test.Err {
+			if err == nil {
+				t.Fatalf("%d: should have error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if provider != test.Expected {
+			t.Fatalf("%d: bad: %s", i, provider)
+		}
+	}
+}
@@ -16,12 +16,26 @@ import (
 // versions out of the builder steps, so sometimes the methods are
 // extremely specific.
 type Driver interface {
+	// Clone a VM from a snapshot of another VM. Linked clones share the
+	// disks of the snapshot instead of copying them.
+	Clone(name string, source string, snapshot string, linked bool) error
+
 	// Create a SATA controller.
 	CreateSATAController(vm string, controller string, portcount int) error
 
 	// Create a SCSI controller.
 	CreateSCSIController(vm string, controller string) error
 
+	// Create an NVMe controller.
+	CreateNVMeController(vm string, controller string, portcount int) error
+
+	// Create a virtio-scsi controller.
+	CreateVirtIOController(vm string, controller string, portcount int) error
+
+	// ConfigFile returns the path of the settings file of a VM, the
+	// directory of which holds its other files.
+	ConfigFile(string) (string, error)
+
 	// Delete a VM by name
 	Delete(string) error
 
@@ -34,6 +48,9 @@ type Driver interface {
 	// Checks if the VM with the given name is running.
 	IsRunning(string) (bool, error)
 
+	// VMExists checks if a VM with the given name is registered.
+	VMExists(string) (bool, error)
+
 	// Stop stops a running machine, forcefully.
 	Stop(string) error
 
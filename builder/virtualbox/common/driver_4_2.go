@@ -63,6 +63,48 @@ func (d *VBox42Driver) CreateSCSIController(vmName string, name string) error {
 	return d.VBoxManage(command...)
 }
 
+func (d *VBox42Driver) CreateNVMeController(vmName string, name string, portcount int) error {
+	command := []string{
+		"storagectl", vmName,
+		"--name", name,
+		"--add", "pcie",
+		"--controller", "NVMe",
+		"--portcount", strconv.Itoa(portcount),
+	}
+
+	return d.VBoxManage(command...)
+}
+
+func (d *VBox42Driver) CreateVirtIOController(vmName string, name string, portcount int) error {
+	command := []string{
+		"storagectl", vmName,
+		"--name", name,
+		"--add", "virtio",
+		"--controller", "VirtIO",
+		"--portcount", strconv.Itoa(portcount),
+	}
+
+	return d.VBoxManage(command...)
+}
+
+func (d *VBox42Driver) ConfigFile(name string) (string, error) {
+	stdout, err := d.VBoxManageWithOutput("showvminfo", name, "--machinereadable")
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(stdout, "\n") {
+		// Need to trim off CR character when running in windows
+		line = strings.TrimRight(line, "\r")
+		// The value is quoted but not escaped, as Windows paths show
+		if strings.HasPrefix(line, "CfgFile=") {
+			return strings.Trim(strings.TrimPrefix(line, "CfgFile="), `"`), nil
+		}
+	}
+
+	return "", fmt.Errorf("Cannot find the settings file of %s in vboxmanage output", name)
+}
+
 func (d *VBox42Driver) Delete(name string) error {
 	ctx := context.TODO()
 	return retry.Config{
@@ -104,6 +146,20 @@ func (d *VBox42Driver) Iso() (string, error) {
 	return "", fmt.Errorf("Cannot find \"Default Guest Additions ISO\" in vboxmanage output (or it is empty)")
 }
 
+func (d *VBox42Driver) Clone(name string, source string, snapshot string, linked bool) error {
+	args := []string{
+		"clonevm", source,
+		"--snapshot", snapshot,
+		"--name", name,
+		"--register",
+	}
+	if linked {
+		args = append(args, "--options", "link")
+	}
+
+	return d.VBoxManage(args...)
+}
+
 func (d *VBox42Driver) Import(name string, path string, flags []string) error {
 	args := []string{
 		"import", path,
@@ -148,6 +204,23 @@ func (d *VBox42Driver) IsRunning(name string) (bool, error) {
 	return false, nil
 }
 
+func (d *VBox42Driver) VMExists(name string) (bool, error) {
+	stdout, err := d.VBoxManageWithOutput("list", "vms")
+	if err != nil {
+		return false, err
+	}
+
+	// Each line is made of the quoted name and of the UUID of a VM
+	quoted := fmt.Sprintf("%q ", name)
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), quoted) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 func (d *VBox42Driver) Stop(name string) error {
 	if err := d.VBoxManage("controlvm", name, "poweroff"); err != nil {
 		return err
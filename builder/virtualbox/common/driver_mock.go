@@ -13,6 +13,25 @@ type DriverMock struct {
 	CreateSCSIControllerController string
 	CreateSCSIControllerErr        error
 
+	CreateNVMeControllerVM         string
+	CreateNVMeControllerController string
+	CreateNVMeControllerErr        error
+
+	CreateVirtIOControllerVM         string
+	CreateVirtIOControllerController string
+	CreateVirtIOControllerErr        error
+
+	ConfigFileName   string
+	ConfigFileResult string
+	ConfigFileErr    error
+
+	CloneCalled   bool
+	CloneName     string
+	CloneSource   string
+	CloneSnapshot string
+	CloneLinked   bool
+	CloneErr      error
+
 	DeleteCalled bool
 	DeleteName   string
 	DeleteErr    error
@@ -30,6 +49,10 @@ type DriverMock struct {
 	IsRunningReturn bool
 	IsRunningErr    error
 
+	VMExistsName   string
+	VMExistsResult bool
+	VMExistsErr    error
+
 	StopName string
 	StopErr  error
 
@@ -70,6 +93,15 @@ func (d *DriverMock) CreateSCSIController(vm string, controller string) error {
 	return d.CreateSCSIControllerErr
 }
 
+func (d *DriverMock) Clone(name string, source string, snapshot string, linked bool) error {
+	d.CloneCalled = true
+	d.CloneName = name
+	d.CloneSource = source
+	d.CloneSnapshot = snapshot
+	d.CloneLinked = linked
+	return d.CloneErr
+}
+
 func (d *DriverMock) Delete(name string) error {
 	d.DeleteCalled = true
 	d.DeleteName = name
@@ -97,6 +129,11 @@ func (d *DriverMock) IsRunning(name string) (bool, error) {
 	return d.IsRunningReturn, d.IsRunningErr
 }
 
+func (d *DriverMock) VMExists(name string) (bool, error) {
+	d.VMExistsName = name
+	return d.VMExistsResult, d.VMExistsErr
+}
+
 func (d *DriverMock) Stop(name string) error {
 	d.StopName = name
 	return d.StopErr
@@ -116,6 +153,23 @@ func (d *DriverMock) VBoxManage(args ...string) error {
 	return nil
 }
 
+func (d *DriverMock) CreateNVMeController(vm string, controller string, portcount int) error {
+	d.CreateNVMeControllerVM = vm
+	d.CreateNVMeControllerController = controller
+	return d.CreateNVMeControllerErr
+}
+
+func (d *DriverMock) CreateVirtIOController(vm string, controller string, portcount int) error {
+	d.CreateVirtIOControllerVM = vm
+	d.CreateVirtIOControllerController = controller
+	return d.CreateVirtIOControllerErr
+}
+
+func (d *DriverMock) ConfigFile(name string) (string, error) {
+	d.ConfigFileName = name
+	return d.ConfigFileResult, d.ConfigFileErr
+}
+
 func (d *DriverMock) Verify() error {
 	d.VerifyCalled = true
 	return d.VerifyErr
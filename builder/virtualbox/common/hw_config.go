@@ -22,6 +22,26 @@ type HWConfig struct {
 	// Specifies whether or not to enable the USB bus when
 	// building the VM. Defaults to false.
 	USB bool `mapstructure:"usb" required:"false"`
+	// The type of the USB controller enabled by usb: ohci (USB 1.1), ehci
+	// (USB 2.0) or xhci (USB 3.0). Defaults to ohci. ehci and xhci need
+	// the VirtualBox Extension Pack, and xhci VirtualBox 5.0 or later.
+	USBController string `mapstructure:"usb_controller" required:"false"`
+	// The audio controller emulated when sound is not none: ac97, hda or
+	// sb16. Defaults to the controller of the guest_os_type.
+	AudioController string `mapstructure:"audio_controller" required:"false"`
+	// The firmware of the VM: bios, the default, or efi. The NVRAM of EFI
+	// VMs, which holds their boot entries, is exported next to the VM as
+	// VM_NAME.nvram. efi requires VirtualBox 6.1 or later, which stores the
+	// NVRAM in a file.
+	Firmware string `mapstructure:"firmware" required:"false"`
+	// If true, the hardware virtualization extensions of the CPU are exposed
+	// to the VM, so that it can run its own hypervisor. Requires VirtualBox
+	// 6.0 or later. Defaults to false.
+	NestedVirt bool `mapstructure:"nested_virt" required:"false"`
+	// The graphics controller of the VM: vboxvga, vmsvga, vboxsvga or none.
+	// Defaults to the controller of the guest_os_type. Requires VirtualBox
+	// 5.2 or later, and 6.0 or later for vboxsvga.
+	GraphicsController string `mapstructure:"gfx_controller" required:"false"`
 }
 
 func (c *HWConfig) Prepare(ctx *interpolate.Context) []error {
@@ -47,5 +67,80 @@ func (c *HWConfig) Prepare(ctx *interpolate.Context) []error {
 		c.Sound = "none"
 	}
 
+	if c.USBController == "" {
+		c.USBController = "ohci"
+	}
+	if !oneOf(c.USBController, "ohci", "ehci", "xhci") {
+		errs = append(errs, fmt.Errorf("usb_controller can only be ohci, ehci or xhci"))
+	}
+	if c.AudioController != "" && !oneOf(c.AudioController, "ac97", "hda", "sb16") {
+		errs = append(errs, fmt.Errorf("audio_controller can only be ac97, hda or sb16"))
+	}
+
+	if c.Firmware == "" {
+		c.Firmware = "bios"
+	}
+	if !oneOf(c.Firmware, "bios", "efi") {
+		errs = append(errs, fmt.Errorf("firmware can only be bios or efi"))
+	}
+
+	if c.GraphicsController != "" && !oneOf(c.GraphicsController, "vboxvga", "vmsvga", "vboxsvga", "none") {
+		errs = append(errs, fmt.Errorf("gfx_controller can only be vboxvga, vmsvga, vboxsvga or none"))
+	}
+
 	return errs
 }
+
+// VersionRequirements returns the minimum VirtualBox versions of the
+// hardware options that are set.
+func (c *HWConfig) VersionRequirements() []VersionRequirement {
+	var reqs []VersionRequirement
+	if c.USBController == "xhci" {
+		reqs = append(reqs, VersionRequirement{Option: "usb_controller xhci", Version: "5.0"})
+	}
+	if c.Firmware == "efi" {
+		reqs = append(reqs, VersionRequirement{Option: "firmware efi", Version: "6.1"})
+	}
+	if c.NestedVirt {
+		reqs = append(reqs, VersionRequirement{Option: "nested_virt", Version: "6.0"})
+	}
+	switch c.GraphicsController {
+	case "":
+	case "vboxsvga":
+		reqs = append(reqs, VersionRequirement{Option: "gfx_controller vboxsvga", Version: "6.0"})
+	default:
+		reqs = append(reqs, VersionRequirement{Option: "gfx_controller " + c.GraphicsController, Version: "5.2"})
+	}
+	return reqs
+}
+
+// ModifyVMArgs returns the flags of `VBoxManage modifyvm` setting the
+// hardware options that are not defaults of VirtualBox.
+func (c *HWConfig) ModifyVMArgs() []string {
+	var args []string
+	if c.USB && c.USBController != "ohci" {
+		args = append(args, "--usb"+c.USBController, "on")
+	}
+	if c.AudioController != "" && c.Sound != "none" {
+		args = append(args, "--audiocontroller", c.AudioController)
+	}
+	if c.Firmware != "bios" {
+		args = append(args, "--firmware", c.Firmware)
+	}
+	if c.NestedVirt {
+		args = append(args, "--nested-hw-virt", "on")
+	}
+	if c.GraphicsController != "" {
+		args = append(args, "--graphicscontroller", c.GraphicsController)
+	}
+	return args
+}
+
+func oneOf(value string, values ...string) bool {
+	for _, v := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer/template/interpolate"
@@ -20,3 +21,65 @@ func TestHWConfigPrepare(t *testing.T) {
 		t.Errorf("bad memory size: %d", c.MemorySize)
 	}
 }
+
+func TestHWConfigPrepare_controllers(t *testing.T) {
+	c := new(HWConfig)
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.USBController != "ohci" {
+		t.Fatalf("bad usb controller: %s", c.USBController)
+	}
+	if c.Firmware != "bios" {
+		t.Fatalf("bad firmware: %s", c.Firmware)
+	}
+	if args := c.ModifyVMArgs(); len(args) > 0 {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	c = &HWConfig{
+		USBController:      "usb4",
+		AudioController:    "fake",
+		Firmware:           "uefi",
+		GraphicsController: "fake",
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) != 4 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestHWConfigModifyVMArgs(t *testing.T) {
+	c := &HWConfig{
+		Sound:              "pulse",
+		USB:                true,
+		USBController:      "xhci",
+		AudioController:    "hda",
+		Firmware:           "efi",
+		NestedVirt:         true,
+		GraphicsController: "vmsvga",
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	expected := []string{
+		"--usbxhci", "on",
+		"--audiocontroller", "hda",
+		"--firmware", "efi",
+		"--nested-hw-virt", "on",
+		"--graphicscontroller", "vmsvga",
+	}
+	if args := c.ModifyVMArgs(); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	expectedReqs := []VersionRequirement{
+		{Option: "usb_controller xhci", Version: "5.0"},
+		{Option: "firmware efi", Version: "6.1"},
+		{Option: "nested_virt", Version: "6.0"},
+		{Option: "gfx_controller vmsvga", Version: "5.2"},
+	}
+	if reqs := c.VersionRequirements(); !reflect.DeepEqual(reqs, expectedReqs) {
+		t.Fatalf("bad: %#v", reqs)
+	}
+}
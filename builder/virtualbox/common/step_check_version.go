@@ -0,0 +1,76 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	versionUtil "github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// VersionRequirement is the minimum version of VirtualBox supporting an
+// option of the configuration.
+type VersionRequirement struct {
+	// The option and its value, as shown to the user.
+	Option string
+	// The minimum version of VirtualBox.
+	Version string
+}
+
+// This step checks that the installed VirtualBox supports the options of
+// the configuration.
+//
+// Uses:
+//   driver Driver
+//   ui packer.Ui
+//
+// Produces:
+type StepCheckVersion struct {
+	Requirements []VersionRequirement
+}
+
+func (s *StepCheckVersion) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Requirements) == 0 {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	version, err := driver.Version()
+	if err != nil {
+		return halt(fmt.Errorf("Error reading the version of VirtualBox: %s", err))
+	}
+	current, err := versionUtil.NewVersion(version)
+	if err != nil {
+		return halt(fmt.Errorf("Error parsing the version of VirtualBox %q: %s", version, err))
+	}
+	log.Printf("Checking the options of the configuration against VirtualBox %s", current)
+
+	var errs *packer.MultiError
+	for _, r := range s.Requirements {
+		minimum, err := versionUtil.NewVersion(r.Version)
+		if err != nil {
+			return halt(err)
+		}
+		if current.LessThan(minimum) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"%s requires VirtualBox %s or later, found %s", r.Option, r.Version, version))
+		}
+	}
+	if errs != nil {
+		return halt(errs)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepCheckVersion) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepCheckVersion_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCheckVersion)
+}
+
+func TestStepCheckVersion(t *testing.T) {
+	state := testState(t)
+	step := &StepCheckVersion{
+		Requirements: []VersionRequirement{
+			{Option: "firmware efi", Version: "6.1"},
+			{Option: "hard_drive_interface nvme", Version: "5.0"},
+		},
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "6.1.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+}
+
+func TestStepCheckVersion_unsupported(t *testing.T) {
+	state := testState(t)
+	step := &StepCheckVersion{
+		Requirements: []VersionRequirement{
+			{Option: "firmware efi", Version: "6.1"},
+			{Option: "hard_drive_interface nvme", Version: "5.0"},
+		},
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "6.0.14"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if msg := err.(error).Error(); !strings.Contains(msg, "firmware efi requires VirtualBox 6.1 or later, found 6.0.14") ||
+		strings.Contains(msg, "nvme") {
+		t.Fatalf("bad: %s", msg)
+	}
+}
+
+func TestStepCheckVersion_noRequirements(t *testing.T) {
+	state := testState(t)
+	step := new(StepCheckVersion)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("driver").(*DriverMock).VersionCalled {
+		t.Fatal("should not read the version")
+	}
+}
@@ -1,22 +1,23 @@
-package vm
+package common
 
 import (
 	"context"
 	"fmt"
 	"log"
 
-	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
 )
 
+// StepCreateSnapshot takes the snapshot TargetSnapshot of the stopped VM
+// Name, replacing its existing child snapshot with the same name.
 type StepCreateSnapshot struct {
 	Name           string
 	TargetSnapshot string
 }
 
 func (s *StepCreateSnapshot) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
-	driver := state.Get("driver").(vboxcommon.Driver)
+	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
 	if s.TargetSnapshot != "" {
 		running, err := driver.IsRunning(s.Name)
@@ -40,8 +41,11 @@ func (s *StepCreateSnapshot) Run(_ context.Context, state multistep.StateBag) mu
 			return multistep.ActionHalt
 		}
 
-		currentSnapshot := snapshotTree.GetCurrentSnapshot()
-		targetSnapshot := currentSnapshot.GetChildWithName(s.TargetSnapshot)
+		// A VM without snapshots, like an imported VM, has no snapshot tree
+		var targetSnapshot *VBoxSnapshot
+		if snapshotTree != nil {
+			targetSnapshot = snapshotTree.GetCurrentSnapshot().GetChildWithName(s.TargetSnapshot)
+		}
 		if nil != targetSnapshot {
 			log.Printf("Deleting existing target snapshot %s", s.TargetSnapshot)
 			err = driver.DeleteSnapshot(s.Name, targetSnapshot)
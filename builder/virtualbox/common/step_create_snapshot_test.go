@@ -0,0 +1,45 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepCreateSnapshot_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCreateSnapshot)
+}
+
+func TestStepCreateSnapshot(t *testing.T) {
+	state := testState(t)
+	step := &StepCreateSnapshot{Name: "foo", TargetSnapshot: "provisioned"}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// a VM without snapshots
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.CreateSnapshotCalled) != 1 || driver.CreateSnapshotCalled[0] != "provisioned" {
+		t.Fatalf("bad: %#v", driver.CreateSnapshotCalled)
+	}
+	if len(driver.DeleteSnapshotCalled) != 0 {
+		t.Fatalf("bad: %#v", driver.DeleteSnapshotCalled)
+	}
+}
+
+func TestStepCreateSnapshot_running(t *testing.T) {
+	state := testState(t)
+	step := &StepCreateSnapshot{Name: "foo", TargetSnapshot: "provisioned"}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsRunningReturn = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.CreateSnapshotCalled) != 0 {
+		t.Fatalf("bad: %#v", driver.CreateSnapshotCalled)
+	}
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// This step copies the NVRAM of an EFI VM, which holds its EFI variables
+// such as the boot entries, into the output directory. The NVRAM is not
+// part of the OVF export, so it has to be restored next to the imported VM
+// for it to boot from the same entries.
+//
+// Uses:
+//   driver Driver
+//   ui packer.Ui
+//   vmName string
+//
+// Produces:
+//   nvramPath string - The path of the exported NVRAM.
+type StepExportNVRAM struct {
+	Firmware   string
+	OutputDir  string
+	SkipExport bool
+}
+
+func (s *StepExportNVRAM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Firmware != "efi" || s.SkipExport {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	vmName := state.Get("vmName").(string)
+
+	halt := func(err error) multistep.StepAction {
+		err = fmt.Errorf("Error exporting NVRAM: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	cfgFile, err := driver.ConfigFile(vmName)
+	if err != nil {
+		return halt(err)
+	}
+
+	// VirtualBox names the NVRAM after the settings file of the VM
+	base := strings.TrimSuffix(filepath.Base(cfgFile), filepath.Ext(cfgFile))
+	src := filepath.Join(filepath.Dir(cfgFile), base+".nvram")
+	dst := filepath.Join(s.OutputDir, vmName+".nvram")
+
+	ui.Say("Exporting NVRAM...")
+	if err := copyFile(src, dst); err != nil {
+		return halt(err)
+	}
+
+	state.Put("nvramPath", dst)
+	return multistep.ActionContinue
+}
+
+func (s *StepExportNVRAM) Cleanup(state multistep.StateBag) {}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepExportNVRAM_impl(t *testing.T) {
+	var _ multistep.Step = new(StepExportNVRAM)
+}
+
+func TestStepExportNVRAM(t *testing.T) {
+	vmDir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(vmDir)
+	outputDir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	if err := ioutil.WriteFile(filepath.Join(vmDir, "foo.nvram"), []byte("nvram"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	state.Put("vmName", "foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.ConfigFileResult = filepath.Join(vmDir, "foo.vbox")
+
+	step := &StepExportNVRAM{Firmware: "efi", OutputDir: outputDir}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	path := filepath.Join(outputDir, "foo.nvram")
+	if state.Get("nvramPath").(string) != path {
+		t.Fatalf("bad: %#v", state.Get("nvramPath"))
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "nvram" {
+		t.Fatalf("bad: %q", data)
+	}
+}
+
+func TestStepExportNVRAM_bios(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	step := &StepExportNVRAM{Firmware: "bios"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("driver").(*DriverMock).ConfigFileName != "" {
+		t.Fatal("should not export the NVRAM")
+	}
+}
+
+func TestStepExportNVRAM_skipExport(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	step := &StepExportNVRAM{Firmware: "efi", SkipExport: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("driver").(*DriverMock).ConfigFileName != "" {
+		t.Fatal("should not export the NVRAM")
+	}
+	if _, ok := state.GetOk("nvramPath"); ok {
+		t.Fatal("should not have an NVRAM path")
+	}
+}
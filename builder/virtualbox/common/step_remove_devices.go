@@ -83,6 +83,11 @@ func (s *StepRemoveDevices) Run(ctx context.Context, state multistep.StateBag) m
 				port = "1"
 				device = "0"
 			}
+			if _, ok := state.GetOk("attachedIsoOnVirtio"); ok {
+				controllerName = "VirtIO Controller"
+				port = "1"
+				device = "0"
+			}
 
 			command := []string{
 				"storageattach", vmName,
@@ -88,7 +88,10 @@ type Config struct {
 	// The type of controller that the primary hard drive is attached to,
 	// defaults to ide. When set to sata, the drive is attached to an AHCI SATA
 	// controller. When set to scsi, the drive is attached to an LsiLogic SCSI
-	// controller.
+	// controller. When set to nvme, the drive is attached to an NVMe
+	// controller, which requires VirtualBox 5.0 or later. When set to
+	// virtio-scsi, the drive is attached to a virtio-scsi controller, which
+	// requires VirtualBox 6.1 or later.
 	HardDriveInterface string `mapstructure:"hard_drive_interface" required:"false"`
 	// The number of ports available on any SATA controller created, defaults
 	// to 1. VirtualBox supports up to 30 ports on a maximum of 1 SATA
@@ -101,6 +104,8 @@ type Config struct {
 	HardDriveNonrotational bool `mapstructure:"hard_drive_nonrotational" required:"false"`
 	// The type of controller that the ISO is attached to, defaults to ide.
 	// When set to sata, the drive is attached to an AHCI SATA controller.
+	// When set to virtio-scsi, the drive is attached to a virtio-scsi
+	// controller, which requires VirtualBox 6.1 or later.
 	ISOInterface string `mapstructure:"iso_interface" required:"false"`
 	// Set this to true if you would like to keep the VM registered with
 	// virtualbox. Defaults to false.
@@ -128,6 +133,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 				"guest_additions_url",
 				"vboxmanage",
 				"vboxmanage_post",
+				"http_content",
 			},
 		},
 	}, raws...)
@@ -184,7 +190,10 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	if b.config.GuestAdditionsInterface == "" {
-		b.config.GuestAdditionsInterface = b.config.ISOInterface
+		b.config.GuestAdditionsInterface = "ide"
+		if b.config.ISOInterface == "sata" {
+			b.config.GuestAdditionsInterface = "sata"
+		}
 	}
 
 	if b.config.VMName == "" {
@@ -192,9 +201,11 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 			"packer-%s-%d", b.config.PackerBuildName, interpolate.InitTime.Unix())
 	}
 
-	if b.config.HardDriveInterface != "ide" && b.config.HardDriveInterface != "sata" && b.config.HardDriveInterface != "scsi" {
+	switch b.config.HardDriveInterface {
+	case "ide", "sata", "scsi", "nvme", "virtio-scsi":
+	default:
 		errs = packer.MultiErrorAppend(
-			errs, errors.New("hard_drive_interface can only be ide, sata, or scsi"))
+			errs, errors.New("hard_drive_interface can only be ide, sata, scsi, nvme or virtio-scsi"))
 	}
 
 	if b.config.SATAPortCount == 0 {
@@ -206,9 +217,14 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 			errs, errors.New("sata_port_count cannot be greater than 30"))
 	}
 
-	if b.config.ISOInterface != "ide" && b.config.ISOInterface != "sata" {
+	if b.config.ISOInterface != "ide" && b.config.ISOInterface != "sata" && b.config.ISOInterface != "virtio-scsi" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("iso_interface can only be ide, sata or virtio-scsi"))
+	}
+
+	if b.config.GuestAdditionsInterface != "ide" && b.config.GuestAdditionsInterface != "sata" {
 		errs = packer.MultiErrorAppend(
-			errs, errors.New("iso_interface can only be ide or sata"))
+			errs, errors.New("guest_additions_interface can only be ide or sata"))
 	}
 
 	validMode := false
@@ -248,6 +264,22 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	return warnings, nil
 }
 
+// versionRequirements returns the minimum VirtualBox versions of the
+// options that are set.
+func (c *Config) versionRequirements() []vboxcommon.VersionRequirement {
+	reqs := c.HWConfig.VersionRequirements()
+	switch c.HardDriveInterface {
+	case "nvme":
+		reqs = append(reqs, vboxcommon.VersionRequirement{Option: "hard_drive_interface nvme", Version: "5.0"})
+	case "virtio-scsi":
+		reqs = append(reqs, vboxcommon.VersionRequirement{Option: "hard_drive_interface virtio-scsi", Version: "6.1"})
+	}
+	if c.ISOInterface == "virtio-scsi" {
+		reqs = append(reqs, vboxcommon.VersionRequirement{Option: "iso_interface virtio-scsi", Version: "6.1"})
+	}
+	return reqs
+}
+
 func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
 	// Create the driver that we'll use to communicate with VirtualBox
 	driver, err := vboxcommon.NewDriver()
@@ -270,6 +302,8 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			ResultKey:    "iso_path",
 			TargetPath:   b.config.TargetPath,
 			Url:          b.config.ISOUrls,
+			Connections:  b.config.DownloadConnections,
+			RateLimit:    b.config.DownloadRateLimit,
 		},
 		&common.StepOutputDir{
 			Force: b.config.PackerForce,
@@ -281,15 +315,21 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:       b.config.HTTPDir,
+			HTTPContent:   b.config.HTTPContent,
+			HTTPPortMin:   b.config.HTTPPortMin,
+			HTTPPortMax:   b.config.HTTPPortMax,
+			HTTPTemplates: b.config.HTTPTemplates,
+			Ctx:           b.config.ctx,
 		},
 		&vboxcommon.StepSshKeyPair{
 			Debug:        b.config.PackerDebug,
 			DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
 			Comm:         &b.config.Comm,
 		},
+		&vboxcommon.StepCheckVersion{
+			Requirements: b.config.versionRequirements(),
+		},
 		new(vboxcommon.StepSuppressMessages),
 		new(stepCreateVM),
 		new(stepCreateDisk),
@@ -325,6 +365,9 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			GroupInterval: b.config.BootConfig.BootGroupInterval,
 			Comm:          &b.config.Comm,
 		},
+		&common.StepWaitForHTTPCallback{
+			Timeout: b.config.WaitForHTTPCallback,
+		},
 		&communicator.StepConnect{
 			Config:    &b.config.SSHConfig.Comm,
 			Host:      vboxcommon.CommHost(b.config.SSHConfig.Comm.SSHHost),
@@ -357,6 +400,11 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Commands: b.config.VBoxManagePost,
 			Ctx:      b.config.ctx,
 		},
+		&vboxcommon.StepExportNVRAM{
+			Firmware:   b.config.Firmware,
+			OutputDir:  b.config.OutputDir,
+			SkipExport: b.config.SkipExport,
+		},
 		&vboxcommon.StepExport{
 			Format:         b.config.Format,
 			OutputDir:      b.config.OutputDir,
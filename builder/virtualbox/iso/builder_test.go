@@ -301,14 +301,45 @@ func TestBuilderPrepare_HardDriveInterface(t *testing.T) {
 	}
 
 	// Test with a good
-	config["hard_drive_interface"] = "sata"
-	b = Builder{}
-	warns, err = b.Prepare(config)
+	for _, iface := range []string{"sata", "scsi", "nvme", "virtio-scsi"} {
+		config["hard_drive_interface"] = iface
+		b = Builder{}
+		warns, err = b.Prepare(config)
+		if len(warns) > 0 {
+			t.Fatalf("bad: %#v", warns)
+		}
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+	}
+}
+
+func TestBuilderPrepare_VersionRequirements(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["hard_drive_interface"] = "nvme"
+	config["iso_interface"] = "virtio-scsi"
+	config["firmware"] = "efi"
+
+	warns, err := b.Prepare(config)
 	if len(warns) > 0 {
 		t.Fatalf("bad: %#v", warns)
 	}
 	if err != nil {
-		t.Fatalf("should not have error: %s", err)
+		t.Fatalf("err: %s", err)
+	}
+
+	if b.config.GuestAdditionsInterface != "ide" {
+		t.Fatalf("bad: %s", b.config.GuestAdditionsInterface)
+	}
+
+	expected := []common.VersionRequirement{
+		{Option: "firmware efi", Version: "6.1"},
+		{Option: "hard_drive_interface nvme", Version: "5.0"},
+		{Option: "iso_interface virtio-scsi", Version: "6.1"},
+	}
+	if reqs := b.config.versionRequirements(); !reflect.DeepEqual(reqs, expected) {
+		t.Fatalf("bad: %#v", reqs)
 	}
 }
 
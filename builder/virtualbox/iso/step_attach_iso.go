@@ -33,6 +33,10 @@ func (s *stepAttachISO) Run(ctx context.Context, state multistep.StateBag) multi
 		controllerName = "SATA Controller"
 		port = "1"
 		device = "0"
+	} else if config.ISOInterface == "virtio-scsi" {
+		controllerName = "VirtIO Controller"
+		port = "1"
+		device = "0"
 	}
 
 	// If it's a symlink, resolve it to it's target.
@@ -69,6 +73,9 @@ func (s *stepAttachISO) Run(ctx context.Context, state multistep.StateBag) multi
 	if controllerName == "SATA Controller" {
 		state.Put("attachedIsoOnSata", true)
 	}
+	if controllerName == "VirtIO Controller" {
+		state.Put("attachedIsoOnVirtio", true)
+	}
 
 	return multistep.ActionContinue
 }
@@ -89,6 +96,10 @@ func (s *stepAttachISO) Cleanup(state multistep.StateBag) {
 		controllerName = "SATA Controller"
 		port = "1"
 		device = "0"
+	} else if config.ISOInterface == "virtio-scsi" {
+		controllerName = "VirtIO Controller"
+		port = "1"
+		device = "0"
 	}
 
 	command := []string{
@@ -75,14 +75,37 @@ func (s *stepCreateDisk) Run(ctx context.Context, state multistep.StateBag) mult
 		}
 	}
 
+	if config.HardDriveInterface == "nvme" {
+		if err := driver.CreateNVMeController(vmName, "NVMe Controller", 1); err != nil {
+			err := fmt.Errorf("Error creating disk controller: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	// The ISO is attached to the second port of the virtio-scsi controller
+	// when it shares it with the hard drive.
+	if config.HardDriveInterface == "virtio-scsi" || config.ISOInterface == "virtio-scsi" {
+		if err := driver.CreateVirtIOController(vmName, "VirtIO Controller", 2); err != nil {
+			err := fmt.Errorf("Error creating disk controller: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	// Attach the disk to the controller
 	controllerName := "IDE Controller"
-	if config.HardDriveInterface == "sata" {
+	switch config.HardDriveInterface {
+	case "sata":
 		controllerName = "SATA Controller"
-	}
-
-	if config.HardDriveInterface == "scsi" {
+	case "scsi":
 		controllerName = "SCSI Controller"
+	case "nvme":
+		controllerName = "NVMe Controller"
+	case "virtio-scsi":
+		controllerName = "VirtIO Controller"
 	}
 
 	nonrotational := "off"
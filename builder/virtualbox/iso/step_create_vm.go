@@ -44,6 +44,9 @@ func (s *stepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 	} else {
 		commands[5] = []string{"modifyvm", name, "--audio", config.HWConfig.Sound, "--audioin", "on", "--audioout", "on"}
 	}
+	if args := config.HWConfig.ModifyVMArgs(); len(args) > 0 {
+		commands = append(commands, append([]string{"modifyvm", name}, args...))
+	}
 
 	ui.Say("Creating virtual machine...")
 	for _, command := range commands {
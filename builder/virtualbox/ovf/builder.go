@@ -47,6 +47,21 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
+	// The VM is exported, unless a snapshot of it is taken instead.
+	var exportStep multistep.Step = &vboxcommon.StepExport{
+		Format:         b.config.Format,
+		OutputDir:      b.config.OutputDir,
+		ExportOpts:     b.config.ExportConfig.ExportOpts,
+		SkipNatMapping: b.config.SSHSkipNatMapping,
+		SkipExport:     b.config.SkipExport,
+	}
+	if b.config.TargetSnapshot != "" {
+		exportStep = &vboxcommon.StepCreateSnapshot{
+			Name:           b.config.VMName,
+			TargetSnapshot: b.config.TargetSnapshot,
+		}
+	}
+
 	// Build the steps.
 	steps := []multistep.Step{
 		&common.StepOutputDir{
@@ -60,9 +75,12 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:       b.config.HTTPDir,
+			HTTPContent:   b.config.HTTPContent,
+			HTTPPortMin:   b.config.HTTPPortMin,
+			HTTPPortMax:   b.config.HTTPPortMax,
+			HTTPTemplates: b.config.HTTPTemplates,
+			Ctx:           b.config.ctx,
 		},
 		&vboxcommon.StepSshKeyPair{
 			Debug:        b.config.PackerDebug,
@@ -87,6 +105,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		&StepImport{
 			Name:        b.config.VMName,
 			ImportFlags: b.config.ImportFlags,
+			LinkedClone: b.config.LinkedClone,
 		},
 		&vboxcommon.StepAttachGuestAdditions{
 			GuestAdditionsMode:      b.config.GuestAdditionsMode,
@@ -119,6 +138,9 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			GroupInterval: b.config.BootConfig.BootGroupInterval,
 			Comm:          &b.config.Comm,
 		},
+		&common.StepWaitForHTTPCallback{
+			Timeout: b.config.WaitForHTTPCallback,
+		},
 		&communicator.StepConnect{
 			Config:    &b.config.SSHConfig.Comm,
 			Host:      vboxcommon.CommHost(b.config.SSHConfig.Comm.SSHHost),
@@ -150,13 +172,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			Commands: b.config.VBoxManagePost,
 			Ctx:      b.config.ctx,
 		},
-		&vboxcommon.StepExport{
-			Format:         b.config.Format,
-			OutputDir:      b.config.OutputDir,
-			ExportOpts:     b.config.ExportConfig.ExportOpts,
-			SkipNatMapping: b.config.SSHSkipNatMapping,
-			SkipExport:     b.config.SkipExport,
-		},
+		exportStep,
 	}
 
 	// Run the steps.
@@ -100,6 +100,18 @@ type Config struct {
 	// not export the VM. Useful if the build output is not the resultant image,
 	// but created inside the VM.
 	SkipExport bool `mapstructure:"skip_export" required:"false"`
+	// If true, the OVF is imported once as a base VM, which stays
+	// registered with VirtualBox, and the VM of each build is a linked
+	// clone of a snapshot of the base VM, sharing its disks instead of
+	// copying them. The base VM is reused by the next builds of the same
+	// source_path. Defaults to false.
+	LinkedClone bool `mapstructure:"linked_clone" required:"false"`
+	// The name of a snapshot taken of the VM after provisioning, instead of
+	// exporting it: setting target_snapshot implies skip_export and
+	// keep_registered. The VM can be the source of the next layer of an
+	// incremental build with the `virtualbox-vm` builder and its
+	// `attach_snapshot` option.
+	TargetSnapshot string `mapstructure:"target_snapshot" required:"false"`
 
 	ctx interpolate.Context
 }
@@ -116,6 +128,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 				"guest_additions_url",
 				"vboxmanage",
 				"vboxmanage_post",
+				"http_content",
 			},
 		},
 	}, raws...)
@@ -192,6 +205,11 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 
 	// Warnings
 	var warnings []string
+	if c.TargetSnapshot != "" {
+		c.SkipExport = true
+		c.KeepRegistered = true
+	}
+
 	if c.ShutdownCommand == "" {
 		warnings = append(warnings,
 			"A shutdown_command was not specified. Without a shutdown command, Packer\n"+
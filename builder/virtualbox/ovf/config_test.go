@@ -128,3 +128,23 @@ func TestNewConfig_shutdown_timeout(t *testing.T) {
 		t.Fatalf("bad: %s", err)
 	}
 }
+
+func TestNewConfig_targetSnapshot(t *testing.T) {
+	c := testConfig(t)
+	c["target_snapshot"] = "provisioned"
+	result, _, err := NewConfig(c)
+	if err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+	if !result.SkipExport || !result.KeepRegistered {
+		t.Fatalf("target_snapshot should skip the export and keep the VM: %#v", result)
+	}
+
+	result, _, err = NewConfig(testConfig(t))
+	if err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+	if result.SkipExport || result.KeepRegistered {
+		t.Fatalf("bad: %#v", result)
+	}
+}
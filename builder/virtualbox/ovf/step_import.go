@@ -2,17 +2,26 @@ package ovf
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
+	"github.com/hashicorp/packer/common/filelock"
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
 )
 
+// The snapshot of the base VMs of linked clones.
+const baseSnapshot = "packer-base"
+
 // This step imports an OVF VM into VirtualBox.
 type StepImport struct {
 	Name        string
 	ImportFlags []string
+	LinkedClone bool
 
 	vmName string
 }
@@ -22,12 +31,20 @@ func (s *StepImport) Run(ctx context.Context, state multistep.StateBag) multiste
 	ui := state.Get("ui").(packer.Ui)
 	vmPath := state.Get("vm_path").(string)
 
-	ui.Say(fmt.Sprintf("Importing VM: %s", vmPath))
-	if err := driver.Import(s.Name, vmPath, s.ImportFlags); err != nil {
-		err := fmt.Errorf("Error importing VM: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	if s.LinkedClone {
+		if err := s.cloneBase(driver, ui, vmPath); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	} else {
+		ui.Say(fmt.Sprintf("Importing VM: %s", vmPath))
+		if err := driver.Import(s.Name, vmPath, s.ImportFlags); err != nil {
+			err := fmt.Errorf("Error importing VM: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	s.vmName = s.Name
@@ -56,3 +73,63 @@ func (s *StepImport) Cleanup(state multistep.StateBag) {
 		ui.Error(fmt.Sprintf("Error deleting VM: %s", err))
 	}
 }
+
+// cloneBase creates the VM as a linked clone of the base VM of vmPath,
+// importing the base VM if it is not registered yet. Builds of the same OVF
+// share the base VM, and a lock keeps them from importing it at once.
+func (s *StepImport) cloneBase(driver vboxcommon.Driver, ui packer.Ui, vmPath string) error {
+	base, err := baseName(vmPath)
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %s", vmPath, err)
+	}
+
+	lockPath, err := packer.CachePath(base + packer.CacheLockSuffix)
+	if err != nil {
+		return fmt.Errorf("Error locking base VM %s: %s", base, err)
+	}
+	lock := filelock.New(lockPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	exists, err := driver.VMExists(base)
+	if err != nil {
+		return fmt.Errorf("Error looking for base VM %s: %s", base, err)
+	}
+
+	if exists {
+		ui.Say(fmt.Sprintf("Using base VM: %s", base))
+	} else {
+		ui.Say(fmt.Sprintf("Importing base VM %s: %s", base, vmPath))
+		if err := driver.Import(base, vmPath, s.ImportFlags); err != nil {
+			return fmt.Errorf("Error importing base VM: %s", err)
+		}
+		if err := driver.CreateSnapshot(base, baseSnapshot); err != nil {
+			if err := driver.Delete(base); err != nil {
+				ui.Error(fmt.Sprintf("Error deleting base VM: %s", err))
+			}
+			return fmt.Errorf("Error creating snapshot of base VM: %s", err)
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Creating linked clone: %s", s.Name))
+	if err := driver.Clone(s.Name, base, baseSnapshot, true); err != nil {
+		return fmt.Errorf("Error cloning base VM: %s", err)
+	}
+	return nil
+}
+
+// baseName returns the name of the base VM of the linked clones of the
+// OVF at vmPath. The name changes along with the size and the modification
+// time of the file, so that a new OVF at the same path gets a new base VM.
+func baseName(vmPath string) (string, error) {
+	if abs, err := filepath.Abs(vmPath); err == nil {
+		vmPath = abs
+	}
+	fi, err := os.Stat(vmPath)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s\x00%d\x00%d", vmPath, fi.Size(), fi.ModTime().UnixNano())
+	sum := sha256.Sum256([]byte(key))
+	return "packer-base-" + hex.EncodeToString(sum[:])[:12], nil
+}
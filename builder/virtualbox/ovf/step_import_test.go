@@ -2,6 +2,9 @@ package ovf
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
@@ -63,3 +66,124 @@ func TestStepImport(t *testing.T) {
 		t.Fatalf("bad: %#v", driver.DeleteName)
 	}
 }
+
+// testOVA writes an OVA file and sets PACKER_CACHE_DIR, where the lock of
+// the base VM is, to a temporary directory. It returns the path of the OVA
+// and a cleanup function.
+func testOVA(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := filepath.Join(dir, "foo.ova")
+	if err := ioutil.WriteFile(path, []byte("ova"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	old := os.Getenv("PACKER_CACHE_DIR")
+	os.Setenv("PACKER_CACHE_DIR", filepath.Join(dir, "cache"))
+	return path, func() {
+		os.Setenv("PACKER_CACHE_DIR", old)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestStepImport_linkedClone(t *testing.T) {
+	ova, cleanup := testOVA(t)
+	defer cleanup()
+
+	state := testState(t)
+	config, _, _ := NewConfig(testConfig(t))
+	state.Put("vm_path", ova)
+	state.Put("config", config)
+	step := &StepImport{Name: "bar", LinkedClone: true}
+
+	driver := state.Get("driver").(*vboxcommon.DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// the base VM is imported and snapshotted
+	base, err := baseName(ova)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if driver.VMExistsName != base {
+		t.Fatalf("bad: %#v", driver.VMExistsName)
+	}
+	if !driver.ImportCalled || driver.ImportName != base {
+		t.Fatalf("bad: %#v", driver.ImportName)
+	}
+	if len(driver.CreateSnapshotCalled) != 1 || driver.CreateSnapshotCalled[0] != baseSnapshot {
+		t.Fatalf("bad: %#v", driver.CreateSnapshotCalled)
+	}
+
+	// the VM is a linked clone of the base VM
+	if !driver.CloneCalled || !driver.CloneLinked {
+		t.Fatal("clone should be called")
+	}
+	if driver.CloneName != "bar" || driver.CloneSource != base || driver.CloneSnapshot != baseSnapshot {
+		t.Fatalf("bad: %s %s %s", driver.CloneName, driver.CloneSource, driver.CloneSnapshot)
+	}
+
+	// only the clone is deleted
+	step.Cleanup(state)
+	if driver.DeleteName != "bar" {
+		t.Fatalf("bad: %#v", driver.DeleteName)
+	}
+}
+
+func TestStepImport_linkedCloneExistingBase(t *testing.T) {
+	ova, cleanup := testOVA(t)
+	defer cleanup()
+
+	state := testState(t)
+	config, _, _ := NewConfig(testConfig(t))
+	state.Put("vm_path", ova)
+	state.Put("config", config)
+	step := &StepImport{Name: "bar", LinkedClone: true}
+
+	driver := state.Get("driver").(*vboxcommon.DriverMock)
+	driver.VMExistsResult = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.ImportCalled || len(driver.CreateSnapshotCalled) > 0 {
+		t.Fatal("the base VM should be reused")
+	}
+	base, err := baseName(ova)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !driver.CloneCalled || driver.CloneSource != base {
+		t.Fatalf("bad: %#v", driver.CloneSource)
+	}
+}
+
+func TestBaseName(t *testing.T) {
+	ova, cleanup := testOVA(t)
+	defer cleanup()
+
+	base, err := baseName(ova)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// a new OVF at the same path has another base VM
+	if err := ioutil.WriteFile(ova, []byte("new ova"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	updated, err := baseName(ova)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if updated == base {
+		t.Fatalf("bad: %s", updated)
+	}
+
+	if _, err := baseName(ova + ".missing"); err == nil {
+		t.Fatal("should have error")
+	}
+}
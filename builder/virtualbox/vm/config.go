@@ -53,6 +53,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 				"guest_additions_url",
 				"vboxmanage",
 				"vboxmanage_post",
+				"http_content",
 			},
 		},
 	}, raws...)
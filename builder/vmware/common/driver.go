@@ -79,6 +79,21 @@ type Driver interface {
 	HostIP(multistep.StateBag) (string, error)
 }
 
+// A RegisteringDriver is a Driver that registers the VMs it starts, such as
+// the vmrest driver. The VMs it registered must be unregistered once they
+// are not used anymore.
+type RegisteringDriver interface {
+	Driver
+
+	// Registered reports whether the VM of vmxPath has been registered by
+	// the driver.
+	Registered(vmxPath string) bool
+
+	// Unregister removes the VM of vmxPath, registered by the driver. This
+	// may delete the files of the VM.
+	Unregister(vmxPath string) error
+}
+
 // NewDriver returns a new driver implementation for this operating
 // system, or an error if the driver couldn't be initialized.
 func NewDriver(dconfig *DriverConfig, config *SSHConfig, vmName string) (Driver, error) {
@@ -100,6 +115,16 @@ func NewDriver(dconfig *DriverConfig, config *SSHConfig, vmName string) (Driver,
 			},
 		}
 
+	} else if dconfig.VMRestURL != "" {
+		drivers = []Driver{
+			&VMRestDriver{
+				URL:       dconfig.VMRestURL,
+				Username:  dconfig.VMRestUser,
+				Password:  dconfig.VMRestPassword,
+				SSHConfig: config,
+			},
+		}
+
 	} else {
 		switch runtime.GOOS {
 		case "darwin":
@@ -52,6 +52,15 @@ type DriverConfig struct {
 	// given are valid. If you set this flag to true, Packer will skip this
 	// validation. Default: false.
 	SkipValidateCredentials bool `mapstructure:"skip_validate_credentials" required:"false"`
+	// The URL of the VMware Workstation REST API served by `vmrest`, such as
+	// `http://127.0.0.1:8697`. When this is set, Packer starts and stops the
+	// VM and discovers its IP address through this API instead of running
+	// `vmrun`. This can't be used with `remote_type`.
+	VMRestURL string `mapstructure:"vmrest_url" required:"false"`
+	// The username used to authenticate to `vmrest`.
+	VMRestUser string `mapstructure:"vmrest_username" required:"false"`
+	// The password used to authenticate to `vmrest`.
+	VMRestPassword string `mapstructure:"vmrest_password" required:"false"`
 }
 
 func (c *DriverConfig) Prepare(ctx *interpolate.Context) []error {
@@ -77,7 +86,17 @@ func (c *DriverConfig) Prepare(ctx *interpolate.Context) []error {
 		c.RemotePort = 22
 	}
 
-	return nil
+	var errs []error
+	if c.VMRestURL != "" {
+		if c.RemoteType != "" {
+			errs = append(errs, fmt.Errorf("vmrest_url can't be used with remote_type"))
+		}
+		if u, err := url.Parse(c.VMRestURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("vmrest_url must be an http or https URL, got %q", c.VMRestURL))
+		}
+	}
+
+	return errs
 }
 
 func (c *DriverConfig) Validate(SkipExport bool) error {
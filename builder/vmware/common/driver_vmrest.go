@@ -0,0 +1,620 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+// The media type of the requests and responses of the vmrest API.
+const vmrestMediaType = "application/vnd.vmware.vmw.rest-v1+json"
+
+// VMRestDriver is a driver that controls VMware Workstation through the
+// REST API served by vmrest, instead of running vmrun. The power state
+// and the IP address of the VM are read from the API, which registers the
+// VMs it starts.
+type VMRestDriver struct {
+	VmwareDriver
+
+	// The URL of vmrest, such as http://127.0.0.1:8697.
+	URL      string
+	Username string
+	Password string
+
+	// vmware-vdiskmanager still creates and compacts the disks, as vmrest
+	// does not manage them.
+	VdiskManagerPath string
+
+	// SSHConfig are the SSH settings for the VM
+	SSHConfig *SSHConfig
+
+	client *http.Client
+
+	// The absolute paths of the VMs registered by the driver.
+	registered map[string]bool
+}
+
+// vmrestVM is a VM registered in vmrest.
+type vmrestVM struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+type vmrestNIC struct {
+	Index      int    `json:"index"`
+	Type       string `json:"type"`
+	VMnet      string `json:"vmnet"`
+	MACAddress string `json:"macAddress"`
+}
+
+type vmrestVMnet struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Subnet string `json:"subnet"`
+	Mask   string `json:"mask"`
+}
+
+// vmrestError is the body of the error responses of vmrest.
+type vmrestError struct {
+	Code    int    `json:"Code"`
+	Message string `json:"Message"`
+}
+
+// request sends a request to vmrest and decodes its JSON response into
+// out, unless out is nil.
+func (d *VMRestDriver) request(method, path string, in, out interface{}) error {
+	if d.client == nil {
+		d.client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	url := strings.TrimRight(d.URL, "/") + "/api" + path
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.Username, d.Password)
+	req.Header.Set("Accept", vmrestMediaType)
+	if in != nil {
+		req.Header.Set("Content-Type", vmrestMediaType)
+	}
+
+	log.Printf("vmrest: %s %s", method, url)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error calling vmrest: %s", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading the response of vmrest: %s", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var e vmrestError
+		if err := json.Unmarshal(data, &e); err == nil && e.Message != "" {
+			return fmt.Errorf("VMware error: %s (vmrest code %d)", e.Message, e.Code)
+		}
+		return fmt.Errorf("VMware error: %s %s returned %s", method, path, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("Error decoding the response of vmrest: %s", err)
+	}
+	return nil
+}
+
+// vmID returns the ID of the VM of vmxPath in vmrest, or an empty string
+// if it is not registered.
+func (d *VMRestDriver) vmID(vmxPath string) (string, error) {
+	vmxPath, err := filepath.Abs(vmxPath)
+	if err != nil {
+		return "", err
+	}
+
+	var vms []vmrestVM
+	if err := d.request("GET", "/vms", nil, &vms); err != nil {
+		return "", err
+	}
+	for _, vm := range vms {
+		if sameFile(vm.Path, vmxPath) {
+			return vm.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// register registers the VM of vmxPath in vmrest unless it already is, and
+// returns its ID.
+func (d *VMRestDriver) register(vmxPath string) (string, error) {
+	id, err := d.vmID(vmxPath)
+	if err != nil || id != "" {
+		return id, err
+	}
+
+	vmxPath, err = filepath.Abs(vmxPath)
+	if err != nil {
+		return "", err
+	}
+	registration := map[string]string{
+		"name": strings.TrimSuffix(filepath.Base(vmxPath), filepath.Ext(vmxPath)),
+		"path": vmxPath,
+	}
+	var vm vmrestVM
+	if err := d.request("POST", "/vms/registration", registration, &vm); err != nil {
+		return "", fmt.Errorf("Error registering %s: %s", vmxPath, err)
+	}
+	if d.registered == nil {
+		d.registered = make(map[string]bool)
+	}
+	d.registered[vmxPath] = true
+	return vm.ID, nil
+}
+
+// Registered reports whether the VM of vmxPath has been registered in
+// vmrest by the driver.
+func (d *VMRestDriver) Registered(vmxPath string) bool {
+	vmxPath, err := filepath.Abs(vmxPath)
+	return err == nil && d.registered[vmxPath]
+}
+
+// Unregister removes the VM of vmxPath, registered by the driver, from
+// vmrest. vmrest has no request to only unregister a VM: the VM is deleted
+// along with its files.
+func (d *VMRestDriver) Unregister(vmxPath string) error {
+	if !d.Registered(vmxPath) {
+		return fmt.Errorf("%s was not registered by Packer", vmxPath)
+	}
+	id, err := d.vmID(vmxPath)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if err := d.request("DELETE", "/vms/"+id, nil, nil); err != nil {
+			return fmt.Errorf("Error unregistering %s: %s", vmxPath, err)
+		}
+	}
+	vmxPath, _ = filepath.Abs(vmxPath)
+	delete(d.registered, vmxPath)
+	return nil
+}
+
+// registeredID returns the ID of the VM of the state, which has been
+// registered when it was started.
+func (d *VMRestDriver) registeredID(state multistep.StateBag) (string, error) {
+	vmxPath := state.Get("vmx_path").(string)
+	id, err := d.vmID(vmxPath)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("%s is not registered in vmrest", vmxPath)
+	}
+	return id, nil
+}
+
+// setPower changes the power state of a VM. The body of power requests is
+// the bare operation, such as "on" or "off".
+func (d *VMRestDriver) setPower(id, state string) error {
+	return d.requestRaw("PUT", "/vms/"+id+"/power", state)
+}
+
+// requestRaw sends a request with a body that is not JSON encoded.
+func (d *VMRestDriver) requestRaw(method, path, body string) error {
+	if d.client == nil {
+		d.client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	url := strings.TrimRight(d.URL, "/") + "/api" + path
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.Username, d.Password)
+	req.Header.Set("Accept", vmrestMediaType)
+	req.Header.Set("Content-Type", vmrestMediaType)
+
+	log.Printf("vmrest: %s %s %s", method, url, body)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error calling vmrest: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e vmrestError
+		if err := json.NewDecoder(resp.Body).Decode(&e); err == nil && e.Message != "" {
+			return fmt.Errorf("VMware error: %s (vmrest code %d)", e.Message, e.Code)
+		}
+		return fmt.Errorf("VMware error: %s %s returned %s", method, path, resp.Status)
+	}
+	return nil
+}
+
+func (d *VMRestDriver) Clone(dst, src string, linked bool) error {
+	if linked {
+		return fmt.Errorf("Linked clones are not supported by the vmrest driver.")
+	}
+
+	vmx, err := ReadVMX(src)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Copy the disks next to the clone, with the files of their extents
+	for _, disk := range vmxDisks(vmx) {
+		path := disk.fileName
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(src), path)
+		}
+		files, err := vmdkFiles(path)
+		if err != nil {
+			return fmt.Errorf("Error reading disk %s: %s", disk.fileName, err)
+		}
+		for _, f := range files {
+			if err := copyFile(f, filepath.Join(filepath.Dir(dst), filepath.Base(f))); err != nil {
+				return fmt.Errorf("Error copying disk %s: %s", f, err)
+			}
+		}
+		key := fmt.Sprintf("%s%d:%d.filename", disk.bus, disk.controller, disk.unit)
+		vmx[key] = filepath.Base(path)
+	}
+
+	// The clone gets its own UUID and MAC addresses, without VMware asking
+	// whether it was moved or copied
+	vmx["uuid.action"] = "create"
+	for k := range vmx {
+		if strings.HasPrefix(k, "ethernet") && strings.HasSuffix(k, ".generatedaddress") {
+			delete(vmx, k)
+		}
+	}
+	delete(vmx, "uuid.bios")
+	delete(vmx, "uuid.location")
+
+	return WriteVMX(dst, vmx)
+}
+
+func (d *VMRestDriver) CompactDisk(diskPath string) error {
+	if d.VdiskManagerPath == "" {
+		return fmt.Errorf("'vmware-vdiskmanager' application not found")
+	}
+
+	defragCmd := exec.Command(d.VdiskManagerPath, "-d", diskPath)
+	if _, _, err := runAndLog(defragCmd); err != nil {
+		return err
+	}
+
+	shrinkCmd := exec.Command(d.VdiskManagerPath, "-k", diskPath)
+	if _, _, err := runAndLog(shrinkCmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *VMRestDriver) CreateDisk(output string, size string, adapter_type string, type_id string) error {
+	if d.VdiskManagerPath == "" {
+		return fmt.Errorf("'vmware-vdiskmanager' application not found")
+	}
+
+	cmd := exec.Command(d.VdiskManagerPath, "-c", "-s", size, "-a", adapter_type, "-t", type_id, output)
+	if _, _, err := runAndLog(cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *VMRestDriver) IsRunning(vmxPath string) (bool, error) {
+	id, err := d.vmID(vmxPath)
+	if err != nil || id == "" {
+		return false, err
+	}
+
+	var power struct {
+		State string `json:"power_state"`
+	}
+	if err := d.request("GET", "/vms/"+id+"/power", nil, &power); err != nil {
+		return false, err
+	}
+	return power.State == "poweredOn", nil
+}
+
+func (d *VMRestDriver) CommHost(state multistep.StateBag) (string, error) {
+	return CommHost(d.SSHConfig)(state)
+}
+
+func (d *VMRestDriver) Start(vmxPath string, headless bool) error {
+	id, err := d.register(vmxPath)
+	if err != nil {
+		return err
+	}
+
+	if !headless {
+		log.Printf("vmrest always starts VMs headless")
+	}
+	return d.setPower(id, "on")
+}
+
+func (d *VMRestDriver) Stop(vmxPath string) error {
+	id, err := d.vmID(vmxPath)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("%s is not registered in vmrest", vmxPath)
+	}
+	return d.setPower(id, "off")
+}
+
+func (d *VMRestDriver) SuppressMessages(vmxPath string) error {
+	return nil
+}
+
+func (d *VMRestDriver) Verify() error {
+	if d.URL == "" {
+		return fmt.Errorf("The URL of vmrest is not set")
+	}
+
+	// Check that vmrest answers and accepts the credentials
+	var vms []vmrestVM
+	if err := d.request("GET", "/vms", nil, &vms); err != nil {
+		return fmt.Errorf("vmrest is not available at %s: %s", d.URL, err)
+	}
+
+	if d.VdiskManagerPath == "" {
+		if path, err := workstationFindVdiskManager(); err == nil {
+			if _, err := os.Stat(path); err == nil {
+				d.VdiskManagerPath = path
+			}
+		}
+	}
+	log.Printf("vmrest URL: %s", d.URL)
+	log.Printf("vdisk-manager path: %s", d.VdiskManagerPath)
+
+	// The network types of the VMs are mapped with the virtual networks
+	// of vmrest
+	d.VmwareDriver.NetworkMapper = d.networkMap
+
+	return nil
+}
+
+func (d *VMRestDriver) ToolsIsoPath(flavor string) string {
+	return workstationToolsIsoPath(flavor)
+}
+
+func (d *VMRestDriver) ToolsInstall() error {
+	return nil
+}
+
+func (d *VMRestDriver) GetVmwareDriver() VmwareDriver {
+	return d.VmwareDriver
+}
+
+// nic returns the first network adapter of the VM of the state.
+func (d *VMRestDriver) nic(state multistep.StateBag) (*vmrestNIC, error) {
+	id, err := d.registeredID(state)
+	if err != nil {
+		return nil, err
+	}
+
+	var nics struct {
+		Num  int         `json:"num"`
+		NICs []vmrestNIC `json:"nics"`
+	}
+	if err := d.request("GET", "/vms/"+id+"/nic", nil, &nics); err != nil {
+		return nil, err
+	}
+	if len(nics.NICs) == 0 {
+		return nil, fmt.Errorf("The VM has no network adapter")
+	}
+	return &nics.NICs[0], nil
+}
+
+func (d *VMRestDriver) GuestAddress(state multistep.StateBag) (string, error) {
+	nic, err := d.nic(state)
+	if err != nil {
+		return "", err
+	}
+
+	mac, err := net.ParseMAC(nic.MACAddress)
+	if err != nil {
+		return "", err
+	}
+	return mac.String(), nil
+}
+
+func (d *VMRestDriver) GuestIP(state multistep.StateBag) (string, error) {
+	id, err := d.registeredID(state)
+	if err != nil {
+		return "", err
+	}
+
+	// vmrest reads the IP address from the VMware Tools of the guest, and
+	// fails until they run
+	var ip struct {
+		IP string `json:"ip"`
+	}
+	if err := d.request("GET", "/vms/"+id+"/ip", nil, &ip); err != nil {
+		return "", err
+	}
+	return ip.IP, nil
+}
+
+// hostInterface returns the network interface of the host on the virtual
+// network of the VM, named after the vmnet.
+func (d *VMRestDriver) hostInterface(state multistep.StateBag) (*net.Interface, string, error) {
+	nic, err := d.nic(state)
+	if err != nil {
+		return nil, "", err
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, intf := range interfaces {
+		if strings.HasSuffix(strings.ToLower(intf.Name), strings.ToLower(nic.VMnet)) {
+			intf := intf
+			return &intf, nic.VMnet, nil
+		}
+	}
+	return nil, nic.VMnet, nil
+}
+
+func (d *VMRestDriver) HostAddress(state multistep.StateBag) (string, error) {
+	intf, vmnet, err := d.hostInterface(state)
+	if err != nil {
+		return "", err
+	}
+	if intf == nil {
+		return "", fmt.Errorf("Unable to find the host interface of %s", vmnet)
+	}
+	return intf.HardwareAddr.String(), nil
+}
+
+func (d *VMRestDriver) HostIP(state multistep.StateBag) (string, error) {
+	intf, vmnet, err := d.hostInterface(state)
+	if err != nil {
+		return "", err
+	}
+
+	if intf != nil {
+		addrs, err := intf.Addrs()
+		if err != nil {
+			return "", err
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				return ipnet.IP.String(), nil
+			}
+		}
+	}
+
+	// The host has the first address of the subnet of the virtual network
+	vmnets, err := d.vmnets()
+	if err != nil {
+		return "", err
+	}
+	for _, n := range vmnets {
+		if n.Name != vmnet {
+			continue
+		}
+		ip := net.ParseIP(n.Subnet).To4()
+		if ip == nil {
+			return "", fmt.Errorf("Invalid subnet %q of %s", n.Subnet, vmnet)
+		}
+		ip[3]++
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("Unable to find the host IP of %s", vmnet)
+}
+
+// vmnets returns the virtual networks of vmrest.
+func (d *VMRestDriver) vmnets() ([]vmrestVMnet, error) {
+	var vmnets struct {
+		Num    int           `json:"num"`
+		VMnets []vmrestVMnet `json:"vmnets"`
+	}
+	if err := d.request("GET", "/vmnet", nil, &vmnets); err != nil {
+		return nil, err
+	}
+	return vmnets.VMnets, nil
+}
+
+// networkMap maps the types of the virtual networks of vmrest, such as nat
+// or bridged, to their devices, as netmap.conf does for Workstation.
+func (d *VMRestDriver) networkMap() (NetworkNameMapper, error) {
+	vmnets, err := d.vmnets()
+	if err != nil {
+		return nil, err
+	}
+	netmap := make(NetworkMap, 0, len(vmnets))
+	for _, n := range vmnets {
+		netmap = append(netmap, map[string]string{"name": n.Type, "device": n.Name})
+	}
+	return netmap, nil
+}
+
+// vmdkFiles returns the files of a disk: its descriptor and the files of
+// its extents.
+func vmdkFiles(path string) ([]string, error) {
+	disk, err := openVMDK(path)
+	if err != nil {
+		return nil, err
+	}
+	defer disk.Close()
+
+	if disk.parent != nil {
+		return nil, fmt.Errorf("disks with a parent can not be copied")
+	}
+
+	files := []string{path}
+	for _, e := range disk.extents {
+		if e.file != nil && !sameFile(e.file.Name(), path) {
+			files = append(files, e.file.Name())
+		}
+	}
+	return files, nil
+}
+
+func sameFile(a, b string) bool {
+	if a == b {
+		return true
+	}
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
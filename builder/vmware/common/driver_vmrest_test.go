@@ -0,0 +1,370 @@
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeVMRest is an in-memory vmrest server.
+type fakeVMRest struct {
+	sync.Mutex
+
+	// id -> path and power state of the registered VMs
+	paths  map[string]string
+	powers map[string]string
+	ip     string
+
+	requests []string
+}
+
+// newFakeVMRest starts a fake vmrest server, and returns it with a driver
+// using it. The server must be closed.
+func newFakeVMRest() (*fakeVMRest, *httptest.Server, *VMRestDriver) {
+	f := &fakeVMRest{
+		paths:  make(map[string]string),
+		powers: make(map[string]string),
+	}
+	server := httptest.NewServer(f)
+
+	return f, server, &VMRestDriver{URL: server.URL, Username: "user", Password: "pass"}
+}
+
+func testTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return dir
+}
+
+func (f *fakeVMRest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		f.fail(w, http.StatusUnauthorized, 100, "Authentication failed")
+		return
+	}
+	if r.Header.Get("Accept") != vmrestMediaType {
+		f.fail(w, http.StatusNotAcceptable, 101, "Unsupported media type")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/vms":
+		vms := []vmrestVM{}
+		for id, path := range f.paths {
+			vms = append(vms, vmrestVM{ID: id, Path: path})
+		}
+		f.reply(w, vms)
+	case r.Method == "POST" && r.URL.Path == "/api/vms/registration":
+		var registration struct{ Name, Path string }
+		json.NewDecoder(r.Body).Decode(&registration)
+		id := "VM" + registration.Name
+		f.paths[id] = registration.Path
+		f.powers[id] = "poweredOff"
+		f.reply(w, vmrestVM{ID: id, Path: registration.Path})
+	case r.Method == "GET" && r.URL.Path == "/api/vmnet":
+		f.reply(w, map[string]interface{}{
+			"num":    1,
+			"vmnets": []vmrestVMnet{{Name: "vmnet8", Type: "nat", Subnet: "172.16.83.0", Mask: "255.255.255.0"}},
+		})
+	case r.Method == "DELETE" && len(parts) == 3 && parts[1] == "vms":
+		if _, ok := f.paths[parts[2]]; !ok {
+			f.fail(w, http.StatusNotFound, 120, "The virtual machine is not found")
+			return
+		}
+		delete(f.paths, parts[2])
+		delete(f.powers, parts[2])
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 4 && parts[1] == "vms":
+		id := parts[2]
+		if _, ok := f.paths[id]; !ok {
+			f.fail(w, http.StatusNotFound, 120, "The virtual machine is not found")
+			return
+		}
+		switch r.Method + " " + parts[3] {
+		case "GET power":
+			f.reply(w, map[string]string{"power_state": f.powers[id]})
+		case "PUT power":
+			body, _ := ioutil.ReadAll(r.Body)
+			f.powers[id] = map[string]string{"on": "poweredOn", "off": "poweredOff"}[string(body)]
+			f.reply(w, map[string]string{"power_state": f.powers[id]})
+		case "GET ip":
+			if f.ip == "" {
+				f.fail(w, http.StatusInternalServerError, 106, "Unable to get the IP address")
+				return
+			}
+			f.reply(w, map[string]string{"ip": f.ip})
+		case "GET nic":
+			f.reply(w, map[string]interface{}{
+				"num":  1,
+				"nics": []vmrestNIC{{Index: 1, Type: "nat", VMnet: "vmnet8", MACAddress: "00:0C:29:AB:CD:EF"}},
+			})
+		default:
+			f.fail(w, http.StatusNotFound, 0, "")
+		}
+	default:
+		f.fail(w, http.StatusNotFound, 0, "")
+	}
+}
+
+func (f *fakeVMRest) reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", vmrestMediaType)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeVMRest) fail(w http.ResponseWriter, status, code int, message string) {
+	w.WriteHeader(status)
+	if message != "" {
+		json.NewEncoder(w).Encode(vmrestError{Code: code, Message: message})
+	}
+}
+
+func TestVMRestDriver_impl(t *testing.T) {
+	var _ Driver = new(VMRestDriver)
+}
+
+func TestVMRestDriver_lifecycle(t *testing.T) {
+	f, server, driver := newFakeVMRest()
+	defer server.Close()
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+	vmxPath := filepath.Join(dir, "packer.vmx")
+	if err := ioutil.WriteFile(vmxPath, []byte(".encoding = \"UTF-8\"\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := driver.Verify(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// an unregistered VM is not running
+	running, err := driver.IsRunning(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if running {
+		t.Fatal("should not be running")
+	}
+
+	// starting the VM registers it
+	if err := driver.Start(vmxPath, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if f.paths["VMpacker"] != vmxPath {
+		t.Fatalf("bad: %#v", f.paths)
+	}
+	running, err = driver.IsRunning(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !running {
+		t.Fatal("should be running")
+	}
+
+	// starting it again does not register it twice
+	if err := driver.Start(vmxPath, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	registrations := 0
+	for _, r := range f.requests {
+		if r == "POST /api/vms/registration" {
+			registrations++
+		}
+	}
+	if registrations != 1 {
+		t.Fatalf("bad: %#v", f.requests)
+	}
+
+	if err := driver.Stop(vmxPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if f.powers["VMpacker"] != "poweredOff" {
+		t.Fatalf("bad: %#v", f.powers)
+	}
+}
+
+func TestVMRestDriver_network(t *testing.T) {
+	f, server, driver := newFakeVMRest()
+	defer server.Close()
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+	vmxPath := filepath.Join(dir, "packer.vmx")
+	f.paths["VMpacker"] = vmxPath
+
+	state := testState(t)
+	state.Put("vmx_path", vmxPath)
+
+	// the IP address is unknown until the VMware Tools run
+	if _, err := driver.GuestIP(state); err == nil || !strings.Contains(err.Error(), "Unable to get the IP address") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	f.ip = "172.16.83.128"
+	ip, err := driver.GuestIP(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ip != "172.16.83.128" {
+		t.Fatalf("bad: %s", ip)
+	}
+
+	mac, err := driver.GuestAddress(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mac != "00:0c:29:ab:cd:ef" {
+		t.Fatalf("bad: %s", mac)
+	}
+
+	// the host of the test has no vmnet8 interface, so the address is
+	// derived from the subnet
+	host, err := driver.HostIP(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if host != "172.16.83.1" {
+		t.Fatalf("bad: %s", host)
+	}
+
+	// the VM must have been registered
+	state.Put("vmx_path", filepath.Join(filepath.Dir(vmxPath), "other.vmx"))
+	if _, err := driver.GuestIP(state); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestVMRestDriver_networkMap(t *testing.T) {
+	_, server, driver := newFakeVMRest()
+	defer server.Close()
+
+	if err := driver.Verify(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	mapper := driver.GetVmwareDriver().NetworkMapper
+	if mapper == nil {
+		t.Fatal("should have a network mapper")
+	}
+	netmap, err := mapper()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	devices, err := netmap.NameIntoDevices("NAT")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(devices, []string{"vmnet8"}) {
+		t.Fatalf("bad: %#v", devices)
+	}
+	name, err := netmap.DeviceIntoName("vmnet8")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if name != "nat" {
+		t.Fatalf("bad: %s", name)
+	}
+
+	// a custom network is not a network type
+	if _, err := netmap.NameIntoDevices("vmnet2"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestVMRestDriver_errors(t *testing.T) {
+	_, server, driver := newFakeVMRest()
+	defer server.Close()
+	driver.Password = "wrong"
+
+	err := driver.Verify()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "Authentication failed") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	driver = &VMRestDriver{}
+	if err := driver.Verify(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestVMRestDriver_Clone(t *testing.T) {
+	src, disks := testExportVM(t)
+	defer os.RemoveAll(src)
+	dst := testTempDir(t)
+	defer os.RemoveAll(dst)
+
+	driver := new(VMRestDriver)
+	if err := driver.Clone(filepath.Join(dst, "clone.vmx"), filepath.Join(src, "fixture.vmx"), true); err == nil {
+		t.Fatal("linked clones should not be supported")
+	}
+
+	if err := driver.Clone(filepath.Join(dst, "clone.vmx"), filepath.Join(src, "fixture.vmx"), false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	vmx, err := ReadVMX(filepath.Join(dst, "clone.vmx"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if vmx["uuid.action"] != "create" {
+		t.Fatalf("bad: %#v", vmx)
+	}
+	if vmx["sata0:1.filename"] != "/tmp/install.iso" {
+		t.Fatalf("bad: the CD-ROM should not change: %#v", vmx)
+	}
+
+	// the disks are copied with their extents
+	for _, f := range []string{"disk.vmdk", "disk-f001.vmdk", "disk-f002.vmdk", "data.vmdk"} {
+		if _, err := os.Stat(filepath.Join(dst, f)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if data := readDisk(t, filepath.Join(dst, "data.vmdk")); string(data) != string(disks[1]) {
+		t.Fatal("bad: the content of the disk differs")
+	}
+}
+
+func TestDriverConfigPrepare_vmrest(t *testing.T) {
+	c := &DriverConfig{VMRestURL: "http://127.0.0.1:8697"}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	c = &DriverConfig{VMRestURL: "127.0.0.1:8697"}
+	if errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	c = &DriverConfig{VMRestURL: "http://127.0.0.1:8697", RemoteType: "esx5"}
+	if errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestNewDriver_vmrest(t *testing.T) {
+	_, server, _ := newFakeVMRest()
+	defer server.Close()
+	config := &DriverConfig{VMRestURL: server.URL, VMRestUser: "user", VMRestPassword: "pass"}
+
+	driver, err := NewDriver(config, new(SSHConfig), "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := driver.(*VMRestDriver); !ok {
+		t.Fatalf("bad: %#v", driver)
+	}
+}
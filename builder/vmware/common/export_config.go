@@ -10,11 +10,14 @@ import (
 
 type ExportConfig struct {
 	// Either "ovf", "ova" or "vmx", this specifies the output
-	// format of the exported virtual machine. This defaults to "ovf".
-	// Before using this option, you need to install ovftool. This option
-	// currently only works when option remote_type is set to "esx5".
-	// Since ovftool is only capable of password based authentication
-	// remote_password must be set when exporting the VM.
+	// format of the exported virtual machine. When remote_type is set to
+	// "esx5", the VM is exported with ovftool, which needs to be installed,
+	// and this defaults to "ovf". Since ovftool is only capable of password
+	// based authentication remote_password must be set when exporting the
+	// VM. Local builds are exported without ovftool when this is set to
+	// "ovf" or "ova": the disks are converted to stream optimized VMDKs and
+	// described by an OVF descriptor and its manifest, which replace the
+	// files of the VM in the output directory.
 	Format string `mapstructure:"format" required:"false"`
 	// Extra options to pass to ovftool during export. Each item in the array
 	// is a new argument. The options `--noSSLVerify`, `--skipManifestCheck`,
@@ -27,9 +30,8 @@ type ExportConfig struct {
 	OVFToolOptions []string `mapstructure:"ovftool_options" required:"false"`
 	// Defaults to `false`. When enabled, Packer will not export the VM. Useful
 	// if the build output is not the resultant image, but created inside the
-	// VM. Currently, exporting the build VM is only supported when building on
-	// ESXi e.g. when `remote_type` is set to `esx5`. See the [Building on a
-	// Remote vSphere
+	// VM. Local builds are only exported when `format` is set. See the
+	// [Building on a Remote vSphere
 	// Hypervisor](/docs/builders/vmware-iso.html#building-on-a-remote-vsphere-hypervisor)
 	// section below for more info.
 	SkipExport bool `mapstructure:"skip_export" required:"false"`
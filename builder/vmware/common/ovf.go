@@ -0,0 +1,546 @@
+package common
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const ovfStreamOptimizedFormat = "http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"
+
+// The CIM resource types of the virtual hardware of OVF descriptors.
+const (
+	ovfResourceProcessor      = 3
+	ovfResourceMemory         = 4
+	ovfResourceIDEController  = 5
+	ovfResourceSCSIController = 6
+	ovfResourceEthernet       = 10
+	ovfResourceDisk           = 17
+	ovfResourceSATAController = 20
+)
+
+type ovfEnvelope struct {
+	XMLName   xml.Name `xml:"Envelope"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	XmlnsOvf  string   `xml:"xmlns:ovf,attr"`
+	XmlnsRasd string   `xml:"xmlns:rasd,attr"`
+	XmlnsVssd string   `xml:"xmlns:vssd,attr"`
+	XmlnsVmw  string   `xml:"xmlns:vmw,attr"`
+
+	Files    []ovfFile          `xml:"References>File"`
+	Disks    ovfDiskSection     `xml:"DiskSection"`
+	Networks *ovfNetworkSection `xml:"NetworkSection,omitempty"`
+	System   ovfVirtualSystem   `xml:"VirtualSystem"`
+}
+
+type ovfFile struct {
+	Href string `xml:"ovf:href,attr"`
+	ID   string `xml:"ovf:id,attr"`
+	Size int64  `xml:"ovf:size,attr"`
+}
+
+type ovfDiskSection struct {
+	Info  string    `xml:"Info"`
+	Disks []ovfDisk `xml:"Disk"`
+}
+
+type ovfDisk struct {
+	Capacity      int64  `xml:"ovf:capacity,attr"`
+	CapacityUnits string `xml:"ovf:capacityAllocationUnits,attr"`
+	DiskID        string `xml:"ovf:diskId,attr"`
+	FileRef       string `xml:"ovf:fileRef,attr"`
+	Format        string `xml:"ovf:format,attr"`
+	PopulatedSize int64  `xml:"ovf:populatedSize,attr"`
+}
+
+type ovfNetworkSection struct {
+	Info     string       `xml:"Info"`
+	Networks []ovfNetwork `xml:"Network"`
+}
+
+type ovfNetwork struct {
+	Name        string `xml:"ovf:name,attr"`
+	Description string `xml:"Description"`
+}
+
+type ovfVirtualSystem struct {
+	ID              string             `xml:"ovf:id,attr"`
+	Info            string             `xml:"Info"`
+	Name            string             `xml:"Name"`
+	OperatingSystem ovfOperatingSystem `xml:"OperatingSystemSection"`
+	Hardware        ovfHardware        `xml:"VirtualHardwareSection"`
+}
+
+type ovfOperatingSystem struct {
+	ID          int    `xml:"ovf:id,attr"`
+	Info        string `xml:"Info"`
+	Description string `xml:"Description"`
+}
+
+type ovfHardware struct {
+	Info   string      `xml:"Info"`
+	System ovfSystem   `xml:"System"`
+	Items  []ovfItem   `xml:"Item"`
+	Config []ovfConfig `xml:"vmw:Config"`
+}
+
+type ovfSystem struct {
+	ElementName string `xml:"vssd:ElementName"`
+	InstanceID  int    `xml:"vssd:InstanceID"`
+	Identifier  string `xml:"vssd:VirtualSystemIdentifier"`
+	Type        string `xml:"vssd:VirtualSystemType"`
+}
+
+// ovfItem is a virtual device. The elements are in the order of the CIM
+// schema.
+type ovfItem struct {
+	Address             string `xml:"rasd:Address,omitempty"`
+	AddressOnParent     string `xml:"rasd:AddressOnParent,omitempty"`
+	AllocationUnits     string `xml:"rasd:AllocationUnits,omitempty"`
+	AutomaticAllocation string `xml:"rasd:AutomaticAllocation,omitempty"`
+	Connection          string `xml:"rasd:Connection,omitempty"`
+	Description         string `xml:"rasd:Description,omitempty"`
+	ElementName         string `xml:"rasd:ElementName"`
+	HostResource        string `xml:"rasd:HostResource,omitempty"`
+	InstanceID          int    `xml:"rasd:InstanceID"`
+	Parent              int    `xml:"rasd:Parent,omitempty"`
+	ResourceSubType     string `xml:"rasd:ResourceSubType,omitempty"`
+	ResourceType        int    `xml:"rasd:ResourceType"`
+	VirtualQuantity     int64  `xml:"rasd:VirtualQuantity,omitempty"`
+}
+
+type ovfConfig struct {
+	Required string `xml:"ovf:required,attr"`
+	Key      string `xml:"vmw:key,attr"`
+	Value    string `xml:"vmw:value,attr"`
+}
+
+// vmxDisk is a hard disk of a VMX.
+type vmxDisk struct {
+	// scsi, sata, ide or nvme
+	bus        string
+	controller int
+	unit       int
+	fileName   string
+}
+
+var vmxDiskRe = regexp.MustCompile(`^(scsi|sata|ide|nvme)(\d+):(\d+)\.filename$`)
+
+// vmxDisks returns the hard disks of a VMX, ordered by their controller.
+func vmxDisks(vmx map[string]string) []vmxDisk {
+	var disks []vmxDisk
+	for k, v := range vmx {
+		m := vmxDiskRe.FindStringSubmatch(k)
+		if m == nil || !strings.HasSuffix(strings.ToLower(v), ".vmdk") {
+			continue
+		}
+		device := strings.TrimSuffix(k, ".filename")
+		if strings.ToLower(vmx[device+".present"]) != "true" {
+			continue
+		}
+		if t := vmx[device+".devicetype"]; t != "" && !strings.HasSuffix(strings.ToLower(t), "disk") {
+			continue
+		}
+		controller, _ := strconv.Atoi(m[2])
+		unit, _ := strconv.Atoi(m[3])
+		disks = append(disks, vmxDisk{bus: m[1], controller: controller, unit: unit, fileName: v})
+	}
+
+	buses := map[string]int{"ide": 0, "scsi": 1, "sata": 2, "nvme": 3}
+	sort.Slice(disks, func(i, j int) bool {
+		a, b := disks[i], disks[j]
+		if a.bus != b.bus {
+			return buses[a.bus] < buses[b.bus]
+		}
+		if a.controller != b.controller {
+			return a.controller < b.controller
+		}
+		return a.unit < b.unit
+	})
+	return disks
+}
+
+// ovfDiskInfo is a disk converted for an OVF package.
+type ovfDiskInfo struct {
+	vmxDisk
+	file          string
+	size          int64
+	capacity      int64
+	populatedSize int64
+}
+
+// ExportOVF exports the VM of the VMX at vmxPath into dir without ovftool,
+// as an OVF descriptor, its manifest and its disks converted to stream
+// optimized VMDKs, named after name. When format is "ova", they are
+// packaged into name.ova. It returns the paths of the exported files.
+func ExportOVF(vmxPath, dir, name, format string) ([]string, error) {
+	vmx, err := ReadVMX(vmxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var disks []ovfDiskInfo
+	for i, d := range vmxDisks(vmx) {
+		src := d.fileName
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(filepath.Dir(vmxPath), src)
+		}
+		info := ovfDiskInfo{vmxDisk: d, file: fmt.Sprintf("%s-disk%d.vmdk", name, i+1)}
+		if err := exportDisk(src, filepath.Join(dir, info.file), &info); err != nil {
+			return nil, fmt.Errorf("Error converting %s: %s", d.fileName, err)
+		}
+		disks = append(disks, info)
+	}
+
+	ovfName := name + ".ovf"
+	descriptor, err := xml.MarshalIndent(ovfDescriptor(vmx, name, disks), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(dir, ovfName))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(f, xml.Header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(append(descriptor, '\n')); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	files := []string{ovfName}
+	for _, d := range disks {
+		files = append(files, d.file)
+	}
+
+	// the manifest lists the checksums of the other files
+	mfName := name + ".mf"
+	var manifest strings.Builder
+	for _, file := range files {
+		sum, err := sha256File(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&manifest, "SHA256(%s)= %s\n", file, sum)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, mfName), []byte(manifest.String()), 0644); err != nil {
+		return nil, err
+	}
+
+	// the descriptor comes first in OVA packages, followed by the manifest
+	files = append([]string{ovfName, mfName}, files[1:]...)
+
+	if format == "ova" {
+		ova := name + ".ova"
+		if err := writeOVA(dir, ova, files); err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			os.Remove(filepath.Join(dir, file))
+		}
+		files = []string{ova}
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = filepath.Join(dir, file)
+	}
+	return paths, nil
+}
+
+// exportDisk converts the disk src into the stream optimized VMDK dst.
+func exportDisk(src, dst string, info *ovfDiskInfo) error {
+	disk, err := openVMDK(src)
+	if err != nil {
+		return err
+	}
+	defer disk.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	info.capacity = disk.Size()
+	info.populatedSize, err = writeStreamOptimized(f, disk, info.capacity, filepath.Base(dst), disk.adapterType)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	info.size = fi.Size()
+	return nil
+}
+
+// ovfDescriptor describes the virtual hardware of a VMX and its converted
+// disks.
+func ovfDescriptor(vmx map[string]string, name string, disks []ovfDiskInfo) *ovfEnvelope {
+	env := &ovfEnvelope{
+		Xmlns:     "http://schemas.dmtf.org/ovf/envelope/1",
+		XmlnsOvf:  "http://schemas.dmtf.org/ovf/envelope/1",
+		XmlnsRasd: "http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData",
+		XmlnsVssd: "http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData",
+		XmlnsVmw:  "http://www.vmware.com/schema/ovf",
+	}
+	env.Disks.Info = "Virtual disk information"
+
+	displayName := vmx["displayname"]
+	if displayName == "" {
+		displayName = name
+	}
+
+	guestOS := vmx["guestos"]
+	if guestOS == "" {
+		guestOS = "other"
+	}
+	// the CIM operating system types "Other" and "Other 64-Bit"
+	osID := 1
+	if strings.HasSuffix(guestOS, "-64") {
+		osID = 102
+	}
+
+	hwVersion, _ := strconv.Atoi(vmx["virtualhw.version"])
+	if hwVersion == 0 {
+		hwVersion = 9
+	}
+
+	env.System = ovfVirtualSystem{
+		ID:   name,
+		Info: "A virtual machine",
+		Name: displayName,
+		OperatingSystem: ovfOperatingSystem{
+			ID:          osID,
+			Info:        "The kind of installed guest operating system",
+			Description: guestOS,
+		},
+	}
+	hw := &env.System.Hardware
+	hw.Info = "Virtual hardware requirements"
+	hw.System = ovfSystem{
+		ElementName: "Virtual Hardware Family",
+		Identifier:  name,
+		Type:        fmt.Sprintf("vmx-%02d", hwVersion),
+	}
+
+	id := 0
+	add := func(item ovfItem) int {
+		id++
+		item.InstanceID = id
+		hw.Items = append(hw.Items, item)
+		return id
+	}
+
+	cpus, _ := strconv.ParseInt(vmx["numvcpus"], 10, 64)
+	if cpus == 0 {
+		cpus = 1
+	}
+	add(ovfItem{
+		AllocationUnits: "hertz * 10^6",
+		Description:     "Number of Virtual CPUs",
+		ElementName:     fmt.Sprintf("%d virtual CPU(s)", cpus),
+		ResourceType:    ovfResourceProcessor,
+		VirtualQuantity: cpus,
+	})
+
+	memory, _ := strconv.ParseInt(vmx["memsize"], 10, 64)
+	if memory == 0 {
+		memory = 512
+	}
+	add(ovfItem{
+		AllocationUnits: "byte * 2^20",
+		Description:     "Memory Size",
+		ElementName:     fmt.Sprintf("%dMB of memory", memory),
+		ResourceType:    ovfResourceMemory,
+		VirtualQuantity: memory,
+	})
+
+	controllers := make(map[string]int)
+	for i, d := range disks {
+		key := fmt.Sprintf("%s%d", d.bus, d.controller)
+		parent, ok := controllers[key]
+		if !ok {
+			item := ovfItem{
+				Address:     strconv.Itoa(d.controller),
+				ElementName: key,
+			}
+			switch d.bus {
+			case "ide":
+				item.Description = "IDE Controller"
+				item.ResourceType = ovfResourceIDEController
+			case "scsi":
+				item.Description = "SCSI Controller"
+				item.ResourceType = ovfResourceSCSIController
+				item.ResourceSubType = vmx[key+".virtualdev"]
+				if item.ResourceSubType == "" {
+					item.ResourceSubType = "lsilogic"
+				}
+			case "sata":
+				item.Description = "SATA Controller"
+				item.ResourceType = ovfResourceSATAController
+				item.ResourceSubType = "vmware.sata.ahci"
+			case "nvme":
+				item.Description = "NVMe Controller"
+				item.ResourceType = ovfResourceSATAController
+				item.ResourceSubType = "vmware.nvme.controller"
+			}
+			parent = add(item)
+			controllers[key] = parent
+		}
+
+		diskID := fmt.Sprintf("vmdisk%d", i+1)
+		fileID := fmt.Sprintf("file%d", i+1)
+		env.Files = append(env.Files, ovfFile{Href: d.file, ID: fileID, Size: d.size})
+		env.Disks.Disks = append(env.Disks.Disks, ovfDisk{
+			Capacity:      d.capacity,
+			CapacityUnits: "byte",
+			DiskID:        diskID,
+			FileRef:       fileID,
+			Format:        ovfStreamOptimizedFormat,
+			PopulatedSize: d.populatedSize,
+		})
+		add(ovfItem{
+			AddressOnParent: strconv.Itoa(d.unit),
+			ElementName:     fmt.Sprintf("Hard Disk %d", i+1),
+			HostResource:    "ovf:/disk/" + diskID,
+			Parent:          parent,
+			ResourceType:    ovfResourceDisk,
+		})
+	}
+
+	networks := make(map[string]bool)
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("ethernet%d", i)
+		if _, ok := vmx[key+".present"]; !ok {
+			break
+		}
+		if strings.ToLower(vmx[key+".present"]) != "true" {
+			continue
+		}
+
+		network := vmx[key+".connectiontype"]
+		if network == "" {
+			network = "nat"
+		}
+		networks[network] = true
+
+		subType := map[string]string{
+			"e1000":   "E1000",
+			"e1000e":  "E1000e",
+			"vmxnet3": "VmxNet3",
+			"vlance":  "PCNet32",
+		}[strings.ToLower(vmx[key+".virtualdev"])]
+		if subType == "" {
+			subType = "E1000"
+		}
+
+		add(ovfItem{
+			AddressOnParent:     strconv.Itoa(i),
+			AutomaticAllocation: "true",
+			Connection:          network,
+			Description:         fmt.Sprintf("%s ethernet adapter on %q", subType, network),
+			ElementName:         key,
+			ResourceSubType:     subType,
+			ResourceType:        ovfResourceEthernet,
+		})
+	}
+	if len(networks) > 0 {
+		env.Networks = &ovfNetworkSection{Info: "The list of logical networks"}
+		names := make([]string, 0, len(networks))
+		for n := range networks {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			env.Networks.Networks = append(env.Networks.Networks, ovfNetwork{
+				Name:        n,
+				Description: fmt.Sprintf("The %s network", n),
+			})
+		}
+	}
+
+	if firmware := vmx["firmware"]; firmware != "" {
+		hw.Config = append(hw.Config, ovfConfig{Required: "false", Key: "firmware", Value: firmware})
+	}
+
+	return env
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeOVA packages files of dir, in order, into the tarball name.
+func writeOVA(dir, name string, files []string) error {
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, file := range files {
+		if err := addTarFile(tw, filepath.Join(dir, file), file); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func addTarFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		Format:  tar.FormatUSTAR,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,169 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testExportVM copies the VMX of the export fixtures into a directory,
+// with its disks.
+func testExportVM(t *testing.T) (string, [][]byte) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	vmx, err := ioutil.ReadFile("test-fixtures/export/fixture.vmx")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "fixture.vmx"), vmx, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	first, second := testFlatExtents(t, "test-fixtures/export/disk.vmdk", dir)
+	disk := append(append(append([]byte{}, first...), make([]byte, 2048*sectorSize)...), second...)
+
+	data := testDiskData(16*streamGrainSize*sectorSize, 5)
+	writeSparseVMDK(t, filepath.Join(dir, "data.vmdk"), data, "")
+
+	return dir, [][]byte{disk, data}
+}
+
+func TestVMXDisks(t *testing.T) {
+	vmx, err := ReadVMX("test-fixtures/export/fixture.vmx")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []vmxDisk{
+		{bus: "scsi", controller: 0, unit: 0, fileName: "disk.vmdk"},
+		{bus: "sata", controller: 0, unit: 0, fileName: "data.vmdk"},
+	}
+	if disks := vmxDisks(vmx); !reflect.DeepEqual(disks, expected) {
+		t.Fatalf("bad: %#v", disks)
+	}
+}
+
+func TestExportOVF(t *testing.T) {
+	dir, disks := testExportVM(t)
+	defer os.RemoveAll(dir)
+	out, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(out)
+
+	files, err := ExportOVF(filepath.Join(dir, "fixture.vmx"), out, "packer", "ovf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		filepath.Join(out, "packer.ovf"),
+		filepath.Join(out, "packer.mf"),
+		filepath.Join(out, "packer-disk1.vmdk"),
+		filepath.Join(out, "packer-disk2.vmdk"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	// the converted disks have the content of the disks of the VM
+	for i, disk := range disks {
+		if read := readDisk(t, files[2+i]); !bytes.Equal(read, disk) {
+			t.Fatalf("bad: the content of disk %d differs", i+1)
+		}
+	}
+
+	descriptor, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, s := range []string{
+		`<File ovf:href="packer-disk1.vmdk" ovf:id="file1"`,
+		`ovf:capacity="5242880" ovf:capacityAllocationUnits="byte" ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:format="` + ovfStreamOptimizedFormat + `"`,
+		`<Network ovf:name="bridged">`,
+		`<Network ovf:name="nat">`,
+		`<Name>fixture</Name>`,
+		`<OperatingSystemSection ovf:id="102">`,
+		`<vssd:VirtualSystemType>vmx-14</vssd:VirtualSystemType>`,
+		`<rasd:ResourceSubType>pvscsi</rasd:ResourceSubType>`,
+		`<rasd:ResourceSubType>vmware.sata.ahci</rasd:ResourceSubType>`,
+		`<rasd:HostResource>ovf:/disk/vmdisk2</rasd:HostResource>`,
+		`<rasd:ResourceSubType>VmxNet3</rasd:ResourceSubType>`,
+		`<rasd:VirtualQuantity>1024</rasd:VirtualQuantity>`,
+		`<vmw:Config ovf:required="false" vmw:key="firmware" vmw:value="efi"></vmw:Config>`,
+	} {
+		if !strings.Contains(string(descriptor), s) {
+			t.Fatalf("bad: %s not in descriptor:\n%s", s, descriptor)
+		}
+	}
+	if strings.Contains(string(descriptor), "install.iso") {
+		t.Fatalf("bad: the CD-ROM is exported as a disk:\n%s", descriptor)
+	}
+
+	manifest, err := ioutil.ReadFile(files[1])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, f := range []string{files[0], files[2], files[3]} {
+		sum, err := sha256File(f)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		line := fmt.Sprintf("SHA256(%s)= %s\n", filepath.Base(f), sum)
+		if !strings.Contains(string(manifest), line) {
+			t.Fatalf("bad: %s not in manifest:\n%s", line, manifest)
+		}
+	}
+}
+
+func TestExportOVF_ova(t *testing.T) {
+	dir, _ := testExportVM(t)
+	defer os.RemoveAll(dir)
+
+	files, err := ExportOVF(filepath.Join(dir, "fixture.vmx"), dir, "packer", "ova")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(files, []string{filepath.Join(dir, "packer.ova")}) {
+		t.Fatalf("bad: %#v", files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "packer.ovf")); !os.IsNotExist(err) {
+		t.Fatal("the packaged files should be removed")
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		names = append(names, hdr.Name)
+	}
+
+	// the descriptor comes first
+	expected := []string{"packer.ovf", "packer.mf", "packer-disk1.vmdk", "packer-disk2.vmdk"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+}
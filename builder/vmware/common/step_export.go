@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 
@@ -14,10 +16,14 @@ import (
 	"github.com/hashicorp/packer/packer"
 )
 
-// This step exports a VM built on ESXi using ovftool
+// This step exports a VM built on ESXi using ovftool, or a local VM with
+// ExportOVF.
 //
 // Uses:
+//   dir OutputDir
 //   display_name string
+//   driver Driver
+//   vmx_path string
 type StepExport struct {
 	Format         string
 	SkipExport     bool
@@ -67,8 +73,11 @@ func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multiste
 	}
 
 	if c.RemoteType != "esx5" {
-		ui.Say("Skipping export of virtual machine (export is allowed only for ESXi)...")
-		return multistep.ActionContinue
+		if s.Format != "ovf" && s.Format != "ova" {
+			ui.Say("Skipping export of virtual machine (export of local builds needs format ovf or ova)...")
+			return multistep.ActionContinue
+		}
+		return s.exportLocal(state)
 	}
 
 	ovftool := GetOVFTool()
@@ -107,4 +116,60 @@ func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multiste
 	return multistep.ActionContinue
 }
 
+// exportLocal exports the local VM into the output directory, where the
+// exported files replace the files of the VM.
+func (s *StepExport) exportLocal(state multistep.StateBag) multistep.StepAction {
+	dir := state.Get("dir").(OutputDir)
+	ui := state.Get("ui").(packer.Ui)
+	vmxPath := state.Get("vmx_path").(string)
+
+	halt := func(err error) multistep.StepAction {
+		err = fmt.Errorf("Error exporting virtual machine: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	vmFiles, err := dir.ListFiles()
+	if err != nil {
+		return halt(err)
+	}
+
+	tmp, err := ioutil.TempDir(dir.String(), "export")
+	if err != nil {
+		return halt(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	ui.Say(fmt.Sprintf("Exporting virtual machine to %s...", strings.ToUpper(s.Format)))
+	files, err := ExportOVF(vmxPath, tmp, s.VMName, s.Format)
+	if err != nil {
+		return halt(err)
+	}
+
+	// The exported files replace the VM, so a VM registered by the driver
+	// is unregistered, which may delete its files.
+	if d, ok := state.Get("driver").(RegisteringDriver); ok && d.Registered(vmxPath) {
+		ui.Say("Unregistering virtual machine...")
+		if err := d.Unregister(vmxPath); err != nil {
+			return halt(err)
+		}
+	}
+
+	for _, f := range vmFiles {
+		if err := dir.Remove(f); err != nil && !os.IsNotExist(err) {
+			return halt(err)
+		}
+	}
+	for _, f := range files {
+		dst := filepath.Join(dir.String(), filepath.Base(f))
+		if err := os.Rename(f, dst); err != nil {
+			return halt(err)
+		}
+		ui.Message(fmt.Sprintf("Exported %s", dst))
+	}
+
+	return multistep.ActionContinue
+}
+
 func (s *StepExport) Cleanup(state multistep.StateBag) {}
@@ -2,6 +2,9 @@ package common
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer/helper/multistep"
@@ -34,3 +37,78 @@ func TestStepExport_wrongtype_impl(t *testing.T) {
 	testStepExport_wrongtype_impl(t, "foo")
 	testStepExport_wrongtype_impl(t, "")
 }
+
+func TestStepExport_local(t *testing.T) {
+	dir, _ := testExportVM(t)
+	defer os.RemoveAll(dir)
+
+	state := testState(t)
+	state.Put("driverConfig", new(DriverConfig))
+	outputDir := new(LocalOutputDir)
+	outputDir.SetOutputDir(dir)
+	state.Put("dir", outputDir)
+	state.Put("vmx_path", filepath.Join(dir, "fixture.vmx"))
+
+	step := &StepExport{Format: "ova", VMName: "packer", OutputDir: dir}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatalf("should NOT have error: %s", state.Get("error"))
+	}
+
+	// the OVA replaces the files of the VM
+	files, err := outputDir.ListFiles()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(files, []string{filepath.Join(dir, "packer.ova")}) {
+		t.Fatalf("bad: %#v", files)
+	}
+}
+
+func TestStepExport_localVMRest(t *testing.T) {
+	f, server, driver := newFakeVMRest()
+	defer server.Close()
+	dir, _ := testExportVM(t)
+	defer os.RemoveAll(dir)
+	vmxPath := filepath.Join(dir, "fixture.vmx")
+
+	if err := driver.Start(vmxPath, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := driver.Stop(vmxPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	state.Put("driver", driver)
+	state.Put("driverConfig", new(DriverConfig))
+	outputDir := new(LocalOutputDir)
+	outputDir.SetOutputDir(dir)
+	state.Put("dir", outputDir)
+	state.Put("vmx_path", vmxPath)
+
+	step := &StepExport{Format: "ova", VMName: "packer", OutputDir: dir}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatalf("should NOT have error: %s", state.Get("error"))
+	}
+
+	// the VM, replaced by the OVA, is not registered anymore
+	if driver.Registered(vmxPath) {
+		t.Fatal("should not be registered")
+	}
+	if len(f.paths) != 0 {
+		t.Fatalf("bad: %#v", f.paths)
+	}
+	files, err := outputDir.ListFiles()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(files, []string{filepath.Join(dir, "packer.ova")}) {
+		t.Fatalf("bad: %#v", files)
+	}
+}
@@ -91,5 +91,31 @@ func (s *StepRun) Cleanup(state multistep.StateBag) {
 				ui.Error(fmt.Sprintf("Error stopping VM: %s", err))
 			}
 		}
+
+		if d, ok := driver.(RegisteringDriver); ok && d.Registered(s.vmxPath) {
+			s.unregister(d, state)
+		}
+	}
+}
+
+// unregister removes the VM that the driver registered to start it.
+// Unregistering deletes the files of the VM, so the VM of a successful
+// build, which makes the artifact, stays registered, unless StepExport
+// replaced its files and unregistered it.
+func (s *StepRun) unregister(driver RegisteringDriver, state multistep.StateBag) {
+	ui := state.Get("ui").(packer.Ui)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		ui.Message(fmt.Sprintf(
+			"The VM stays registered in vmrest, as vmrest can only unregister it\n"+
+				"by deleting its files: %s", s.vmxPath))
+		return
+	}
+
+	ui.Say("Unregistering virtual machine...")
+	if err := driver.Unregister(s.vmxPath); err != nil {
+		ui.Error(fmt.Sprintf("Error unregistering VM: %s", err))
 	}
 }
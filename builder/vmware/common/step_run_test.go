@@ -2,6 +2,9 @@ package common
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer/helper/multistep"
@@ -81,3 +84,43 @@ func TestStepRun_cleanupRunning(t *testing.T) {
 		t.Fatal("stop should be called")
 	}
 }
+
+func TestStepRun_cleanupVMRest(t *testing.T) {
+	f, server, driver := newFakeVMRest()
+	defer server.Close()
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+	vmxPath := filepath.Join(dir, "packer.vmx")
+	if err := ioutil.WriteFile(vmxPath, []byte(".encoding = \"UTF-8\"\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, halted := range []bool{false, true} {
+		state := testState(t)
+		state.Put("driver", driver)
+		state.Put("vmx_path", vmxPath)
+		if halted {
+			state.Put(multistep.StateHalted, true)
+		}
+
+		step := new(StepRun)
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v", action)
+		}
+		if !driver.Registered(vmxPath) {
+			t.Fatal("should be registered")
+		}
+
+		step.Cleanup(state)
+		if f.powers["VMpacker"] == "poweredOn" {
+			t.Fatal("should be stopped")
+		}
+		_, ok := f.paths["VMpacker"]
+		if ok == halted {
+			t.Fatalf("halted %t: bad: %#v", halted, f.paths)
+		}
+		if driver.Registered(vmxPath) == halted {
+			t.Fatalf("halted %t: bad registration", halted)
+		}
+	}
+}
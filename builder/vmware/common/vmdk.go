@@ -0,0 +1,653 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The layout of VMDK files is described by the Virtual Disk Format 5.0
+// specification of VMware.
+const (
+	sectorSize = 512
+
+	sparseMagic = 0x564d444b // "KDMV"
+
+	// flags of sparse extent headers
+	sparseFlagValidNewLineTest = 1 << 0
+	sparseFlagZeroGrainGTE     = 1 << 2
+	sparseFlagCompressed       = 1 << 16
+	sparseFlagMarkers          = 1 << 17
+
+	sparseCompressDeflate = 1
+
+	// the gdOffset of stream optimized disks which write their grain
+	// directory at the end of the file, after their grains
+	sparseGDAtEnd = 0xffffffffffffffff
+
+	// the types of the metadata markers of stream optimized disks
+	markerEOS    = 0
+	markerGT     = 1
+	markerGD     = 2
+	markerFooter = 3
+
+	streamGrainSize    = 128
+	streamNumGTEsPerGT = 512
+)
+
+// sparseHeader is the header of hosted sparse extents, which starts their
+// first sector.
+type sparseHeader struct {
+	MagicNumber        uint32
+	Version            uint32
+	Flags              uint32
+	Capacity           uint64
+	GrainSize          uint64
+	DescriptorOffset   uint64
+	DescriptorSize     uint64
+	NumGTEsPerGT       uint32
+	RGDOffset          uint64
+	GDOffset           uint64
+	OverHead           uint64
+	UncleanShutdown    uint8
+	SingleEndLineChar  byte
+	NonEndLineChar     byte
+	DoubleEndLineChar1 byte
+	DoubleEndLineChar2 byte
+	CompressAlgorithm  uint16
+	Pad                [433]uint8
+}
+
+// vmdkExtent is an extent of a virtual disk, a range of its sectors
+// stored in a file.
+type vmdkExtent struct {
+	// the number of sectors of the extent
+	sectors int64
+	// SPARSE, FLAT, VMFS or ZERO
+	kind string
+	file *os.File
+	// the offset of FLAT extents in their file, in sectors
+	offset int64
+	sparse *sparseExtent
+}
+
+// vmdkDisk reads the content of a virtual disk, made of the extents of
+// its descriptor. The grains that are not allocated in the sparse extents
+// of a child disk are read from its parent.
+type vmdkDisk struct {
+	extents     []*vmdkExtent
+	parent      *vmdkDisk
+	adapterType string
+}
+
+// openVMDK opens the virtual disk of the descriptor, or monolithic sparse
+// extent, at path.
+func openVMDK(path string) (*vmdkDisk, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var magic uint32
+	if err := binary.Read(f, binary.LittleEndian, &magic); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+
+	var descriptor []byte
+	var sparse *sparseExtent
+	if magic == sparseMagic {
+		// the descriptor is embedded in monolithic sparse disks
+		sparse, err = readSparseExtent(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Error reading %s: %s", path, err)
+		}
+		descriptor = make([]byte, sparse.header.DescriptorSize*sectorSize)
+		if _, err := f.ReadAt(descriptor, int64(sparse.header.DescriptorOffset)*sectorSize); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Error reading the descriptor of %s: %s", path, err)
+		}
+		descriptor = bytes.TrimRight(descriptor, "\x00")
+	} else {
+		defer f.Close()
+		// descriptor files are small, unlike flat extents
+		descriptor, err = ioutil.ReadAll(io.NewSectionReader(f, 0, 64*1024))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", path, err)
+		}
+		if !bytes.Contains(descriptor, []byte("createType")) {
+			return nil, fmt.Errorf("%s is not a VMDK descriptor", path)
+		}
+	}
+
+	d, err := parseVMDKDescriptor(string(descriptor), filepath.Dir(path), f, sparse)
+	if err != nil {
+		if sparse != nil {
+			f.Close()
+		}
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	return d, nil
+}
+
+var vmdkExtentRe = regexp.MustCompile(`^(?:RW|RDONLY|NOACCESS)\s+(\d+)\s+(\w+)(?:\s+"([^"]*)"(?:\s+(\d+))?)?`)
+
+// parseVMDKDescriptor opens the extents and the parent of the disk of a
+// descriptor. self is the monolithic sparse file embedding the descriptor,
+// if any.
+func parseVMDKDescriptor(descriptor, dir string, self *os.File, selfSparse *sparseExtent) (*vmdkDisk, error) {
+	d := new(vmdkDisk)
+	var parentCID, parentHint string
+
+	scanner := bufio.NewScanner(strings.NewReader(descriptor))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if m := vmdkExtentRe.FindStringSubmatch(line); m != nil {
+			e := &vmdkExtent{kind: m[2]}
+			e.sectors, _ = strconv.ParseInt(m[1], 10, 64)
+			if m[4] != "" {
+				e.offset, _ = strconv.ParseInt(m[4], 10, 64)
+			}
+			if err := d.openExtent(e, dir, m[3], self, selfSparse); err != nil {
+				d.Close()
+				return nil, err
+			}
+			d.extents = append(d.extents, e)
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		switch key {
+		case "parentCID":
+			parentCID = value
+		case "parentFileNameHint":
+			parentHint = value
+		case "ddb.adapterType":
+			d.adapterType = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if len(d.extents) == 0 {
+		return nil, fmt.Errorf("the descriptor has no extents")
+	}
+
+	if parentCID != "" && parentCID != "ffffffff" {
+		if parentHint == "" {
+			d.Close()
+			return nil, fmt.Errorf("the disk has a parent but no parentFileNameHint")
+		}
+		if !filepath.IsAbs(parentHint) {
+			parentHint = filepath.Join(dir, parentHint)
+		}
+		parent, err := openVMDK(parentHint)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.parent = parent
+	}
+
+	return d, nil
+}
+
+func (d *vmdkDisk) openExtent(e *vmdkExtent, dir, name string, self *os.File, selfSparse *sparseExtent) error {
+	switch e.kind {
+	case "ZERO":
+		return nil
+	case "SPARSE", "FLAT", "VMFS":
+	default:
+		return fmt.Errorf("extents of type %s are not supported", e.kind)
+	}
+
+	// the extent of a monolithic sparse disk is the file of the descriptor
+	if selfSparse != nil && e.kind == "SPARSE" && len(d.extents) == 0 {
+		e.file = self
+		e.sparse = selfSparse
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	e.file = f
+	if e.kind == "SPARSE" {
+		if e.sparse, err = readSparseExtent(f); err != nil {
+			f.Close()
+			return fmt.Errorf("Error reading %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Size returns the capacity of the disk in bytes.
+func (d *vmdkDisk) Size() int64 {
+	var sectors int64
+	for _, e := range d.extents {
+		sectors += e.sectors
+	}
+	return sectors * sectorSize
+}
+
+func (d *vmdkDisk) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= d.Size() {
+			return n, io.EOF
+		}
+
+		var e *vmdkExtent
+		start := int64(0)
+		for _, e = range d.extents {
+			if pos < start+e.sectors*sectorSize {
+				break
+			}
+			start += e.sectors * sectorSize
+		}
+		rel := pos - start
+
+		chunk := p[n:]
+		if rest := e.sectors*sectorSize - rel; int64(len(chunk)) > rest {
+			chunk = chunk[:rest]
+		}
+
+		switch e.kind {
+		case "ZERO":
+			zero(chunk)
+		case "FLAT", "VMFS":
+			read, err := e.file.ReadAt(chunk, e.offset*sectorSize+rel)
+			if err == io.EOF {
+				// flat extents may be shorter than their descriptor says
+				zero(chunk[read:])
+			} else if err != nil {
+				return n, err
+			}
+		case "SPARSE":
+			grainBytes := int64(e.sparse.header.GrainSize) * sectorSize
+			in := rel % grainBytes
+			if rest := grainBytes - in; int64(len(chunk)) > rest {
+				chunk = chunk[:rest]
+			}
+			grain, err := e.sparse.grain(rel / grainBytes)
+			if err != nil {
+				return n, err
+			}
+			switch {
+			case grain != nil:
+				copy(chunk, grain[in:])
+			case d.parent != nil:
+				if _, err := d.parent.ReadAt(chunk, pos); err != nil && err != io.EOF {
+					return n, err
+				}
+			default:
+				zero(chunk)
+			}
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+func (d *vmdkDisk) Close() error {
+	for _, e := range d.extents {
+		if e.file != nil {
+			e.file.Close()
+		}
+	}
+	if d.parent != nil {
+		d.parent.Close()
+	}
+	return nil
+}
+
+// sparseExtent reads the grains of a hosted sparse extent.
+type sparseExtent struct {
+	file   *os.File
+	header sparseHeader
+	// the grain table entries of all the grains of the extent
+	gtes []uint32
+
+	// the last grain read
+	cached     int64
+	cachedData []byte
+}
+
+func readSparseExtent(f *os.File) (*sparseExtent, error) {
+	e := &sparseExtent{file: f, cached: -1}
+	if err := readSparseHeader(f, 0, &e.header); err != nil {
+		return nil, err
+	}
+	if e.header.GrainSize == 0 || e.header.NumGTEsPerGT == 0 {
+		return nil, fmt.Errorf("invalid sparse extent header")
+	}
+
+	gdOffset := e.header.GDOffset
+	if gdOffset == sparseGDAtEnd {
+		// stream optimized disks have the grain directory in their footer,
+		// followed by the end-of-stream marker
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		var footer sparseHeader
+		if err := readSparseHeader(f, fi.Size()-2*sectorSize, &footer); err != nil {
+			return nil, fmt.Errorf("Error reading footer: %s", err)
+		}
+		gdOffset = footer.GDOffset
+	}
+
+	grains := (e.header.Capacity + e.header.GrainSize - 1) / e.header.GrainSize
+	numGTs := (grains + uint64(e.header.NumGTEsPerGT) - 1) / uint64(e.header.NumGTEsPerGT)
+
+	gd := make([]uint32, numGTs)
+	if err := binary.Read(io.NewSectionReader(f, int64(gdOffset)*sectorSize, int64(numGTs)*4),
+		binary.LittleEndian, gd); err != nil {
+		return nil, fmt.Errorf("Error reading grain directory: %s", err)
+	}
+
+	e.gtes = make([]uint32, numGTs*uint64(e.header.NumGTEsPerGT))
+	for i, gde := range gd {
+		if gde == 0 {
+			// grain tables may be omitted when none of their grains is
+			// allocated
+			continue
+		}
+		gt := e.gtes[i*int(e.header.NumGTEsPerGT) : (i+1)*int(e.header.NumGTEsPerGT)]
+		if err := binary.Read(io.NewSectionReader(f, int64(gde)*sectorSize, int64(len(gt))*4),
+			binary.LittleEndian, gt); err != nil {
+			return nil, fmt.Errorf("Error reading grain table: %s", err)
+		}
+	}
+	e.gtes = e.gtes[:grains]
+
+	return e, nil
+}
+
+func readSparseHeader(f *os.File, offset int64, h *sparseHeader) error {
+	if err := binary.Read(io.NewSectionReader(f, offset, sectorSize), binary.LittleEndian, h); err != nil {
+		return err
+	}
+	if h.MagicNumber != sparseMagic {
+		return fmt.Errorf("invalid sparse extent magic number %#x", h.MagicNumber)
+	}
+	return nil
+}
+
+// grain returns the content of grain i, or nil when it is not allocated.
+func (e *sparseExtent) grain(i int64) ([]byte, error) {
+	if i == e.cached {
+		return e.cachedData, nil
+	}
+
+	gte := e.gtes[i]
+	if gte == 0 {
+		return nil, nil
+	}
+
+	data := make([]byte, e.header.GrainSize*sectorSize)
+	switch {
+	case gte == 1 && e.header.Flags&sparseFlagZeroGrainGTE != 0:
+		// a grain of zeros
+	case e.header.Flags&sparseFlagCompressed != 0:
+		// compressed grains are preceded by their LBA and their size
+		var marker struct {
+			LBA  uint64
+			Size uint32
+		}
+		r := io.NewSectionReader(e.file, int64(gte)*sectorSize, 1<<40)
+		if err := binary.Read(r, binary.LittleEndian, &marker); err != nil {
+			return nil, err
+		}
+		zr, err := zlib.NewReader(io.LimitReader(r, int64(marker.Size)))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(zr, data); err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+	default:
+		if _, err := e.file.ReadAt(data, int64(gte)*sectorSize); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+
+	e.cached, e.cachedData = i, data
+	return data, nil
+}
+
+func zero(p []byte) {
+	for i := range p {
+		p[i] = 0
+	}
+}
+
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sectorWriter counts the bytes written to w and pads them to sectors.
+type sectorWriter struct {
+	w   io.Writer
+	pos int64
+}
+
+func (s *sectorWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.pos += int64(n)
+	return n, err
+}
+
+func (s *sectorWriter) sector() uint64 {
+	return uint64(s.pos / sectorSize)
+}
+
+func (s *sectorWriter) pad() error {
+	if rest := s.pos % sectorSize; rest != 0 {
+		_, err := s.Write(make([]byte, sectorSize-rest))
+		return err
+	}
+	return nil
+}
+
+// writeMarker writes the metadata marker of a stream optimized disk
+// announcing sectors sectors of metadata of type kind.
+func (s *sectorWriter) writeMarker(sectors uint64, kind uint32) error {
+	marker := make([]byte, sectorSize)
+	binary.LittleEndian.PutUint64(marker[0:], sectors)
+	binary.LittleEndian.PutUint32(marker[12:], kind)
+	_, err := s.Write(marker)
+	return err
+}
+
+// streamAdapterType returns the adapter type written in the descriptor of
+// a stream optimized disk, which can only be ide, buslogic or lsilogic. The
+// other adapters, such as pvscsi or lsisas1068, are declared as lsilogic;
+// the OVF descriptor tells the actual controller of the disk.
+func streamAdapterType(adapterType string) string {
+	switch adapterType {
+	case "ide", "buslogic":
+		return adapterType
+	default:
+		return "lsilogic"
+	}
+}
+
+// writeStreamOptimized writes the content of disk, of size bytes, to w as
+// a stream optimized VMDK, the compressed format of disks of OVF packages.
+// name is the name of the written file. It returns the number of bytes of
+// the disk that hold data.
+func writeStreamOptimized(w io.Writer, disk io.ReaderAt, size int64, name, adapterType string) (int64, error) {
+	capacity := uint64((size + sectorSize - 1) / sectorSize)
+	grainBytes := int64(streamGrainSize * sectorSize)
+	grains := (capacity + streamGrainSize - 1) / streamGrainSize
+	numGTs := (grains + streamNumGTEsPerGT - 1) / streamNumGTEsPerGT
+
+	adapterType = streamAdapterType(adapterType)
+	cylinders := capacity / (255 * 63)
+	if cylinders > 65535 {
+		cylinders = 65535
+	}
+	descriptor := fmt.Sprintf(`# Disk DescriptorFile
+version=1
+encoding="UTF-8"
+CID=%08x
+parentCID=ffffffff
+createType="streamOptimized"
+
+# Extent description
+RW %d SPARSE "%s"
+
+# The Disk Data Base
+#DDB
+
+ddb.adapterType = "%s"
+ddb.geometry.cylinders = "%d"
+ddb.geometry.heads = "255"
+ddb.geometry.sectors = "63"
+ddb.virtualHWVersion = "4"
+`, rand.Uint32(), capacity, name, adapterType, cylinders)
+	descriptorSize := uint64((len(descriptor) + sectorSize - 1) / sectorSize)
+
+	header := sparseHeader{
+		MagicNumber:        sparseMagic,
+		Version:            3,
+		Flags:              sparseFlagValidNewLineTest | sparseFlagCompressed | sparseFlagMarkers,
+		Capacity:           capacity,
+		GrainSize:          streamGrainSize,
+		DescriptorOffset:   1,
+		DescriptorSize:     descriptorSize,
+		NumGTEsPerGT:       streamNumGTEsPerGT,
+		GDOffset:           sparseGDAtEnd,
+		OverHead:           1 + descriptorSize,
+		SingleEndLineChar:  '\n',
+		NonEndLineChar:     ' ',
+		DoubleEndLineChar1: '\r',
+		DoubleEndLineChar2: '\n',
+		CompressAlgorithm:  sparseCompressDeflate,
+	}
+
+	sw := &sectorWriter{w: w}
+	if err := binary.Write(sw, binary.LittleEndian, &header); err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(sw, descriptor); err != nil {
+		return 0, err
+	}
+	if err := sw.pad(); err != nil {
+		return 0, err
+	}
+
+	var populated int64
+	gd := make([]uint32, numGTs)
+	data := make([]byte, grainBytes)
+	var compressed bytes.Buffer
+	for i := uint64(0); i < numGTs; i++ {
+		gt := make([]uint32, streamNumGTEsPerGT)
+		allocated := false
+		for j := uint64(0); j < streamNumGTEsPerGT; j++ {
+			grain := i*streamNumGTEsPerGT + j
+			if grain >= grains {
+				break
+			}
+
+			n, err := disk.ReadAt(data, int64(grain)*grainBytes)
+			if err != nil && err != io.EOF {
+				return 0, err
+			}
+			zero(data[n:])
+			if isZero(data) {
+				continue
+			}
+			populated += int64(n)
+
+			compressed.Reset()
+			zw := zlib.NewWriter(&compressed)
+			if _, err := zw.Write(data); err != nil {
+				return 0, err
+			}
+			if err := zw.Close(); err != nil {
+				return 0, err
+			}
+
+			// grain marker: the LBA and the size of the compressed grain
+			gt[j] = uint32(sw.sector())
+			allocated = true
+			marker := make([]byte, 12)
+			binary.LittleEndian.PutUint64(marker[0:], grain*streamGrainSize)
+			binary.LittleEndian.PutUint32(marker[8:], uint32(compressed.Len()))
+			if _, err := sw.Write(marker); err != nil {
+				return 0, err
+			}
+			if _, err := sw.Write(compressed.Bytes()); err != nil {
+				return 0, err
+			}
+			if err := sw.pad(); err != nil {
+				return 0, err
+			}
+		}
+
+		if !allocated {
+			continue
+		}
+		if err := sw.writeMarker(streamNumGTEsPerGT*4/sectorSize, markerGT); err != nil {
+			return 0, err
+		}
+		gd[i] = uint32(sw.sector())
+		if err := binary.Write(sw, binary.LittleEndian, gt); err != nil {
+			return 0, err
+		}
+	}
+
+	gdSectors := (numGTs*4 + sectorSize - 1) / sectorSize
+	if err := sw.writeMarker(gdSectors, markerGD); err != nil {
+		return 0, err
+	}
+	header.GDOffset = sw.sector()
+	if err := binary.Write(sw, binary.LittleEndian, gd); err != nil {
+		return 0, err
+	}
+	if err := sw.pad(); err != nil {
+		return 0, err
+	}
+
+	if err := sw.writeMarker(1, markerFooter); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(sw, binary.LittleEndian, &header); err != nil {
+		return 0, err
+	}
+	if err := sw.writeMarker(0, markerEOS); err != nil {
+		return 0, err
+	}
+
+	return populated, nil
+}
@@ -0,0 +1,255 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSparseVMDK writes data as a monolithic sparse disk at path, with a
+// parent disk when parent is not empty. Only the grains that are not zero
+// are allocated.
+func writeSparseVMDK(t *testing.T, path string, data []byte, parent string) {
+	capacity := uint64(len(data) / sectorSize)
+	grains := (capacity + streamGrainSize - 1) / streamGrainSize
+	if grains > streamNumGTEsPerGT {
+		t.Fatalf("bad: the test disk needs more than one grain table")
+	}
+
+	parentCID := "ffffffff"
+	hint := ""
+	if parent != "" {
+		parentCID = "12345678"
+		hint = fmt.Sprintf("parentFileNameHint=%q\n", parent)
+	}
+	descriptor := fmt.Sprintf("# Disk DescriptorFile\nversion=1\nCID=12345678\nparentCID=%s\n"+
+		"createType=\"monolithicSparse\"\n%sRW %d SPARSE %q\n", parentCID, hint, capacity, filepath.Base(path))
+
+	// header, descriptor, grain directory, grain table, grains
+	header := sparseHeader{
+		MagicNumber:      sparseMagic,
+		Version:          1,
+		Flags:            sparseFlagValidNewLineTest,
+		Capacity:         capacity,
+		GrainSize:        streamGrainSize,
+		DescriptorOffset: 1,
+		DescriptorSize:   1,
+		NumGTEsPerGT:     streamNumGTEsPerGT,
+		GDOffset:         2,
+		OverHead:         7,
+	}
+	gt := make([]uint32, streamNumGTEsPerGT)
+	var grainData bytes.Buffer
+	grainBytes := streamGrainSize * sectorSize
+	for i := 0; i < int(grains); i++ {
+		grain := make([]byte, grainBytes)
+		copy(grain, data[i*grainBytes:])
+		if isZero(grain) {
+			continue
+		}
+		gt[i] = uint32(7 + grainData.Len()/sectorSize)
+		grainData.Write(grain)
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &header)
+	d := make([]byte, sectorSize)
+	copy(d, descriptor)
+	buf.Write(d)
+	gd := make([]uint32, sectorSize/4)
+	gd[0] = 3
+	binary.Write(&buf, binary.LittleEndian, gd)
+	binary.Write(&buf, binary.LittleEndian, gt)
+	buf.Write(grainData.Bytes())
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// testDiskData returns the content of a disk of size bytes, with data in
+// some of its grains only.
+func testDiskData(size int, seed int64) []byte {
+	data := make([]byte, size)
+	r := rand.New(rand.NewSource(seed))
+	grainBytes := streamGrainSize * sectorSize
+	for i := 0; i < size; i += 3 * grainBytes {
+		end := i + grainBytes/2
+		if end > size {
+			end = size
+		}
+		r.Read(data[i:end])
+	}
+	return data
+}
+
+func readDisk(t *testing.T, path string) []byte {
+	disk, err := openVMDK(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer disk.Close()
+
+	data := make([]byte, disk.Size())
+	if _, err := disk.ReadAt(data, 0); err != nil && err != io.EOF {
+		t.Fatalf("err: %s", err)
+	}
+	return data
+}
+
+func TestOpenVMDK_sparse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := testDiskData(10*streamGrainSize*sectorSize+3*sectorSize, 1)
+	path := filepath.Join(dir, "disk.vmdk")
+	writeSparseVMDK(t, path, data, "")
+
+	if read := readDisk(t, path); !bytes.Equal(read, data) {
+		t.Fatal("bad: the content of the disk differs")
+	}
+}
+
+func TestOpenVMDK_parent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	size := 8 * streamGrainSize * sectorSize
+	grainBytes := streamGrainSize * sectorSize
+	base := testDiskData(size, 1)
+	writeSparseVMDK(t, filepath.Join(dir, "base.vmdk"), base, "")
+
+	// the child only has its second grain
+	child := make([]byte, size)
+	for i := grainBytes; i < 2*grainBytes; i++ {
+		child[i] = 0xff
+	}
+	writeSparseVMDK(t, filepath.Join(dir, "child.vmdk"), child, "base.vmdk")
+
+	expected := append([]byte{}, base...)
+	copy(expected[grainBytes:2*grainBytes], child[grainBytes:])
+	if read := readDisk(t, filepath.Join(dir, "child.vmdk")); !bytes.Equal(read, expected) {
+		t.Fatal("bad: the content of the disk differs")
+	}
+}
+
+func TestOpenVMDK_flat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	first, second := testFlatExtents(t, "test-fixtures/export/disk.vmdk", dir)
+
+	expected := append(append(append([]byte{}, first...), make([]byte, 2048*sectorSize)...), second...)
+	if read := readDisk(t, filepath.Join(dir, "disk.vmdk")); !bytes.Equal(read, expected) {
+		t.Fatal("bad: the content of the disk differs")
+	}
+}
+
+// testFlatExtents copies the descriptor of the flat disk of the export
+// fixtures into dir, and writes its extents.
+func testFlatExtents(t *testing.T, descriptor, dir string) ([]byte, []byte) {
+	contents, err := ioutil.ReadFile(descriptor)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, filepath.Base(descriptor)), contents, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	first := testDiskData(4096*sectorSize, 2)
+	second := testDiskData(4096*sectorSize, 3)
+	if err := ioutil.WriteFile(filepath.Join(dir, "disk-f001.vmdk"), first, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "disk-f002.vmdk"), second, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return first, second
+}
+
+func TestStreamAdapterType(t *testing.T) {
+	cases := map[string]string{
+		"":           "lsilogic",
+		"ide":        "ide",
+		"buslogic":   "buslogic",
+		"lsilogic":   "lsilogic",
+		"lsisas1068": "lsilogic",
+		"pvscsi":     "lsilogic",
+		"legacyESX":  "lsilogic",
+	}
+	for adapterType, expected := range cases {
+		if actual := streamAdapterType(adapterType); actual != expected {
+			t.Fatalf("%s: bad: %s", adapterType, actual)
+		}
+	}
+}
+
+func TestWriteStreamOptimized(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := testDiskData(600*streamGrainSize*sectorSize+5*sectorSize, 4)
+	path := filepath.Join(dir, "stream.vmdk")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	populated, err := writeStreamOptimized(f, bytes.NewReader(data), int64(len(data)), "stream.vmdk", "pvscsi")
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// a third of the grains hold data
+	if expected := int64(200*streamGrainSize*sectorSize + 5*sectorSize); populated != expected {
+		t.Fatalf("bad: %d, expected %d", populated, expected)
+	}
+
+	var header sparseHeader
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, &header); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if header.Version != 3 || header.GDOffset != sparseGDAtEnd ||
+		header.Flags != sparseFlagValidNewLineTest|sparseFlagCompressed|sparseFlagMarkers {
+		t.Fatalf("bad: %#v", header)
+	}
+	if !bytes.Contains(raw, []byte(`createType="streamOptimized"`)) {
+		t.Fatal("bad: the descriptor is not embedded")
+	}
+	if !bytes.Contains(raw, []byte(`ddb.adapterType = "lsilogic"`)) {
+		t.Fatal("bad: the adapter type is not lsilogic")
+	}
+	if len(raw) >= len(data)/2 {
+		t.Fatalf("bad: the disk is not compressed: %d bytes", len(raw))
+	}
+	// the file ends with the end-of-stream marker
+	if !isZero(raw[len(raw)-sectorSize:]) {
+		t.Fatal("bad: no end-of-stream marker")
+	}
+
+	if read := readDisk(t, path); !bytes.Equal(read, data[:len(read)]) || len(read) != len(data) {
+		t.Fatal("bad: the content of the disk differs")
+	}
+}
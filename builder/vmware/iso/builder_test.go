@@ -241,6 +241,27 @@ func TestBuilderPrepare_Format(t *testing.T) {
 		t.Fatal("should have error")
 	}
 
+	// Good, local builds are exported without ovftool
+	delete(config, "format")
+	b = Builder{}
+	warns, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.Format != "" {
+		t.Fatalf("bad: %s", b.config.Format)
+	}
+
+	config["format"] = "ova"
+	b = Builder{}
+	warns, err = b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
 	goodFormats := []string{"ova", "ovf", "vmx"}
 
 	for _, format := range goodFormats {
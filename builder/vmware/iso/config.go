@@ -82,11 +82,14 @@ type Config struct {
 	//   VMware clients. For ESXi, refer to the proper ESXi documentation.
 	DiskTypeId string `mapstructure:"disk_type_id" required:"false"`
 	// Either "ovf", "ova" or "vmx", this specifies the output
-	// format of the exported virtual machine. This defaults to "ovf".
-	// Before using this option, you need to install ovftool. This option
-	// currently only works when option remote_type is set to "esx5".
-	// Since ovftool is only capable of password based authentication
-	// remote_password must be set when exporting the VM.
+	// format of the exported virtual machine. When remote_type is set to
+	// "esx5", the VM is exported with ovftool, which needs to be installed,
+	// and this defaults to "ovf". Since ovftool is only capable of password
+	// based authentication remote_password must be set when exporting the
+	// VM. Local builds are exported without ovftool when this is set to
+	// "ovf" or "ova": the disks are converted to stream optimized VMDKs and
+	// described by an OVF descriptor and its manifest, which replace the
+	// files of the VM in the output directory.
 	Format string `mapstructure:"format" required:"false"`
 	// The adapter type (or bus) that will be used
 	// by the cdrom device. This is chosen by default based on the disk adapter
@@ -130,6 +133,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 			Exclude: []string{
 				"boot_command",
 				"tools_upload_path",
+				"http_content",
 			},
 		},
 	}, raws...)
@@ -236,16 +240,13 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		}
 	}
 
-	if c.Format != "" {
-		if c.RemoteType != "esx5" {
-			errs = packer.MultiErrorAppend(errs,
-				fmt.Errorf("format is only valid when RemoteType=esx5"))
-		}
-	} else {
+	// Local builds are only exported when a format is set, without
+	// ovftool.
+	if c.Format == "" && c.RemoteType != "" {
 		c.Format = "ovf"
 	}
 
-	if !(c.Format == "ova" || c.Format == "ovf" || c.Format == "vmx") {
+	if c.Format != "" && !(c.Format == "ova" || c.Format == "ovf" || c.Format == "vmx") {
 		errs = packer.MultiErrorAppend(errs,
 			fmt.Errorf("format must be one of ova, ovf, or vmx"))
 	}
@@ -68,6 +68,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 			Exclude: []string{
 				"boot_command",
 				"tools_upload_path",
+				"http_content",
 			},
 		},
 	}, raws...)
@@ -122,16 +123,13 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		errs = packer.MultiErrorAppend(errs, err)
 	}
 
-	if c.Format != "" {
-		if c.RemoteType != "esx5" {
-			errs = packer.MultiErrorAppend(errs,
-				fmt.Errorf("format is only valid when remote_type=esx5"))
-		}
-	} else {
+	// Local builds are only exported when a format is set, without
+	// ovftool.
+	if c.Format == "" && c.RemoteType != "" {
 		c.Format = "ovf"
 	}
 
-	if !(c.Format == "ova" || c.Format == "ovf" || c.Format == "vmx") {
+	if c.Format != "" && !(c.Format == "ova" || c.Format == "ovf" || c.Format == "vmx") {
 		errs = packer.MultiErrorAppend(errs,
 			fmt.Errorf("format must be one of ova, ovf, or vmx"))
 	}
@@ -0,0 +1,376 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+	"github.com/hashicorp/packer/common/filelock"
+	"github.com/hashicorp/packer/packer"
+
+	"github.com/posener/complete"
+)
+
+type CacheCommand struct {
+	Meta
+}
+
+func (c *CacheCommand) Run(args []string) int {
+	if len(args) == 0 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		return c.runList(args[1:])
+	case "verify":
+		return c.runVerify(args[1:])
+	case "prune":
+		return c.runPrune(args[1:])
+	case "import":
+		return c.runImport(args[1:])
+	default:
+		c.Ui.Error(fmt.Sprintf("Unknown cache subcommand: %s\n", args[0]))
+		c.Ui.Error(c.Help())
+		return 1
+	}
+}
+
+func (c *CacheCommand) runList(args []string) int {
+	flags := c.Meta.FlagSet("cache list", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	entries, err := packer.CacheEntries()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error listing cache: %s", err))
+		return 1
+	}
+	if len(entries) == 0 {
+		c.Ui.Say("The cache is empty.")
+		return 0
+	}
+
+	for _, e := range entries {
+		source, checksum := "unknown", "none"
+		if e.Metadata != nil {
+			source = e.Metadata.Source
+			if e.Metadata.Checksum != "" {
+				checksum = e.Metadata.Checksum
+				if e.Metadata.ChecksumType != "" {
+					checksum = e.Metadata.ChecksumType + ":" + checksum
+				}
+			}
+		}
+		lastUsed := e.LastUsed().UTC().Format(time.RFC3339)
+
+		c.Ui.Machine("cache-entry", e.Name(), source, strconv.FormatInt(e.Info.Size(), 10), checksum, lastUsed)
+		c.Ui.Say(fmt.Sprintf("%s\n"+
+			"  source:    %s\n"+
+			"  size:      %s\n"+
+			"  checksum:  %s\n"+
+			"  last used: %s",
+			e.Name(), source, formatSize(e.Info.Size()), checksum, lastUsed))
+	}
+	return 0
+}
+
+func (c *CacheCommand) runVerify(args []string) int {
+	var flagRemove bool
+	flags := c.Meta.FlagSet("cache verify", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.BoolVar(&flagRemove, "remove", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	entries, err := packer.CacheEntries()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error listing cache: %s", err))
+		return 1
+	}
+
+	ret := 0
+	for _, e := range entries {
+		ok, err := e.Verify()
+		switch {
+		case err == packer.ErrCacheNoChecksum:
+			c.Ui.Say(fmt.Sprintf("%s: skipped, %s", e.Name(), err))
+		case err != nil:
+			c.Ui.Error(fmt.Sprintf("%s: error verifying: %s", e.Name(), err))
+			ret = 1
+		case ok:
+			c.Ui.Say(fmt.Sprintf("%s: OK", e.Name()))
+		default:
+			c.Ui.Error(fmt.Sprintf("%s: checksum mismatch", e.Name()))
+			ret = 1
+			if flagRemove {
+				c.removeEntry(e)
+			}
+		}
+	}
+	return ret
+}
+
+func (c *CacheCommand) runPrune(args []string) int {
+	var olderThan time.Duration
+	var flagDryRun bool
+	flags := c.Meta.FlagSet("cache prune", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.DurationVar(&olderThan, "older-than", 30*24*time.Hour, "")
+	flags.BoolVar(&flagDryRun, "dry-run", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	entries, err := packer.CacheEntries()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error listing cache: %s", err))
+		return 1
+	}
+
+	ret := 0
+	deadline := time.Now().Add(-olderThan)
+	for _, e := range entries {
+		if e.LastUsed().After(deadline) {
+			continue
+		}
+		if flagDryRun {
+			c.Ui.Say(fmt.Sprintf("Would remove %s", e.Name()))
+			continue
+		}
+		if !c.removeEntry(e) {
+			ret = 1
+		}
+	}
+	return ret
+}
+
+// removeEntry removes a cached file while holding its lock, the same lock
+// downloads hold while they write the file. Files locked by a concurrent
+// build are left alone. It returns false when the file could not be removed.
+func (c *CacheCommand) removeEntry(e *packer.CacheEntry) bool {
+	lock := filelock.New(e.Path + packer.CacheLockSuffix)
+	locked, err := lock.TryLock()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to lock %s: %s", e.Path, err))
+		return false
+	}
+	if !locked {
+		c.Ui.Error(fmt.Sprintf("Skipping %s: in use by another build", e.Name()))
+		return false
+	}
+	defer lock.Unlock()
+
+	if err := e.Remove(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to remove %s: %s", e.Path, err))
+		return false
+	}
+	c.Ui.Say(fmt.Sprintf("Removed %s", e.Name()))
+	return true
+}
+
+func (c *CacheCommand) runImport(args []string) int {
+	var flagChecksum, flagExtension, flagSource string
+	flags := c.Meta.FlagSet("cache import", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.StringVar(&flagChecksum, "checksum", "", "")
+	flags.StringVar(&flagExtension, "extension", "", "")
+	flags.StringVar(&flagSource, "source", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		return 1
+	}
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error importing %s: %s", args[0], err))
+		return 1
+	}
+
+	var checksumType, checksum string
+	if flagChecksum != "" {
+		parts := strings.SplitN(flagChecksum, ":", 2)
+		if len(parts) != 2 {
+			c.Ui.Error("The checksum must be of the form TYPE:VALUE, for example sha256:abcd...")
+			return 1
+		}
+		checksumType, checksum = strings.ToLower(parts[0]), parts[1]
+	}
+	if flagSource == "" {
+		u, err := urlhelper.Parse(path)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error importing %s: %s", path, err))
+			return 1
+		}
+		flagSource = u.String()
+	}
+	if flagExtension == "" {
+		flagExtension = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	// Check the file before it gets in the cache, so that a bad file
+	// doesn't get picked up by a build.
+	if checksum != "" {
+		e := &packer.CacheEntry{
+			Path: path,
+			Metadata: &packer.CacheMetadata{
+				Checksum:     checksum,
+				ChecksumType: checksumType,
+			},
+		}
+		ok, err := e.Verify()
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error verifying %s: %s", path, err))
+			return 1
+		}
+		if !ok {
+			c.Ui.Error(fmt.Sprintf("Checksum of %s does not match %s", path, flagChecksum))
+			return 1
+		}
+	}
+
+	target, err := packer.CachePath(packer.CacheKey(checksum, flagSource, flagExtension))
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error importing %s: %s", path, err))
+		return 1
+	}
+
+	// Hold the lock that downloads hold while they write the file
+	lock := filelock.New(target + packer.CacheLockSuffix)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := copyFile(path, target); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error importing %s: %s", path, err))
+		return 1
+	}
+	err = packer.TouchCacheMetadata(target, packer.CacheMetadata{
+		Source:       flagSource,
+		Checksum:     checksum,
+		ChecksumType: checksumType,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error writing cache metadata: %s", err))
+		return 1
+	}
+
+	c.Ui.Say(fmt.Sprintf("%s => %s", path, target))
+	return 0
+}
+
+// copyFile copies src to dst, going through the partial file of dst so
+// that dst is never seen partially written, and the copy is not listed as a
+// cached file.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// The state of an interrupted download of dst does not apply anymore
+	os.Remove(dst + packer.CachePartialSuffix + ".json")
+
+	tmp := dst + packer.CachePartialSuffix
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// formatSize returns a human readable representation of size.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func (*CacheCommand) Help() string {
+	helpText := `
+Usage: packer cache SUBCOMMAND [options] [args]
+
+  Manages the files downloaded by Packer into the cache directory. The
+  cache directory is "packer_cache" unless PACKER_CACHE_DIR is set.
+
+Subcommands:
+
+  list                       Lists the cached files along with their source
+                             URL, size, checksum and last use.
+  verify                     Recomputes the checksum of every cached file and
+                             reports files that do not match.
+  prune                      Removes cached files that have not been used for
+                             a while.
+  import FILE                Copies a local file into the cache, under the name
+                             a build with the same checksum would use.
+
+Verify options:
+
+  -remove                    Removes the files that fail verification.
+
+Prune options:
+
+  -older-than=DURATION       Only removes files not used since DURATION, for
+                             example 168h. Defaults to 720h (30 days).
+  -dry-run                   Only prints the files that would be removed.
+
+Import options:
+
+  -checksum=TYPE:VALUE       The checksum of the file. The file is verified
+                             before being imported.
+  -source=URL                The URL the file originally comes from. This is
+                             used to name the file when there is no checksum.
+  -extension=EXT             The extension of the cached file, for example
+                             iso. Defaults to the extension of FILE.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*CacheCommand) Synopsis() string {
+	return "manage the cache of downloaded files"
+}
+
+func (*CacheCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictSet("list", "verify", "prune", "import")
+}
+
+func (*CacheCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-remove":     complete.PredictNothing,
+		"-older-than": complete.PredictNothing,
+		"-dry-run":    complete.PredictNothing,
+		"-checksum":   complete.PredictNothing,
+		"-source":     complete.PredictNothing,
+		"-extension":  complete.PredictNothing,
+	}
+}
@@ -0,0 +1,219 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/common/filelock"
+	"github.com/hashicorp/packer/packer"
+)
+
+func testCacheCommand(t *testing.T) (*CacheCommand, string, func()) {
+	dir, err := ioutil.TempDir("", "packer-cache")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	old := os.Getenv("PACKER_CACHE_DIR")
+	os.Setenv("PACKER_CACHE_DIR", dir)
+
+	c := &CacheCommand{
+		Meta: testMeta(t),
+	}
+	return c, dir, func() {
+		os.Setenv("PACKER_CACHE_DIR", old)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCacheCommand_importAndList(t *testing.T) {
+	c, dir, cleanup := testCacheCommand(t)
+	defer cleanup()
+
+	src := filepath.Join(dir, "..", filepath.Base(dir)+"-src.iso")
+	if err := ioutil.WriteFile(src, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(src)
+
+	checksum := "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	args := []string{
+		"import",
+		"-checksum=sha1:" + checksum,
+		"-source=http://example.com/hello.iso",
+		src,
+	}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	// the file must be where StepDownload would look for it
+	target := filepath.Join(dir, packer.CacheKey(checksum, "", "iso"))
+	b, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("bad content: %s", b)
+	}
+
+	if code := c.Run([]string{"list"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	out, _ := outputCommand(t, c.Meta)
+	if !strings.Contains(out, "http://example.com/hello.iso") {
+		t.Fatalf("source should be listed: %s", out)
+	}
+	if !strings.Contains(out, "sha1:"+checksum) {
+		t.Fatalf("checksum should be listed: %s", out)
+	}
+
+	if code := c.Run([]string{"verify"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+}
+
+func TestCacheCommand_importUppercaseChecksum(t *testing.T) {
+	c, dir, cleanup := testCacheCommand(t)
+	defer cleanup()
+
+	src := filepath.Join(dir, "..", filepath.Base(dir)+"-src.iso")
+	if err := ioutil.WriteFile(src, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(src)
+
+	checksum := "AAF4C61DDCC5E8A2DABEDE0F3B482CD9AEA9434D"
+	if code := c.Run([]string{"import", "-checksum=SHA1:" + checksum, src}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	// builds find the file whatever the case of their checksum
+	for _, cs := range []string{checksum, strings.ToLower(checksum)} {
+		if _, err := os.Stat(filepath.Join(dir, packer.CacheKey(cs, "", "iso"))); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// only the file is listed, not the copy it went through
+	entries, err := packer.CacheEntries()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("bad: %d entries", len(entries))
+	}
+	if _, err := os.Stat(entries[0].Path + packer.CachePartialSuffix); !os.IsNotExist(err) {
+		t.Fatalf("the partial file should be removed: %s", err)
+	}
+}
+
+func TestCacheCommand_importBadChecksum(t *testing.T) {
+	c, dir, cleanup := testCacheCommand(t)
+	defer cleanup()
+
+	src := filepath.Join(dir, "..", filepath.Base(dir)+"-src.iso")
+	if err := ioutil.WriteFile(src, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(src)
+
+	args := []string{
+		"import",
+		"-checksum=sha1:0000000000000000000000000000000000000000",
+		src,
+	}
+	if code := c.Run(args); code != 1 {
+		fatalCommand(t, c.Meta)
+	}
+	entries, err := packer.CacheEntries()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("nothing should be imported, got %d entries", len(entries))
+	}
+}
+
+func TestCacheCommand_verify(t *testing.T) {
+	c, dir, cleanup := testCacheCommand(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "corrupted.iso")
+	if err := ioutil.WriteFile(path, []byte("corrupted"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := packer.TouchCacheMetadata(path, packer.CacheMetadata{
+		Checksum:     "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		ChecksumType: "sha1",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if code := c.Run([]string{"verify", "-remove"}); code != 1 {
+		fatalCommand(t, c.Meta)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("corrupted file should be removed")
+	}
+}
+
+func TestCacheCommand_prune(t *testing.T) {
+	c, dir, cleanup := testCacheCommand(t)
+	defer cleanup()
+
+	for _, name := range []string{"old.iso", "new.iso"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := packer.TouchCacheMetadata(path, packer.CacheMetadata{Source: name}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	old := filepath.Join(dir, "old.iso")
+	m, err := packer.ReadCacheMetadata(old)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	m.LastUsed = time.Now().Add(-48 * time.Hour)
+	if err := packer.WriteCacheMetadata(old, m); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if code := c.Run([]string{"prune"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatalf("old.iso should be kept by default: %s", err)
+	}
+
+	lock := filelock.New(old + packer.CacheLockSuffix)
+	if locked, err := lock.TryLock(); err != nil || !locked {
+		t.Fatalf("bad: %t, %s", locked, err)
+	}
+	if code := c.Run([]string{"prune", "-older-than=24h"}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatalf("locked old.iso should be kept: %s", err)
+	}
+	lock.Unlock()
+
+	if code := c.Run([]string{"prune", "-older-than=24h"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("old.iso should be pruned")
+	}
+	if _, err := os.Stat(old + packer.CacheMetadataSuffix); !os.IsNotExist(err) {
+		t.Fatalf("old.iso metadata should be pruned")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.iso")); err != nil {
+		t.Fatalf("new.iso should be kept: %s", err)
+	}
+}
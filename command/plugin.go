@@ -20,6 +20,7 @@ import (
 	amazonebsvolumebuilder "github.com/hashicorp/packer/builder/amazon/ebsvolume"
 	amazoninstancebuilder "github.com/hashicorp/packer/builder/amazon/instance"
 	azurearmbuilder "github.com/hashicorp/packer/builder/azure/arm"
+	chrootbuilder "github.com/hashicorp/packer/builder/chroot"
 	cloudstackbuilder "github.com/hashicorp/packer/builder/cloudstack"
 	digitaloceanbuilder "github.com/hashicorp/packer/builder/digitalocean"
 	dockerbuilder "github.com/hashicorp/packer/builder/docker"
@@ -30,6 +31,7 @@ import (
 	hypervisobuilder "github.com/hashicorp/packer/builder/hyperv/iso"
 	hypervvmcxbuilder "github.com/hashicorp/packer/builder/hyperv/vmcx"
 	jdcloudbuilder "github.com/hashicorp/packer/builder/jdcloud"
+	libvirtbuilder "github.com/hashicorp/packer/builder/libvirt"
 	linodebuilder "github.com/hashicorp/packer/builder/linode"
 	lxcbuilder "github.com/hashicorp/packer/builder/lxc"
 	lxdbuilder "github.com/hashicorp/packer/builder/lxd"
@@ -45,6 +47,7 @@ import (
 	oscchrootbuilder "github.com/hashicorp/packer/builder/osc/chroot"
 	parallelsisobuilder "github.com/hashicorp/packer/builder/parallels/iso"
 	parallelspvmbuilder "github.com/hashicorp/packer/builder/parallels/pvm"
+	podmanbuilder "github.com/hashicorp/packer/builder/podman"
 	profitbricksbuilder "github.com/hashicorp/packer/builder/profitbricks"
 	proxmoxbuilder "github.com/hashicorp/packer/builder/proxmox"
 	qemubuilder "github.com/hashicorp/packer/builder/qemu"
@@ -60,6 +63,7 @@ import (
 	vmwarevmxbuilder "github.com/hashicorp/packer/builder/vmware/vmx"
 	yandexbuilder "github.com/hashicorp/packer/builder/yandex"
 	alicloudimportpostprocessor "github.com/hashicorp/packer/post-processor/alicloud-import"
+	amazonamimanagementpostprocessor "github.com/hashicorp/packer/post-processor/amazon-ami-management"
 	amazonimportpostprocessor "github.com/hashicorp/packer/post-processor/amazon-import"
 	artificepostprocessor "github.com/hashicorp/packer/post-processor/artifice"
 	checksumpostprocessor "github.com/hashicorp/packer/post-processor/checksum"
@@ -73,6 +77,7 @@ import (
 	googlecomputeexportpostprocessor "github.com/hashicorp/packer/post-processor/googlecompute-export"
 	googlecomputeimportpostprocessor "github.com/hashicorp/packer/post-processor/googlecompute-import"
 	manifestpostprocessor "github.com/hashicorp/packer/post-processor/manifest"
+	openstackimportpostprocessor "github.com/hashicorp/packer/post-processor/openstack-import"
 	shelllocalpostprocessor "github.com/hashicorp/packer/post-processor/shell-local"
 	vagrantpostprocessor "github.com/hashicorp/packer/post-processor/vagrant"
 	vagrantcloudpostprocessor "github.com/hashicorp/packer/post-processor/vagrant-cloud"
@@ -109,6 +114,7 @@ var Builders = map[string]packer.Builder{
 	"amazon-ebsvolume":    new(amazonebsvolumebuilder.Builder),
 	"amazon-instance":     new(amazoninstancebuilder.Builder),
 	"azure-arm":           new(azurearmbuilder.Builder),
+	"chroot":              new(chrootbuilder.Builder),
 	"cloudstack":          new(cloudstackbuilder.Builder),
 	"digitalocean":        new(digitaloceanbuilder.Builder),
 	"docker":              new(dockerbuilder.Builder),
@@ -119,6 +125,7 @@ var Builders = map[string]packer.Builder{
 	"hyperv-iso":          new(hypervisobuilder.Builder),
 	"hyperv-vmcx":         new(hypervvmcxbuilder.Builder),
 	"jdcloud":             new(jdcloudbuilder.Builder),
+	"libvirt":             new(libvirtbuilder.Builder),
 	"linode":              new(linodebuilder.Builder),
 	"lxc":                 new(lxcbuilder.Builder),
 	"lxd":                 new(lxdbuilder.Builder),
@@ -134,6 +141,7 @@ var Builders = map[string]packer.Builder{
 	"osc-chroot":          new(oscchrootbuilder.Builder),
 	"parallels-iso":       new(parallelsisobuilder.Builder),
 	"parallels-pvm":       new(parallelspvmbuilder.Builder),
+	"podman":              new(podmanbuilder.Builder),
 	"profitbricks":        new(profitbricksbuilder.Builder),
 	"proxmox":             new(proxmoxbuilder.Builder),
 	"qemu":                new(qemubuilder.Builder),
@@ -171,25 +179,27 @@ var Provisioners = map[string]packer.Provisioner{
 }
 
 var PostProcessors = map[string]packer.PostProcessor{
-	"alicloud-import":      new(alicloudimportpostprocessor.PostProcessor),
-	"amazon-import":        new(amazonimportpostprocessor.PostProcessor),
-	"artifice":             new(artificepostprocessor.PostProcessor),
-	"checksum":             new(checksumpostprocessor.PostProcessor),
-	"compress":             new(compresspostprocessor.PostProcessor),
-	"digitalocean-import":  new(digitaloceanimportpostprocessor.PostProcessor),
-	"docker-import":        new(dockerimportpostprocessor.PostProcessor),
-	"docker-push":          new(dockerpushpostprocessor.PostProcessor),
-	"docker-save":          new(dockersavepostprocessor.PostProcessor),
-	"docker-tag":           new(dockertagpostprocessor.PostProcessor),
-	"exoscale-import":      new(exoscaleimportpostprocessor.PostProcessor),
-	"googlecompute-export": new(googlecomputeexportpostprocessor.PostProcessor),
-	"googlecompute-import": new(googlecomputeimportpostprocessor.PostProcessor),
-	"manifest":             new(manifestpostprocessor.PostProcessor),
-	"shell-local":          new(shelllocalpostprocessor.PostProcessor),
-	"vagrant":              new(vagrantpostprocessor.PostProcessor),
-	"vagrant-cloud":        new(vagrantcloudpostprocessor.PostProcessor),
-	"vsphere":              new(vspherepostprocessor.PostProcessor),
-	"vsphere-template":     new(vspheretemplatepostprocessor.PostProcessor),
+	"alicloud-import":       new(alicloudimportpostprocessor.PostProcessor),
+	"amazon-ami-management": new(amazonamimanagementpostprocessor.PostProcessor),
+	"amazon-import":         new(amazonimportpostprocessor.PostProcessor),
+	"artifice":              new(artificepostprocessor.PostProcessor),
+	"checksum":              new(checksumpostprocessor.PostProcessor),
+	"compress":              new(compresspostprocessor.PostProcessor),
+	"digitalocean-import":   new(digitaloceanimportpostprocessor.PostProcessor),
+	"docker-import":         new(dockerimportpostprocessor.PostProcessor),
+	"docker-push":           new(dockerpushpostprocessor.PostProcessor),
+	"docker-save":           new(dockersavepostprocessor.PostProcessor),
+	"docker-tag":            new(dockertagpostprocessor.PostProcessor),
+	"exoscale-import":       new(exoscaleimportpostprocessor.PostProcessor),
+	"googlecompute-export":  new(googlecomputeexportpostprocessor.PostProcessor),
+	"googlecompute-import":  new(googlecomputeimportpostprocessor.PostProcessor),
+	"manifest":              new(manifestpostprocessor.PostProcessor),
+	"openstack-import":      new(openstackimportpostprocessor.PostProcessor),
+	"shell-local":           new(shelllocalpostprocessor.PostProcessor),
+	"vagrant":               new(vagrantpostprocessor.PostProcessor),
+	"vagrant-cloud":         new(vagrantcloudpostprocessor.PostProcessor),
+	"vsphere":               new(vspherepostprocessor.PostProcessor),
+	"vsphere-template":      new(vspheretemplatepostprocessor.PostProcessor),
 }
 
 var pluginRegexp = regexp.MustCompile("packer-(builder|post-processor|provisioner)-(.+)")
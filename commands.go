@@ -22,6 +22,12 @@ func init() {
 				Meta: *CommandMeta,
 			}, nil
 		},
+		"cache": func() (cli.Command, error) {
+			return &command.CacheCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"console": func() (cli.Command, error) {
 			return &command.ConsoleCommand{
 				Meta: *CommandMeta,
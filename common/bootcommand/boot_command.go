@@ -62,18 +62,22 @@ var g = &grammar{
 							alternatives: []interface{}{
 								&ruleRefExpr{
 									pos:  position{line: 10, col: 13, offset: 87},
+									name: "WaitForHTTPCallback",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 10, col: 35, offset: 109},
 									name: "Wait",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 20, offset: 94},
+									pos:  position{line: 10, col: 42, offset: 116},
 									name: "CharToggle",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 33, offset: 107},
+									pos:  position{line: 10, col: 55, offset: 129},
 									name: "Special",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 43, offset: 117},
+									pos:  position{line: 10, col: 65, offset: 139},
 									name: "Literal",
 								},
 							},
@@ -84,36 +88,37 @@ var g = &grammar{
 		},
 		{
 			name: "Wait",
-			pos:  position{line: 14, col: 1, offset: 150},
+			pos:  position{line: 14, col: 1, offset: 172},
 			expr: &actionExpr{
-				pos: position{line: 14, col: 8, offset: 157},
+				pos: position{line: 14, col: 8, offset: 179},
 				run: (*parser).callonWait1,
 				expr: &seqExpr{
-					pos: position{line: 14, col: 8, offset: 157},
+					pos: position{line: 14, col: 8, offset: 179},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 14, col: 8, offset: 157},
+							pos:  position{line: 14, col: 8, offset: 179},
 							name: "ExprStart",
 						},
 						&litMatcher{
-							pos:        position{line: 14, col: 18, offset: 167},
+							pos:        position{line: 14, col: 18, offset: 189},
 							val:        "wait",
 							ignoreCase: false,
+							want:       "\"wait\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 14, col: 25, offset: 174},
+							pos:   position{line: 14, col: 25, offset: 196},
 							label: "duration",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 14, col: 34, offset: 183},
+								pos: position{line: 14, col: 34, offset: 205},
 								expr: &choiceExpr{
-									pos: position{line: 14, col: 36, offset: 185},
+									pos: position{line: 14, col: 36, offset: 207},
 									alternatives: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 14, col: 36, offset: 185},
+											pos:  position{line: 14, col: 36, offset: 207},
 											name: "Duration",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 14, col: 47, offset: 196},
+											pos:  position{line: 14, col: 47, offset: 218},
 											name: "Integer",
 										},
 									},
@@ -121,7 +126,45 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 14, col: 58, offset: 207},
+							pos:  position{line: 14, col: 58, offset: 229},
+							name: "ExprEnd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "WaitForHTTPCallback",
+			pos:  position{line: 27, col: 1, offset: 475},
+			expr: &actionExpr{
+				pos: position{line: 27, col: 23, offset: 497},
+				run: (*parser).callonWaitForHTTPCallback1,
+				expr: &seqExpr{
+					pos: position{line: 27, col: 23, offset: 497},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 27, col: 23, offset: 497},
+							name: "ExprStart",
+						},
+						&litMatcher{
+							pos:        position{line: 27, col: 33, offset: 507},
+							val:        "waitForHTTPCallback",
+							ignoreCase: false,
+							want:       "\"waitForHTTPCallback\"",
+						},
+						&labeledExpr{
+							pos:   position{line: 27, col: 55, offset: 529},
+							label: "timeout",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 27, col: 63, offset: 537},
+								expr: &ruleRefExpr{
+									pos:  position{line: 27, col: 65, offset: 539},
+									name: "Duration",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 27, col: 77, offset: 551},
 							name: "ExprEnd",
 						},
 					},
@@ -130,44 +173,44 @@ var g = &grammar{
 		},
 		{
 			name: "CharToggle",
-			pos:  position{line: 27, col: 1, offset: 453},
+			pos:  position{line: 35, col: 1, offset: 704},
 			expr: &actionExpr{
-				pos: position{line: 27, col: 14, offset: 466},
+				pos: position{line: 35, col: 14, offset: 717},
 				run: (*parser).callonCharToggle1,
 				expr: &seqExpr{
-					pos: position{line: 27, col: 14, offset: 466},
+					pos: position{line: 35, col: 14, offset: 717},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 27, col: 14, offset: 466},
+							pos:  position{line: 35, col: 14, offset: 717},
 							name: "ExprStart",
 						},
 						&labeledExpr{
-							pos:   position{line: 27, col: 24, offset: 476},
+							pos:   position{line: 35, col: 24, offset: 727},
 							label: "lit",
 							expr: &ruleRefExpr{
-								pos:  position{line: 27, col: 29, offset: 481},
+								pos:  position{line: 35, col: 29, offset: 732},
 								name: "Literal",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 27, col: 38, offset: 490},
+							pos:   position{line: 35, col: 38, offset: 741},
 							label: "t",
 							expr: &choiceExpr{
-								pos: position{line: 27, col: 41, offset: 493},
+								pos: position{line: 35, col: 41, offset: 744},
 								alternatives: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 27, col: 41, offset: 493},
+										pos:  position{line: 35, col: 41, offset: 744},
 										name: "On",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 27, col: 46, offset: 498},
+										pos:  position{line: 35, col: 46, offset: 749},
 										name: "Off",
 									},
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 27, col: 51, offset: 503},
+							pos:  position{line: 35, col: 51, offset: 754},
 							name: "ExprEnd",
 						},
 					},
@@ -176,39 +219,39 @@ var g = &grammar{
 		},
 		{
 			name: "Special",
-			pos:  position{line: 31, col: 1, offset: 574},
+			pos:  position{line: 39, col: 1, offset: 825},
 			expr: &actionExpr{
-				pos: position{line: 31, col: 11, offset: 584},
+				pos: position{line: 39, col: 11, offset: 835},
 				run: (*parser).callonSpecial1,
 				expr: &seqExpr{
-					pos: position{line: 31, col: 11, offset: 584},
+					pos: position{line: 39, col: 11, offset: 835},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 31, col: 11, offset: 584},
+							pos:  position{line: 39, col: 11, offset: 835},
 							name: "ExprStart",
 						},
 						&labeledExpr{
-							pos:   position{line: 31, col: 21, offset: 594},
+							pos:   position{line: 39, col: 21, offset: 845},
 							label: "s",
 							expr: &ruleRefExpr{
-								pos:  position{line: 31, col: 24, offset: 597},
+								pos:  position{line: 39, col: 24, offset: 848},
 								name: "SpecialKey",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 31, col: 36, offset: 609},
+							pos:   position{line: 39, col: 36, offset: 860},
 							label: "t",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 31, col: 38, offset: 611},
+								pos: position{line: 39, col: 38, offset: 862},
 								expr: &choiceExpr{
-									pos: position{line: 31, col: 39, offset: 612},
+									pos: position{line: 39, col: 39, offset: 863},
 									alternatives: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 31, col: 39, offset: 612},
+											pos:  position{line: 39, col: 39, offset: 863},
 											name: "On",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 31, col: 44, offset: 617},
+											pos:  position{line: 39, col: 44, offset: 868},
 											name: "Off",
 										},
 									},
@@ -216,7 +259,7 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 31, col: 50, offset: 623},
+							pos:  position{line: 39, col: 50, offset: 874},
 							name: "ExprEnd",
 						},
 					},
@@ -225,39 +268,41 @@ var g = &grammar{
 		},
 		{
 			name: "Number",
-			pos:  position{line: 39, col: 1, offset: 810},
+			pos:  position{line: 47, col: 1, offset: 1061},
 			expr: &actionExpr{
-				pos: position{line: 39, col: 10, offset: 819},
+				pos: position{line: 47, col: 10, offset: 1070},
 				run: (*parser).callonNumber1,
 				expr: &seqExpr{
-					pos: position{line: 39, col: 10, offset: 819},
+					pos: position{line: 47, col: 10, offset: 1070},
 					exprs: []interface{}{
 						&zeroOrOneExpr{
-							pos: position{line: 39, col: 10, offset: 819},
+							pos: position{line: 47, col: 10, offset: 1070},
 							expr: &litMatcher{
-								pos:        position{line: 39, col: 10, offset: 819},
+								pos:        position{line: 47, col: 10, offset: 1070},
 								val:        "-",
 								ignoreCase: false,
+								want:       "\"-\"",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 39, col: 15, offset: 824},
+							pos:  position{line: 47, col: 15, offset: 1075},
 							name: "Integer",
 						},
 						&zeroOrOneExpr{
-							pos: position{line: 39, col: 23, offset: 832},
+							pos: position{line: 47, col: 23, offset: 1083},
 							expr: &seqExpr{
-								pos: position{line: 39, col: 25, offset: 834},
+								pos: position{line: 47, col: 25, offset: 1085},
 								exprs: []interface{}{
 									&litMatcher{
-										pos:        position{line: 39, col: 25, offset: 834},
+										pos:        position{line: 47, col: 25, offset: 1085},
 										val:        ".",
 										ignoreCase: false,
+										want:       "\".\"",
 									},
 									&oneOrMoreExpr{
-										pos: position{line: 39, col: 29, offset: 838},
+										pos: position{line: 47, col: 29, offset: 1089},
 										expr: &ruleRefExpr{
-											pos:  position{line: 39, col: 29, offset: 838},
+											pos:  position{line: 47, col: 29, offset: 1089},
 											name: "Digit",
 										},
 									},
@@ -270,29 +315,30 @@ var g = &grammar{
 		},
 		{
 			name: "Integer",
-			pos:  position{line: 43, col: 1, offset: 884},
+			pos:  position{line: 51, col: 1, offset: 1135},
 			expr: &choiceExpr{
-				pos: position{line: 43, col: 11, offset: 894},
+				pos: position{line: 51, col: 11, offset: 1145},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 43, col: 11, offset: 894},
+						pos:        position{line: 51, col: 11, offset: 1145},
 						val:        "0",
 						ignoreCase: false,
+						want:       "\"0\"",
 					},
 					&actionExpr{
-						pos: position{line: 43, col: 17, offset: 900},
+						pos: position{line: 51, col: 17, offset: 1151},
 						run: (*parser).callonInteger3,
 						expr: &seqExpr{
-							pos: position{line: 43, col: 17, offset: 900},
+							pos: position{line: 51, col: 17, offset: 1151},
 							exprs: []interface{}{
 								&ruleRefExpr{
-									pos:  position{line: 43, col: 17, offset: 900},
+									pos:  position{line: 51, col: 17, offset: 1151},
 									name: "NonZeroDigit",
 								},
 								&zeroOrMoreExpr{
-									pos: position{line: 43, col: 30, offset: 913},
+									pos: position{line: 51, col: 30, offset: 1164},
 									expr: &ruleRefExpr{
-										pos:  position{line: 43, col: 30, offset: 913},
+										pos:  position{line: 51, col: 30, offset: 1164},
 										name: "Digit",
 									},
 								},
@@ -304,21 +350,21 @@ var g = &grammar{
 		},
 		{
 			name: "Duration",
-			pos:  position{line: 47, col: 1, offset: 977},
+			pos:  position{line: 55, col: 1, offset: 1228},
 			expr: &actionExpr{
-				pos: position{line: 47, col: 12, offset: 988},
+				pos: position{line: 55, col: 12, offset: 1239},
 				run: (*parser).callonDuration1,
 				expr: &oneOrMoreExpr{
-					pos: position{line: 47, col: 12, offset: 988},
+					pos: position{line: 55, col: 12, offset: 1239},
 					expr: &seqExpr{
-						pos: position{line: 47, col: 14, offset: 990},
+						pos: position{line: 55, col: 14, offset: 1241},
 						exprs: []interface{}{
 							&ruleRefExpr{
-								pos:  position{line: 47, col: 14, offset: 990},
+								pos:  position{line: 55, col: 14, offset: 1241},
 								name: "Number",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 47, col: 21, offset: 997},
+								pos:  position{line: 55, col: 21, offset: 1248},
 								name: "TimeUnit",
 							},
 						},
@@ -328,253 +374,293 @@ var g = &grammar{
 		},
 		{
 			name: "On",
-			pos:  position{line: 51, col: 1, offset: 1060},
+			pos:  position{line: 59, col: 1, offset: 1311},
 			expr: &actionExpr{
-				pos: position{line: 51, col: 6, offset: 1065},
+				pos: position{line: 59, col: 6, offset: 1316},
 				run: (*parser).callonOn1,
 				expr: &litMatcher{
-					pos:        position{line: 51, col: 6, offset: 1065},
+					pos:        position{line: 59, col: 6, offset: 1316},
 					val:        "on",
 					ignoreCase: true,
+					want:       "\"on\"i",
 				},
 			},
 		},
 		{
 			name: "Off",
-			pos:  position{line: 55, col: 1, offset: 1098},
+			pos:  position{line: 63, col: 1, offset: 1349},
 			expr: &actionExpr{
-				pos: position{line: 55, col: 7, offset: 1104},
+				pos: position{line: 63, col: 7, offset: 1355},
 				run: (*parser).callonOff1,
 				expr: &litMatcher{
-					pos:        position{line: 55, col: 7, offset: 1104},
+					pos:        position{line: 63, col: 7, offset: 1355},
 					val:        "off",
 					ignoreCase: true,
+					want:       "\"off\"i",
 				},
 			},
 		},
 		{
 			name: "Literal",
-			pos:  position{line: 59, col: 1, offset: 1139},
+			pos:  position{line: 67, col: 1, offset: 1390},
 			expr: &actionExpr{
-				pos: position{line: 59, col: 11, offset: 1149},
+				pos: position{line: 67, col: 11, offset: 1400},
 				run: (*parser).callonLiteral1,
 				expr: &anyMatcher{
-					line: 59, col: 11, offset: 1149,
+					line: 67, col: 11, offset: 1400,
 				},
 			},
 		},
 		{
 			name: "ExprEnd",
-			pos:  position{line: 64, col: 1, offset: 1230},
+			pos:  position{line: 72, col: 1, offset: 1481},
 			expr: &litMatcher{
-				pos:        position{line: 64, col: 11, offset: 1240},
+				pos:        position{line: 72, col: 11, offset: 1491},
 				val:        ">",
 				ignoreCase: false,
+				want:       "\">\"",
 			},
 		},
 		{
 			name: "ExprStart",
-			pos:  position{line: 65, col: 1, offset: 1244},
+			pos:  position{line: 73, col: 1, offset: 1495},
 			expr: &litMatcher{
-				pos:        position{line: 65, col: 13, offset: 1256},
+				pos:        position{line: 73, col: 13, offset: 1507},
 				val:        "<",
 				ignoreCase: false,
+				want:       "\"<\"",
 			},
 		},
 		{
 			name: "SpecialKey",
-			pos:  position{line: 66, col: 1, offset: 1260},
+			pos:  position{line: 74, col: 1, offset: 1511},
 			expr: &choiceExpr{
-				pos: position{line: 66, col: 14, offset: 1273},
+				pos: position{line: 74, col: 14, offset: 1524},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 66, col: 14, offset: 1273},
+						pos:        position{line: 74, col: 14, offset: 1524},
 						val:        "bs",
 						ignoreCase: true,
+						want:       "\"bs\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 22, offset: 1281},
+						pos:        position{line: 74, col: 22, offset: 1532},
 						val:        "del",
 						ignoreCase: true,
+						want:       "\"del\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 31, offset: 1290},
+						pos:        position{line: 74, col: 31, offset: 1541},
 						val:        "enter",
 						ignoreCase: true,
+						want:       "\"enter\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 42, offset: 1301},
+						pos:        position{line: 74, col: 42, offset: 1552},
 						val:        "esc",
 						ignoreCase: true,
+						want:       "\"esc\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 51, offset: 1310},
+						pos:        position{line: 74, col: 51, offset: 1561},
 						val:        "f10",
 						ignoreCase: true,
+						want:       "\"f10\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 60, offset: 1319},
+						pos:        position{line: 74, col: 60, offset: 1570},
 						val:        "f11",
 						ignoreCase: true,
+						want:       "\"f11\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 69, offset: 1328},
+						pos:        position{line: 74, col: 69, offset: 1579},
 						val:        "f12",
 						ignoreCase: true,
+						want:       "\"f12\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 11, offset: 1345},
+						pos:        position{line: 75, col: 11, offset: 1596},
 						val:        "f1",
 						ignoreCase: true,
+						want:       "\"f1\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 19, offset: 1353},
+						pos:        position{line: 75, col: 19, offset: 1604},
 						val:        "f2",
 						ignoreCase: true,
+						want:       "\"f2\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 27, offset: 1361},
+						pos:        position{line: 75, col: 27, offset: 1612},
 						val:        "f3",
 						ignoreCase: true,
+						want:       "\"f3\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 35, offset: 1369},
+						pos:        position{line: 75, col: 35, offset: 1620},
 						val:        "f4",
 						ignoreCase: true,
+						want:       "\"f4\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 43, offset: 1377},
+						pos:        position{line: 75, col: 43, offset: 1628},
 						val:        "f5",
 						ignoreCase: true,
+						want:       "\"f5\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 51, offset: 1385},
+						pos:        position{line: 75, col: 51, offset: 1636},
 						val:        "f6",
 						ignoreCase: true,
+						want:       "\"f6\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 59, offset: 1393},
+						pos:        position{line: 75, col: 59, offset: 1644},
 						val:        "f7",
 						ignoreCase: true,
+						want:       "\"f7\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 67, offset: 1401},
+						pos:        position{line: 75, col: 67, offset: 1652},
 						val:        "f8",
 						ignoreCase: true,
+						want:       "\"f8\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 75, offset: 1409},
+						pos:        position{line: 75, col: 75, offset: 1660},
 						val:        "f9",
 						ignoreCase: true,
+						want:       "\"f9\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 12, offset: 1426},
+						pos:        position{line: 76, col: 12, offset: 1677},
 						val:        "return",
 						ignoreCase: true,
+						want:       "\"return\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 24, offset: 1438},
+						pos:        position{line: 76, col: 24, offset: 1689},
 						val:        "tab",
 						ignoreCase: true,
+						want:       "\"tab\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 33, offset: 1447},
+						pos:        position{line: 76, col: 33, offset: 1698},
 						val:        "up",
 						ignoreCase: true,
+						want:       "\"up\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 41, offset: 1455},
+						pos:        position{line: 76, col: 41, offset: 1706},
 						val:        "down",
 						ignoreCase: true,
+						want:       "\"down\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 51, offset: 1465},
+						pos:        position{line: 76, col: 51, offset: 1716},
 						val:        "spacebar",
 						ignoreCase: true,
+						want:       "\"spacebar\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 65, offset: 1479},
+						pos:        position{line: 76, col: 65, offset: 1730},
 						val:        "insert",
 						ignoreCase: true,
+						want:       "\"insert\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 77, offset: 1491},
+						pos:        position{line: 76, col: 77, offset: 1742},
 						val:        "home",
 						ignoreCase: true,
+						want:       "\"home\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 11, offset: 1509},
+						pos:        position{line: 77, col: 11, offset: 1760},
 						val:        "end",
 						ignoreCase: true,
+						want:       "\"end\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 20, offset: 1518},
+						pos:        position{line: 77, col: 20, offset: 1769},
 						val:        "pageup",
 						ignoreCase: true,
+						want:       "\"pageUp\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 32, offset: 1530},
+						pos:        position{line: 77, col: 32, offset: 1781},
 						val:        "pagedown",
 						ignoreCase: true,
+						want:       "\"pageDown\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 46, offset: 1544},
+						pos:        position{line: 77, col: 46, offset: 1795},
 						val:        "leftalt",
 						ignoreCase: true,
+						want:       "\"leftAlt\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 59, offset: 1557},
+						pos:        position{line: 77, col: 59, offset: 1808},
 						val:        "leftctrl",
 						ignoreCase: true,
+						want:       "\"leftCtrl\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 73, offset: 1571},
+						pos:        position{line: 77, col: 73, offset: 1822},
 						val:        "leftshift",
 						ignoreCase: true,
+						want:       "\"leftShift\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 11, offset: 1594},
+						pos:        position{line: 78, col: 11, offset: 1845},
 						val:        "rightalt",
 						ignoreCase: true,
+						want:       "\"rightAlt\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 25, offset: 1608},
+						pos:        position{line: 78, col: 25, offset: 1859},
 						val:        "rightctrl",
 						ignoreCase: true,
+						want:       "\"rightCtrl\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 40, offset: 1623},
+						pos:        position{line: 78, col: 40, offset: 1874},
 						val:        "rightshift",
 						ignoreCase: true,
+						want:       "\"rightShift\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 56, offset: 1639},
+						pos:        position{line: 78, col: 56, offset: 1890},
 						val:        "leftsuper",
 						ignoreCase: true,
+						want:       "\"leftSuper\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 71, offset: 1654},
+						pos:        position{line: 78, col: 71, offset: 1905},
 						val:        "rightsuper",
 						ignoreCase: true,
+						want:       "\"rightSuper\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 71, col: 11, offset: 1678},
+						pos:        position{line: 79, col: 11, offset: 1929},
 						val:        "left",
 						ignoreCase: true,
+						want:       "\"left\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 71, col: 21, offset: 1688},
+						pos:        position{line: 79, col: 21, offset: 1939},
 						val:        "right",
 						ignoreCase: true,
+						want:       "\"right\"i",
 					},
 				},
 			},
 		},
 		{
 			name: "NonZeroDigit",
-			pos:  position{line: 73, col: 1, offset: 1698},
+			pos:  position{line: 81, col: 1, offset: 1949},
 			expr: &charClassMatcher{
-				pos:        position{line: 73, col: 16, offset: 1713},
+				pos:        position{line: 81, col: 16, offset: 1964},
 				val:        "[1-9]",
 				ranges:     []rune{'1', '9'},
 				ignoreCase: false,
@@ -583,9 +669,9 @@ var g = &grammar{
 		},
 		{
 			name: "Digit",
-			pos:  position{line: 74, col: 1, offset: 1719},
+			pos:  position{line: 82, col: 1, offset: 1970},
 			expr: &charClassMatcher{
-				pos:        position{line: 74, col: 9, offset: 1727},
+				pos:        position{line: 82, col: 9, offset: 1978},
 				val:        "[0-9]",
 				ranges:     []rune{'0', '9'},
 				ignoreCase: false,
@@ -594,44 +680,51 @@ var g = &grammar{
 		},
 		{
 			name: "TimeUnit",
-			pos:  position{line: 75, col: 1, offset: 1733},
+			pos:  position{line: 83, col: 1, offset: 1984},
 			expr: &choiceExpr{
-				pos: position{line: 75, col: 13, offset: 1745},
+				pos: position{line: 83, col: 13, offset: 1996},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 75, col: 13, offset: 1745},
+						pos:        position{line: 83, col: 13, offset: 1996},
 						val:        "ns",
 						ignoreCase: false,
+						want:       "\"ns\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 20, offset: 1752},
+						pos:        position{line: 83, col: 20, offset: 2003},
 						val:        "us",
 						ignoreCase: false,
+						want:       "\"us\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 27, offset: 1759},
+						pos:        position{line: 83, col: 27, offset: 2010},
 						val:        "µs",
 						ignoreCase: false,
+						want:       "\"µs\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 34, offset: 1767},
+						pos:        position{line: 83, col: 34, offset: 2018},
 						val:        "ms",
 						ignoreCase: false,
+						want:       "\"ms\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 41, offset: 1774},
+						pos:        position{line: 83, col: 41, offset: 2025},
 						val:        "s",
 						ignoreCase: false,
+						want:       "\"s\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 47, offset: 1780},
+						pos:        position{line: 83, col: 47, offset: 2031},
 						val:        "m",
 						ignoreCase: false,
+						want:       "\"m\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 53, offset: 1786},
+						pos:        position{line: 83, col: 53, offset: 2037},
 						val:        "h",
 						ignoreCase: false,
+						want:       "\"h\"",
 					},
 				},
 			},
@@ -639,11 +732,11 @@ var g = &grammar{
 		{
 			name:        "_",
 			displayName: "\"whitespace\"",
-			pos:         position{line: 77, col: 1, offset: 1792},
+			pos:         position{line: 85, col: 1, offset: 2043},
 			expr: &zeroOrMoreExpr{
-				pos: position{line: 77, col: 19, offset: 1810},
+				pos: position{line: 85, col: 19, offset: 2061},
 				expr: &charClassMatcher{
-					pos:        position{line: 77, col: 19, offset: 1810},
+					pos:        position{line: 85, col: 19, offset: 2061},
 					val:        "[ \\n\\t\\r]",
 					chars:      []rune{' ', '\n', '\t', '\r'},
 					ignoreCase: false,
@@ -653,11 +746,11 @@ var g = &grammar{
 		},
 		{
 			name: "EOF",
-			pos:  position{line: 79, col: 1, offset: 1822},
+			pos:  position{line: 87, col: 1, offset: 2073},
 			expr: &notExpr{
-				pos: position{line: 79, col: 8, offset: 1829},
+				pos: position{line: 87, col: 8, offset: 2080},
 				expr: &anyMatcher{
-					line: 79, col: 9, offset: 1830,
+					line: 87, col: 9, offset: 2081,
 				},
 			},
 		},
@@ -703,6 +796,20 @@ func (p *parser) callonWait1() (interface{}, error) {
 	return p.cur.onWait1(stack["duration"])
 }
 
+func (c *current) onWaitForHTTPCallback1(timeout interface{}) (interface{}, error) {
+	var d time.Duration
+	if timeout != nil {
+		d = timeout.(time.Duration)
+	}
+	return &waitForHTTPCallbackExpression{d}, nil
+}
+
+func (p *parser) callonWaitForHTTPCallback1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onWaitForHTTPCallback1(stack["timeout"])
+}
+
 func (c *current) onCharToggle1(lit, t interface{}) (interface{}, error) {
 	return &literal{lit.(*literal).s, t.(KeyAction)}, nil
 }
@@ -846,18 +953,17 @@ func Entrypoint(ruleName string) Option {
 //
 // Example usage:
 //
-//     input := "input"
-//     stats := Stats{}
-//     _, err := Parse("input-file", []byte(input), Statistics(&stats, "no match"))
-//     if err != nil {
-//         log.Panicln(err)
-//     }
-//     b, err := json.MarshalIndent(stats.ChoiceAltCnt, "", "  ")
-//     if err != nil {
-//         log.Panicln(err)
-//     }
-//     fmt.Println(string(b))
-//
+//	input := "input"
+//	stats := Stats{}
+//	_, err := Parse("input-file", []byte(input), Statistics(&stats, "no match"))
+//	if err != nil {
+//	    log.Panicln(err)
+//	}
+//	b, err := json.MarshalIndent(stats.ChoiceAltCnt, "", "  ")
+//	if err != nil {
+//	    log.Panicln(err)
+//	}
+//	fmt.Println(string(b))
 func Statistics(stats *Stats, choiceNoMatch string) Option {
 	return func(p *parser) Option {
 		oldStats := p.Stats
@@ -1095,6 +1201,7 @@ type litMatcher struct {
 	pos        position
 	val        string
 	ignoreCase bool
+	want       string
 }
 
 type charClassMatcher struct {
@@ -1889,11 +1996,6 @@ func (p *parser) parseLitMatcher(lit *litMatcher) (interface{}, bool) {
 		defer p.out(p.in("parseLitMatcher"))
 	}
 
-	ignoreCase := ""
-	if lit.ignoreCase {
-		ignoreCase = "i"
-	}
-	val := string(strconv.AppendQuote([]byte{}, lit.val)) + ignoreCase // wrap 'lit.val' with double quotes
 	start := p.pt
 	for _, want := range lit.val {
 		cur := p.pt.rn
@@ -1901,13 +2003,13 @@ func (p *parser) parseLitMatcher(lit *litMatcher) (interface{}, bool) {
 			cur = unicode.ToLower(cur)
 		}
 		if cur != want {
-			p.failAt(false, start.position, val)
+			p.failAt(false, start.position, lit.want)
 			p.restore(start)
 			return nil, false
 		}
 		p.read()
 	}
-	p.failAt(true, start.position, val)
+	p.failAt(true, start.position, lit.want)
 	return p.sliceFrom(start), true
 }
 
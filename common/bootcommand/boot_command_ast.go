@@ -118,6 +118,57 @@ func (w *waitExpression) String() string {
 	return fmt.Sprintf("Wait<%s>", w.d)
 }
 
+type waitForHTTPCallbackExpression struct {
+	timeout time.Duration
+}
+
+// Do waits for the guest to call the callback endpoint of the HTTP server.
+// It waits forever when no timeout is set, and is cancellable through the
+// context.
+func (w *waitForHTTPCallbackExpression) Do(ctx context.Context, driver BCDriver) error {
+	driver.Flush()
+	done, ok := ctx.Value(httpCallbackKey{}).(<-chan struct{})
+	if !ok || done == nil {
+		return fmt.Errorf("<waitForHTTPCallback> requires the HTTP server, " +
+			"set http_directory or http_content")
+	}
+	var timeout <-chan time.Time
+	if w.timeout > 0 {
+		log.Printf("[INFO] Waiting %s for the HTTP callback", w.timeout)
+		timeout = time.After(w.timeout)
+	} else {
+		log.Printf("[INFO] Waiting for the HTTP callback")
+	}
+	select {
+	case <-done:
+		return nil
+	case <-timeout:
+		return fmt.Errorf("Timeout waiting for the HTTP callback after %s", w.timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Validate returns an error if the timeout is < 0
+func (w *waitForHTTPCallbackExpression) Validate() error {
+	if w.timeout < 0 {
+		return fmt.Errorf("Expecting a positive timeout. Got %s", w.timeout)
+	}
+	return nil
+}
+
+func (w *waitForHTTPCallbackExpression) String() string {
+	return fmt.Sprintf("WaitForHTTPCallback<%s>", w.timeout)
+}
+
+type httpCallbackKey struct{}
+
+// WithHTTPCallback returns a copy of ctx in which the `<waitForHTTPCallback>`
+// expressions wait for done to be closed.
+func WithHTTPCallback(ctx context.Context, done <-chan struct{}) context.Context {
+	return context.WithValue(ctx, httpCallbackKey{}, done)
+}
+
 type specialExpression struct {
 	s      string
 	action KeyAction
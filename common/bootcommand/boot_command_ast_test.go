@@ -1,15 +1,18 @@
 package bootcommand
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_parse(t *testing.T) {
 	in := "<wait><wait20><wait3s><wait4m2ns>"
+	in += "<waitForHTTPCallback><waitForHTTPCallback10m>"
 	in += "foo/bar > one 界"
 	in += "<fon> b<fOff>"
 	in += "<foo><f3><f12><spacebar><leftalt><rightshift><rightsuper>"
@@ -18,6 +21,8 @@ func Test_parse(t *testing.T) {
 		"Wait<20s>",
 		"Wait<3s>",
 		"Wait<4m0.000000002s>",
+		"WaitForHTTPCallback<0s>",
+		"WaitForHTTPCallback<10m0s>",
 		"LIT-Press(f)",
 		"LIT-Press(o)",
 		"LIT-Press(o)",
@@ -102,6 +107,10 @@ func Test_validation(t *testing.T) {
 			"<wait-1m>",
 			false,
 		},
+		{
+			"<waitForHTTPCallback-1m>",
+			false,
+		},
 		{
 			"<f1>",
 			true,
@@ -132,3 +141,28 @@ func Test_empty(t *testing.T) {
 	assert.NoError(t, err, "should have parsed an empty input okay.")
 	assert.Len(t, exp, 0)
 }
+
+type noopDriver struct{}
+
+func (noopDriver) SendKey(rune, KeyAction) error       { return nil }
+func (noopDriver) SendSpecial(string, KeyAction) error { return nil }
+func (noopDriver) Flush() error                        { return nil }
+
+func Test_waitForHTTPCallback(t *testing.T) {
+	seq, err := GenerateExpressionSequence("<waitForHTTPCallback50ms>")
+	assert.NoError(t, err)
+
+	// no HTTP server
+	assert.Error(t, seq.Do(context.Background(), noopDriver{}))
+
+	// timeout
+	done := make(chan struct{})
+	ctx := WithHTTPCallback(context.Background(), done)
+	assert.Error(t, seq.Do(ctx, noopDriver{}))
+
+	// the guest called back
+	close(done)
+	start := time.Now()
+	assert.NoError(t, seq.Do(ctx, noopDriver{}))
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+}
@@ -64,6 +64,12 @@ import (
 //     Valid time units are `ns`, `us` (or `µs`), `ms`, `s`, `m`, `h`. For
 //     example `<wait10m>` or `<wait1m20s>`.
 //
+// -   `<waitForHTTPCallback> <waitForHTTPCallbackXX>` - Pauses until the
+//     guest calls `http://{{ .HTTPIP }}:{{ .HTTPPort }}/packer/callback`,
+//     for example from an installer script. The optional `XX` is a timeout
+//     in the format of `<waitXX>`, after which the build fails. This
+//     requires the HTTP server to be started, see `http_directory`.
+//
 // -   `<XXXOn> <XXXOff>` - Any printable keyboard character, and of these
 //      "special" expressions, with the exception of the `<wait>` types, can
 //      also be toggled on or off. For example, to simulate ctrl+c, use
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	getter "github.com/hashicorp/go-getter"
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// verifyChecksumFile downloads the checksum file at checksumURL and checks
+// its OpenPGP signature against the keys of keyringPath. The signature is
+// downloaded from signatureURL when set, otherwise the checksum file must be
+// clearsigned.
+//
+// On success the verified content of the checksum file is written to a
+// temporary file, whose path is returned. The checksum file must be read
+// from there and not downloaded again, otherwise the content read might not
+// be the one that was verified.
+func verifyChecksumFile(checksumURL, signatureURL, keyringPath string) (string, error) {
+	keyring, err := readKeyring(keyringPath)
+	if err != nil {
+		return "", fmt.Errorf("Error reading iso_checksum_keyring: %s", err)
+	}
+
+	checksums, err := fetchFile(checksumURL)
+	if err != nil {
+		return "", fmt.Errorf("Error downloading checksum file: %s", err)
+	}
+
+	var signer *openpgp.Entity
+	if signatureURL != "" {
+		signature, err := fetchFile(signatureURL)
+		if err != nil {
+			return "", fmt.Errorf("Error downloading iso_checksum_signature_url: %s", err)
+		}
+		signer, err = checkDetachedSignature(keyring, checksums, signature)
+		if err != nil {
+			return "", fmt.Errorf("The signature of the checksum file %s is not valid: %s", checksumURL, err)
+		}
+	} else {
+		block, _ := clearsign.Decode(checksums)
+		if block == nil {
+			return "", fmt.Errorf("The checksum file %s is not clearsigned; "+
+				"set iso_checksum_signature_url to verify a detached signature", checksumURL)
+		}
+		signer, err = openpgp.CheckDetachedSignature(keyring,
+			bytes.NewReader(block.Bytes), block.ArmoredSignature.Body)
+		if err != nil {
+			return "", fmt.Errorf("The signature of the checksum file %s is not valid: %s", checksumURL, err)
+		}
+		checksums = block.Plaintext
+	}
+	log.Printf("Checksum file %s signed by key %X", checksumURL, signer.PrimaryKey.Fingerprint)
+
+	// keep the base name of the checksum file, go-getter uses it to match
+	// the entry of the downloaded file.
+	u, err := urlhelper.Parse(checksumURL)
+	if err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir("", "packer-checksum")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, filepath.Base(u.Path))
+	if err := ioutil.WriteFile(path, checksums, 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return path, nil
+}
+
+// readKeyring reads an armored or binary OpenPGP keyring.
+func readKeyring(path string) (openpgp.EntityList, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := armor.Decode(bytes.NewReader(b)); err == nil {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(b))
+}
+
+// checkDetachedSignature checks an armored or binary detached signature.
+func checkDetachedSignature(keyring openpgp.KeyRing, signed, signature []byte) (*openpgp.Entity, error) {
+	if _, err := armor.Decode(bytes.NewReader(signature)); err == nil {
+		return openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(signature))
+	}
+	return openpgp.CheckDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(signature))
+}
+
+// fetchFile downloads src with go-getter and returns its content.
+func fetchFile(src string) ([]byte, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Printf("get working directory: %v", err)
+		// here we ignore the error in case the
+		// working directory is not needed.
+	}
+	dir, err := ioutil.TempDir("", "packer-checksum")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "file")
+	gc := getter.Client{
+		Src:     src,
+		Dst:     dst,
+		Pwd:     wd,
+		Mode:    getter.ClientModeFile,
+		Getters: getter.Getters,
+	}
+	if err := gc.Get(); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(dst)
+}
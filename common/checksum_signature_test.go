@@ -0,0 +1,168 @@
+// +build !windows
+
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+const testSHA256SUMS = `2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  the-OS.iso
+486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7  other.iso
+`
+
+func testEntity(t *testing.T) *openpgp.Entity {
+	e, err := openpgp.NewEntity("Packer Test", "", "packer@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return e
+}
+
+func writeTestKeyring(t *testing.T, path string, e *openpgp.Entity) {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := e.Serialize(w); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	w.Close()
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestISOConfigPrepare_ISOChecksumSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-signature")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	trusted := testEntity(t)
+	untrusted := testEntity(t)
+	keyring := filepath.Join(dir, "keyring.asc")
+	writeTestKeyring(t, keyring, trusted)
+
+	sums := filepath.Join(dir, "SHA256SUMS")
+	if err := ioutil.WriteFile(sums, []byte(testSHA256SUMS), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tampered := filepath.Join(dir, "SHA256SUMS.tampered")
+	if err := ioutil.WriteFile(tampered, []byte(strings.Replace(testSHA256SUMS, "2cf2", "0000", 1)), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sign := func(e *openpgp.Entity, name string) string {
+		var sig bytes.Buffer
+		if err := openpgp.ArmoredDetachSign(&sig, e, strings.NewReader(testSHA256SUMS), nil); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, sig.Bytes(), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return path
+	}
+	goodSig := sign(trusted, "SHA256SUMS.gpg")
+	badSig := sign(untrusted, "SHA256SUMS.untrusted.gpg")
+
+	clearsigned := func(e *openpgp.Entity, name string) string {
+		var buf bytes.Buffer
+		w, err := clearsign.Encode(&buf, e.PrivateKey, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		w.Write([]byte(testSHA256SUMS))
+		w.Close()
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return path
+	}
+	goodClear := clearsigned(trusted, "CHECKSUM")
+	badClear := clearsigned(untrusted, "CHECKSUM.untrusted")
+
+	tests := []struct {
+		name      string
+		url       string
+		signature string
+		keyring   string
+		wantErr   bool
+	}{
+		{"detached signature", sums, goodSig, keyring, false},
+		{"clearsigned", goodClear, "", keyring, false},
+		{"untrusted detached signature", sums, badSig, keyring, true},
+		{"untrusted clearsigned", badClear, "", keyring, true},
+		{"tampered checksum file", tampered, goodSig, keyring, true},
+		{"not clearsigned", sums, "", keyring, true},
+		{"signature without keyring", sums, goodSig, "", true},
+		{"missing keyring", sums, goodSig, filepath.Join(dir, "missing.asc"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := testISOConfig()
+			i.ISOChecksum = ""
+			i.ISOChecksumType = ""
+			i.ISOChecksumURL = tt.url
+			i.ISOChecksumSignatureURL = tt.signature
+			i.ISOChecksumKeyring = tt.keyring
+
+			_, errs := i.Prepare(nil)
+			if (len(errs) > 0) != tt.wantErr {
+				t.Fatalf("Prepare() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if i.ISOChecksumType != "sha256" {
+				t.Fatalf("bad checksum type: %s", i.ISOChecksumType)
+			}
+			if !strings.HasPrefix(testSHA256SUMS, i.ISOChecksum) {
+				t.Fatalf("bad checksum: %s", i.ISOChecksum)
+			}
+		})
+	}
+}
+
+func TestISOConfigPrepare_ISOChecksumKeyringWithoutFile(t *testing.T) {
+	i := testISOConfig()
+	i.ISOChecksumKeyring = "keyring.asc"
+
+	_, errs := i.Prepare(nil)
+	if len(errs) == 0 {
+		t.Fatal("should error, the keyring can only verify a checksum file")
+	}
+}
+
+func TestISOConfigPrepare_ISOChecksumKeyringWithNone(t *testing.T) {
+	i := testISOConfig()
+	i.ISOChecksumType = "none"
+	i.ISOChecksumKeyring = "keyring.asc"
+
+	_, errs := i.Prepare(nil)
+	if len(errs) == 0 {
+		t.Fatal("should error, there is no checksum file to verify")
+	}
+
+	i = testISOConfig()
+	i.ISOChecksumType = "none"
+	i.ISOChecksumSignatureURL = "http://example.com/SHA256SUMS.gpg"
+
+	_, errs = i.Prepare(nil)
+	if len(errs) == 0 {
+		t.Fatal("should error, there is no checksum file to verify")
+	}
+}
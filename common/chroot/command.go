@@ -1,3 +1,5 @@
+// Package chroot contains the steps and the communicator shared by the
+// builders which provision a device by chrooting into its mounted filesystem.
 package chroot
 
 import (
@@ -0,0 +1,42 @@
+package chroot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The magic number at the beginning of qcow2 images: QFI\xfb
+var qcow2Magic = []byte{'Q', 'F', 'I', 0xfb}
+
+// ImageInfo returns the format, raw or qcow2, and the virtual size in bytes
+// of a disk image.
+func ImageInfo(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	if fi.IsDir() {
+		return "", 0, fmt.Errorf("%s is a directory", path)
+	}
+
+	// The virtual size of a qcow2 image is the 64-bit big-endian integer
+	// following the magic, the version and the backing file offset and size
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(f, header); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", 0, err
+	}
+	if bytes.Equal(header[:4], qcow2Magic) {
+		return "qcow2", int64(binary.BigEndian.Uint64(header[24:32])), nil
+	}
+
+	return "raw", fi.Size(), nil
+}
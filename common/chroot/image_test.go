@@ -0,0 +1,63 @@
+package chroot
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const gigabyte = 1024 * 1024 * 1024
+
+// testImages writes a raw image of 3 MB and a qcow2 header of a 5 GB image.
+func testImages(t *testing.T) (string, string, string) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw := filepath.Join(dir, "disk.raw")
+	if err := ioutil.WriteFile(raw, make([]byte, 3*1024*1024), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	header := make([]byte, 104)
+	copy(header, qcow2Magic)
+	binary.BigEndian.PutUint32(header[4:8], 3)
+	binary.BigEndian.PutUint64(header[24:32], 5*gigabyte)
+	qcow2 := filepath.Join(dir, "disk.qcow2")
+	if err := ioutil.WriteFile(qcow2, header, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return dir, raw, qcow2
+}
+
+func TestImageInfo(t *testing.T) {
+	dir, raw, qcow2 := testImages(t)
+	defer os.RemoveAll(dir)
+
+	format, size, err := ImageInfo(raw)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if format != "raw" || size != 3*1024*1024 {
+		t.Fatalf("bad: %s, %d", format, size)
+	}
+
+	format, size, err = ImageInfo(qcow2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if format != "qcow2" || size != 5*gigabyte {
+		t.Fatalf("bad: %s, %d", format, size)
+	}
+
+	if _, _, err := ImageInfo(dir); err == nil {
+		t.Fatal("should have error with a directory")
+	}
+	if _, _, err := ImageInfo(filepath.Join(dir, "missing.raw")); err == nil {
+		t.Fatal("should have error with a missing image")
+	}
+}
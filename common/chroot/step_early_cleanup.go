@@ -10,7 +10,7 @@ import (
 )
 
 // StepEarlyCleanup performs some of the cleanup steps early in order to
-// prepare for snapshotting and creating an AMI.
+// prepare for snapshotting the device, or converting the image.
 type StepEarlyCleanup struct{}
 
 func (s *StepEarlyCleanup) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -0,0 +1,32 @@
+package chroot
+
+import "testing"
+
+func TestMountDeviceCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{}
+	raw = new(StepMountDevice)
+	if _, ok := raw.(Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestPartitionDevice(t *testing.T) {
+	cases := []struct {
+		Device    string
+		Partition string
+		Expected  string
+	}{
+		{"/dev/xvdf", "1", "/dev/xvdf1"},
+		{"/dev/xvdf", "0", "/dev/xvdf"},
+		{"/dev/loop0", "1", "/dev/loop0p1"},
+		{"/dev/loop0", "", "/dev/loop0"},
+		{"/dev/nvme1n1", "2", "/dev/nvme1n1p2"},
+		{"/dev/nvme1n1p", "1", "/dev/nvme1n1p1"},
+	}
+
+	for _, tc := range cases {
+		if actual := PartitionDevice(tc.Device, tc.Partition); actual != tc.Expected {
+			t.Fatalf("bad %s %s: %s", tc.Device, tc.Partition, actual)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
 )
 
 type postMountCommandsData struct {
@@ -16,10 +17,10 @@ type postMountCommandsData struct {
 // device, but prior to the bind mount and copy steps.
 type StepPostMountCommands struct {
 	Commands []string
+	Ctx      interpolate.Context
 }
 
 func (s *StepPostMountCommands) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	config := state.Get("config").(*Config)
 	device := state.Get("device").(string)
 	mountPath := state.Get("mount_path").(string)
 	ui := state.Get("ui").(packer.Ui)
@@ -29,7 +30,7 @@ func (s *StepPostMountCommands) Run(ctx context.Context, state multistep.StateBa
 		return multistep.ActionContinue
 	}
 
-	ictx := config.ctx
+	ictx := s.Ctx
 	ictx.Data = &postMountCommandsData{
 		Device:    device,
 		MountPath: mountPath,
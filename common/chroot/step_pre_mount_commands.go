@@ -5,6 +5,7 @@ import (
 
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
 )
 
 type preMountCommandsData struct {
@@ -14,10 +15,10 @@ type preMountCommandsData struct {
 // StepPreMountCommands sets up the a new block device when building from scratch
 type StepPreMountCommands struct {
 	Commands []string
+	Ctx      interpolate.Context
 }
 
 func (s *StepPreMountCommands) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	config := state.Get("config").(*Config)
 	device := state.Get("device").(string)
 	ui := state.Get("ui").(packer.Ui)
 	wrappedCommand := state.Get("wrappedCommand").(CommandWrapper)
@@ -26,7 +27,7 @@ func (s *StepPreMountCommands) Run(ctx context.Context, state multistep.StateBag
 		return multistep.ActionContinue
 	}
 
-	ictx := config.ctx
+	ictx := s.Ctx
 	ictx.Data = &preMountCommandsData{Device: device}
 
 	ui.Say("Running device setup commands...")
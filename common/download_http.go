@@ -0,0 +1,472 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	getter "github.com/hashicorp/go-getter"
+	"github.com/hashicorp/packer/packer"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// The default number of connections used to download a file over
+	// HTTP(S).
+	defaultDownloadConnections = 4
+
+	// Files are never split in chunks smaller than this, so that small files
+	// are downloaded with a single request.
+	minDownloadChunkSize = 1 << 20
+
+	// The number of consecutive failures of a chunk allowed on every mirror
+	// before giving up.
+	downloadRetriesPerMirror = 3
+
+	// The state of a download is saved every time this many bytes have been
+	// written so that an interrupted download can be resumed.
+	downloadStateSaveInterval = 8 << 20
+)
+
+// errRangesUnsupported is returned by httpDownloader.Download when none of
+// the mirrors can be used for range requests, either because they don't
+// support them or because they can't be reached. The download should then
+// be done by go-getter with a single stream, which reports the actual error
+// if any.
+var errRangesUnsupported = errors.New("server does not support range requests")
+
+// httpDownloader downloads a file over HTTP(S) using concurrent ranged
+// requests. The file is split in chunks downloaded in parallel; a chunk
+// failing on a mirror resumes from where it stopped on the next mirror.
+//
+// The file is written to dst + ".part" along with a dst + ".part.json"
+// state file so that an interrupted download resumes where it stopped. The
+// part file is renamed to dst once complete.
+type httpDownloader struct {
+	Client *http.Client
+
+	// Mirrors are URLs serving the same file.
+	Mirrors []string
+
+	// The maximum number of concurrent connections.
+	Connections int
+
+	// The maximum number of bytes per second read across all connections,
+	// zero means no limit.
+	RateLimit int64
+
+	ProgressListener getter.ProgressTracker
+}
+
+// downloadState is saved next to the partial file to resume a download.
+type downloadState struct {
+	Size int64 `json:"size"`
+	// The ETag, or else the Last-Modified date, of the file when the
+	// download started. A download is only resumed when the file still
+	// has it.
+	Validator string           `json:"validator"`
+	Chunks    []*downloadChunk `json:"chunks"`
+}
+
+// downloadChunk is the [Start, End) byte range of a file, of which Done
+// bytes have already been written.
+type downloadChunk struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+func (c *downloadChunk) remaining() int64 {
+	return c.End - c.Start - c.Done
+}
+
+func newDownloadState(size int64, validator string, connections int) *downloadState {
+	n := int64(connections)
+	if n < 1 {
+		n = 1
+	}
+	if max := (size + minDownloadChunkSize - 1) / minDownloadChunkSize; n > max {
+		n = max
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	s := &downloadState{Size: size, Validator: validator}
+	chunkSize := size / n
+	for i := int64(0); i < n; i++ {
+		c := &downloadChunk{Start: i * chunkSize, End: (i + 1) * chunkSize}
+		if i == n-1 {
+			c.End = size
+		}
+		s.Chunks = append(s.Chunks, c)
+	}
+	return s
+}
+
+func readDownloadState(path string) (*downloadState, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := new(downloadState)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (d *httpDownloader) Download(ctx context.Context, dst string) error {
+	size, validator, mirrors, err := d.probe(ctx)
+	if err != nil {
+		return err
+	}
+
+	partPath := dst + packer.CachePartialSuffix
+	statePath := partPath + ".json"
+
+	// Without a validator, a file of the same size may not be the same file,
+	// so the download starts over.
+	state, err := readDownloadState(statePath)
+	if err == nil && state.Size == size && validator != "" && state.Validator == validator {
+		if _, err := os.Stat(partPath); err == nil {
+			log.Printf("Resuming download of %s", dst)
+		} else {
+			state = nil
+		}
+	} else {
+		if err == nil {
+			log.Printf("Not resuming download of %s: the file changed", dst)
+		}
+		state = nil
+	}
+	if state == nil {
+		state = newDownloadState(size, validator, d.Connections)
+	}
+
+	f, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+
+	var lock sync.Mutex
+	var unsaved int64
+	save := func() error {
+		b, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		unsaved = 0
+		return ioutil.WriteFile(statePath, b, 0644)
+	}
+	written := func(c *downloadChunk, n int64) {
+		lock.Lock()
+		defer lock.Unlock()
+		c.Done += n
+		unsaved += n
+		if unsaved >= downloadStateSaveInterval {
+			if err := f.Sync(); err == nil {
+				save()
+			}
+		}
+	}
+
+	var limiter *rateLimiter
+	if d.RateLimit > 0 {
+		limiter = &rateLimiter{rate: float64(d.RateLimit)}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for i, c := range state.Chunks {
+		if c.remaining() <= 0 {
+			continue
+		}
+		i, c := i, c
+		g.Go(func() error {
+			return d.downloadChunk(gctx, mirrors, i, len(state.Chunks), c, f, limiter, written)
+		})
+	}
+	err = g.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if err != nil {
+		// keep what was downloaded so far for the next attempt
+		f.Sync()
+		if serr := save(); serr != nil {
+			log.Printf("Failed to save download state %s: %s", statePath, serr)
+		}
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, dst); err != nil {
+		return err
+	}
+	os.Remove(statePath)
+	return nil
+}
+
+// probe finds the size of the file, the validator of the file on the first
+// mirror and the mirrors accepting range requests.
+func (d *httpDownloader) probe(ctx context.Context) (int64, string, []string, error) {
+	var size int64 = -1
+	var validator string
+	var mirrors []string
+	for _, m := range d.Mirrors {
+		req, err := http.NewRequest("HEAD", m, nil)
+		if err != nil {
+			log.Printf("Ignoring mirror %s: %s", m, err)
+			continue
+		}
+		resp, err := d.Client.Do(req.WithContext(ctx))
+		if err != nil {
+			log.Printf("Ignoring mirror %s: %s", m, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Printf("Ignoring mirror %s: bad response code: %d", m, resp.StatusCode)
+			continue
+		}
+		if resp.ContentLength <= 0 || resp.Header.Get("Accept-Ranges") != "bytes" {
+			log.Printf("%s does not accept range requests", m)
+			continue
+		}
+		if size != -1 && resp.ContentLength != size {
+			log.Printf("Ignoring mirror %s: size %d differs from %d", m, resp.ContentLength, size)
+			continue
+		}
+		if len(mirrors) == 0 {
+			validator = responseValidator(resp)
+		}
+		size = resp.ContentLength
+		mirrors = append(mirrors, m)
+	}
+
+	if len(mirrors) == 0 {
+		return 0, "", nil, errRangesUnsupported
+	}
+	return size, validator, mirrors, nil
+}
+
+// responseValidator returns the strong ETag of a response, or else its
+// Last-Modified date, which tell whether a file changed.
+func responseValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// downloadChunk downloads the remaining bytes of chunk c into f. Every
+// chunk starts on a different mirror, and moves to the next one whenever a
+// request fails.
+func (d *httpDownloader) downloadChunk(ctx context.Context, mirrors []string, i, n int, c *downloadChunk, f io.WriterAt, limiter *rateLimiter, written func(*downloadChunk, int64)) error {
+	m := i % len(mirrors)
+	failures := 0
+	for c.remaining() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		before := c.remaining()
+		err := d.fetch(ctx, mirrors[m], i, n, c, f, limiter, written)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if c.remaining() < before {
+			failures = 0
+		}
+		failures++
+		if failures >= downloadRetriesPerMirror*len(mirrors) {
+			return err
+		}
+		next := (m + 1) % len(mirrors)
+		log.Printf("Downloading bytes %d-%d from %s failed: %s; trying %s",
+			c.Start+c.Done, c.End-1, mirrors[m], err, mirrors[next])
+		m = next
+	}
+	return nil
+}
+
+func (d *httpDownloader) fetch(ctx context.Context, src string, i, n int, c *downloadChunk, f io.WriterAt, limiter *rateLimiter, written func(*downloadChunk, int64)) error {
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", c.Start+c.Done, c.End-1))
+	resp, err := d.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("bad response code: %d", resp.StatusCode)
+	}
+
+	var body io.ReadCloser = resp.Body
+	if d.ProgressListener != nil {
+		name := src
+		if n > 1 {
+			name = fmt.Sprintf("%s (%d/%d)", src, i+1, n)
+		}
+		body = d.ProgressListener.TrackProgress(name, c.Done, c.End-c.Start, body)
+		defer body.Close()
+	}
+
+	buf := make([]byte, 32*1024)
+	if limiter != nil && int64(len(buf)) > d.RateLimit {
+		buf = buf[:d.RateLimit]
+	}
+	for c.remaining() > 0 {
+		if int64(len(buf)) > c.remaining() {
+			buf = buf[:c.remaining()]
+		}
+		nr, rerr := body.Read(buf)
+		if nr > 0 {
+			if _, err := f.WriteAt(buf[:nr], c.Start+c.Done); err != nil {
+				return err
+			}
+			written(c, int64(nr))
+			if err := limiter.wait(ctx, nr); err != nil {
+				return err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	if c.remaining() > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// rateLimiter spreads transfers so that they don't exceed rate bytes per
+// second on average.
+type rateLimiter struct {
+	lock sync.Mutex
+	rate float64
+	// the time at which the next transfer is allowed
+	next time.Time
+}
+
+// wait is called after transferring n bytes and blocks for as long as the
+// transfer should have taken at the allowed rate. A nil rateLimiter never
+// blocks.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedTracker limits the speed of the downloads tracked by a
+// go-getter ProgressTracker, such as the single stream HTTP downloads of
+// go-getter.
+type rateLimitedTracker struct {
+	getter.ProgressTracker
+
+	ctx     context.Context
+	rate    int64
+	limiter *rateLimiter
+}
+
+func newRateLimitedTracker(ctx context.Context, tracker getter.ProgressTracker, rate int64) *rateLimitedTracker {
+	return &rateLimitedTracker{
+		ProgressTracker: tracker,
+		ctx:             ctx,
+		rate:            rate,
+		limiter:         &rateLimiter{rate: float64(rate)},
+	}
+}
+
+func (t *rateLimitedTracker) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	return t.ProgressTracker.TrackProgress(src, currentSize, totalSize, &rateLimitedReader{stream, t})
+}
+
+type rateLimitedReader struct {
+	io.ReadCloser
+	t *rateLimitedTracker
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.t.rate {
+		p = p[:r.t.rate]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := r.t.limiter.wait(r.t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// ParseByteSize parses sizes such as "512", "10K", "10KB", "1.5M" or
+// "2GiB" into a number of bytes. Units are powers of 1024. A trailing "/s"
+// is accepted so that rates can be written naturally.
+func ParseByteSize(s string) (int64, error) {
+	orig := s
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "/S")
+	s = strings.TrimSuffix(s, "B")
+	s = strings.TrimSuffix(s, "I")
+
+	multiplier := float64(1)
+	if l := len(s); l > 0 {
+		if i := strings.IndexByte("KMGT", s[l-1]); i != -1 {
+			for ; i >= 0; i-- {
+				multiplier *= 1024
+			}
+			s = s[:l-1]
+		}
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", orig)
+	}
+	return int64(v * multiplier), nil
+}
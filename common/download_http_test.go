@@ -0,0 +1,360 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// rangeServer serves content and records the Range header of every GET
+// request it receives.
+type rangeServer struct {
+	content []byte
+
+	// when set, GET requests only send this many bytes before the
+	// connection is closed.
+	failAfter int
+
+	// when set, GET requests fail with an internal server error.
+	broken bool
+
+	// the ETag of the content, if any.
+	etag string
+
+	lock   sync.Mutex
+	ranges []string
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		s.lock.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.lock.Unlock()
+	}
+	if s.broken && r.Method == "GET" {
+		http.Error(w, "broken", http.StatusInternalServerError)
+		return
+	}
+	if s.failAfter > 0 && r.Method == "GET" {
+		start, end := parseTestRange(r.Header.Get("Range"))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(s.content[start : start+s.failAfter])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+	}
+	http.ServeContent(w, r, "file.iso", time.Time{}, bytes.NewReader(s.content))
+}
+
+func (s *rangeServer) requestedRanges() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+func parseTestRange(h string) (int, int) {
+	parts := strings.Split(strings.TrimPrefix(h, "bytes="), "-")
+	start, _ := strconv.Atoi(parts[0])
+	end, _ := strconv.Atoi(parts[1])
+	return start, end
+}
+
+func testContent(size int) []byte {
+	b := make([]byte, size)
+	rand.New(rand.NewSource(42)).Read(b)
+	return b
+}
+
+func testDownloadDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "packer-download")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return dir
+}
+
+func TestHTTPDownloader_parallel(t *testing.T) {
+	content := testContent(3*minDownloadChunkSize + 123)
+	s := &rangeServer{content: content}
+	srvr := httptest.NewServer(s)
+	defer srvr.Close()
+
+	dir := testDownloadDir(t)
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "file.iso")
+
+	d := &httpDownloader{
+		Client:      http.DefaultClient,
+		Mirrors:     []string{srvr.URL + "/file.iso"},
+		Connections: 4,
+	}
+	if err := d.Download(context.Background(), dst); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	got, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content differs")
+	}
+	if ranges := s.requestedRanges(); len(ranges) != 4 {
+		t.Fatalf("expected 4 ranged requests, got %#v", ranges)
+	}
+	for _, p := range []string{dst + ".part", dst + ".part.json"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed", p)
+		}
+	}
+}
+
+func TestHTTPDownloader_mirrorFailover(t *testing.T) {
+	content := testContent(minDownloadChunkSize)
+	bad := &rangeServer{content: content, failAfter: 1000}
+	badSrvr := httptest.NewServer(bad)
+	defer badSrvr.Close()
+	good := &rangeServer{content: content}
+	goodSrvr := httptest.NewServer(good)
+	defer goodSrvr.Close()
+
+	dir := testDownloadDir(t)
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "file.iso")
+
+	d := &httpDownloader{
+		Client:      http.DefaultClient,
+		Mirrors:     []string{badSrvr.URL + "/file.iso", goodSrvr.URL + "/file.iso"},
+		Connections: 1,
+	}
+	if err := d.Download(context.Background(), dst); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	got, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content differs")
+	}
+	// the good mirror must continue where the bad one stopped
+	ranges := good.requestedRanges()
+	if len(ranges) != 1 {
+		t.Fatalf("expected 1 request to the good mirror, got %#v", ranges)
+	}
+	if start, _ := parseTestRange(ranges[0]); start != 1000 {
+		t.Fatalf("expected download to resume at 1000, got %s", ranges[0])
+	}
+}
+
+func TestHTTPDownloader_allMirrorsFail(t *testing.T) {
+	content := testContent(minDownloadChunkSize)
+	bad := &rangeServer{content: content, broken: true}
+	srvr := httptest.NewServer(bad)
+	defer srvr.Close()
+
+	dir := testDownloadDir(t)
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "file.iso")
+
+	d := &httpDownloader{
+		Client:      http.DefaultClient,
+		Mirrors:     []string{srvr.URL + "/file.iso"},
+		Connections: 1,
+	}
+	if err := d.Download(context.Background(), dst); err == nil {
+		t.Fatalf("should fail")
+	}
+	if n := len(bad.requestedRanges()); n != downloadRetriesPerMirror {
+		t.Fatalf("expected %d attempts, got %d", downloadRetriesPerMirror, n)
+	}
+	// the partial download is kept to be resumed later
+	if _, err := readDownloadState(dst + ".part.json"); err != nil {
+		t.Fatalf("state should be saved: %s", err)
+	}
+}
+
+// testResume simulates an interrupted download of a file that had the
+// validator, and resumes it from a server sending the file with etag. It
+// returns the ranges requested.
+func testResume(t *testing.T, validator, etag string) []string {
+	content := testContent(2 * minDownloadChunkSize)
+	s := &rangeServer{content: content, etag: etag}
+	srvr := httptest.NewServer(s)
+	defer srvr.Close()
+
+	dir := testDownloadDir(t)
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "file.iso")
+
+	// simulate an interrupted download: half of the first chunk and the
+	// whole second chunk were written.
+	half := minDownloadChunkSize / 2
+	partial := make([]byte, len(content))
+	copy(partial[:half], content[:half])
+	copy(partial[minDownloadChunkSize:], content[minDownloadChunkSize:])
+	if err := ioutil.WriteFile(dst+".part", partial, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state := &downloadState{
+		Size:      int64(len(content)),
+		Validator: validator,
+		Chunks: []*downloadChunk{
+			{Start: 0, End: minDownloadChunkSize, Done: int64(half)},
+			{Start: minDownloadChunkSize, End: int64(len(content)), Done: minDownloadChunkSize},
+		},
+	}
+	b, _ := json.Marshal(state)
+	if err := ioutil.WriteFile(dst+".part.json", b, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d := &httpDownloader{
+		Client:      http.DefaultClient,
+		Mirrors:     []string{srvr.URL + "/file.iso"},
+		Connections: 2,
+	}
+	if err := d.Download(context.Background(), dst); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	got, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content differs")
+	}
+	return s.requestedRanges()
+}
+
+func TestHTTPDownloader_resume(t *testing.T) {
+	ranges := testResume(t, `"v1"`, `"v1"`)
+	half := minDownloadChunkSize / 2
+	expected := "bytes=" + strconv.Itoa(half) + "-" + strconv.Itoa(minDownloadChunkSize-1)
+	if len(ranges) != 1 || ranges[0] != expected {
+		t.Fatalf("expected a single request for %s, got %#v", expected, ranges)
+	}
+}
+
+func TestHTTPDownloader_resumeChangedFile(t *testing.T) {
+	// the file changed since the download started, or it can't be told
+	for _, etag := range []string{`"v2"`, `W/"v1"`, ""} {
+		ranges := testResume(t, `"v1"`, etag)
+		if len(ranges) != 2 || ranges[0] == ranges[1] {
+			t.Fatalf("%s: expected the download to start over, got %#v", etag, ranges)
+		}
+		for _, r := range ranges {
+			if start, _ := parseTestRange(r); start%minDownloadChunkSize != 0 {
+				t.Fatalf("%s: expected the download to start over, got %#v", etag, ranges)
+			}
+		}
+	}
+}
+
+func TestHTTPDownloader_rateLimit(t *testing.T) {
+	content := testContent(64 * 1024)
+	srvr := httptest.NewServer(&rangeServer{content: content})
+	defer srvr.Close()
+
+	dir := testDownloadDir(t)
+	defer os.RemoveAll(dir)
+
+	d := &httpDownloader{
+		Client:      http.DefaultClient,
+		Mirrors:     []string{srvr.URL + "/file.iso"},
+		Connections: 1,
+		RateLimit:   128 * 1024,
+	}
+	start := time.Now()
+	if err := d.Download(context.Background(), filepath.Join(dir, "file.iso")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// 64KiB at 128KiB/s takes half a second
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Fatalf("download was not rate limited, took %s", elapsed)
+	}
+}
+
+func TestRateLimitedTracker(t *testing.T) {
+	content := testContent(64 * 1024)
+	ui := &packer.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)}
+	tracker := newRateLimitedTracker(context.Background(), ui, 128*1024)
+
+	start := time.Now()
+	body := tracker.TrackProgress("file.iso", 0, int64(len(content)), ioutil.NopCloser(bytes.NewReader(content)))
+	got, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body.Close()
+	if !bytes.Equal(got, content) {
+		t.Fatalf("read content differs")
+	}
+	// 64KiB at 128KiB/s takes half a second
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Fatalf("read was not rate limited, took %s", elapsed)
+	}
+}
+
+func TestHTTPDownloader_rangesUnsupported(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("no ranges here"))
+	}))
+	defer srvr.Close()
+
+	dir := testDownloadDir(t)
+	defer os.RemoveAll(dir)
+
+	d := &httpDownloader{
+		Client:  http.DefaultClient,
+		Mirrors: []string{srvr.URL + "/file.iso"},
+	}
+	if err := d.Download(context.Background(), filepath.Join(dir, "file.iso")); err != errRangesUnsupported {
+		t.Fatalf("expected errRangesUnsupported, got %v", err)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"10K", 10 * 1024, false},
+		{"10KB", 10 * 1024, false},
+		{"1.5M", 3 * 512 * 1024, false},
+		{"2GiB", 2 << 30, false},
+		{"5mb/s", 5 << 20, false},
+		{"", 0, true},
+		{"fast", 0, true},
+		{"-1M", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseByteSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Fatalf("ParseByteSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
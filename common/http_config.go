@@ -4,6 +4,9 @@ package common
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/packer/template/interpolate"
 )
@@ -15,6 +18,19 @@ import (
 // Example usage from a builder:
 //
 //   `wget http://{{ .HTTPIP }}:{{ .HTTPPort }}/foo/bar/preseed.cfg`
+//
+// When `http_templates` is set, the files served are rendered as templates
+// every time they are requested, so a single kickstart or preseed file can
+// be shared by several builds:
+//
+// ``` json
+// {
+//   "http_templates": true,
+//   "http_content": {
+//     "/preseed.cfg": "d-i netcfg/get_hostname string {{ build_name }}\nd-i mirror/http/proxy string http://{{ .HTTPIP }}:3142/"
+//   }
+// }
+// ```
 type HTTPConfig struct {
 	// Path to a directory to serve using an HTTP server. The files in this
 	// directory will be available over HTTP that will be requestable from the
@@ -23,6 +39,19 @@ type HTTPConfig struct {
 	// started. The address and port of the HTTP server will be available as
 	// variables in `boot_command`. This is covered in more detail below.
 	HTTPDir string `mapstructure:"http_directory"`
+	// Key/Values to serve using an HTTP server. The keys represent the paths
+	// and the values contents. This is useful for hosting kickstart files and
+	// so on without having to keep them on disk. `http_content` can be used
+	// along with `http_directory`; when a path is in both, `http_content`
+	// wins.
+	HTTPContent map[string]string `mapstructure:"http_content"`
+	// When true, the files of `http_directory` and `http_content` are rendered
+	// as templates every time they are requested. Templates can use the user
+	// variables, the `build_name` and `build_type` functions, as well as
+	// `{{ .HTTPIP }}` and `{{ .HTTPPort }}`: the address the guest used to
+	// reach the HTTP server. Binary files are served as they are. Defaults to
+	// false.
+	HTTPTemplates bool `mapstructure:"http_templates"`
 	// These are the minimum and maximum port to use for the HTTP server
 	// started to serve the `http_directory`. Because Packer often runs in
 	// parallel, Packer will choose a randomly available port in this range to
@@ -31,6 +60,15 @@ type HTTPConfig struct {
 	// are `8000` and `9000`, respectively.
 	HTTPPortMin int `mapstructure:"http_port_min"`
 	HTTPPortMax int `mapstructure:"http_port_max"`
+	// When set, Packer waits for the guest to call
+	// `http://{{ .HTTPIP }}:{{ .HTTPPort }}/packer/callback` after typing the
+	// `boot_command`, and only then connects to the communicator. The value
+	// is a duration such as `30m`, after which the build fails. Installers
+	// can call this endpoint when they are done, for example with `curl` in
+	// the `%post` section of a kickstart file. They can also `POST` their
+	// progress to `/packer/log`, which is shown in the output of Packer.
+	RawWaitForHTTPCallback string        `mapstructure:"wait_for_http_callback"`
+	WaitForHTTPCallback    time.Duration ``
 }
 
 func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
@@ -50,5 +88,31 @@ func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
 			errors.New("http_port_min must be less than http_port_max"))
 	}
 
+	if c.RawWaitForHTTPCallback != "" {
+		d, err := time.ParseDuration(c.RawWaitForHTTPCallback)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing wait_for_http_callback: %s", err))
+		} else if d <= 0 {
+			errs = append(errs, errors.New("wait_for_http_callback must be positive"))
+		} else {
+			c.WaitForHTTPCallback = d
+		}
+		if c.HTTPDir == "" && len(c.HTTPContent) == 0 {
+			errs = append(errs, errors.New("wait_for_http_callback requires http_directory or http_content"))
+		}
+	}
+
+	for k, v := range c.HTTPContent {
+		if strings.Trim(k, "/") == "" {
+			errs = append(errs, errors.New("http_content paths must not be empty"))
+			continue
+		}
+		if c.HTTPTemplates {
+			if err := interpolate.Validate(v, ctx); err != nil {
+				errs = append(errs, fmt.Errorf("http_content %q is not a valid template: %s", k, err))
+			}
+		}
+	}
+
 	return errs
 }
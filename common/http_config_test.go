@@ -2,6 +2,7 @@ package common
 
 import (
 	"testing"
+	"time"
 )
 
 func TestHTTPConfigPrepare_Bounds(t *testing.T) {
@@ -43,3 +44,55 @@ func TestHTTPConfigPrepare_Bounds(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 }
+
+func TestHTTPConfigPrepare_Content(t *testing.T) {
+	// Test bad path
+	h := HTTPConfig{
+		HTTPContent: map[string]string{"/": "foo"},
+	}
+	if err := h.Prepare(nil); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test bad template
+	h = HTTPConfig{
+		HTTPContent:   map[string]string{"/ks.cfg": "{{ .HTTPIP "},
+		HTTPTemplates: true,
+	}
+	if err := h.Prepare(nil); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test the same content is fine when it is not a template
+	h.HTTPTemplates = false
+	if err := h.Prepare(nil); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestHTTPConfigPrepare_WaitForHTTPCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  HTTPConfig
+		want    time.Duration
+		wantErr bool
+	}{
+		{"unset", HTTPConfig{}, 0, false},
+		{"valid", HTTPConfig{HTTPDir: "http", RawWaitForHTTPCallback: "30m"}, 30 * time.Minute, false},
+		{"with content", HTTPConfig{HTTPContent: map[string]string{"/ks.cfg": ""}, RawWaitForHTTPCallback: "1h"}, time.Hour, false},
+		{"bad duration", HTTPConfig{HTTPDir: "http", RawWaitForHTTPCallback: "soon"}, 0, true},
+		{"negative", HTTPConfig{HTTPDir: "http", RawWaitForHTTPCallback: "-1m"}, 0, true},
+		{"no http server", HTTPConfig{RawWaitForHTTPCallback: "30m"}, 30 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.config.Prepare(nil)
+			if (len(errs) > 0) != tt.wantErr {
+				t.Fatalf("Prepare() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+			if tt.config.WaitForHTTPCallback != tt.want {
+				t.Fatalf("WaitForHTTPCallback = %s, want %s", tt.config.WaitForHTTPCallback, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 
 	getter "github.com/hashicorp/go-getter"
@@ -68,6 +69,15 @@ import (
 // }
 // ```
 //
+// ``` json
+// {
+//   "iso_checksum_url": "ubuntu.org/.../SHA256SUMS",
+//   "iso_checksum_signature_url": "ubuntu.org/.../SHA256SUMS.gpg",
+//   "iso_checksum_keyring": "./ubuntu-cdimage-keyring.gpg",
+//   "iso_url": "ubuntu.org/.../ubuntu-14.04.1-server-amd64.iso"
+// }
+// ```
+//
 type ISOConfig struct {
 	// The checksum for the ISO file or virtual hard drive file. The algorithm
 	// to use when computing the checksum can be optionally specified with
@@ -81,6 +91,19 @@ type ISOConfig struct {
 	// least one of `iso_checksum` and `iso_checksum_url` must be defined.
 	// `iso_checksum_url` will be ignored if `iso_checksum` is non empty.
 	ISOChecksumURL string `mapstructure:"iso_checksum_url"`
+	// An URL to a detached OpenPGP signature of the checksum file, for example
+	// the `SHA256SUMS.gpg` file published next to `SHA256SUMS`. The signature
+	// can be armored or binary. When set, `iso_checksum_keyring` must be set
+	// too and the build fails unless the checksum file is signed by one of the
+	// keys of the keyring.
+	ISOChecksumSignatureURL string `mapstructure:"iso_checksum_signature_url"`
+	// The path to an OpenPGP keyring, armored or binary, holding the public
+	// keys trusted to sign the checksum file. When set, Packer verifies the
+	// signature of the checksum file before using any checksum from it. If
+	// `iso_checksum_signature_url` is not set, the checksum file must be
+	// clearsigned, like the `CHECKSUM` files of Fedora. It can't be set when
+	// `iso_checksum_type` is `none`.
+	ISOChecksumKeyring string `mapstructure:"iso_checksum_keyring"`
 	// The algorithm to be used when computing the checksum of the file
 	// specified in `iso_checksum`. Currently, valid values are "", "none",
 	// "md5", "sha1", "sha256", "sha512" or "file". Since the validity of ISO
@@ -106,6 +129,20 @@ type ISOConfig struct {
 	TargetPath string `mapstructure:"iso_target_path"`
 	// The extension of the iso file after download. This defaults to `iso`.
 	TargetExtension string `mapstructure:"iso_target_extension"`
+	// The maximum number of concurrent connections used to download the ISO
+	// over HTTP(S). When the server supports range requests, the file is
+	// split in chunks that are downloaded in parallel from all the HTTP(S)
+	// URLs of `iso_urls`; a chunk failing on one URL continues on the next
+	// one. Interrupted downloads are resumed from the partial file left in
+	// the packer cache, unless the ETag or the Last-Modified date of the file
+	// changed, or the server sends neither. Defaults to 4, set to 1 to use a
+	// single connection.
+	DownloadConnections int `mapstructure:"download_connections"`
+	// The maximum download speed, for example `10MB` to download at most
+	// 10 megabytes per second, across all connections. Units are K, M and G,
+	// in powers of 1024. The limit also applies when the server does not
+	// support range requests. By default the speed is not limited.
+	DownloadRateLimit string `mapstructure:"download_rate_limit"`
 }
 
 func (c *ISOConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs []error) {
@@ -133,8 +170,23 @@ func (c *ISOConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs [
 	}
 	c.TargetExtension = strings.ToLower(c.TargetExtension)
 
+	if c.DownloadConnections < 0 {
+		errs = append(errs, errors.New("download_connections must not be negative"))
+	}
+	if c.DownloadRateLimit != "" {
+		if _, err := ParseByteSize(c.DownloadRateLimit); err != nil {
+			errs = append(errs, fmt.Errorf("Error parsing download_rate_limit: %s", err))
+		}
+	}
+
 	// Warnings
 	if c.ISOChecksumType == "none" {
+		// There is no checksum file whose signature could be verified
+		if c.ISOChecksumKeyring != "" || c.ISOChecksumSignatureURL != "" {
+			errs = append(errs, errors.New("iso_checksum_keyring and "+
+				"iso_checksum_signature_url can't be used with an "+
+				"iso_checksum_type of none"))
+		}
 		warnings = append(warnings,
 			"A checksum type of 'none' was specified. Since ISO files are so big,\n"+
 				"a checksum is highly recommended.")
@@ -160,7 +212,28 @@ func (c *ISOConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs [
 	if c.ISOChecksum == "" {
 		errs = append(errs, fmt.Errorf("A checksum must be specified"))
 	}
+	if c.ISOChecksumSignatureURL != "" && c.ISOChecksumKeyring == "" {
+		errs = append(errs, errors.New("iso_checksum_keyring must be set to "+
+			"verify iso_checksum_signature_url"))
+		return warnings, errs
+	}
+	if c.ISOChecksumKeyring != "" && c.ISOChecksumType != "file" {
+		errs = append(errs, errors.New("iso_checksum_keyring can only be used "+
+			"with a checksum file, set with iso_checksum_url"))
+		return warnings, errs
+	}
 	if c.ISOChecksumType == "file" {
+		checksumURL := c.ISOChecksumURL
+		if c.ISOChecksumKeyring != "" {
+			path, err := verifyChecksumFile(c.ISOChecksum, c.ISOChecksumSignatureURL, c.ISOChecksumKeyring)
+			if err != nil {
+				errs = append(errs, err)
+				return warnings, errs
+			}
+			defer os.RemoveAll(filepath.Dir(path))
+			checksumURL = path
+		}
+
 		u, err := url.Parse(c.ISOUrls[0])
 		wd, err := os.Getwd()
 		if err != nil {
@@ -175,7 +248,7 @@ func (c *ISOConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs [
 			Dir:     false,
 			Getters: getter.Getters,
 		}
-		cksum, err := gc.ChecksumFromFile(c.ISOChecksumURL, u)
+		cksum, err := gc.ChecksumFromFile(checksumURL, u)
 		if cksum == nil || err != nil {
 			errs = append(errs, fmt.Errorf("Couldn't extract checksum from checksum file"))
 		} else {
@@ -258,3 +258,32 @@ func TestISOConfigPrepare_TargetExtension(t *testing.T) {
 		t.Fatalf("should've lowercased: %s", i.TargetExtension)
 	}
 }
+
+func TestISOConfigPrepare_Download(t *testing.T) {
+	i := testISOConfig()
+	i.DownloadConnections = 8
+	i.DownloadRateLimit = "10MB"
+	warns, err := i.Prepare(nil)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test bad rate limit
+	i = testISOConfig()
+	i.DownloadRateLimit = "fast"
+	_, err = i.Prepare(nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test negative connections
+	i = testISOConfig()
+	i.DownloadConnections = -1
+	_, err = i.Prepare(nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
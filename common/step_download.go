@@ -2,10 +2,10 @@ package common
 
 import (
 	"context"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -50,6 +50,14 @@ type StepDownload struct {
 	// extension on the URL is used. Otherwise, this will be forced
 	// on the downloaded file for every URL.
 	Extension string
+
+	// The maximum number of concurrent connections used to download a file
+	// over HTTP(S). HTTP(S) downloads are split in chunks fetched in parallel
+	// from all the HTTP(S) URLs of Url. Defaults to 4.
+	Connections int
+
+	// The maximum download speed, for example "10MB". No limit if empty.
+	RateLimit string
 }
 
 func (s *StepDownload) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -140,22 +148,13 @@ func (s *StepDownload) download(ctx context.Context, ui packer.Ui, source string
 		// store file under sha1(hash) if set
 		// hash can sometimes be a checksum url
 		// otherwise, use sha1(source_url)
-		var shaSum [20]byte
-		if s.Checksum != "" {
-			shaSum = sha1.Sum([]byte(s.Checksum))
-		} else {
-			shaSum = sha1.Sum([]byte(u.String()))
-		}
-		targetPath = hex.EncodeToString(shaSum[:])
-		if s.Extension != "" {
-			targetPath += "." + s.Extension
-		}
+		targetPath = packer.CacheKey(s.Checksum, u.String(), s.Extension)
 	}
 	targetPath, err = packer.CachePath(targetPath)
 	if err != nil {
 		return "", fmt.Errorf("CachePath: %s", err)
 	}
-	lockFile := targetPath + ".lock"
+	lockFile := targetPath + packer.CacheLockSuffix
 
 	log.Printf("Acquiring lock for: %s (%s)", u.String(), lockFile)
 	lock := filelock.New(lockFile)
@@ -186,26 +185,64 @@ func (s *StepDownload) download(ctx context.Context, ui packer.Ui, source string
 		}
 	}
 
+	rateLimit, err := s.rateLimit()
+	if err != nil {
+		return "", err
+	}
+	// The single stream HTTP downloads of go-getter are limited through
+	// their progress tracker.
+	var progress getter.ProgressTracker = ui
+	if rateLimit > 0 {
+		progress = newRateLimitedTracker(ctx, ui, rateLimit)
+	}
+
 	ui.Say(fmt.Sprintf("Trying %s", u.String()))
+	getFile := true
+	if scheme := strings.ToLower(u.Scheme); scheme == "http" || scheme == "https" {
+		done, err := s.downloadHTTP(ctx, ui, u, targetPath, rateLimit)
+		if err != nil {
+			ui.Say(fmt.Sprintf("Download failed %s", err))
+			return "", err
+		}
+		// go-getter doesn't download again a file matching its checksum, so
+		// let it verify the file when there is one.
+		getFile = !done || u.Query().Get("checksum") != ""
+	}
+
 	gc := getter.Client{
 		Ctx:              ctx,
 		Dst:              targetPath,
 		Src:              src,
-		ProgressListener: ui,
+		ProgressListener: progress,
 		Pwd:              wd,
 		Dir:              false,
 		Getters:          getters,
 	}
 
-	switch err := gc.Get(); err.(type) {
+	var getErr error
+	if getFile {
+		getErr = gc.Get()
+	}
+	switch err := getErr; err.(type) {
 	case nil: // success !
 		ui.Say(fmt.Sprintf("%s => %s", u.String(), targetPath))
+		err := packer.TouchCacheMetadata(targetPath, packer.CacheMetadata{
+			Source:       source,
+			Checksum:     s.Checksum,
+			ChecksumType: s.ChecksumType,
+		})
+		if err != nil {
+			// the metadata is only used to manage the cache, a failure
+			// to write it should not fail the build.
+			log.Printf("Failed to write cache metadata for %s: %s", targetPath, err)
+		}
 		return targetPath, nil
 	case *getter.ChecksumError:
 		ui.Say(fmt.Sprintf("Checksum did not match, removing %s", targetPath))
 		if err := os.Remove(targetPath); err != nil {
 			ui.Error(fmt.Sprintf("Failed to remove cache file. Please remove manually: %s", targetPath))
 		}
+		os.Remove(targetPath + packer.CacheMetadataSuffix)
 		return "", err
 	default:
 		ui.Say(fmt.Sprintf("Download failed %s", err))
@@ -213,4 +250,83 @@ func (s *StepDownload) download(ctx context.Context, ui packer.Ui, source string
 	}
 }
 
+// rateLimit returns the maximum download speed in bytes per second, zero
+// meaning no limit.
+func (s *StepDownload) rateLimit() (int64, error) {
+	if s.RateLimit == "" {
+		return 0, nil
+	}
+	rateLimit, err := ParseByteSize(s.RateLimit)
+	if err != nil {
+		return 0, fmt.Errorf("download rate limit: %s", err)
+	}
+	return rateLimit, nil
+}
+
+// downloadHTTP downloads u to targetPath using concurrent ranged requests,
+// failing over to the other HTTP(S) URLs of the step when a request fails.
+// It returns false when the download should be left to go-getter instead;
+// for example when the server doesn't support range requests.
+func (s *StepDownload) downloadHTTP(ctx context.Context, ui packer.Ui, u *url.URL, targetPath string, rateLimit int64) (bool, error) {
+	if u.Query().Get("checksum") != "" {
+		if _, err := os.Stat(targetPath); err == nil {
+			// the file was already downloaded, go-getter will check it.
+			return false, nil
+		}
+	}
+	for _, param := range []string{"archive", "filename"} {
+		if u.Query().Get(param) != "" {
+			// these require go-getter magic
+			return false, nil
+		}
+	}
+
+	connections := s.Connections
+	if connections == 0 {
+		connections = defaultDownloadConnections
+	}
+
+	// All the URLs point to the same file, so every HTTP(S) one can serve
+	// the chunks of the download.
+	mirrors := []string{stripChecksum(u)}
+	for _, source := range s.Url {
+		m, err := urlhelper.Parse(source)
+		if err != nil {
+			continue
+		}
+		if scheme := strings.ToLower(m.Scheme); scheme != "http" && scheme != "https" {
+			continue
+		}
+		if mirror := stripChecksum(m); mirror != mirrors[0] {
+			mirrors = append(mirrors, mirror)
+		}
+	}
+
+	d := &httpDownloader{
+		Client:           http.DefaultClient,
+		Mirrors:          mirrors,
+		Connections:      connections,
+		RateLimit:        rateLimit,
+		ProgressListener: ui,
+	}
+	switch err := d.Download(ctx, targetPath); err {
+	case nil:
+		return true, nil
+	case errRangesUnsupported:
+		log.Printf("Downloading %s with a single stream: %s", u, err)
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// stripChecksum returns u without its go-getter checksum parameter.
+func stripChecksum(u *url.URL) string {
+	c := *u
+	q := c.Query()
+	q.Del("checksum")
+	c.RawQuery = q.Encode()
+	return c.String()
+}
+
 func (s *StepDownload) Cleanup(multistep.StateBag) {}
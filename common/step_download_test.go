@@ -69,6 +69,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(abs(t, "./test-fixtures/root/another.txt")),
 				toSha1(abs(t, "./test-fixtures/root/another.txt")) + ".lock",
+				toSha1(abs(t, "./test-fixtures/root/another.txt")) + ".meta",
 			},
 		},
 		{"double slashes on a local filesystem passes",
@@ -77,6 +78,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(abs(t, "./test-fixtures/root//another.txt")),
 				toSha1(abs(t, "./test-fixtures/root//another.txt")) + ".lock",
+				toSha1(abs(t, "./test-fixtures/root//another.txt")) + ".meta",
 			},
 		},
 		{"none checksum works, without a checksum",
@@ -85,6 +87,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(abs(t, "./test-fixtures/root/another.txt")),
 				toSha1(abs(t, "./test-fixtures/root/another.txt")) + ".lock",
+				toSha1(abs(t, "./test-fixtures/root/another.txt")) + ".meta",
 			},
 		},
 		{"bad checksum removes file - checksum from string - no Checksum Type",
@@ -114,6 +117,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(srvr.URL+"/root/another.txt.sha1sum") + ".txt",
 				toSha1(srvr.URL+"/root/another.txt.sha1sum") + ".txt.lock",
+				toSha1(srvr.URL+"/root/another.txt.sha1sum") + ".txt.meta",
 			},
 		},
 		{"successfull http dl - checksum from http file - url",
@@ -122,6 +126,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1("file:"+srvr.URL+"/root/another.txt.sha1sum") + ".txt",
 				toSha1("file:"+srvr.URL+"/root/another.txt.sha1sum") + ".txt.lock",
+				toSha1("file:"+srvr.URL+"/root/another.txt.sha1sum") + ".txt.meta",
 			},
 		},
 		{"successfull http dl - checksum from url",
@@ -130,6 +135,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"successfull http dl - checksum from parameter - no checksum type",
@@ -138,6 +144,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"successfull http dl - checksum from parameter - checksum type",
@@ -146,6 +153,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"successfull relative symlink - checksum from url",
@@ -154,6 +162,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"successfull relative symlink - checksum from parameter - no checksum type",
@@ -162,6 +171,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"successfull relative symlink - checksum from parameter -  checksum type",
@@ -170,6 +180,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"successfull absolute symlink - checksum from url",
@@ -178,6 +189,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"successfull absolute symlink - checksum from parameter - no checksum type",
@@ -186,6 +198,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"successfull absolute symlink - checksum from parameter - checksum type",
@@ -194,6 +207,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/another.txt"]) + ".txt",
 				toSha1(cs["/root/another.txt"]) + ".txt.lock",
+				toSha1(cs["/root/another.txt"]) + ".txt.meta",
 			},
 		},
 		{"wrong first 2 urls - absolute urls - checksum from parameter - no checksum type",
@@ -209,6 +223,7 @@ func TestStepDownload_Run(t *testing.T) {
 			[]string{
 				toSha1(cs["/root/basic.txt"]),
 				toSha1(cs["/root/basic.txt"]) + ".lock",
+				toSha1(cs["/root/basic.txt"]) + ".meta",
 			},
 		},
 	}
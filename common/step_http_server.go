@@ -3,36 +3,76 @@ package common
 import (
 	"context"
 	"fmt"
-
+	"io/ioutil"
+	"log"
+	gonet "net"
 	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/hashicorp/packer/common/net"
 	"github.com/hashicorp/packer/helper/common"
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+const (
+	// HTTPCallbackPath is called by the guest to tell Packer it is ready,
+	// usually at the end of the installation. The body of the request, if
+	// any, is shown to the user.
+	HTTPCallbackPath = "/packer/callback"
+
+	// The body of the requests sent to HTTPLogPath is shown to the user. It
+	// allows installers to report their progress.
+	HTTPLogPath = "/packer/log"
+
+	// The maximum size of the body of a callback or log request.
+	maxHTTPCallbackBody = 1 << 20
 )
 
 // This step creates and runs the HTTP server that is serving files from the
 // directory specified by the 'http_directory` configuration parameter in the
-// template.
+// template, along with the files of `http_content`. The server also exposes
+// the HTTPCallbackPath and HTTPLogPath endpoints to the guest.
 //
 // Uses:
 //   ui     packer.Ui
 //
 // Produces:
 //   http_port int - The port the HTTP server started on.
+//   http_callback <-chan struct{} - Closed when the guest calls
+//     HTTPCallbackPath.
 type StepHTTPServer struct {
 	HTTPDir     string
+	HTTPContent map[string]string
 	HTTPPortMin int
 	HTTPPortMax int
 
-	l *net.Listener
+	// When HTTPTemplates is set, the files served are rendered as templates
+	// using Ctx when they are requested.
+	HTTPTemplates bool
+	Ctx           interpolate.Context
+
+	l            *net.Listener
+	callback     chan struct{}
+	callbackOnce sync.Once
+}
+
+// httpTemplateData is the data available to the files rendered by the HTTP
+// server.
+type httpTemplateData struct {
+	HTTPIP   string
+	HTTPPort int
 }
 
 func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packer.Ui)
 
-	if s.HTTPDir == "" {
+	if s.HTTPDir == "" && len(s.HTTPContent) == 0 {
 		state.Put("http_port", 0)
 		return multistep.ActionContinue
 	}
@@ -55,19 +95,174 @@ func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) mult
 	}
 
 	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", s.l.Port))
+	s.callback = make(chan struct{})
 
 	// Start the HTTP server and run it in the background
-	fileServer := http.FileServer(http.Dir(s.HTTPDir))
-	server := &http.Server{Addr: httpAddr, Handler: fileServer}
+	server := &http.Server{Addr: httpAddr, Handler: s.handler(ui)}
 	go server.Serve(s.l)
 
 	// Save the address into the state so it can be accessed in the future
 	state.Put("http_port", s.l.Port)
+	state.Put("http_callback", (<-chan struct{})(s.callback))
 	SetHTTPPort(fmt.Sprintf("%d", s.l.Port))
 
 	return multistep.ActionContinue
 }
 
+// handler returns the handler of the HTTP server, which logs every request
+// to ui.
+func (s *StepHTTPServer) handler(ui packer.Ui) http.Handler {
+	content := make(map[string]string, len(s.HTTPContent))
+	for k, v := range s.HTTPContent {
+		content[path.Clean("/"+k)] = v
+	}
+
+	var fileServer http.Handler
+	if s.HTTPDir != "" {
+		fileServer = http.FileServer(http.Dir(s.HTTPDir))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lw := &loggingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			ui.Message(fmt.Sprintf("HTTP server: %s \"%s %s\" %d",
+				r.RemoteAddr, r.Method, r.URL.Path, lw.status))
+		}()
+
+		p := path.Clean("/" + r.URL.Path)
+		switch p {
+		case HTTPCallbackPath:
+			s.serveCallback(ui, lw, r)
+			return
+		case HTTPLogPath:
+			s.serveLog(ui, lw, r)
+			return
+		}
+		if body, ok := content[p]; ok {
+			s.serveContent(ui, lw, r, p, []byte(body))
+			return
+		}
+		if fileServer == nil {
+			http.NotFound(lw, r)
+			return
+		}
+		if !s.HTTPTemplates {
+			fileServer.ServeHTTP(lw, r)
+			return
+		}
+
+		f, err := http.Dir(s.HTTPDir).Open(p)
+		if err != nil {
+			// let the file server answer with the right error
+			fileServer.ServeHTTP(lw, r)
+			return
+		}
+		defer f.Close()
+		if fi, err := f.Stat(); err != nil || fi.IsDir() {
+			fileServer.ServeHTTP(lw, r)
+			return
+		}
+		body, err := ioutil.ReadAll(f)
+		if err != nil {
+			http.Error(lw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.serveContent(ui, lw, r, p, body)
+	})
+}
+
+// serveContent serves body, rendering it first when templates are enabled.
+// Binary files, which can't be templates, are served as they are.
+func (s *StepHTTPServer) serveContent(ui packer.Ui, w http.ResponseWriter, r *http.Request, name string, body []byte) {
+	if s.HTTPTemplates && utf8.Valid(body) {
+		ctx := s.Ctx
+		ctx.Data = &httpTemplateData{
+			HTTPIP:   httpTemplateIP(r),
+			HTTPPort: s.l.Port,
+		}
+		rendered, err := interpolate.Render(string(body), &ctx)
+		if err != nil {
+			err := fmt.Errorf("Error rendering %s: %s", name, err)
+			ui.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body = []byte(rendered)
+	}
+	http.ServeContent(w, r, name, time.Time{}, strings.NewReader(string(body)))
+}
+
+// serveLog shows the lines of the body of r to the user.
+func (s *StepHTTPServer) serveLog(ui packer.Ui, w http.ResponseWriter, r *http.Request) {
+	if showHTTPBody(ui, w, r) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// serveCallback shows the body of r to the user, then signals the steps
+// waiting for the guest that it called back.
+func (s *StepHTTPServer) serveCallback(ui packer.Ui, w http.ResponseWriter, r *http.Request) {
+	if !showHTTPBody(ui, w, r) {
+		return
+	}
+	s.callbackOnce.Do(func() {
+		ui.Say(fmt.Sprintf("Guest called back from %s", r.RemoteAddr))
+		close(s.callback)
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func showHTTPBody(ui packer.Ui, w http.ResponseWriter, r *http.Request) bool {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPCallbackBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			ui.Message(fmt.Sprintf("Guest: %s", line))
+		}
+	}
+	return true
+}
+
+// HTTPCallback returns a channel closed when the guest calls the
+// HTTPCallbackPath endpoint of the HTTP server, or nil when the HTTP server
+// is not running.
+func HTTPCallback(state multistep.StateBag) <-chan struct{} {
+	if done, ok := state.GetOk("http_callback"); ok {
+		return done.(<-chan struct{})
+	}
+	return nil
+}
+
+// httpTemplateIP returns the IP of the HTTP server as seen by the guest. It
+// is the one set by the builder when there is one, otherwise the address
+// the request was sent to.
+func httpTemplateIP(r *http.Request) string {
+	if ip := GetHTTPIP(); ip != "" {
+		return ip
+	}
+	host, _, err := gonet.SplitHostPort(r.Host)
+	if err != nil {
+		log.Printf("Can't find the HTTP server IP from %q: %s", r.Host, err)
+		return r.Host
+	}
+	return host
+}
+
+// loggingResponseWriter records the status of a response so that it can be
+// logged.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *loggingResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
 func SetHTTPPort(port string) error {
 	return common.SetSharedState("port", port, "")
 }
@@ -0,0 +1,242 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+var _ multistep.Step = new(StepHTTPServer)
+
+func testHTTPGet(t *testing.T, port int, p string) (int, string) {
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, p))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func testHTTPServerDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "packer-http")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	files := map[string][]byte{
+		"ks.cfg":   []byte("hostname {{ build_name }}\nurl http://{{ .HTTPIP }}:{{ .HTTPPort }}/repo\n"),
+		"initrd":   {0xff, 0xfe, '{', '{', 0x00},
+		"user.cfg": []byte("user {{ user `username` }}\n"),
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	return dir
+}
+
+func TestStepHTTPServer_noContent(t *testing.T) {
+	state := testState(t)
+	step := &StepHTTPServer{}
+	defer step.Cleanup(state)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if port := state.Get("http_port").(int); port != 0 {
+		t.Fatalf("no server should be started, got port %d", port)
+	}
+}
+
+func TestStepHTTPServer_static(t *testing.T) {
+	dir := testHTTPServerDir(t)
+	defer os.RemoveAll(dir)
+
+	state := testState(t)
+	step := &StepHTTPServer{
+		HTTPDir:     dir,
+		HTTPContent: map[string]string{"inline.cfg": "inline {{ build_name }}"},
+		HTTPPortMin: 8000,
+		HTTPPortMax: 9000,
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	port := state.Get("http_port").(int)
+
+	// without templates, files are served as they are
+	if _, body := testHTTPGet(t, port, "/ks.cfg"); !strings.Contains(body, "{{ build_name }}") {
+		t.Fatalf("file should not be rendered: %s", body)
+	}
+	if _, body := testHTTPGet(t, port, "/inline.cfg"); body != "inline {{ build_name }}" {
+		t.Fatalf("content should not be rendered: %s", body)
+	}
+	if status, _ := testHTTPGet(t, port, "/missing.cfg"); status != http.StatusNotFound {
+		t.Fatalf("expected not found, got %d", status)
+	}
+
+	// every request is logged to the ui
+	out := state.Get("ui").(*packer.BasicUi).Writer.(*bytes.Buffer).String()
+	for _, expected := range []string{`"GET /ks.cfg" 200`, `"GET /missing.cfg" 404`} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("request should be logged as %s: %s", expected, out)
+		}
+	}
+}
+
+func TestStepHTTPServer_templates(t *testing.T) {
+	dir := testHTTPServerDir(t)
+	defer os.RemoveAll(dir)
+
+	state := testState(t)
+	step := &StepHTTPServer{
+		HTTPDir: dir,
+		HTTPContent: map[string]string{
+			"/preseed/inline.cfg": "inline {{ build_name }}",
+			"/bad.cfg":            "{{ .Nope }}",
+		},
+		HTTPPortMin:   8000,
+		HTTPPortMax:   9000,
+		HTTPTemplates: true,
+		Ctx: interpolate.Context{
+			BuildName:     "centos",
+			UserVariables: map[string]string{"username": "packer"},
+		},
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	port := state.Get("http_port").(int)
+
+	expected := fmt.Sprintf("hostname centos\nurl http://127.0.0.1:%d/repo\n", port)
+	if _, body := testHTTPGet(t, port, "/ks.cfg"); body != expected {
+		t.Fatalf("bad rendered file: %q, expected %q", body, expected)
+	}
+	if _, body := testHTTPGet(t, port, "/user.cfg"); body != "user packer\n" {
+		t.Fatalf("bad rendered file: %q", body)
+	}
+	if _, body := testHTTPGet(t, port, "/preseed/inline.cfg"); body != "inline centos" {
+		t.Fatalf("bad rendered content: %q", body)
+	}
+	if _, body := testHTTPGet(t, port, "/initrd"); body != string([]byte{0xff, 0xfe, '{', '{', 0x00}) {
+		t.Fatalf("binary files should not be rendered: %q", body)
+	}
+	if status, _ := testHTTPGet(t, port, "/bad.cfg"); status != http.StatusInternalServerError {
+		t.Fatalf("expected a server error, got %d", status)
+	}
+}
+
+func TestStepHTTPServer_callback(t *testing.T) {
+	state := testState(t)
+	step := &StepHTTPServer{
+		HTTPContent: map[string]string{"/ks.cfg": "text"},
+		HTTPPortMin: 8000,
+		HTTPPortMax: 9000,
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	port := state.Get("http_port").(int)
+	done := HTTPCallback(state)
+
+	post := func(p, body string) int {
+		resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d%s", port, p), "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := post(HTTPLogPath, "installing packages\r\n\nconfiguring network\n"); status != http.StatusNoContent {
+		t.Fatalf("bad status: %d", status)
+	}
+	select {
+	case <-done:
+		t.Fatal("logs should not complete the callback")
+	default:
+	}
+
+	if status := post(HTTPCallbackPath, "installation complete"); status != http.StatusNoContent {
+		t.Fatalf("bad status: %d", status)
+	}
+	// calling back twice is fine
+	if status, _ := testHTTPGet(t, port, HTTPCallbackPath); status != http.StatusNoContent {
+		t.Fatalf("bad status: %d", status)
+	}
+
+	wait := &StepWaitForHTTPCallback{Timeout: 5 * time.Second}
+	if action := wait.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	out := state.Get("ui").(*packer.BasicUi).Writer.(*bytes.Buffer).String()
+	for _, expected := range []string{
+		"Guest: installing packages\n",
+		"Guest: configuring network\n",
+		"Guest: installation complete\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("output should contain %q: %s", expected, out)
+		}
+	}
+	if n := strings.Count(out, "Guest called back"); n != 1 {
+		t.Fatalf("the callback should be reported once, got %d: %s", n, out)
+	}
+}
+
+func TestStepWaitForHTTPCallback(t *testing.T) {
+	// disabled
+	state := testState(t)
+	if action := new(StepWaitForHTTPCallback).Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// without HTTP server
+	step := &StepWaitForHTTPCallback{Timeout: time.Minute}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// timeout
+	state = testState(t)
+	state.Put("http_callback", (<-chan struct{})(make(chan struct{})))
+	step = &StepWaitForHTTPCallback{Timeout: 10 * time.Millisecond}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+
+	// cancelled
+	state = testState(t)
+	state.Put("http_callback", (<-chan struct{})(make(chan struct{})))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	step = &StepWaitForHTTPCallback{Timeout: time.Minute}
+	if action := step.Run(ctx, state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
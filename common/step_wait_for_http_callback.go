@@ -0,0 +1,58 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepWaitForHTTPCallback waits for the guest to call the HTTPCallbackPath
+// endpoint of the HTTP server, so that the build can proceed as soon as the
+// installation is done. It does nothing when Timeout is zero.
+//
+// Uses:
+//   http_callback <-chan struct{}
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type StepWaitForHTTPCallback struct {
+	Timeout time.Duration
+}
+
+func (s *StepWaitForHTTPCallback) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Timeout == 0 {
+		return multistep.ActionContinue
+	}
+	ui := state.Get("ui").(packer.Ui)
+
+	done := HTTPCallback(state)
+	if done == nil {
+		err := fmt.Errorf("wait_for_http_callback requires the HTTP server to be running")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Waiting up to %s for the guest to call %s...", s.Timeout, HTTPCallbackPath))
+	timeout := time.NewTimer(s.Timeout)
+	defer timeout.Stop()
+	select {
+	case <-done:
+		return multistep.ActionContinue
+	case <-timeout.C:
+		err := fmt.Errorf("Timeout waiting for the guest to call back after %s", s.Timeout)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	case <-ctx.Done():
+		err := fmt.Errorf("Interrupted waiting for the guest to call back: %s", ctx.Err())
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+}
+
+func (s *StepWaitForHTTPCallback) Cleanup(multistep.StateBag) {}
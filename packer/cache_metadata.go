@@ -0,0 +1,280 @@
+package packer
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheMetadataSuffix is appended to the path of a cached file to get the
+// path of the metadata index describing it.
+const CacheMetadataSuffix = ".meta"
+
+// CacheLockSuffix is appended to the path of a cached file to get the path
+// of the lock file protecting it while it is being downloaded.
+const CacheLockSuffix = ".lock"
+
+// CachePartialSuffix is appended to the path of a cached file to get the
+// path of the file while it is being downloaded. The state of the download
+// is saved at this path followed by ".json" so that it can be resumed.
+const CachePartialSuffix = ".part"
+
+// CacheMetadata is the small index written next to every file stored in the
+// cache. It records where the file came from and when it was last used so
+// that the cache can later be listed, verified and pruned.
+type CacheMetadata struct {
+	// The URL or path the file was retrieved from.
+	Source string `json:"source"`
+	// The checksum of the file, along with the type of the checksum. These
+	// may be empty when the file was downloaded without a checksum.
+	Checksum     string `json:"checksum,omitempty"`
+	ChecksumType string `json:"checksum_type,omitempty"`
+	// The size in bytes of the cached file.
+	Size int64 `json:"size"`
+	// When the file was first put in the cache and when it was last
+	// requested by a build.
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// CacheKey returns the file name under which a download is stored in the
+// cache. Files are named after the sha1 of their checksum when there is one,
+// and after the sha1 of their source otherwise. Hexadecimal checksums are
+// case insensitive, so they are lowercased first.
+func CacheKey(checksum, source, extension string) string {
+	if _, err := hex.DecodeString(checksum); err == nil {
+		checksum = strings.ToLower(checksum)
+	}
+
+	var shaSum [20]byte
+	if checksum != "" {
+		shaSum = sha1.Sum([]byte(checksum))
+	} else {
+		shaSum = sha1.Sum([]byte(source))
+	}
+	key := hex.EncodeToString(shaSum[:])
+	if extension != "" {
+		key += "." + extension
+	}
+	return key
+}
+
+// ReadCacheMetadata reads the metadata index of the cached file at path.
+func ReadCacheMetadata(path string) (*CacheMetadata, error) {
+	b, err := ioutil.ReadFile(path + CacheMetadataSuffix)
+	if err != nil {
+		return nil, err
+	}
+	m := new(CacheMetadata)
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WriteCacheMetadata writes the metadata index of the cached file at path.
+// The index is written to a temporary file first and then renamed so that
+// readers never see a partially written index.
+func WriteCacheMetadata(path string, m *CacheMetadata) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + CacheMetadataSuffix + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path+CacheMetadataSuffix)
+}
+
+// TouchCacheMetadata records that the cached file at path has just been
+// used. The index is created when it doesn't exist yet, otherwise its source
+// and checksum are refreshed while its creation time is kept.
+func TouchCacheMetadata(path string, m CacheMetadata) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	m.Size = fi.Size()
+	m.Created = now
+	m.LastUsed = now
+	if old, err := ReadCacheMetadata(path); err == nil && !old.Created.IsZero() {
+		m.Created = old.Created
+	}
+	return WriteCacheMetadata(path, &m)
+}
+
+// CacheEntry is a file stored in the cache.
+type CacheEntry struct {
+	// Absolute path of the cached file.
+	Path string
+	// Metadata of the file, nil if the file has no metadata index; for
+	// example because it was downloaded by an older version of Packer.
+	Metadata *CacheMetadata
+	// Info is the result of stat-ing Path.
+	Info os.FileInfo
+}
+
+// Name returns the file name of the entry, relative to the cache directory.
+func (e *CacheEntry) Name() string {
+	dir, err := CachePath()
+	if err != nil {
+		return e.Path
+	}
+	rel, err := filepath.Rel(dir, e.Path)
+	if err != nil {
+		return e.Path
+	}
+	return rel
+}
+
+// LastUsed returns the last time the entry was used. When the entry has no
+// metadata the modification time of the file is used instead.
+func (e *CacheEntry) LastUsed() time.Time {
+	if e.Metadata != nil && !e.Metadata.LastUsed.IsZero() {
+		return e.Metadata.LastUsed
+	}
+	return e.Info.ModTime()
+}
+
+// Remove deletes the cached file along with its metadata index, lock and
+// any leftover partial download.
+func (e *CacheEntry) Remove() error {
+	if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	suffixes := []string{
+		CacheMetadataSuffix,
+		CacheLockSuffix,
+		CachePartialSuffix,
+		CachePartialSuffix + ".json",
+	}
+	for _, suffix := range suffixes {
+		if err := os.Remove(e.Path + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// CacheEntries returns the files stored in the cache directory, sorted by
+// path. Files at the root of the cache are always returned; files in sub
+// directories are only returned when they have a metadata index, so that
+// internal state such as port locks is not reported.
+func CacheEntries() ([]*CacheEntry, error) {
+	dir, err := CachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*CacheEntry
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, CacheMetadataSuffix) ||
+			strings.HasSuffix(path, CacheMetadataSuffix+".tmp") ||
+			strings.HasSuffix(path, CacheLockSuffix) ||
+			strings.HasSuffix(path, CachePartialSuffix) ||
+			strings.HasSuffix(path, CachePartialSuffix+".json") {
+			return nil
+		}
+		e := &CacheEntry{Path: path, Info: info}
+		if m, err := ReadCacheMetadata(path); err == nil {
+			e.Metadata = m
+		} else if filepath.Dir(path) != dir {
+			return nil
+		}
+		// Follow symlinks so that the size reported is the one of the
+		// actual file.
+		if fi, err := os.Stat(path); err == nil {
+			e.Info = fi
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// ErrCacheNoChecksum is returned by CacheEntry.Verify when the entry has no
+// checksum it can be verified against.
+var ErrCacheNoChecksum = errors.New("no usable checksum recorded")
+
+// Verify recomputes the checksum of the cached file and compares it to the
+// one recorded in its metadata. It returns ErrCacheNoChecksum when there is
+// nothing to compare against, for example when the file was downloaded
+// without a checksum or with a checksum file.
+func (e *CacheEntry) Verify() (bool, error) {
+	if e.Metadata == nil {
+		return false, ErrCacheNoChecksum
+	}
+	checksumType, checksum := e.Metadata.ChecksumType, e.Metadata.Checksum
+	if parts := strings.SplitN(checksum, ":", 2); len(parts) == 2 {
+		checksumType, checksum = parts[0], parts[1]
+	}
+	checksum = strings.ToLower(checksum)
+
+	var h hash.Hash
+	switch strings.ToLower(checksumType) {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	case "", "none":
+		// guess the type from the checksum length, as go-getter does
+		switch len(checksum) {
+		case md5.Size * 2:
+			h = md5.New()
+		case sha1.Size * 2:
+			h = sha1.New()
+		case sha256.Size * 2:
+			h = sha256.New()
+		case sha512.Size * 2:
+			h = sha512.New()
+		}
+	}
+	if h == nil {
+		return false, ErrCacheNoChecksum
+	}
+	if _, err := hex.DecodeString(checksum); err != nil {
+		return false, ErrCacheNoChecksum
+	}
+
+	f, err := os.Open(e.Path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) == checksum, nil
+}
@@ -0,0 +1,181 @@
+package packer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testCacheDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "packer-cache")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	old := os.Getenv("PACKER_CACHE_DIR")
+	os.Setenv("PACKER_CACHE_DIR", dir)
+	return dir, func() {
+		os.Setenv("PACKER_CACHE_DIR", old)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestTouchCacheMetadata(t *testing.T) {
+	dir, cleanup := testCacheDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "file.iso")
+	if err := ioutil.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := TouchCacheMetadata(path, CacheMetadata{Source: "http://example.com/file.iso"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	first, err := ReadCacheMetadata(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if first.Size != 5 {
+		t.Fatalf("bad size: %d", first.Size)
+	}
+	if first.Source != "http://example.com/file.iso" {
+		t.Fatalf("bad source: %s", first.Source)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := TouchCacheMetadata(path, CacheMetadata{Source: "http://mirror.example.com/file.iso"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	second, err := ReadCacheMetadata(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !second.Created.Equal(first.Created) {
+		t.Fatalf("created time changed: %s != %s", second.Created, first.Created)
+	}
+	if !second.LastUsed.After(first.LastUsed) {
+		t.Fatalf("last used time not updated: %s", second.LastUsed)
+	}
+	if second.Source != "http://mirror.example.com/file.iso" {
+		t.Fatalf("bad source: %s", second.Source)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	lower := CacheKey("aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d", "", "iso")
+	if upper := CacheKey("AAF4C61DDCC5E8A2DABEDE0F3B482CD9AEA9434D", "", "iso"); upper != lower {
+		t.Fatalf("bad: %s != %s", upper, lower)
+	}
+
+	// checksum files are named after their URL, as is
+	url := CacheKey("file:http://example.com/SHA256SUMS", "", "iso")
+	if url == CacheKey("file:http://example.com/sha256sums", "", "iso") {
+		t.Fatalf("bad: %s", url)
+	}
+
+	// without a checksum, the source names the file
+	if CacheKey("", "http://example.com/a.iso", "iso") == CacheKey("", "http://example.com/b.iso", "iso") {
+		t.Fatal("sources should have different keys")
+	}
+}
+
+func TestCacheEntries(t *testing.T) {
+	dir, cleanup := testCacheDir(t)
+	defer cleanup()
+
+	files := map[string]bool{
+		"with-meta.iso":        true,
+		"without-meta.iso":     false,
+		"port/8080":            false,
+		"nested/with-meta.iso": true,
+	}
+	for name, meta := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		ioutil.WriteFile(path+CacheLockSuffix, nil, 0644)
+		if meta {
+			if err := TouchCacheMetadata(path, CacheMetadata{Source: name}); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+	}
+
+	entries, err := CacheEntries()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	expected := []string{
+		filepath.Join("nested", "with-meta.iso"),
+		"with-meta.iso",
+		"without-meta.iso",
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("bad entries: %#v", names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("bad entries: %#v", names)
+		}
+	}
+
+	if err := entries[1].Remove(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, suffix := range []string{"", CacheMetadataSuffix, CacheLockSuffix} {
+		if _, err := os.Stat(filepath.Join(dir, "with-meta.iso"+suffix)); !os.IsNotExist(err) {
+			t.Fatalf("with-meta.iso%s should be removed", suffix)
+		}
+	}
+}
+
+func TestCacheEntry_Verify(t *testing.T) {
+	dir, cleanup := testCacheDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "file.txt")
+	if err := ioutil.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	tests := []struct {
+		name         string
+		checksumType string
+		checksum     string
+		ok           bool
+		err          error
+	}{
+		{"sha1", "sha1", "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d", true, nil},
+		{"guessed sha256", "", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", true, nil},
+		{"prefixed md5", "", "md5:5d41402abc4b2a76b9719d911017c592", true, nil},
+		{"mismatch", "sha1", "0000000000000000000000000000000000000000", false, nil},
+		{"checksum file", "file", "http://example.com/SHA256SUMS", false, ErrCacheNoChecksum},
+		{"no checksum", "", "", false, ErrCacheNoChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &CacheEntry{
+				Path: path,
+				Metadata: &CacheMetadata{
+					Checksum:     tt.checksum,
+					ChecksumType: tt.checksumType,
+				},
+			}
+			ok, err := e.Verify()
+			if err != tt.err {
+				t.Fatalf("err: %v, expected %v", err, tt.err)
+			}
+			if ok != tt.ok {
+				t.Fatalf("ok: %v, expected %v", ok, tt.ok)
+			}
+		})
+	}
+}
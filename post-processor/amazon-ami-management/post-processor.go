@@ -0,0 +1,239 @@
+package amazonamimanagement
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	awscommon "github.com/hashicorp/packer/builder/amazon/common"
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// The builders producing an awscommon.Artifact, whose ID is a list of
+// region:ami-id pairs.
+var builtins = map[string]bool{
+	"mitchellh.amazonebs":                 true,
+	"mitchellh.amazon.chroot":             true,
+	"mitchellh.amazon.ebssurrogate":       true,
+	"mitchellh.amazon.instance":           true,
+	"packer.post-processor.amazon-import": true,
+}
+
+type Config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	awscommon.AccessConfig `mapstructure:",squash"`
+
+	// The tags identifying the images to manage: the images owned by the
+	// account which have all of these tags are candidates for removal, in
+	// every region of the artifact and of `regions`. Required.
+	Tags map[string]string `mapstructure:"tags"`
+	// The number of images to keep in each region, newest first. The images
+	// of the artifact are always kept and count towards this number.
+	// Required.
+	KeepReleases int `mapstructure:"keep_releases"`
+	// Additional regions to clean up, which are not part of the artifact,
+	// for example regions where the image is no longer copied to.
+	Regions []string `mapstructure:"regions"`
+	// Only tell which images and snapshots would be deleted, without
+	// deleting them. Defaults to `false`.
+	DryRun bool `mapstructure:"dry_run"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+
+	// modified in tests
+	getRegionConn func(*awscommon.AccessConfig, string) (ec2iface.EC2API, error)
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	p.config.ctx.Funcs = awscommon.TemplateFuncs
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packer.MultiError)
+	errs = packer.MultiErrorAppend(errs, p.config.AccessConfig.Prepare(&p.config.ctx)...)
+
+	if len(p.config.Tags) == 0 {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("tags must be set to identify the images to manage"))
+	}
+
+	if p.config.KeepReleases < 1 {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("keep_releases must be greater than zero"))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	packer.LogSecretFilter.Set(p.config.AccessKey, p.config.SecretKey, p.config.Token)
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, bool, error) {
+	if !builtins[artifact.BuilderId()] {
+		return nil, false, false, fmt.Errorf(
+			"Unknown artifact type: %s\nCan only manage the images of Amazon builders.",
+			artifact.BuilderId())
+	}
+
+	amis, err := parseArtifactId(artifact.Id())
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	regions := make([]string, 0, len(amis)+len(p.config.Regions))
+	for region := range amis {
+		regions = append(regions, region)
+	}
+	for _, region := range p.config.Regions {
+		if _, ok := amis[region]; !ok {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+
+	// The session needs a region, which can be any of the artifact
+	if p.config.RawRegion == "" && len(regions) > 0 {
+		p.config.RawRegion = regions[0]
+	}
+
+	if p.getRegionConn == nil {
+		p.getRegionConn = getRegionConn
+	}
+
+	for _, region := range regions {
+		ui.Say(fmt.Sprintf("Keeping the %d newest images in %s...", p.config.KeepReleases, region))
+		conn, err := p.getRegionConn(&p.config.AccessConfig, region)
+		if err != nil {
+			return nil, false, false, err
+		}
+		if err := p.cleanRegion(ui, conn, region, amis[region]); err != nil {
+			return nil, false, false, err
+		}
+	}
+
+	return artifact, true, false, nil
+}
+
+// cleanRegion deregisters the images of the region matching the tags, but
+// the newest ones and the one of the artifact, and deletes their snapshots.
+func (p *PostProcessor) cleanRegion(ui packer.Ui, conn ec2iface.EC2API, region, artifactAmi string) error {
+	filters := make([]*ec2.Filter, 0, len(p.config.Tags))
+	for k, v := range p.config.Tags {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String("tag:" + k),
+			Values: []*string{aws.String(v)},
+		})
+	}
+
+	resp, err := conn.DescribeImages(&ec2.DescribeImagesInput{
+		Owners:  []*string{aws.String("self")},
+		Filters: filters,
+	})
+	if err != nil {
+		return fmt.Errorf("Error describing images in %s: %s", region, err)
+	}
+
+	images := resp.Images
+	sort.Slice(images, func(i, j int) bool {
+		// CreationDate is in ISO 8601, so it sorts as a string
+		return aws.StringValue(images[i].CreationDate) > aws.StringValue(images[j].CreationDate)
+	})
+
+	// The image of the artifact is kept in any case
+	kept := 0
+	for _, image := range images {
+		if aws.StringValue(image.ImageId) == artifactAmi {
+			kept++
+		}
+	}
+
+	for _, image := range images {
+		id := aws.StringValue(image.ImageId)
+		if id == artifactAmi {
+			continue
+		}
+		if kept < p.config.KeepReleases {
+			log.Printf("Keeping image %s (%s) in %s", id, aws.StringValue(image.CreationDate), region)
+			kept++
+			continue
+		}
+
+		var snapshotIds []string
+		for _, b := range image.BlockDeviceMappings {
+			if b.Ebs != nil && b.Ebs.SnapshotId != nil {
+				snapshotIds = append(snapshotIds, *b.Ebs.SnapshotId)
+			}
+		}
+
+		if p.config.DryRun {
+			ui.Message(fmt.Sprintf("Would deregister image %s (%s) and delete snapshots %v",
+				id, aws.StringValue(image.Name), snapshotIds))
+			continue
+		}
+
+		ui.Message(fmt.Sprintf("Deregistering image %s (%s)", id, aws.StringValue(image.Name)))
+		if _, err := conn.DeregisterImage(&ec2.DeregisterImageInput{
+			ImageId: image.ImageId,
+		}); err != nil {
+			return fmt.Errorf("Error deregistering image %s in %s: %s", id, region, err)
+		}
+
+		for _, snapshotId := range snapshotIds {
+			ui.Message(fmt.Sprintf("Deleting snapshot %s", snapshotId))
+			if _, err := conn.DeleteSnapshot(&ec2.DeleteSnapshotInput{
+				SnapshotId: aws.String(snapshotId),
+			}); err != nil {
+				return fmt.Errorf("Error deleting snapshot %s in %s: %s", snapshotId, region, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseArtifactId returns the map of regions to AMI IDs of an artifact ID
+// like us-east-1:ami-1234,us-west-2:ami-5678.
+func parseArtifactId(id string) (map[string]string, error) {
+	amis := make(map[string]string)
+	for _, part := range strings.Split(id, ",") {
+		if part == "" {
+			continue
+		}
+		parts := strings.SplitN(part, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Unexpected artifact ID %q", id)
+		}
+		amis[parts[0]] = parts[1]
+	}
+	return amis, nil
+}
+
+func getRegionConn(config *awscommon.AccessConfig, region string) (ec2iface.EC2API, error) {
+	session, err := config.Session()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting region connection: %s", err)
+	}
+
+	return ec2.New(session.Copy(&aws.Config{
+		Region: aws.String(region),
+	})), nil
+}
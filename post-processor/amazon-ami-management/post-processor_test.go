@@ -0,0 +1,236 @@
+package amazonamimanagement
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	awscommon "github.com/hashicorp/packer/builder/amazon/common"
+	"github.com/hashicorp/packer/packer"
+)
+
+type mockEC2Conn struct {
+	ec2iface.EC2API
+
+	images             []*ec2.Image
+	describeInput      *ec2.DescribeImagesInput
+	deregisteredImages []string
+	deletedSnapshots   []string
+}
+
+func (m *mockEC2Conn) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	m.describeInput = input
+	return &ec2.DescribeImagesOutput{Images: m.images}, nil
+}
+
+func (m *mockEC2Conn) DeregisterImage(input *ec2.DeregisterImageInput) (*ec2.DeregisterImageOutput, error) {
+	m.deregisteredImages = append(m.deregisteredImages, *input.ImageId)
+	return &ec2.DeregisterImageOutput{}, nil
+}
+
+func (m *mockEC2Conn) DeleteSnapshot(input *ec2.DeleteSnapshotInput) (*ec2.DeleteSnapshotOutput, error) {
+	m.deletedSnapshots = append(m.deletedSnapshots, *input.SnapshotId)
+	return &ec2.DeleteSnapshotOutput{}, nil
+}
+
+func testImage(id, creationDate, snapshotId string) *ec2.Image {
+	return &ec2.Image{
+		ImageId:      aws.String(id),
+		Name:         aws.String("packer-" + id),
+		CreationDate: aws.String(creationDate),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/sda1"),
+				Ebs:        &ec2.EbsBlockDevice{SnapshotId: aws.String(snapshotId)},
+			},
+			{
+				DeviceName:  aws.String("/dev/sdb"),
+				VirtualName: aws.String("ephemeral0"),
+			},
+		},
+	}
+}
+
+func testConn() *mockEC2Conn {
+	return &mockEC2Conn{
+		images: []*ec2.Image{
+			testImage("ami-1", "2019-10-01T10:00:00.000Z", "snap-1"),
+			testImage("ami-4", "2019-10-04T10:00:00.000Z", "snap-4"),
+			testImage("ami-2", "2019-10-02T10:00:00.000Z", "snap-2"),
+			testImage("ami-3", "2019-10-03T10:00:00.000Z", "snap-3"),
+		},
+	}
+}
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"region":        "us-east-1",
+		"tags":          map[string]string{"Name": "web", "Env": "prod"},
+		"keep_releases": 2,
+	}
+}
+
+func testPP(t *testing.T, config map[string]interface{}, conns map[string]*mockEC2Conn) *PostProcessor {
+	var p PostProcessor
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	p.getRegionConn = func(_ *awscommon.AccessConfig, region string) (ec2iface.EC2API, error) {
+		return conns[region], nil
+	}
+	return &p
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessor_Configure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := testConfig()
+	delete(config, "tags")
+	p = PostProcessor{}
+	if err := p.Configure(config); err == nil {
+		t.Fatal("should have error without tags")
+	}
+
+	config = testConfig()
+	config["keep_releases"] = 0
+	p = PostProcessor{}
+	if err := p.Configure(config); err == nil {
+		t.Fatal("should have error without keep_releases")
+	}
+}
+
+func TestPostProcessor_PostProcess(t *testing.T) {
+	conns := map[string]*mockEC2Conn{
+		"us-east-1": testConn(),
+		"eu-west-1": testConn(),
+	}
+	p := testPP(t, testConfig(), conns)
+	artifact := &packer.MockArtifact{
+		BuilderIdValue: "mitchellh.amazonebs",
+		IdValue:        "eu-west-1:ami-4,us-east-1:ami-4",
+	}
+
+	result, keep, forceOverride, err := p.PostProcess(context.Background(), testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result != artifact || !keep || forceOverride {
+		t.Fatalf("bad: %#v, %t, %t", result, keep, forceOverride)
+	}
+
+	for region, conn := range conns {
+		if !reflect.DeepEqual(conn.deregisteredImages, []string{"ami-2", "ami-1"}) {
+			t.Fatalf("bad %s: %#v", region, conn.deregisteredImages)
+		}
+		if !reflect.DeepEqual(conn.deletedSnapshots, []string{"snap-2", "snap-1"}) {
+			t.Fatalf("bad %s: %#v", region, conn.deletedSnapshots)
+		}
+
+		var filters []string
+		for _, f := range conn.describeInput.Filters {
+			filters = append(filters, *f.Name+"="+*f.Values[0])
+		}
+		sort.Strings(filters)
+		if !reflect.DeepEqual(filters, []string{"tag:Env=prod", "tag:Name=web"}) {
+			t.Fatalf("bad %s: %#v", region, filters)
+		}
+	}
+}
+
+func TestPostProcessor_PostProcess_keepArtifact(t *testing.T) {
+	// The image of the artifact is kept even when it is not the newest
+	conns := map[string]*mockEC2Conn{
+		"us-east-1": testConn(),
+		"us-west-2": testConn(),
+	}
+	config := testConfig()
+	config["keep_releases"] = 1
+	config["regions"] = []string{"us-west-2"}
+	p := testPP(t, config, conns)
+	artifact := &packer.MockArtifact{
+		BuilderIdValue: "mitchellh.amazonebs",
+		IdValue:        "us-east-1:ami-2",
+	}
+
+	if _, _, _, err := p.PostProcess(context.Background(), testUi(), artifact); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(conns["us-east-1"].deregisteredImages, []string{"ami-4", "ami-3", "ami-1"}) {
+		t.Fatalf("bad: %#v", conns["us-east-1"].deregisteredImages)
+	}
+
+	// Other regions keep their newest images
+	if !reflect.DeepEqual(conns["us-west-2"].deregisteredImages, []string{"ami-3", "ami-2", "ami-1"}) {
+		t.Fatalf("bad: %#v", conns["us-west-2"].deregisteredImages)
+	}
+}
+
+func TestPostProcessor_PostProcess_dryRun(t *testing.T) {
+	conns := map[string]*mockEC2Conn{
+		"us-east-1": testConn(),
+	}
+	config := testConfig()
+	config["dry_run"] = true
+	p := testPP(t, config, conns)
+	artifact := &packer.MockArtifact{
+		BuilderIdValue: "mitchellh.amazonebs",
+		IdValue:        "us-east-1:ami-4",
+	}
+
+	ui := testUi()
+	if _, _, _, err := p.PostProcess(context.Background(), ui, artifact); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(conns["us-east-1"].deregisteredImages) != 0 || len(conns["us-east-1"].deletedSnapshots) != 0 {
+		t.Fatal("should not delete anything")
+	}
+	if !bytes.Contains(ui.Writer.(*bytes.Buffer).Bytes(), []byte("Would deregister image ami-1")) {
+		t.Fatalf("bad: %s", ui.Writer.(*bytes.Buffer).String())
+	}
+}
+
+func TestPostProcessor_PostProcess_badArtifact(t *testing.T) {
+	p := testPP(t, testConfig(), nil)
+	artifact := &packer.MockArtifact{
+		BuilderIdValue: "packer.docker",
+		IdValue:        "foo",
+	}
+
+	if _, _, _, err := p.PostProcess(context.Background(), testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestParseArtifactId(t *testing.T) {
+	amis, err := parseArtifactId("eu-west-1:ami-1234,us-east-1:ami-5678")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]string{"eu-west-1": "ami-1234", "us-east-1": "ami-5678"}
+	if !reflect.DeepEqual(amis, expected) {
+		t.Fatalf("bad: %#v", amis)
+	}
+
+	if _, err := parseArtifactId("ami-1234"); err == nil {
+		t.Fatal("should have error")
+	}
+}
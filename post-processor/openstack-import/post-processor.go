@@ -0,0 +1,195 @@
+package openstackimport
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/builder/chroot"
+	"github.com/hashicorp/packer/builder/openstack"
+	"github.com/hashicorp/packer/builder/qemu"
+	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/post-processor/artifice"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+const BuilderId = "packer.post-processor.openstack-import"
+
+// Disk formats of Glance for the extensions of disk image files.
+var diskFormats = map[string]string{
+	".img":   "raw",
+	".iso":   "iso",
+	".qcow2": "qcow2",
+	".raw":   "raw",
+	".vdi":   "vdi",
+	".vhd":   "vhd",
+	".vhdx":  "vhdx",
+	".vmdk":  "vmdk",
+}
+
+type Config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	openstack.AccessConfig `mapstructure:",squash"`
+	openstack.ImageConfig  `mapstructure:",squash"`
+
+	ContainerFormat string `mapstructure:"container_format"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.ContainerFormat == "" {
+		p.config.ContainerFormat = "bare"
+	}
+
+	var errs *packer.MultiError
+	errs = packer.MultiErrorAppend(errs, p.config.AccessConfig.Prepare(&p.config.ctx)...)
+	errs = packer.MultiErrorAppend(errs, p.config.ImageConfig.Prepare(&p.config.ctx)...)
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	packer.LogSecretFilter.Set(p.config.Password, p.config.Token, p.config.ApplicationCredentialSecret)
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, bool, error) {
+	switch artifact.BuilderId() {
+	case qemu.BuilderId, vboxcommon.BuilderId, chroot.BuilderId, artifice.BuilderId:
+		break
+	default:
+		err := fmt.Errorf(
+			"Unknown artifact type: %s\nCan only import from QEMU, VirtualBox, chroot and Artifice artifacts.",
+			artifact.BuilderId())
+		return nil, false, false, err
+	}
+
+	log.Println("Looking for image in artifact")
+	source, diskFormat, err := findImage(artifact.Files())
+	if err != nil {
+		return nil, false, false, err
+	}
+	if p.config.ImageDiskFormat != "" {
+		diskFormat = p.config.ImageDiskFormat
+	}
+	if diskFormat == "" {
+		return nil, false, false, fmt.Errorf(
+			"Unable to detect the disk format of %s, set image_disk_format", source)
+	}
+
+	imageClient, err := p.config.ImageV2Client()
+	if err != nil {
+		return nil, false, false, fmt.Errorf("Error initializing image client: %s", err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", ui)
+
+	steps := []multistep.Step{
+		&stepCreateImage{
+			Client:          imageClient,
+			Name:            p.config.ImageName,
+			DiskFormat:      diskFormat,
+			ContainerFormat: p.config.ContainerFormat,
+			Properties:      p.config.ImageMetadata,
+		},
+		&stepUploadImage{
+			Client: imageClient,
+			Source: source,
+		},
+		&openstack.StepUpdateImageTags{
+			AccessConfig: &p.config.AccessConfig,
+			Tags:         p.config.ImageTags,
+		},
+		&openstack.StepUpdateImageVisibility{
+			AccessConfig: &p.config.AccessConfig,
+			Visibility:   p.config.ImageVisibility,
+		},
+		&openstack.StepAddImageMembers{
+			AccessConfig: &p.config.AccessConfig,
+			Members:      p.config.ImageMembers,
+		},
+		&openstack.StepUpdateImageMinDisk{
+			AccessConfig: &p.config.AccessConfig,
+			MinDisk:      p.config.ImageMinDisk,
+		},
+	}
+
+	runner := common.NewRunnerWithPauseFn(steps, p.config.PackerConfig, ui, state)
+	runner.Run(ctx, state)
+
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, false, false, rawErr.(error)
+	}
+
+	artifact = &openstack.Artifact{
+		ImageId:        state.Get("image").(string),
+		BuilderIdValue: BuilderId,
+		Client:         imageClient,
+	}
+
+	return artifact, false, false, nil
+}
+
+// findImage returns the disk image file of the artifact and its disk format,
+// detected from the content of the file or its extension. A single file of
+// unknown format is returned with an empty format.
+func findImage(files []string) (string, string, error) {
+	for _, path := range files {
+		qcow2, err := isQcow2(path)
+		if err != nil {
+			return "", "", err
+		}
+		if qcow2 {
+			return path, "qcow2", nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if format, ok := diskFormats[ext]; ok {
+			return path, format, nil
+		}
+	}
+
+	if len(files) == 1 {
+		return files[0], "", nil
+	}
+
+	return "", "", fmt.Errorf("No disk image file found in artifact")
+}
+
+// isQcow2 reports whether the file starts with the magic of the qcow2
+// format.
+func isQcow2(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("Error opening %s: %s", path, err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := f.Read(magic); err != nil {
+		return false, nil
+	}
+
+	return string(magic) == "QFI\xfb", nil
+}
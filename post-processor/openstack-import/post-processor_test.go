@@ -0,0 +1,262 @@
+package openstackimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/builder/openstack"
+	"github.com/hashicorp/packer/builder/qemu"
+	"github.com/hashicorp/packer/packer"
+)
+
+// fakeGlance serves the token endpoint of Keystone and the images of Glance,
+// recording the requests made to the images.
+type fakeGlance struct {
+	server *httptest.Server
+
+	created map[string]interface{}
+	data    []byte
+	patches []interface{}
+	members []string
+}
+
+func newFakeGlance() *fakeGlance {
+	g := new(fakeGlance)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/auth/tokens", g.handleTokens)
+	mux.HandleFunc("/image/v2/images", g.handleImages)
+	mux.HandleFunc("/image/v2/images/image-1", g.handleImage)
+	mux.HandleFunc("/image/v2/images/image-1/file", g.handleFile)
+	mux.HandleFunc("/image/v2/images/image-1/members", g.handleMembers)
+	g.server = httptest.NewServer(mux)
+	return g
+}
+
+func (g *fakeGlance) handleTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Subject-Token", "token-1")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"token": {"catalog": [{"type": "image", "name": "glance", "endpoints": [
+		{"interface": "public", "region": "RegionOne", "url": "%s/image/"}]}]}}`, g.server.URL)
+}
+
+func (g *fakeGlance) handleImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewDecoder(r.Body).Decode(&g.created)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprint(w, `{"id": "image-1", "status": "queued"}`)
+}
+
+func (g *fakeGlance) handleImage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PATCH":
+		var patch interface{}
+		json.NewDecoder(r.Body).Decode(&patch)
+		g.patches = append(g.patches, patch)
+	case "DELETE":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"id": "image-1", "status": "active"}`)
+}
+
+func (g *fakeGlance) handleFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	g.data, _ = ioutil.ReadAll(r.Body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *fakeGlance) handleMembers(w http.ResponseWriter, r *http.Request) {
+	var member struct {
+		Member string `json:"member"`
+	}
+	json.NewDecoder(r.Body).Decode(&member)
+	g.members = append(g.members, member.Member)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"image_id": "image-1", "member_id": "%s", "status": "pending"}`, member.Member)
+}
+
+func testConfig(g *fakeGlance) map[string]interface{} {
+	return map[string]interface{}{
+		"identity_endpoint": g.server.URL + "/v3",
+		"token":             "secret",
+		"image_name":        "packer-test",
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	g := newFakeGlance()
+	defer g.server.Close()
+
+	var p PostProcessor
+	if err := p.Configure(testConfig(g)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.ContainerFormat != "bare" {
+		t.Fatalf("bad: %s", p.config.ContainerFormat)
+	}
+
+	config := testConfig(g)
+	delete(config, "image_name")
+	p = PostProcessor{}
+	if err := p.Configure(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorPostProcess(t *testing.T) {
+	g := newFakeGlance()
+	defer g.server.Close()
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "packer-qemu")
+	if err := ioutil.WriteFile(image, []byte("QFI\xfbdata"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := testConfig(g)
+	config["metadata"] = map[string]string{"os_distro": "debian"}
+	config["image_visibility"] = "shared"
+	config["image_members"] = []string{"project-1", "project-2"}
+	config["image_tags"] = []string{"packer"}
+
+	var p PostProcessor
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &packer.MockArtifact{
+		BuilderIdValue: qemu.BuilderId,
+		FilesValue:     []string{image},
+	}
+	result, keep, _, err := p.PostProcess(context.Background(), testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if keep {
+		t.Fatal("should not keep the input artifact")
+	}
+	if result.Id() != "image-1" || result.BuilderId() != BuilderId {
+		t.Fatalf("bad: %s, %s", result.Id(), result.BuilderId())
+	}
+	if _, ok := result.(*openstack.Artifact); !ok {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	expected := map[string]interface{}{
+		"name":             "packer-test",
+		"disk_format":      "qcow2",
+		"container_format": "bare",
+		"os_distro":        "debian",
+		"image_type":       "image",
+	}
+	if !reflect.DeepEqual(g.created, expected) {
+		t.Fatalf("bad: %#v", g.created)
+	}
+	if string(g.data) != "QFI\xfbdata" {
+		t.Fatalf("bad: %q", g.data)
+	}
+	if len(g.patches) != 2 {
+		t.Fatalf("bad: %#v", g.patches)
+	}
+	if !reflect.DeepEqual(g.members, []string{"project-1", "project-2"}) {
+		t.Fatalf("bad: %#v", g.members)
+	}
+}
+
+func TestPostProcessorPostProcess_BadArtifact(t *testing.T) {
+	g := newFakeGlance()
+	defer g.server.Close()
+
+	var p PostProcessor
+	if err := p.Configure(testConfig(g)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &packer.MockArtifact{
+		BuilderIdValue: "packer.file",
+	}
+	if _, _, _, err := p.PostProcess(context.Background(), testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+	if g.created != nil {
+		t.Fatalf("bad: %#v", g.created)
+	}
+}
+
+func TestFindImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"box.ovf":   "<Envelope/>",
+		"disk.vmdk": "KDMV",
+		"disk":      "data",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	path, format, err := findImage([]string{
+		filepath.Join(dir, "box.ovf"), filepath.Join(dir, "disk.vmdk")})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if path != filepath.Join(dir, "disk.vmdk") || format != "vmdk" {
+		t.Fatalf("bad: %s, %s", path, format)
+	}
+
+	path, format, err = findImage([]string{filepath.Join(dir, "disk")})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if path != filepath.Join(dir, "disk") || format != "" {
+		t.Fatalf("bad: %s, %s", path, format)
+	}
+
+	if _, _, err := findImage([]string{filepath.Join(dir, "box.ovf"), filepath.Join(dir, "disk")}); err == nil {
+		t.Fatal("should have error")
+	}
+}
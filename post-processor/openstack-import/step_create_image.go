@@ -0,0 +1,69 @@
+package openstackimport
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// stepCreateImage creates the image record in Glance, ready for the upload of
+// its data.
+type stepCreateImage struct {
+	Client          *gophercloud.ServiceClient
+	Name            string
+	DiskFormat      string
+	ContainerFormat string
+	Properties      map[string]string
+
+	imageId string
+}
+
+func (s *stepCreateImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say(fmt.Sprintf("Creating image: %s", s.Name))
+	image, err := images.Create(s.Client, images.CreateOpts{
+		Name:            s.Name,
+		DiskFormat:      s.DiskFormat,
+		ContainerFormat: s.ContainerFormat,
+		Properties:      s.Properties,
+	}).Extract()
+	if err != nil {
+		err := fmt.Errorf("Error creating image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.imageId = image.ID
+	ui.Message(fmt.Sprintf("Image: %s", s.imageId))
+	state.Put("image", s.imageId)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateImage) Cleanup(state multistep.StateBag) {
+	if s.imageId == "" {
+		return
+	}
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	ui.Say(fmt.Sprintf("Deleting image: %s", s.imageId))
+	if err := images.Delete(s.Client, s.imageId).ExtractErr(); err != nil {
+		log.Printf("Error deleting image: %s", err)
+		ui.Error(fmt.Sprintf(
+			"Error deleting image. Please delete it manually.\n\n"+
+				"Name: %s\nError: %s", s.imageId, err))
+	}
+}
@@ -0,0 +1,56 @@
+package openstackimport
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/imagedata"
+	"github.com/hashicorp/packer/builder/openstack"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// stepUploadImage uploads the disk image file as the data of the image and
+// waits for the image to become active.
+type stepUploadImage struct {
+	Client *gophercloud.ServiceClient
+	Source string
+}
+
+func (s *stepUploadImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	imageId := state.Get("image").(string)
+	ui := state.Get("ui").(packer.Ui)
+
+	f, err := os.Open(s.Source)
+	if err != nil {
+		err := fmt.Errorf("Error opening image file: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer f.Close()
+
+	ui.Say(fmt.Sprintf("Uploading %s to image %s...", s.Source, imageId))
+	if err := imagedata.Upload(s.Client, imageId, f).ExtractErr(); err != nil {
+		err := fmt.Errorf("Error uploading image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Waiting for image to become ready...")
+	if err := openstack.WaitForImage(ctx, s.Client, imageId); err != nil {
+		err := fmt.Errorf("Error waiting for image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadImage) Cleanup(multistep.StateBag) {
+	// No cleanup...
+}